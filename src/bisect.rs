@@ -0,0 +1,519 @@
+//! Automated `git bisect` for "it used to work" failures.
+//!
+//! `why bisect --good <rev> -- <cmd>` bisects between a known good revision
+//! and a bad one (HEAD by default), running the command at each step and
+//! classifying its exit code the way `--capture` does. The output at the
+//! first bad commit is then explained together with that commit's diff,
+//! trimmed to the hunks the output points at.
+//!
+//! Steps are marked with `git bisect good|bad|skip` directly instead of
+//! through `git bisect run`, which aborts on exit codes above 127: a test
+//! killed by SIGSEGV is a bad commit, not a reason to stop. Only local
+//! revisions are used, the worktree must be clean, and the bisect is always
+//! reset, also on errors and Ctrl-C.
+
+use anyhow::{bail, Context, Result};
+use colored::Colorize;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::Arc;
+
+use crate::config::Config;
+use crate::git_context::git;
+use crate::output::{interpret_exit_code, shell_exit_code};
+
+/// Upper bound on bisect steps (2^64 commits is plenty)
+const MAX_STEPS: usize = 64;
+
+/// Longest diff included in the prompt
+const MAX_DIFF_CHARS: usize = 3000;
+
+/// Exit code `git bisect run` uses for "can't test this commit"
+const SKIP_EXIT_CODE: i32 = 125;
+
+/// Lockfiles and other generated files left out of the diff unless named
+const GENERATED_FILES: &[&str] = &[
+    "Cargo.lock",
+    "package-lock.json",
+    "yarn.lock",
+    "pnpm-lock.yaml",
+    "bun.lockb",
+    "go.sum",
+    "poetry.lock",
+    "uv.lock",
+    "Gemfile.lock",
+    "composer.lock",
+];
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// What a test run says about a commit
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Verdict {
+    Good,
+    Bad,
+    Skip,
+}
+
+impl Verdict {
+    /// Classify an exit code like `--capture` does
+    ///
+    /// Exit codes the config skips (0 and 130 by default) are good, except
+    /// 130 itself, which means the run was interrupted and returns `None`.
+    /// 125 skips the commit, as with `git bisect run`. Anything else is bad.
+    pub fn from_exit_code(code: i32, config: &Config) -> Option<Self> {
+        match code {
+            130 => None,
+            SKIP_EXIT_CODE => Some(Verdict::Skip),
+            code if config.should_skip_exit_code(code) => Some(Verdict::Good),
+            _ => Some(Verdict::Bad),
+        }
+    }
+
+    fn as_arg(self) -> &'static str {
+        match self {
+            Verdict::Good => "good",
+            Verdict::Bad => "bad",
+            Verdict::Skip => "skip",
+        }
+    }
+}
+
+/// A finished bisect
+#[derive(Debug, Clone)]
+pub struct BisectResult {
+    /// Test command as typed
+    pub command: String,
+    /// Good revision as given
+    pub good: String,
+    /// Bad revision as given
+    pub bad: String,
+    /// First bad commit (full hash)
+    pub commit: String,
+    /// "1a2b3c4 Ann Lee: Switch to the new config parser"
+    pub commit_summary: String,
+    /// Commits tested
+    pub steps: usize,
+    /// Exit code of the command at the first bad commit
+    pub exit_code: i32,
+    /// Combined stdout and stderr at the first bad commit
+    pub output: String,
+    /// Diff of the first bad commit, trimmed to relevant hunks
+    pub diff: String,
+}
+
+impl BisectResult {
+    /// Build the input to explain
+    pub fn to_input(&self) -> String {
+        let mut input = format!(
+            "Command: {}\n\
+             First bad commit: {} (found by git bisect in {} steps between {} and {})\n\
+             Exit code: {} ({})\n\nOutput:\n{}",
+            self.command,
+            self.commit_summary,
+            self.steps,
+            self.good,
+            self.bad,
+            self.exit_code,
+            interpret_exit_code(self.exit_code),
+            self.output.trim()
+        );
+        if !self.diff.trim().is_empty() {
+            input.push_str(&format!(
+                "\n\nChanges in the first bad commit:\n{}",
+                self.diff.trim_end()
+            ));
+        }
+        input
+    }
+}
+
+// ============================================================================
+// Running
+// ============================================================================
+
+/// Resets the bisect when dropped, so every exit path leaves the repo as it was
+struct BisectGuard<'a> {
+    root: &'a Path,
+}
+
+impl Drop for BisectGuard<'_> {
+    fn drop(&mut self) {
+        git(self.root, &["bisect", "reset"]).ok();
+    }
+}
+
+/// Bisect `command` between `good` and `bad` in the repository containing `dir`
+pub fn run(
+    command: &[String],
+    good: &str,
+    bad: &str,
+    dir: &Path,
+    config: &Config,
+) -> Result<BisectResult> {
+    if command.is_empty() {
+        bail!("No command specified. Use: why bisect --good <rev> -- <command>");
+    }
+
+    let root = PathBuf::from(
+        git(dir, &["rev-parse", "--show-toplevel"])
+            .context("why bisect must be run inside a git repository")?
+            .trim(),
+    );
+    let bisect_start = git(dir, &["rev-parse", "--git-path", "BISECT_START"])?;
+    if dir.join(bisect_start.trim()).exists() {
+        bail!("A bisect is already in progress; finish it with `git bisect reset` first");
+    }
+    let dirty = git(dir, &["status", "--porcelain", "--untracked-files=no"])?;
+    if !dirty.trim().is_empty() {
+        bail!("The worktree has uncommitted changes; commit or stash them before bisecting");
+    }
+    let good_sha = resolve(dir, good)?;
+    let bad_sha = resolve(dir, bad)?;
+    if good_sha == bad_sha {
+        bail!("--good and --bad are the same commit");
+    }
+    if git(dir, &["merge-base", "--is-ancestor", &good_sha, &bad_sha]).is_err() {
+        bail!("{} is not an ancestor of {}", good, bad);
+    }
+
+    let interrupted = Arc::new(AtomicBool::new(false));
+    let flag = Arc::clone(&interrupted);
+    // The test command gets the SIGINT too; stop after it exits
+    ctrlc::set_handler(move || flag.store(true, Ordering::SeqCst)).ok();
+
+    let _guard = BisectGuard { root: &root };
+    git(dir, &["bisect", "start", &bad_sha, &good_sha, "--"])?;
+
+    let command_str = command.join(" ");
+    let mut outputs: HashMap<String, (i32, String)> = HashMap::new();
+    let mut steps = 0;
+    let first_bad = loop {
+        if steps >= MAX_STEPS {
+            bail!("git bisect didn't converge after {} steps", MAX_STEPS);
+        }
+        let head = git(dir, &["rev-parse", "HEAD"])?.trim().to_string();
+        eprintln!(
+            "{}",
+            format!("Testing {}", short_summary(dir, &head)).dimmed()
+        );
+        let (exit_code, output) = run_test(command, dir)?;
+        steps += 1;
+
+        let verdict = Verdict::from_exit_code(exit_code, config)
+            .filter(|_| !interrupted.load(Ordering::SeqCst));
+        let Some(verdict) = verdict else {
+            bail!("Bisect interrupted; the repository has been reset");
+        };
+        if verdict == Verdict::Bad {
+            outputs.insert(head.clone(), (exit_code, output));
+        }
+
+        let step = git(dir, &["bisect", verdict.as_arg()])?;
+        if let Some(sha) = first_bad_commit(&step) {
+            break sha;
+        }
+        if step.contains("only 'skip'ped commits left") {
+            bail!(
+                "Every remaining commit was skipped (exit {}), so the first bad commit \
+                 can't be determined",
+                SKIP_EXIT_CODE
+            );
+        }
+    };
+
+    // git can name the bad end without testing it; run it there to get output
+    let (exit_code, output) = match outputs.remove(&first_bad) {
+        Some(result) => result,
+        None => {
+            git(dir, &["checkout", "--quiet", &first_bad])?;
+            eprintln!(
+                "{}",
+                format!("Testing {}", short_summary(dir, &first_bad)).dimmed()
+            );
+            let (exit_code, output) = run_test(command, dir)?;
+            steps += 1;
+            if Verdict::from_exit_code(exit_code, config) != Some(Verdict::Bad) {
+                bail!(
+                    "`{}` doesn't fail at {}; nothing to bisect (or the failure is flaky)",
+                    command_str,
+                    bad
+                );
+            }
+            (exit_code, output)
+        }
+    };
+
+    let diff = git(
+        dir,
+        &[
+            "show",
+            "--format=",
+            "--no-color",
+            "--no-ext-diff",
+            &first_bad,
+        ],
+    )
+    .unwrap_or_default();
+
+    Ok(BisectResult {
+        command: command_str,
+        good: good.to_string(),
+        bad: bad.to_string(),
+        commit_summary: short_summary(dir, &first_bad),
+        commit: first_bad,
+        steps,
+        exit_code,
+        diff: relevant_hunks(&diff, &output, MAX_DIFF_CHARS),
+        output,
+    })
+}
+
+/// Run the test command, returning its exit code and combined output
+fn run_test(command: &[String], dir: &Path) -> Result<(i32, String)> {
+    let output = Command::new(&command[0])
+        .args(&command[1..])
+        .current_dir(dir)
+        .stdin(Stdio::null())
+        .output()
+        .with_context(|| format!("Failed to run command: {}", command.join(" ")))?;
+
+    let mut text = String::from_utf8_lossy(&output.stdout).to_string();
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    if !stderr.trim().is_empty() {
+        if !text.is_empty() && !text.ends_with('\n') {
+            text.push('\n');
+        }
+        text.push_str(&stderr);
+    }
+    Ok((shell_exit_code(&output.status), text))
+}
+
+/// Resolve a local revision to a commit hash
+fn resolve(dir: &Path, rev: &str) -> Result<String> {
+    let spec = format!("{}^{{commit}}", rev);
+    git(dir, &["rev-parse", "--verify", "--quiet", &spec])
+        .map(|sha| sha.trim().to_string())
+        .with_context(|| format!("Unknown revision '{}' (only local revisions are used)", rev))
+}
+
+fn short_summary(dir: &Path, sha: &str) -> String {
+    git(dir, &["show", "-s", "--format=%h %an: %s", sha])
+        .map(|s| s.trim().to_string())
+        .unwrap_or_else(|_| sha.chars().take(7).collect())
+}
+
+/// Hash from "<sha> is the first bad commit"
+fn first_bad_commit(step_output: &str) -> Option<String> {
+    step_output.lines().find_map(|line| {
+        line.strip_suffix(" is the first bad commit")
+            .map(|sha| sha.trim().to_string())
+    })
+}
+
+// ============================================================================
+// Diff Trimming
+// ============================================================================
+
+/// Keep the hunks of `diff` that the failing `output` points at
+///
+/// Files whose name appears in the output come first. Lockfiles are left out
+/// unless named. When nothing is named, hunks are kept in order until the
+/// budget runs out.
+pub fn relevant_hunks(diff: &str, output: &str, max_chars: usize) -> String {
+    let files = split_files(diff);
+    let named = |path: &str| {
+        let name = path.rsplit('/').next().unwrap_or(path);
+        output.contains(path) || output.contains(name)
+    };
+
+    let (mut ordered, rest): (Vec<_>, Vec<_>) = files.iter().partition(|(path, _)| named(path));
+    let mut left_out = 0;
+    for file in rest {
+        let name = file.0.rsplit('/').next().unwrap_or(&file.0);
+        if GENERATED_FILES.contains(&name) {
+            left_out += split_hunks(&file.1).len();
+        } else {
+            ordered.push(file);
+        }
+    }
+
+    let mut out = String::new();
+    for (_, section) in ordered {
+        for hunk in split_hunks(section) {
+            if out.len() + hunk.len() > max_chars {
+                left_out += 1;
+                continue;
+            }
+            out.push_str(&hunk);
+        }
+    }
+    if left_out > 0 {
+        out.push_str(&format!("... ({} more hunks left out)\n", left_out));
+    }
+    out
+}
+
+/// Split a diff into (path, section) per file
+fn split_files(diff: &str) -> Vec<(String, String)> {
+    let mut files: Vec<(String, String)> = Vec::new();
+    for line in diff.lines() {
+        if let Some(rest) = line.strip_prefix("diff --git a/") {
+            let path = rest.split(" b/").next().unwrap_or(rest).to_string();
+            files.push((path, String::new()));
+        }
+        if let Some((_, section)) = files.last_mut() {
+            section.push_str(line);
+            section.push('\n');
+        }
+    }
+    files
+}
+
+/// Split a file's section into hunks, each prefixed with the file header
+fn split_hunks(section: &str) -> Vec<String> {
+    let mut header = String::new();
+    let mut hunks: Vec<String> = Vec::new();
+    for line in section.lines() {
+        if line.starts_with("@@") {
+            hunks.push(String::new());
+        }
+        match hunks.last_mut() {
+            Some(hunk) => {
+                hunk.push_str(line);
+                hunk.push('\n');
+            }
+            None if line.starts_with("---") || line.starts_with("+++") => {
+                header.push_str(line);
+                header.push('\n');
+            }
+            None => {}
+        }
+    }
+    if hunks.is_empty() {
+        // Binary files, renames and mode changes have no hunks
+        return vec![section.to_string()];
+    }
+    hunks[0].insert_str(0, &header);
+    hunks
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::git_context::TestRepo;
+
+    #[test]
+    fn test_verdict_follows_capture_exit_codes() {
+        let config = Config::default();
+        assert_eq!(Verdict::from_exit_code(0, &config), Some(Verdict::Good));
+        assert_eq!(Verdict::from_exit_code(1, &config), Some(Verdict::Bad));
+        assert_eq!(Verdict::from_exit_code(139, &config), Some(Verdict::Bad));
+        assert_eq!(Verdict::from_exit_code(125, &config), Some(Verdict::Skip));
+        assert_eq!(Verdict::from_exit_code(130, &config), None);
+
+        let mut config = Config::default();
+        config.hook.skip_exit_codes.push(2);
+        assert_eq!(Verdict::from_exit_code(2, &config), Some(Verdict::Good));
+    }
+
+    #[test]
+    fn test_relevant_hunks_prefer_files_in_output() {
+        let diff = "diff --git a/Cargo.lock b/Cargo.lock
+index 1..2 100644
+--- a/Cargo.lock
++++ b/Cargo.lock
+@@ -10,3 +10,3 @@
+-version = \"1.0.1\"
++version = \"1.0.2\"
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old
++new
+diff --git a/src/config.rs b/src/config.rs
+--- a/src/config.rs
++++ b/src/config.rs
+@@ -40,2 +40,2 @@
+-    let port: u32 = raw.parse()?;
++    let port: u16 = raw.parse()?;
+";
+        let output = "thread 'main' panicked at src/config.rs:41:10:\nnumber too large";
+
+        let trimmed = relevant_hunks(diff, output, 1000);
+        assert!(trimmed.starts_with("--- a/src/config.rs\n+++ b/src/config.rs\n@@ -40,2"));
+        assert!(trimmed.contains("+new"));
+        assert!(!trimmed.contains("Cargo.lock"));
+        assert!(trimmed.ends_with("... (1 more hunks left out)\n"));
+
+        let tight = relevant_hunks(diff, output, 150);
+        assert!(tight.contains("let port: u16"));
+        assert!(!tight.contains("+new"));
+    }
+
+    #[test]
+    fn test_first_bad_commit() {
+        let step =
+            "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432 is the first bad commit\ncommit 9f8e7d6\n";
+        assert_eq!(
+            first_bad_commit(step).as_deref(),
+            Some("9f8e7d6c5b4a39281706f5e4d3c2b1a098765432")
+        );
+        assert_eq!(
+            first_bad_commit("Bisecting: 3 revisions left to test"),
+            None
+        );
+    }
+
+    #[test]
+    fn test_bisect_finds_breaking_commit() {
+        let Some(repo) = TestRepo::init("why-bisect") else {
+            // git isn't installed
+            return;
+        };
+        let dir = &repo.dir;
+        for (i, value) in ["pass", "pass", "pass", "fail", "fail"].iter().enumerate() {
+            std::fs::write(dir.join("state"), value).unwrap();
+            std::fs::write(dir.join(format!("file{}", i)), "x").unwrap();
+            assert!(repo.git(&["add", "-A"]));
+            assert!(repo.git(&["commit", "-q", "-m", &format!("Commit {}", i)]));
+            if i == 0 {
+                assert!(repo.git(&["tag", "v1"]));
+            }
+        }
+        let command: Vec<String> = [
+            "sh",
+            "-c",
+            "grep -qx pass state || { echo state is broken >&2; exit 3; }",
+        ]
+        .iter()
+        .map(|s| s.to_string())
+        .collect();
+
+        let result = run(&command, "v1", "HEAD", dir, &Config::default());
+        let still_bisecting = dir.join(".git").join("BISECT_START").exists();
+        std::fs::write(dir.join("state"), "dirty").unwrap();
+        let dirty = run(&command, "v1", "HEAD", dir, &Config::default());
+
+        let result = result.unwrap();
+        assert!(result.commit_summary.ends_with("Ann Lee: Commit 3"));
+        assert_eq!(result.exit_code, 3);
+        assert_eq!(result.output.trim(), "state is broken");
+        assert!(result.diff.contains("+fail"));
+        assert!(result.to_input().contains("First bad commit: "));
+        assert!(!still_bisecting);
+        assert!(dirty
+            .unwrap_err()
+            .to_string()
+            .contains("uncommitted changes"));
+    }
+}
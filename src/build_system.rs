@@ -0,0 +1,744 @@
+//! Make, Ninja, CMake and Bazel failure unwinding.
+//!
+//! Build systems report failures from the outside in: `make: *** [Makefile:42:
+//! build] Error 2` is the last line printed, but the compiler or script error
+//! that caused it is further up, surrounded by recursive-make directory
+//! noise. This module walks the failure chain back to the innermost failing
+//! target and isolates the block of output that belongs to it.
+
+use regex::Regex;
+use serde::Serialize;
+use std::fmt;
+use std::path::Path;
+use std::sync::OnceLock;
+
+use crate::output::interpret_exit_code;
+use crate::stack_trace::{strip_ansi, StackFrame, StackTrace};
+
+/// Maximum number of lines kept in the isolated block
+const MAX_BLOCK_LINES: usize = 40;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Build system that produced the output
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum BuildTool {
+    Make,
+    Ninja,
+    CMake,
+    Bazel,
+}
+
+impl fmt::Display for BuildTool {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            BuildTool::Make => write!(f, "make"),
+            BuildTool::Ninja => write!(f, "ninja"),
+            BuildTool::CMake => write!(f, "cmake"),
+            BuildTool::Bazel => write!(f, "bazel"),
+        }
+    }
+}
+
+/// One failing target in the chain
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct FailedTarget {
+    /// Target or rule name (e.g. "build", "foo.o", "//src:main")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub target: Option<String>,
+    /// Makefile, CMakeLists.txt or BUILD file that defines it
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<String>,
+    /// Line in that file
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<u32>,
+    /// Column in that file (Bazel only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub column: Option<u32>,
+    /// Directory a recursive make was running in
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub directory: Option<String>,
+    /// Exit code reported for the target's recipe
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exit_code: Option<i32>,
+    /// The build system's own message (e.g. "Error 2", "missing separator")
+    pub message: String,
+}
+
+impl FailedTarget {
+    /// Path of the defining file, resolved against the make directory
+    pub fn path(&self) -> Option<String> {
+        let file = self.file.as_ref()?;
+        Some(match &self.directory {
+            Some(dir) if Path::new(file).is_relative() => {
+                Path::new(dir).join(file).display().to_string()
+            }
+            _ => file.clone(),
+        })
+    }
+
+    /// "foo.o (src/Makefile:10)"
+    fn describe(&self) -> String {
+        let name = self.target.as_deref().unwrap_or("(no target)");
+        match (self.path(), self.line) {
+            (Some(path), Some(line)) => format!("{} ({}:{})", name, path, line),
+            (Some(path), None) => format!("{} ({})", name, path),
+            _ => name.to_string(),
+        }
+    }
+
+    fn to_frame(&self) -> Option<StackFrame> {
+        let mut frame = StackFrame::new().with_file(self.path()?);
+        frame.line = self.line;
+        frame.column = self.column;
+        frame.function = self.target.clone();
+        Some(frame)
+    }
+}
+
+/// A parsed build system failure
+#[derive(Debug, Clone, Serialize)]
+pub struct BuildFailure {
+    /// Build system that reported the failure
+    pub tool: BuildTool,
+    /// Failing targets, innermost first
+    pub chain: Vec<FailedTarget>,
+    /// The command that failed, when the build system printed it
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub command: Option<String>,
+    /// Output that belongs to the innermost failure
+    pub block: Vec<String>,
+    /// Language-level parse of the isolated block
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub nested: Option<Box<StackTrace>>,
+}
+
+impl BuildFailure {
+    /// The innermost failing target
+    pub fn innermost(&self) -> Option<&FailedTarget> {
+        self.chain.first()
+    }
+
+    /// Frames for every failing target that has a file reference
+    pub fn frames(&self) -> Vec<StackFrame> {
+        self.chain
+            .iter()
+            .filter_map(FailedTarget::to_frame)
+            .collect()
+    }
+
+    /// One-line description of the innermost failure
+    pub fn summary(&self) -> String {
+        let Some(target) = self.innermost() else {
+            return format!("{} failed", self.tool);
+        };
+        let mut summary = format!("{}: {}", self.tool, target.describe());
+        match target.exit_code {
+            Some(code) => summary.push_str(&format!(" failed with exit code {}", code)),
+            None => summary.push_str(&format!(": {}", target.message)),
+        }
+        summary
+    }
+
+    /// The isolated block, used instead of the whole log when building the
+    /// prompt
+    pub fn focused_input(&self) -> String {
+        let mut out = vec![format!("Build failed: {}", self.summary())];
+        if let Some(command) = &self.command {
+            out.push(format!("Failing command: {}", command));
+        }
+        if !self.block.is_empty() {
+            out.push("Output:".to_string());
+            out.push(self.block.join("\n"));
+        }
+        out.join("\n")
+    }
+
+    /// Render the interpretation block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = format!("Build failure ({}):\n", self.tool);
+        out.push_str(&format!("- innermost failure: {}\n", self.summary()));
+        if self.chain.len() > 1 {
+            let chain: Vec<String> = self.chain.iter().map(FailedTarget::describe).collect();
+            out.push_str(&format!(
+                "- failure chain (innermost first): {}\n",
+                chain.join(" <- ")
+            ));
+        }
+        if let Some(command) = &self.command {
+            out.push_str(&format!("- failing command: {}\n", command));
+        }
+        if let Some(code) = self.innermost().and_then(|t| t.exit_code) {
+            out.push_str(&format!(
+                "- exit code {}: {}\n",
+                code,
+                interpret_exit_code(code)
+            ));
+        }
+        if let Some(hint) = self.innermost().and_then(|t| message_hint(&t.message)) {
+            out.push_str(&format!("- {}\n", hint));
+        }
+        if let Some(nested) = &self.nested {
+            out.push_str(&format!(
+                "- the output contains a {} error: {}: {}\n",
+                nested.language, nested.error_type, nested.error_message
+            ));
+            if let Some(context) = nested.prompt_context() {
+                out.push_str(&context);
+                out.push('\n');
+            }
+        }
+        out.trim_end().to_string()
+    }
+}
+
+/// Explain build system messages that are not about the recipe's exit code
+fn message_hint(message: &str) -> Option<&'static str> {
+    Some(if message.contains("missing separator") {
+        "recipe lines in a Makefile must start with a tab character, not spaces"
+    } else if message.starts_with("No rule to make target") {
+        "a prerequisite is neither an existing file nor a target with a rule; check the file name and the rule that needs it"
+    } else if message.starts_with("Interrupt") {
+        "the build was interrupted (Ctrl-C or SIGINT)"
+    } else if message.contains("Killed") {
+        "the recipe was killed, often by the OOM killer during a large compile or link"
+    } else if message.contains("Could not find a package configuration file") {
+        "CMake could not find the package; install its development files or set <Package>_DIR or CMAKE_PREFIX_PATH"
+    } else {
+        return None;
+    })
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    make_error: Regex,
+    make_stop: Regex,
+    makefile_stop: Regex,
+    make_directory: Regex,
+    bracket_location: Regex,
+    exit_code: Regex,
+    progress: Regex,
+    ninja_failed: Regex,
+    cmake_error: Regex,
+    bazel_error: Regex,
+    bazel_target: Regex,
+    bazel_exit: Regex,
+    errorish: Regex,
+    command: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        make_error: Regex::new(r"^(?:\S*/)?g?make(?:\[(\d+)\])?: \*\*\* \[(.+?)\] (.+)$").unwrap(),
+        make_stop: Regex::new(r"^(?:\S*/)?g?make(?:\[(\d+)\])?: \*\*\* (.+?)\.\s+Stop\.$").unwrap(),
+        makefile_stop: Regex::new(r"^(\S*(?:[Mm]akefile|\.mk)\S*):(\d+): \*\*\* (.+?)\.\s+Stop\.$")
+            .unwrap(),
+        make_directory: Regex::new(r"^(?:\S*/)?g?make(?:\[(\d+)\])?: (Entering|Leaving) directory ['`](.+)'$")
+            .unwrap(),
+        bracket_location: Regex::new(r"^(.+?):(\d+): (.+)$").unwrap(),
+        exit_code: Regex::new(r"^Error (\d+)").unwrap(),
+        progress: Regex::new(r"^\[\s*(?:\d+%|\d+/\d+)\]").unwrap(),
+        ninja_failed: Regex::new(r"^FAILED: (.+)$").unwrap(),
+        cmake_error: Regex::new(r"^CMake Error at (.+?):(\d+) \((\w+)\):").unwrap(),
+        bazel_error: Regex::new(r"^ERROR: (\S*(?:BUILD|BUILD\.bazel|WORKSPACE|MODULE\.bazel|\.bzl)):(\d+):(\d+): (.+)$")
+            .unwrap(),
+        bazel_target: Regex::new(r"\(from target (\S+)\)\s*(.*)$").unwrap(),
+        bazel_exit: Regex::new(r"\(Exit (\d+)\)").unwrap(),
+        errorish: Regex::new(
+            r"(?i)\berror\b|undefined reference|fatal|traceback|exception|panicked|no such file|not found",
+        )
+        .unwrap(),
+        command: Regex::new(
+            r"^(?:/\S+/)?(?:cc|c\+\+|gcc|g\+\+|clang|clang\+\+|ld|ar|as|nasm|rustc|cargo|go|javac|python\d?(?:\.\d+)?|node|npm|npx|tsc|sh|bash|install|cp|mkdir|protoc|\S+-gcc|\S+-g\+\+)\s",
+        )
+        .unwrap(),
+    })
+}
+
+/// Check whether input contains a make, ninja, CMake or Bazel failure
+pub fn is_build_failure(input: &str) -> bool {
+    let p = patterns();
+    input.lines().any(|line| {
+        let line = line.trim_end();
+        p.make_error.is_match(line)
+            || p.make_stop.is_match(line)
+            || p.makefile_stop.is_match(line)
+            || p.cmake_error.is_match(line)
+            || p.bazel_error.is_match(line)
+            || line.starts_with("ninja: build stopped")
+            || line.starts_with("FAILED: Build did NOT complete successfully")
+    })
+}
+
+/// Walk the failure chain in build output
+pub fn parse_build_failure(input: &str) -> Option<BuildFailure> {
+    let input = strip_ansi(input);
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+    let uses_cmake = input.contains("CMakeFiles/") || input.contains("CMake Error");
+
+    let mut failure = parse_bazel(&lines)
+        .or_else(|| parse_cmake_configure(&lines))
+        .or_else(|| parse_ninja(&lines))
+        .or_else(|| parse_make(&lines))?;
+    if uses_cmake && failure.tool != BuildTool::Bazel {
+        failure.tool = BuildTool::CMake;
+    }
+    Some(failure)
+}
+
+fn parse_make(lines: &[&str]) -> Option<BuildFailure> {
+    let p = patterns();
+    // make level -> directory it entered
+    let mut directories: Vec<(u32, String)> = Vec::new();
+    let mut chain = Vec::new();
+    let mut first_error = None;
+
+    for (index, line) in lines.iter().enumerate() {
+        if let Some(caps) = p.make_directory.captures(line) {
+            let level = caps
+                .get(1)
+                .and_then(|m| m.as_str().parse().ok())
+                .unwrap_or(0);
+            directories.retain(|(l, _)| *l != level);
+            if &caps[2] == "Entering" {
+                directories.push((level, caps[3].to_string()));
+            }
+            continue;
+        }
+
+        let directory_for = |level: Option<u32>| {
+            let level = level.unwrap_or(0);
+            directories
+                .iter()
+                .find(|(l, _)| *l == level)
+                .map(|(_, d)| d.clone())
+        };
+
+        if let Some(caps) = p.make_error.captures(line) {
+            let level = caps.get(1).and_then(|m| m.as_str().parse().ok());
+            let mut target = FailedTarget {
+                directory: directory_for(level),
+                message: caps[3].to_string(),
+                exit_code: p
+                    .exit_code
+                    .captures(&caps[3])
+                    .and_then(|c| c[1].parse().ok()),
+                ..Default::default()
+            };
+            match p.bracket_location.captures(&caps[2]) {
+                Some(location) => {
+                    target.file = Some(location[1].to_string());
+                    target.line = location[2].parse().ok();
+                    target.target = Some(location[3].to_string());
+                }
+                None => target.target = Some(caps[2].to_string()),
+            }
+            chain.push(target);
+            first_error.get_or_insert(index);
+        } else if let Some(caps) = p.makefile_stop.captures(line) {
+            chain.push(FailedTarget {
+                file: Some(caps[1].to_string()),
+                line: caps[2].parse().ok(),
+                message: caps[3].to_string(),
+                ..Default::default()
+            });
+            first_error.get_or_insert(index);
+        } else if let Some(caps) = p.make_stop.captures(line) {
+            let level = caps.get(1).and_then(|m| m.as_str().parse().ok());
+            let message = caps[2].to_string();
+            // "No rule to make target 'x', needed by 'y'"
+            let target = message
+                .split('\'')
+                .nth(1)
+                .or_else(|| message.split('`').nth(1))
+                .map(str::to_string);
+            chain.push(FailedTarget {
+                target,
+                directory: directory_for(level),
+                message,
+                ..Default::default()
+            });
+            first_error.get_or_insert(index);
+        }
+    }
+
+    let first_error = first_error?;
+    let block = isolate_block(lines, first_error);
+    let command = block.iter().find(|l| p.command.is_match(l)).cloned();
+    Some(BuildFailure {
+        tool: BuildTool::Make,
+        chain,
+        command,
+        block,
+        nested: None,
+    })
+}
+
+/// Collect the output that belongs to the failure reported at `end`
+///
+/// Walks back to the previous progress line, directory change or make error,
+/// then trims the block to start near the first error-looking line.
+fn isolate_block(lines: &[&str], end: usize) -> Vec<String> {
+    let p = patterns();
+    let mut start = end;
+    while start > 0 {
+        let line = lines[start - 1];
+        if p.make_error.is_match(line) || p.make_stop.is_match(line) {
+            break;
+        }
+        if p.progress.is_match(line.trim_start()) {
+            // The progress line says what was being built; keep it
+            start -= 1;
+            break;
+        }
+        if p.make_directory.is_match(line) {
+            break;
+        }
+        start -= 1;
+    }
+
+    let mut block: Vec<String> = lines[start..end]
+        .iter()
+        .filter(|l| !p.make_directory.is_match(l) && !l.trim().is_empty())
+        .map(|l| l.to_string())
+        .collect();
+
+    if block.len() > MAX_BLOCK_LINES {
+        let first = block
+            .iter()
+            .position(|l| p.errorish.is_match(l))
+            .unwrap_or(block.len() - MAX_BLOCK_LINES);
+        // Keep the line before the first error; it is usually the command
+        block.drain(..first.saturating_sub(1));
+        block.truncate(MAX_BLOCK_LINES);
+    }
+
+    // The make line itself is already in the chain; echoing it here would
+    // make the block look like a build failure again to the nested parse
+    block
+}
+
+fn parse_ninja(lines: &[&str]) -> Option<BuildFailure> {
+    let p = patterns();
+    if !lines.iter().any(|l| l.starts_with("ninja: build stopped")) {
+        return None;
+    }
+
+    let mut chain = Vec::new();
+    let mut command = None;
+    let mut block = Vec::new();
+    for (index, line) in lines.iter().enumerate() {
+        let Some(caps) = p.ninja_failed.captures(line) else {
+            continue;
+        };
+        chain.push(FailedTarget {
+            target: Some(caps[1].to_string()),
+            message: "subcommand failed".to_string(),
+            ..Default::default()
+        });
+        if command.is_some() {
+            continue;
+        }
+        command = lines.get(index + 1).map(|l| l.to_string());
+        block = lines[index + 1..]
+            .iter()
+            .take_while(|l| {
+                !p.progress.is_match(l) && !l.starts_with("ninja: ") && !p.ninja_failed.is_match(l)
+            })
+            .take(MAX_BLOCK_LINES)
+            .map(|l| l.to_string())
+            .collect();
+    }
+
+    if chain.is_empty() {
+        chain.push(FailedTarget {
+            message: "build stopped: subcommand failed".to_string(),
+            ..Default::default()
+        });
+    }
+    Some(BuildFailure {
+        tool: BuildTool::Ninja,
+        chain,
+        command,
+        block,
+        nested: None,
+    })
+}
+
+fn parse_cmake_configure(lines: &[&str]) -> Option<BuildFailure> {
+    let p = patterns();
+    let (index, caps) = lines
+        .iter()
+        .enumerate()
+        .find_map(|(i, l)| p.cmake_error.captures(l).map(|c| (i, c)))?;
+
+    // The message is indented below the header and ends at a blank line
+    // followed by unindented text
+    let message: Vec<String> = lines[index + 1..]
+        .iter()
+        .take_while(|l| l.is_empty() || l.starts_with(' '))
+        .map(|l| l.trim().to_string())
+        .filter(|l| !l.is_empty())
+        .take(MAX_BLOCK_LINES)
+        .collect();
+
+    Some(BuildFailure {
+        tool: BuildTool::CMake,
+        chain: vec![FailedTarget {
+            target: Some(caps[3].to_string()),
+            file: Some(caps[1].to_string()),
+            line: caps[2].parse().ok(),
+            message: message.join(" "),
+            ..Default::default()
+        }],
+        command: None,
+        // The header is already in the chain, so the block is just the message
+        block: message,
+        nested: None,
+    })
+}
+
+fn parse_bazel(lines: &[&str]) -> Option<BuildFailure> {
+    let p = patterns();
+    let (index, caps) = lines
+        .iter()
+        .enumerate()
+        .find_map(|(i, l)| p.bazel_error.captures(l).map(|c| (i, c)))?;
+
+    let message = caps[4].to_string();
+    let (target, command) = match p.bazel_target.captures(&message) {
+        Some(t) => (
+            Some(t[1].to_string()),
+            Some(t[2].to_string()).filter(|c| !c.is_empty()),
+        ),
+        None => (None, None),
+    };
+    let description = message
+        .split(": (Exit")
+        .next()
+        .unwrap_or(&message)
+        .to_string();
+
+    let block = lines[index + 1..]
+        .iter()
+        .take_while(|l| {
+            !l.starts_with("Target ")
+                && !l.starts_with("INFO:")
+                && !l.starts_with("ERROR:")
+                && !l.starts_with("FAILED:")
+                && !l.starts_with("Use --verbose_failures")
+        })
+        .take(MAX_BLOCK_LINES)
+        .map(|l| l.to_string())
+        .collect();
+
+    Some(BuildFailure {
+        tool: BuildTool::Bazel,
+        chain: vec![FailedTarget {
+            target,
+            file: Some(caps[1].to_string()),
+            line: caps[2].parse().ok(),
+            column: caps[3].parse().ok(),
+            exit_code: p
+                .bazel_exit
+                .captures(&message)
+                .and_then(|c| c[1].parse().ok()),
+            message: description,
+            ..Default::default()
+        }],
+        command,
+        block,
+        nested: None,
+    })
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_unwinds_recursive_make() {
+        let input = "\
+make -C src all
+make[1]: Entering directory '/home/dev/proj/src'
+gcc -Wall -c parser.c -o parser.o
+parser.c: In function 'parse':
+parser.c:42:5: error: 'tokens' undeclared (first use in this function)
+   42 |     tokens[0] = 0;
+      |     ^~~~~~
+make[1]: *** [Makefile:10: parser.o] Error 1
+make[1]: Leaving directory '/home/dev/proj/src'
+make: *** [Makefile:4: all] Error 2
+";
+
+        assert!(is_build_failure(input));
+        let failure = parse_build_failure(input).unwrap();
+        assert_eq!(failure.tool, BuildTool::Make);
+        assert_eq!(failure.chain.len(), 2);
+
+        let inner = failure.innermost().unwrap();
+        assert_eq!(inner.target.as_deref(), Some("parser.o"));
+        assert_eq!(inner.path().as_deref(), Some("/home/dev/proj/src/Makefile"));
+        assert_eq!(inner.line, Some(10));
+        assert_eq!(inner.exit_code, Some(1));
+        assert_eq!(failure.chain[1].path().as_deref(), Some("Makefile"));
+        assert_eq!(failure.chain[1].exit_code, Some(2));
+
+        assert_eq!(
+            failure.command.as_deref(),
+            Some("gcc -Wall -c parser.c -o parser.o")
+        );
+        assert_eq!(failure.block.len(), 5);
+        assert!(!failure
+            .block
+            .iter()
+            .any(|l| l.contains("Entering directory")));
+
+        let summary = failure.prompt_summary();
+        assert!(summary.contains(
+            "- innermost failure: make: parser.o (/home/dev/proj/src/Makefile:10) failed with exit code 1"
+        ));
+        assert!(summary.contains("<- all (Makefile:4)"));
+    }
+
+    #[test]
+    fn test_cmake_make_block_starts_at_progress_line() {
+        let input = "\
+[ 25%] Building C object CMakeFiles/app.dir/util.c.o
+[ 50%] Building C object CMakeFiles/app.dir/main.c.o
+/src/main.c:3:10: fatal error: missing.h: No such file or directory
+    3 | #include \"missing.h\"
+      |          ^~~~~~~~~~~
+compilation terminated.
+gmake[2]: *** [CMakeFiles/app.dir/build.make:76: CMakeFiles/app.dir/main.c.o] Error 1
+gmake[1]: *** [CMakeFiles/Makefile2:83: CMakeFiles/app.dir/all] Error 2
+gmake: *** [Makefile:91: all] Error 2
+";
+
+        let failure = parse_build_failure(input).unwrap();
+        assert_eq!(failure.tool, BuildTool::CMake);
+        assert_eq!(failure.chain.len(), 3);
+        assert_eq!(
+            failure.innermost().unwrap().target.as_deref(),
+            Some("CMakeFiles/app.dir/main.c.o")
+        );
+        assert_eq!(
+            failure.block[0],
+            "[ 50%] Building C object CMakeFiles/app.dir/main.c.o"
+        );
+        assert_eq!(failure.block.len(), 5);
+    }
+
+    #[test]
+    fn test_makefile_syntax_error() {
+        let input = "Makefile:7: *** missing separator.  Stop.";
+        let failure = parse_build_failure(input).unwrap();
+        let inner = failure.innermost().unwrap();
+        assert_eq!(inner.file.as_deref(), Some("Makefile"));
+        assert_eq!(inner.line, Some(7));
+        assert!(failure.prompt_summary().contains("must start with a tab"));
+    }
+
+    #[test]
+    fn test_no_rule_to_make_target() {
+        let input = "make: *** No rule to make target 'src/missing.c', needed by 'app'.  Stop.";
+        let failure = parse_build_failure(input).unwrap();
+        let inner = failure.innermost().unwrap();
+        assert_eq!(inner.target.as_deref(), Some("src/missing.c"));
+        assert!(failure
+            .prompt_summary()
+            .contains("neither an existing file"));
+    }
+
+    #[test]
+    fn test_parses_ninja_failure() {
+        let input = "\
+[1/3] Building CXX object CMakeFiles/app.dir/a.cpp.o
+[2/3] Building CXX object CMakeFiles/app.dir/b.cpp.o
+FAILED: CMakeFiles/app.dir/b.cpp.o
+/usr/bin/c++ -O2 -o CMakeFiles/app.dir/b.cpp.o -c /src/b.cpp
+/src/b.cpp:5:3: error: use of undeclared identifier 'foo'
+    5 |   foo();
+      |   ^
+1 error generated.
+ninja: build stopped: subcommand failed.
+";
+
+        let failure = parse_build_failure(input).unwrap();
+        assert_eq!(failure.tool, BuildTool::CMake);
+        assert_eq!(
+            failure.innermost().unwrap().target.as_deref(),
+            Some("CMakeFiles/app.dir/b.cpp.o")
+        );
+        assert!(failure
+            .command
+            .as_deref()
+            .unwrap()
+            .starts_with("/usr/bin/c++"));
+        assert_eq!(failure.block.len(), 5);
+    }
+
+    #[test]
+    fn test_parses_cmake_configure_error() {
+        let input = "\
+-- The C compiler identification is GNU 13.2.0
+CMake Error at CMakeLists.txt:12 (find_package):
+  By not providing \"FindFoo.cmake\" in CMAKE_MODULE_PATH this project has
+  asked CMake to find a package configuration file provided by \"Foo\", but
+  CMake did not find one.
+
+  Could not find a package configuration file provided by \"Foo\".
+
+
+-- Configuring incomplete, errors occurred!
+";
+
+        let failure = parse_build_failure(input).unwrap();
+        assert_eq!(failure.tool, BuildTool::CMake);
+        let inner = failure.innermost().unwrap();
+        assert_eq!(inner.file.as_deref(), Some("CMakeLists.txt"));
+        assert_eq!(inner.line, Some(12));
+        assert_eq!(inner.target.as_deref(), Some("find_package"));
+        assert!(failure.prompt_summary().contains("CMAKE_PREFIX_PATH"));
+    }
+
+    #[test]
+    fn test_parses_bazel_failure() {
+        let input = "\
+INFO: Analyzed target //src:main (1 packages loaded, 5 targets configured).
+ERROR: /home/dev/proj/src/BUILD:5:10: Compiling src/main.cc failed: (Exit 1): gcc failed: error executing command (from target //src:main) /usr/bin/gcc -c src/main.cc
+src/main.cc:3:1: error: 'intt' does not name a type
+Target //src:main failed to build
+INFO: Elapsed time: 0.5s
+FAILED: Build did NOT complete successfully
+";
+
+        let failure = parse_build_failure(input).unwrap();
+        assert_eq!(failure.tool, BuildTool::Bazel);
+        let inner = failure.innermost().unwrap();
+        assert_eq!(inner.target.as_deref(), Some("//src:main"));
+        assert_eq!(inner.line, Some(5));
+        assert_eq!(inner.column, Some(10));
+        assert_eq!(inner.exit_code, Some(1));
+        assert_eq!(inner.message, "Compiling src/main.cc failed");
+        assert_eq!(
+            failure.command.as_deref(),
+            Some("/usr/bin/gcc -c src/main.cc")
+        );
+        assert_eq!(
+            failure.block,
+            vec!["src/main.cc:3:1: error: 'intt' does not name a type"]
+        );
+    }
+}
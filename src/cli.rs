@@ -1,6 +1,6 @@
 //! Command-line interface definitions for the `why` tool.
 
-use clap::{Parser, Subcommand};
+use clap::{Args, Parser, Subcommand};
 use clap_complete::Shell;
 use std::path::PathBuf;
 
@@ -78,6 +78,15 @@ pub struct Cli {
     #[arg(long, value_name = "PATH")]
     pub context_root: Option<PathBuf>,
 
+    /// Leave git blame, uncommitted changes and commit history out of --context
+    #[arg(long)]
+    pub no_git: bool,
+
+    /// Add OS, shell, toolchain versions and the manifest entries of
+    /// dependencies named in the error to the prompt (shown with --debug)
+    #[arg(long)]
+    pub env: bool,
+
     /// Show parsed stack trace frames (requires stack trace in input)
     #[arg(long)]
     pub show_frames: bool,
@@ -91,6 +100,42 @@ pub struct Cli {
     #[arg(long)]
     pub capture_all: bool,
 
+    /// Run the captured command under strace and include failed system
+    /// calls (missing files, refused connections) in the explanation
+    /// Example: why --capture --trace -- ./server
+    #[arg(long, requires = "capture")]
+    pub trace: bool,
+
+    /// Run the captured command N times and classify its failure as
+    /// deterministic, flaky or environment-dependent
+    /// Example: why --capture --repeat 10 -- cargo test
+    #[arg(
+        long,
+        value_name = "N",
+        requires = "capture",
+        conflicts_with = "trace",
+        value_parser = clap::value_parser!(u32).range(1..)
+    )]
+    pub repeat: Option<u32>,
+
+    /// With --repeat, stop at the first passing run
+    #[arg(long, requires = "repeat", conflicts_with = "until_fail")]
+    pub until_pass: bool,
+
+    /// With --repeat, stop at the first failing run
+    #[arg(long, requires = "repeat")]
+    pub until_fail: bool,
+
+    /// Explain a core dump using a local gdb or lldb; without a path, the
+    /// latest crash recorded by coredumpctl
+    /// Example: why --core core.12345 --binary ./server
+    #[arg(long, value_name = "COREFILE", num_args = 0..=1)]
+    pub core: Option<Option<PathBuf>>,
+
+    /// Binary the core dump belongs to (found from the core if omitted)
+    #[arg(long, value_name = "EXE", requires = "core")]
+    pub binary: Option<PathBuf>,
+
     /// Ask for confirmation before explaining errors (interactive mode)
     #[arg(long)]
     pub confirm: bool,
@@ -118,21 +163,53 @@ pub struct Cli {
     /// Examples:
     ///   why --watch /var/log/app.log
     ///   why --watch "npm run dev"
+    ///   kubectl logs -f pod | why --watch -
+    ///   why --watch /tmp/app.fifo
     #[arg(long, short = 'w', value_name = "TARGET")]
     pub watch: Option<String>,
 
+    /// Pass piped input through to stdout unchanged and explain errors on stderr
+    /// Examples:
+    ///   cargo build 2>&1 | why --tee
+    ///   set -o pipefail; make 2>&1 | why --tee --first
+    #[arg(long, conflicts_with_all = ["watch", "capture", "exit_code"])]
+    pub tee: bool,
+
+    /// Only explain the first error detected in tee mode
+    #[arg(long, requires = "tee")]
+    pub first: bool,
+
     /// Debounce time in milliseconds for watch mode (default: 500)
     #[arg(long, default_value = "500", value_name = "MS")]
     pub debounce: u64,
 
+    /// Lines of output before an error to include as context in watch and
+    /// tee mode (0 to disable)
+    #[arg(long, default_value = "5", value_name = "N")]
+    pub pre_context: usize,
+
+    /// Only keep pre-error context lines from the last SECS seconds
+    #[arg(long, value_name = "SECS")]
+    pub pre_context_secs: Option<u64>,
+
     /// Disable duplicate error suppression in watch mode
     #[arg(long)]
     pub no_dedup: bool,
 
-    /// Custom regex pattern for error detection in watch mode
+    /// Custom regex pattern for error detection in watch mode (replaces the
+    /// built-in heuristics; configured rules still apply)
     #[arg(long, value_name = "REGEX")]
     pub pattern: Option<String>,
 
+    /// Detection preset for watch and tee mode, repeatable
+    /// (rust, node, python, go, java, nginx, postgres, zero-counts)
+    #[arg(long, value_name = "NAME")]
+    pub preset: Vec<String>,
+
+    /// Don't send explained errors to the notification sinks in the config
+    #[arg(long)]
+    pub no_notify: bool,
+
     /// Clear screen between errors in watch mode
     #[arg(long)]
     pub clear: bool,
@@ -144,9 +221,12 @@ pub struct Cli {
     // ========================================================================
     // Daemon Mode (Feature 5)
     // ========================================================================
-    /// Daemon management subcommand
+    /// Daemon management, bisect, diff and detect subcommands
+    ///
+    /// A first word matching a subcommand is parsed as one, so error text
+    /// starting with "diff" or "detect" has to be quoted or piped in
     #[command(subcommand)]
-    pub daemon: Option<DaemonCommand>,
+    pub command: Option<Commands>,
 
     /// Prefer daemon connection for inference (falls back to direct if unavailable)
     #[arg(long, short = 'D')]
@@ -161,6 +241,64 @@ pub struct Cli {
     pub no_auto_start: bool,
 }
 
+/// Subcommands
+#[derive(Subcommand, Debug, Clone)]
+pub enum Commands {
+    #[command(flatten)]
+    Daemon(DaemonCommand),
+    /// Find the commit that broke a command with git bisect, then explain it
+    /// Example: why bisect --good v1.4.0 -- cargo test
+    Bisect(BisectArgs),
+    /// Explain the errors and warnings only a failing log has, compared to a
+    /// passing log of the same job
+    /// Example: why diff good.log bad.log
+    Diff {
+        /// Log of a passing run
+        good: PathBuf,
+        /// Log of a failing run
+        bad: PathBuf,
+    },
+    /// Show which detection rule watch mode would apply to a line
+    /// Example: why detect --explain-line "Failed: 0"
+    Detect(DetectArgs),
+}
+
+/// Arguments for `why bisect`
+#[derive(Args, Debug, Clone)]
+pub struct BisectArgs {
+    /// Last revision where the command passed
+    #[arg(long, value_name = "REV")]
+    pub good: String,
+
+    /// Revision where the command fails
+    #[arg(long, value_name = "REV", default_value = "HEAD")]
+    pub bad: String,
+
+    /// Test command; exit codes are classified as in --capture, 125 skips
+    #[arg(trailing_var_arg = true, required = true, value_name = "COMMAND")]
+    pub test_command: Vec<String>,
+}
+
+/// Arguments for `why detect`
+#[derive(Args, Debug, Clone)]
+pub struct DetectArgs {
+    /// Line to classify
+    #[arg(long, value_name = "LINE")]
+    pub explain_line: String,
+
+    /// Watch target, to apply its [[detect.sources]] rules
+    #[arg(long, value_name = "TARGET", default_value = "-")]
+    pub source: String,
+
+    /// Detection preset, repeatable
+    #[arg(long, value_name = "NAME")]
+    pub preset: Vec<String>,
+
+    /// Custom regex pattern, as with --watch --pattern
+    #[arg(long, value_name = "REGEX")]
+    pub pattern: Option<String>,
+}
+
 /// Daemon management subcommand
 #[derive(Subcommand, Debug, Clone)]
 pub enum DaemonCommand {
@@ -376,6 +514,154 @@ mod tests {
         assert_eq!(cli.last_command, Some("npm run build".to_string()));
     }
 
+    #[test]
+    fn test_cli_parses_bisect_subcommand() {
+        let cli = Cli::parse_from(["why", "bisect", "--good", "v1.4.0", "--", "cargo", "test"]);
+        let Some(Commands::Bisect(args)) = cli.command else {
+            panic!("expected bisect subcommand");
+        };
+        assert_eq!(args.good, "v1.4.0");
+        assert_eq!(args.bad, "HEAD");
+        assert_eq!(args.test_command, vec!["cargo", "test"]);
+        assert!(Cli::try_parse_from(["why", "bisect", "--", "make"]).is_err());
+        assert!(matches!(
+            Cli::parse_from(["why", "status"]).command,
+            Some(Commands::Daemon(DaemonCommand::Status))
+        ));
+    }
+
+    #[test]
+    fn test_cli_parses_diff_subcommand() {
+        let cli = Cli::parse_from(["why", "--json", "diff", "good.log", "bad.log"]);
+        assert!(cli.json);
+        let Some(Commands::Diff { good, bad }) = cli.command else {
+            panic!("expected diff subcommand");
+        };
+        assert_eq!(good, PathBuf::from("good.log"));
+        assert_eq!(bad, PathBuf::from("bad.log"));
+        assert!(Cli::try_parse_from(["why", "diff", "good.log"]).is_err());
+    }
+
+    #[test]
+    fn test_cli_parses_detect_subcommand() {
+        let cli = Cli::parse_from([
+            "why",
+            "detect",
+            "--explain-line",
+            "Failed: 0",
+            "--preset",
+            "rust",
+            "--preset",
+            "node",
+        ]);
+        let Some(Commands::Detect(args)) = cli.command else {
+            panic!("expected detect subcommand");
+        };
+        assert_eq!(args.explain_line, "Failed: 0");
+        assert_eq!(args.source, "-");
+        assert_eq!(args.preset, vec!["rust", "node"]);
+        assert!(args.pattern.is_none());
+        assert!(Cli::try_parse_from(["why", "detect"]).is_err());
+
+        let cli = Cli::parse_from(["why", "--preset", "nginx", "--watch", "/var/log/nginx"]);
+        assert_eq!(cli.preset, vec!["nginx"]);
+        assert!(!cli.no_notify);
+        assert!(Cli::parse_from(["why", "--no-notify", "--watch", "app.log"]).no_notify);
+    }
+
+    #[test]
+    fn test_cli_parses_no_git_flag() {
+        let cli = Cli::parse_from(["why", "--context", "--no-git", "error"]);
+        assert!(cli.context);
+        assert!(cli.no_git);
+        assert!(!Cli::parse_from(["why", "--context", "error"]).no_git);
+    }
+
+    #[test]
+    fn test_cli_parses_env_flag() {
+        let cli = Cli::parse_from(["why", "--env", "--debug", "ModuleNotFoundError"]);
+        assert!(cli.env);
+        assert_eq!(cli.error, vec!["ModuleNotFoundError"]);
+    }
+
+    #[test]
+    fn test_cli_trace_requires_capture() {
+        let cli = Cli::parse_from(["why", "--capture", "--trace", "--", "./server"]);
+        assert!(cli.trace);
+        assert_eq!(cli.error, vec!["./server"]);
+        assert!(Cli::try_parse_from(["why", "--trace"]).is_err());
+    }
+
+    #[test]
+    fn test_cli_parses_repeat() {
+        let cli = Cli::parse_from([
+            "why",
+            "--capture",
+            "--repeat",
+            "5",
+            "--until-fail",
+            "--",
+            "make",
+        ]);
+        assert_eq!(cli.repeat, Some(5));
+        assert!(cli.until_fail);
+        assert!(!cli.until_pass);
+        assert!(Cli::try_parse_from(["why", "--capture", "--repeat", "0", "--", "make"]).is_err());
+        assert!(Cli::try_parse_from(["why", "--capture", "--until-pass", "--", "make"]).is_err());
+        assert!(Cli::try_parse_from([
+            "why",
+            "--capture",
+            "--repeat",
+            "3",
+            "--until-pass",
+            "--until-fail",
+            "--",
+            "make"
+        ])
+        .is_err());
+    }
+
+    #[test]
+    fn test_cli_parses_tee_flags() {
+        let cli = Cli::parse_from(["why", "--tee", "--first"]);
+        assert!(cli.tee);
+        assert!(cli.first);
+        assert!(Cli::try_parse_from(["why", "--first"]).is_err());
+        assert!(Cli::try_parse_from(["why", "--tee", "--watch", "app.log"]).is_err());
+    }
+
+    #[test]
+    fn test_cli_parses_pre_context() {
+        let cli = Cli::parse_from(["why", "--watch", "app.log"]);
+        assert_eq!(cli.pre_context, 5);
+        assert_eq!(cli.pre_context_secs, None);
+
+        let cli = Cli::parse_from([
+            "why",
+            "--tee",
+            "--pre-context",
+            "10",
+            "--pre-context-secs",
+            "30",
+        ]);
+        assert_eq!(cli.pre_context, 10);
+        assert_eq!(cli.pre_context_secs, Some(30));
+    }
+
+    #[test]
+    fn test_cli_parses_core_with_binary() {
+        let cli = Cli::parse_from(["why", "--core", "core.4242", "--binary", "./server"]);
+        assert_eq!(cli.core, Some(Some(PathBuf::from("core.4242"))));
+        assert_eq!(cli.binary, Some(PathBuf::from("./server")));
+    }
+
+    #[test]
+    fn test_cli_parses_bare_core_flag() {
+        let cli = Cli::parse_from(["why", "--core"]);
+        assert_eq!(cli.core, Some(None));
+        assert!(Cli::try_parse_from(["why", "--binary", "./server"]).is_err());
+    }
+
     #[test]
     fn test_cli_parses_hook_mode_full() {
         let cli = Cli::parse_from(["why", "--exit-code", "1", "--last-command", "cargo build"]);
@@ -5,6 +5,8 @@ use serde::Deserialize;
 use std::env;
 use std::path::PathBuf;
 
+use crate::sinks::AlertLevel;
+
 /// Configuration for hook behavior
 #[derive(Debug, Deserialize, Clone)]
 #[serde(default)]
@@ -52,11 +54,124 @@ impl Default for IgnoreCommandsConfig {
     }
 }
 
+/// Extra context gathered for explanations
+#[derive(Debug, Deserialize, Clone)]
+#[serde(default)]
+pub struct ContextConfig {
+    /// Include OS, toolchain versions and manifest dependencies in prompts
+    pub environment: bool,
+    /// Include git blame, uncommitted changes and recent commits with --context
+    pub git: bool,
+}
+
+impl Default for ContextConfig {
+    fn default() -> Self {
+        Self {
+            environment: false,
+            git: true,
+        }
+    }
+}
+
+/// Error detection rules for watch and tee mode
+#[derive(Debug, Deserialize, Clone)]
+#[serde(default)]
+pub struct DetectConfig {
+    /// Fall back to the built-in heuristics
+    pub builtin: bool,
+    /// Named presets (rust, node, python, go, java, nginx, postgres, zero-counts)
+    pub presets: Vec<String>,
+    /// Regex patterns for lines that start an error
+    pub include: Vec<String>,
+    /// Regex patterns for lines that never start an error
+    pub exclude: Vec<String>,
+    /// Rule sets for specific watch sources
+    pub sources: Vec<SourceRules>,
+}
+
+impl Default for DetectConfig {
+    fn default() -> Self {
+        Self {
+            builtin: true,
+            presets: Vec::new(),
+            include: Vec::new(),
+            exclude: Vec::new(),
+            sources: Vec::new(),
+        }
+    }
+}
+
+/// Detection rules applied only to matching watch sources
+#[derive(Debug, Deserialize, Clone, Default)]
+#[serde(default)]
+pub struct SourceRules {
+    /// Regex matched against the watch target (path, command, or `-`)
+    #[serde(rename = "match")]
+    pub source: String,
+    pub presets: Vec<String>,
+    pub include: Vec<String>,
+    pub exclude: Vec<String>,
+}
+
+/// Where a notification sink delivers explained errors
+#[derive(Debug, Deserialize, Clone)]
+#[serde(tag = "type", rename_all = "lowercase")]
+pub enum SinkKind {
+    /// HTTP POST of a Slack/Matrix-compatible payload, or of `template`
+    Webhook {
+        url: String,
+        #[serde(default)]
+        template: Option<String>,
+    },
+    /// Shell command that receives the report as JSON on stdin
+    Exec { command: String },
+    /// File the report is appended to as one JSON line
+    Jsonl { path: PathBuf },
+}
+
+impl SinkKind {
+    /// Sink type name, as written in the config
+    pub fn name(&self) -> &'static str {
+        match self {
+            SinkKind::Webhook { .. } => "webhook",
+            SinkKind::Exec { .. } => "exec",
+            SinkKind::Jsonl { .. } => "jsonl",
+        }
+    }
+}
+
+/// A notification sink for watch mode
+#[derive(Debug, Deserialize, Clone)]
+pub struct SinkConfig {
+    #[serde(flatten)]
+    pub kind: SinkKind,
+    /// Skip reports below this severity (warning, error, critical)
+    #[serde(default)]
+    pub min_severity: AlertLevel,
+    /// Extra attempts after a failed delivery
+    #[serde(default = "default_sink_retries")]
+    pub retries: u32,
+    /// Delay before the first retry, doubled for each one after
+    #[serde(default = "default_sink_backoff_ms")]
+    pub backoff_ms: u64,
+}
+
+fn default_sink_retries() -> u32 {
+    3
+}
+
+fn default_sink_backoff_ms() -> u64 {
+    500
+}
+
 /// Root configuration structure
 #[derive(Debug, Deserialize, Clone, Default)]
 #[serde(default)]
 pub struct Config {
     pub hook: HookConfig,
+    pub context: ContextConfig,
+    pub detect: DetectConfig,
+    pub sinks: Vec<SinkConfig>,
 }
 
 impl Config {
@@ -92,6 +207,13 @@ impl Config {
         if env::var("WHY_HOOK_AUTO").map(|v| v == "1").unwrap_or(false) {
             self.hook.auto_explain = true;
         }
+        // WHY_CONTEXT_ENV=1 adds environment context
+        if env::var("WHY_CONTEXT_ENV")
+            .map(|v| v == "1")
+            .unwrap_or(false)
+        {
+            self.context.environment = true;
+        }
     }
 
     /// Check if hook is disabled via environment variable
@@ -144,9 +266,63 @@ patterns = [
     "^clear$",   # clear command
 ]
 
+[context]
+# Add OS, shell, toolchain versions and the manifest entries of dependencies
+# named in the error to prompts (default: false, same as --env)
+environment = false
+
+# Add git blame, uncommitted changes and recent commits for the failing line
+# to --context (default: true, --no-git turns it off for one run)
+git = true
+
+[detect]
+# Error detection for --watch and --tee. Rules are checked in layers, most
+# specific first: --pattern, matching [[detect.sources]], the include/exclude
+# lists below, presets, then the built-in heuristics. The first layer with a
+# match decides; within a layer excludes win. Check a line with:
+#   why detect --explain-line "Failed: 0"
+
+# Fall back to the built-in heuristics (default: true)
+builtin = true
+
+# Named presets: rust, node, python, go, java, nginx, postgres, and
+# zero-counts to skip summaries like "0 errors" or "error_count=0"
+presets = []
+
+# Regex patterns for lines that start an error, and lines that never do
+include = []
+exclude = []
+
+# Rules for specific watch targets, matched as a regex against the file path,
+# command, or "-" for stdin
+# [[detect.sources]]
+# match = "nginx"
+# presets = ["nginx"]
+# exclude = ["/healthz"]
+
+# Notification sinks: every error explained by --watch or --tee is also sent
+# to each sink below (--no-notify skips them). Failed deliveries are retried
+# `retries` times (default 3), waiting `backoff_ms` (default 500), doubled each
+# time. `min_severity` (warning, error, critical) skips less serious errors.
+#
+# [[sinks]]
+# type = "webhook"   # Slack/Matrix-compatible JSON; https:// needs curl
+# url = "https://hooks.slack.com/services/..."
+# min_severity = "error"
+# template = '{"text": "why: {{summary}}", "report": {{report}}}'
+#
+# [[sinks]]
+# type = "exec"      # report as JSON on stdin, plus $WHY_SEVERITY/$WHY_SUMMARY
+# command = 'notify-send "why" "$WHY_SUMMARY"'
+#
+# [[sinks]]
+# type = "jsonl"
+# path = "~/.local/state/why/errors.jsonl"
+
 # Environment variable overrides:
 # WHY_HOOK_AUTO=1    - Force auto-explain (overrides config)
 # WHY_HOOK_DISABLE=1 - Temporarily disable hook explanations
+# WHY_CONTEXT_ENV=1  - Add environment context (same as --env)
 "#
     .to_string()
 }
@@ -0,0 +1,458 @@
+//! Core dump analysis with a locally installed gdb or lldb.
+//!
+//! A crashed service usually leaves nothing but "Segmentation fault (core
+//! dumped)" on stderr. The core file has the rest: the backtrace with local
+//! variables, the registers, the signal and the faulting instruction. This
+//! module runs the debugger in batch mode to print those, finds the binary
+//! the core belongs to, and can fetch the latest core from `coredumpctl`.
+
+use anyhow::{bail, Context, Result};
+use regex::Regex;
+use std::fmt;
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+use std::sync::OnceLock;
+
+use crate::temp;
+
+/// Frames printed by `bt full`; deeper frames rarely matter and the locals
+/// of each one cost prompt space
+const BACKTRACE_DEPTH: usize = 20;
+
+// ============================================================================
+// Debuggers
+// ============================================================================
+
+/// A debugger that can read core files
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Debugger {
+    Gdb,
+    Lldb,
+}
+
+impl Debugger {
+    /// Executable name
+    pub fn program(&self) -> &'static str {
+        match self {
+            Debugger::Gdb => "gdb",
+            Debugger::Lldb => "lldb",
+        }
+    }
+
+    /// Arguments for a batch run that prints the backtrace with locals, the
+    /// registers, the signal and the faulting instruction
+    pub fn batch_args(&self, core: &Path, binary: Option<&Path>) -> Vec<String> {
+        match self {
+            Debugger::Gdb => {
+                let mut args: Vec<String> = vec!["-batch".into(), "-nx".into(), "-q".into()];
+                for command in [
+                    "set pagination off".to_string(),
+                    "set print frame-arguments scalars".to_string(),
+                    "echo \\n--- backtrace ---\\n".to_string(),
+                    format!("bt full {}", BACKTRACE_DEPTH),
+                    "echo \\n--- signal ---\\n".to_string(),
+                    "p $_siginfo.si_signo".to_string(),
+                    "p $_siginfo._sifields._sigfault.si_addr".to_string(),
+                    "echo \\n--- faulting instruction ---\\n".to_string(),
+                    "x/i $pc".to_string(),
+                    "echo \\n--- registers ---\\n".to_string(),
+                    "info registers".to_string(),
+                ] {
+                    args.push("-ex".into());
+                    args.push(command);
+                }
+                if let Some(binary) = binary {
+                    args.push(binary.display().to_string());
+                }
+                args.push(core.display().to_string());
+                args
+            }
+            Debugger::Lldb => {
+                let mut args: Vec<String> = vec!["--batch".into(), "--no-lldbinit".into()];
+                args.push("--core".into());
+                args.push(core.display().to_string());
+                for command in [
+                    "thread info".to_string(),
+                    format!("thread backtrace --count {}", BACKTRACE_DEPTH),
+                    "frame variable".to_string(),
+                    "disassemble --pc --count 1".to_string(),
+                    "register read".to_string(),
+                ] {
+                    args.push("-o".into());
+                    args.push(command);
+                }
+                if let Some(binary) = binary {
+                    args.push(binary.display().to_string());
+                }
+                args
+            }
+        }
+    }
+
+    /// Debuggers to try, most natural for the platform first
+    fn preference() -> [Debugger; 2] {
+        if cfg!(target_os = "macos") {
+            [Debugger::Lldb, Debugger::Gdb]
+        } else {
+            [Debugger::Gdb, Debugger::Lldb]
+        }
+    }
+
+    /// The first debugger that's installed
+    pub fn detect() -> Option<Debugger> {
+        Self::preference().into_iter().find(|debugger| {
+            Command::new(debugger.program())
+                .arg("--version")
+                .stdin(Stdio::null())
+                .stdout(Stdio::null())
+                .stderr(Stdio::null())
+                .status()
+                .is_ok_and(|status| status.success())
+        })
+    }
+}
+
+impl fmt::Display for Debugger {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{}", self.program())
+    }
+}
+
+// ============================================================================
+// coredumpctl
+// ============================================================================
+
+/// What `coredumpctl info` says about a crash
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct CoredumpInfo {
+    /// PID of the crashed process
+    pub pid: Option<u32>,
+    /// Signal that killed it (e.g. "11 (SEGV)")
+    pub signal: Option<String>,
+    /// Executable that crashed
+    pub executable: Option<PathBuf>,
+    /// Command line of the crashed process
+    pub command_line: Option<String>,
+    /// Whether the core itself is still stored
+    pub present: bool,
+}
+
+struct Patterns {
+    info_field: Regex,
+    generated_by: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        info_field: Regex::new(r"^\s*([A-Za-z ]+?): (.+)$").unwrap(),
+        generated_by: Regex::new(r"^Core was generated by `([^`]+)'").unwrap(),
+    })
+}
+
+/// Parse the output of `coredumpctl info` for a single crash
+pub fn parse_coredumpctl_info(output: &str) -> Option<CoredumpInfo> {
+    let mut info = CoredumpInfo::default();
+    let mut found = false;
+
+    for line in output.lines() {
+        let Some(caps) = patterns().info_field.captures(line) else {
+            continue;
+        };
+        let value = caps[2].trim();
+        match &caps[1] {
+            "PID" => {
+                info.pid = value.split_whitespace().next().and_then(|p| p.parse().ok());
+                found = true;
+            }
+            "Signal" => info.signal = Some(value.to_string()),
+            "Executable" => info.executable = Some(PathBuf::from(value)),
+            "Command Line" => info.command_line = Some(value.to_string()),
+            "Storage" => info.present = value.ends_with("(present)"),
+            _ => {}
+        }
+    }
+
+    found.then_some(info)
+}
+
+/// Whether `coredumpctl` is installed
+pub fn has_coredumpctl() -> bool {
+    Command::new("coredumpctl")
+        .arg("--version")
+        .stdin(Stdio::null())
+        .stdout(Stdio::null())
+        .stderr(Stdio::null())
+        .status()
+        .is_ok_and(|status| status.success())
+}
+
+/// Copy the latest core (optionally for `matching` executable) out of
+/// coredumpctl's storage into `dest`
+pub fn fetch_latest_core(matching: Option<&Path>, dest: &Path) -> Result<CoredumpInfo> {
+    let mut args = vec![
+        "info".to_string(),
+        "--no-pager".to_string(),
+        "-1".to_string(),
+    ];
+    if let Some(binary) = matching {
+        args.push(binary.display().to_string());
+    }
+    let output = Command::new("coredumpctl")
+        .args(&args)
+        .stdin(Stdio::null())
+        .output()
+        .context("Failed to run coredumpctl")?;
+    let text = String::from_utf8_lossy(&output.stdout);
+    let Some(info) = parse_coredumpctl_info(&text) else {
+        bail!("coredumpctl has no recorded crashes");
+    };
+    if !info.present {
+        bail!(
+            "coredumpctl recorded the crash of {} but the core file is no longer stored",
+            info.executable
+                .as_ref()
+                .map_or("the process".to_string(), |e| e.display().to_string())
+        );
+    }
+
+    let mut dump = Command::new("coredumpctl");
+    dump.args(["dump", "--no-pager", "-o"]).arg(dest);
+    match info.pid {
+        Some(pid) => dump.arg(pid.to_string()),
+        None => dump.args(matching),
+    };
+    let status = dump
+        .stdin(Stdio::null())
+        .stdout(Stdio::null())
+        .stderr(Stdio::null())
+        .status()
+        .context("Failed to run coredumpctl dump")?;
+    if !status.success() {
+        bail!("coredumpctl could not extract the core file");
+    }
+
+    Ok(info)
+}
+
+// ============================================================================
+// Analysis
+// ============================================================================
+
+/// Debugger output for a core file
+#[derive(Debug, Clone)]
+pub struct CoreAnalysis {
+    /// Debugger that produced the output
+    pub debugger: Debugger,
+    /// Core file that was analyzed
+    pub core: PathBuf,
+    /// Binary the core belongs to, if known
+    pub binary: Option<PathBuf>,
+    /// coredumpctl's record of the crash, when the core came from there
+    pub crash: Option<CoredumpInfo>,
+    /// Combined debugger output
+    pub output: String,
+}
+
+impl CoreAnalysis {
+    /// Text to explain: a header naming the core and binary, then the
+    /// debugger output
+    pub fn to_input(&self) -> String {
+        let binary = self
+            .binary
+            .as_ref()
+            .map_or("unknown (symbols may be missing)".to_string(), |b| {
+                b.display().to_string()
+            });
+        let core = match &self.crash {
+            Some(crash) => {
+                let mut text = "latest crash recorded by coredumpctl".to_string();
+                if let Some(pid) = crash.pid {
+                    text.push_str(&format!(", PID {}", pid));
+                }
+                if let Some(signal) = &crash.signal {
+                    text.push_str(&format!(", signal {}", signal));
+                }
+                if let Some(command_line) = &crash.command_line {
+                    text.push_str(&format!("\nCommand line: {}", command_line));
+                }
+                text
+            }
+            None => self.core.display().to_string(),
+        };
+        format!(
+            "Core dump: {}\nBinary: {}\nDebugger: {}\n\n{}",
+            core,
+            binary,
+            self.debugger,
+            self.output.trim()
+        )
+    }
+}
+
+/// The program gdb says produced a core ("Core was generated by `./app -x'.")
+pub fn generated_by(output: &str) -> Option<PathBuf> {
+    output.lines().find_map(|line| {
+        let caps = patterns().generated_by.captures(line)?;
+        caps[1].split_whitespace().next().map(PathBuf::from)
+    })
+}
+
+/// Run `debugger` on `core` and collect its output
+pub fn run_debugger(debugger: Debugger, core: &Path, binary: Option<&Path>) -> Result<String> {
+    let output = Command::new(debugger.program())
+        .args(debugger.batch_args(core, binary))
+        .stdin(Stdio::null())
+        .output()
+        .with_context(|| format!("Failed to run {}", debugger))?;
+
+    let mut text = String::from_utf8_lossy(&output.stdout).into_owned();
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    if !stderr.trim().is_empty() {
+        text.push('\n');
+        text.push_str(stderr.trim_end());
+    }
+    if text.trim().is_empty() {
+        bail!("{} printed nothing for {}", debugger, core.display());
+    }
+    Ok(text)
+}
+
+/// Run the debugger, first learning the binary from the core if needed
+fn run_with_symbols(
+    debugger: Debugger,
+    core: &Path,
+    binary: &mut Option<PathBuf>,
+) -> Result<String> {
+    let output = run_debugger(debugger, core, binary.as_deref())?;
+    if binary.is_some() {
+        return Ok(output);
+    }
+    match generated_by(&output).filter(|p| p.is_file()) {
+        Some(program) => {
+            let output = run_debugger(debugger, core, Some(&program))?;
+            *binary = Some(program);
+            Ok(output)
+        }
+        None => Ok(output),
+    }
+}
+
+/// Analyze a core file, or the latest coredumpctl core when `core` is None
+///
+/// Without `binary`, gdb is run once to learn which program produced the
+/// core, then again with that program so the backtrace has symbols.
+pub fn analyze(core: Option<&Path>, binary: Option<&Path>) -> Result<CoreAnalysis> {
+    let Some(debugger) = Debugger::detect() else {
+        bail!("Neither gdb nor lldb is installed; one is needed to read core files");
+    };
+
+    let mut fetched = None;
+    let mut crash = None;
+    let (core, mut binary) = match core {
+        Some(core) => {
+            if !core.is_file() {
+                bail!("Core file not found: {}", core.display());
+            }
+            (core.to_path_buf(), binary.map(Path::to_path_buf))
+        }
+        None => {
+            if !has_coredumpctl() {
+                bail!("No core file given and coredumpctl isn't available. Use: why --core <corefile>");
+            }
+            // coredumpctl matches executables by absolute path
+            let matching = binary.map(|b| b.canonicalize().unwrap_or_else(|_| b.to_path_buf()));
+            // coredumpctl writes through symlinks, so give it a file we own
+            let (_, dest) = temp::create_file("why-core-", "")?;
+            let info = fetch_latest_core(matching.as_deref(), &dest);
+            if info.is_err() {
+                let _ = fs::remove_file(&dest);
+            }
+            let info = info?;
+            crash = Some(info.clone());
+            fetched = Some(dest.clone());
+            (dest, binary.map(Path::to_path_buf).or(info.executable))
+        }
+    };
+
+    let result = run_with_symbols(debugger, &core, &mut binary);
+
+    if let Some(fetched) = fetched {
+        let _ = fs::remove_file(fetched);
+    }
+
+    Ok(CoreAnalysis {
+        debugger,
+        core,
+        binary,
+        crash,
+        output: result?,
+    })
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_gdb_batch_args() {
+        let args = Debugger::Gdb.batch_args(Path::new("core.1234"), Some(Path::new("./server")));
+        assert_eq!(&args[..3], ["-batch", "-nx", "-q"]);
+        assert!(args.contains(&"bt full 20".to_string()));
+        assert!(args.contains(&"x/i $pc".to_string()));
+        assert!(args.contains(&"info registers".to_string()));
+        assert_eq!(args[args.len() - 2], "./server");
+        assert_eq!(args[args.len() - 1], "core.1234");
+    }
+
+    #[test]
+    fn test_lldb_batch_args() {
+        let args = Debugger::Lldb.batch_args(Path::new("/cores/core.99"), None);
+        assert_eq!(
+            &args[..4],
+            ["--batch", "--no-lldbinit", "--core", "/cores/core.99"]
+        );
+        assert!(args.contains(&"thread backtrace --count 20".to_string()));
+        assert!(args.contains(&"register read".to_string()));
+        assert_eq!(args.last().unwrap(), "register read");
+    }
+
+    #[test]
+    fn test_parse_coredumpctl_info() {
+        let output = "           PID: 48213 (server)
+           UID: 1000 (ann)
+        Signal: 11 (SEGV)
+     Timestamp: Tue 2024-03-05 10:12:44 UTC (2min ago)
+  Command Line: ./server --port 8080
+    Executable: /home/ann/proj/build/server
+       Storage: /var/lib/systemd/coredump/core.server.1000.abc.48213.1709633564000000.zst (present)
+  Size on Disk: 212.4K";
+
+        let info = parse_coredumpctl_info(output).unwrap();
+        assert_eq!(info.pid, Some(48213));
+        assert_eq!(info.signal.as_deref(), Some("11 (SEGV)"));
+        assert_eq!(
+            info.executable,
+            Some(PathBuf::from("/home/ann/proj/build/server"))
+        );
+        assert_eq!(info.command_line.as_deref(), Some("./server --port 8080"));
+        assert!(info.present);
+
+        let missing = output.replace("(present)", "(missing)");
+        assert!(!parse_coredumpctl_info(&missing).unwrap().present);
+        assert!(parse_coredumpctl_info("No coredumps found.").is_none());
+    }
+
+    #[test]
+    fn test_generated_by() {
+        let output = "[New LWP 48213]\nCore was generated by `./server --port 8080'.\n\
+                      Program terminated with signal SIGSEGV, Segmentation fault.";
+        assert_eq!(generated_by(output), Some(PathBuf::from("./server")));
+        assert_eq!(generated_by("no header"), None);
+    }
+}
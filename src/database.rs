@@ -0,0 +1,990 @@
+//! Database error parsing.
+//!
+//! Extracts SQLSTATE and vendor error codes, the offending statement and
+//! caret position, and constraint, table and column names from PostgreSQL,
+//! MySQL and SQLite errors, including when they are wrapped by SQLAlchemy,
+//! Prisma or ActiveRecord.
+
+use regex::Regex;
+use serde::Serialize;
+use std::fmt;
+use std::sync::OnceLock;
+
+use crate::stack_trace::strip_ansi;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Database engine that raised the error
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum DatabaseEngine {
+    Postgres,
+    MySql,
+    Sqlite,
+}
+
+impl fmt::Display for DatabaseEngine {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            DatabaseEngine::Postgres => write!(f, "PostgreSQL"),
+            DatabaseEngine::MySql => write!(f, "MySQL"),
+            DatabaseEngine::Sqlite => write!(f, "SQLite"),
+        }
+    }
+}
+
+/// ORM or query layer wrapping the database error
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Orm {
+    SqlAlchemy,
+    Prisma,
+    ActiveRecord,
+}
+
+impl fmt::Display for Orm {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Orm::SqlAlchemy => write!(f, "SQLAlchemy"),
+            Orm::Prisma => write!(f, "Prisma"),
+            Orm::ActiveRecord => write!(f, "ActiveRecord"),
+        }
+    }
+}
+
+/// A parsed database error
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct DatabaseError {
+    /// Database engine, when it can be told from the output
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub engine: Option<DatabaseEngine>,
+    /// ORM that wrapped the error
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub orm: Option<Orm>,
+    /// SQLSTATE printed with the error
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub sqlstate: Option<String>,
+    /// Condition name for the SQLSTATE (e.g. "unique_violation")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub condition: Option<String>,
+    /// Human category of the SQLSTATE class (e.g. "integrity constraint violation")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub category: Option<String>,
+    /// Vendor error code (e.g. "ER_DUP_ENTRY", "1062", "P2002", "SQLITE_CONSTRAINT")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub vendor_code: Option<String>,
+    /// Driver or ORM exception class (e.g. "psycopg2.errors.UniqueViolation")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exception: Option<String>,
+    /// The database's error message
+    pub message: String,
+    /// DETAIL line
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub detail: Option<String>,
+    /// HINT line
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub hint: Option<String>,
+    /// The offending statement, or the line of it the database printed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub statement: Option<String>,
+    /// Line of the statement the error is on (1-based)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub statement_line: Option<u32>,
+    /// Column the caret points at within that line (1-based)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub position: Option<u32>,
+    /// Constraint that was violated
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub constraint: Option<String>,
+    /// Table involved
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub table: Option<String>,
+    /// Column involved
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub column: Option<String>,
+}
+
+impl DatabaseError {
+    /// Name used as the stack trace error type
+    pub fn error_type(&self) -> String {
+        self.condition
+            .clone()
+            .or_else(|| self.vendor_code.clone())
+            .unwrap_or_else(|| "DatabaseError".to_string())
+    }
+
+    /// The statement line with a caret under the error position
+    pub fn statement_with_caret(&self) -> Option<String> {
+        let statement = self.statement.as_ref()?;
+        let Some(position) = self.position else {
+            return Some(statement.clone());
+        };
+        let line_index = self
+            .statement_line
+            .map(|l| (l as usize).saturating_sub(1))
+            .unwrap_or(0);
+        let lines: Vec<&str> = statement.lines().collect();
+        let line_index = line_index.min(lines.len().saturating_sub(1));
+        let mut out = String::new();
+        for (i, line) in lines.iter().enumerate() {
+            out.push_str(line);
+            out.push('\n');
+            if i == line_index {
+                out.push_str(&" ".repeat(position.saturating_sub(1) as usize));
+                out.push_str(&format!("^ (column {})\n", position));
+            }
+        }
+        Some(out.trim_end().to_string())
+    }
+
+    /// Render the error as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let source = match (self.engine, self.orm) {
+            (Some(engine), Some(orm)) => format!("{} via {}", engine, orm),
+            (Some(engine), None) => engine.to_string(),
+            (None, Some(orm)) => orm.to_string(),
+            (None, None) => "SQL".to_string(),
+        };
+        let mut out = format!("Database error ({}):\n", source);
+
+        match (&self.sqlstate, &self.condition) {
+            (Some(code), Some(condition)) => {
+                out.push_str(&format!("- SQLSTATE {} ({})\n", code, condition))
+            }
+            (Some(code), None) => out.push_str(&format!("- SQLSTATE {}\n", code)),
+            (None, Some(condition)) => out.push_str(&format!("- condition: {}\n", condition)),
+            (None, None) => {}
+        }
+        if let Some(category) = &self.category {
+            out.push_str(&format!("- category: {}\n", category));
+        }
+        if let Some(code) = &self.vendor_code {
+            out.push_str(&format!("- vendor code: {}\n", code));
+        }
+        out.push_str(&format!("- message: {}\n", self.message));
+
+        let names: Vec<String> = [
+            ("constraint", &self.constraint),
+            ("table", &self.table),
+            ("column", &self.column),
+        ]
+        .into_iter()
+        .filter_map(|(label, value)| value.as_ref().map(|v| format!("{} {}", label, v)))
+        .collect();
+        if !names.is_empty() {
+            out.push_str(&format!("- involves {}\n", names.join(", ")));
+        }
+
+        if let Some(detail) = &self.detail {
+            out.push_str(&format!("- detail: {}\n", detail));
+        }
+        if let Some(hint) = &self.hint {
+            out.push_str(&format!("- hint: {}\n", hint));
+        }
+        if let Some(statement) = self.statement_with_caret() {
+            out.push_str("- statement:\n");
+            for line in statement.lines() {
+                out.push_str(&format!("    {}\n", line));
+            }
+        }
+        out.trim_end().to_string()
+    }
+}
+
+/// Human category for a SQLSTATE class (its first two characters)
+pub fn sqlstate_category(sqlstate: &str) -> Option<&'static str> {
+    Some(match sqlstate.get(..2)? {
+        "01" => "warning",
+        "02" => "no data",
+        "08" => "connection exception",
+        "0A" => "feature not supported",
+        "21" => "cardinality violation",
+        "22" => "data exception (invalid or out-of-range value)",
+        "23" => "integrity constraint violation",
+        "24" => "invalid cursor state",
+        "25" => "invalid transaction state",
+        "28" => "invalid authorization (login or password rejected)",
+        "2B" | "2D" => "transaction termination error",
+        "34" => "invalid cursor name",
+        "3D" => "invalid catalog name (database does not exist)",
+        "3F" => "invalid schema name",
+        "40" => "transaction rollback (serialization failure or deadlock; retry the transaction)",
+        "42" => "syntax error or access rule violation",
+        "44" => "WITH CHECK OPTION violation",
+        "53" => "insufficient resources (disk, memory or connections)",
+        "54" => "program limit exceeded",
+        "55" => "object not in prerequisite state (e.g. locked)",
+        "57" => "operator intervention (query cancelled or server shutting down)",
+        "58" => "system error outside the database",
+        "HY" => "general driver or server error",
+        "P0" => "PL/pgSQL error",
+        "XX" => "internal database error",
+        _ => return None,
+    })
+}
+
+/// PostgreSQL condition name for common SQLSTATEs
+pub fn sqlstate_condition(sqlstate: &str) -> Option<&'static str> {
+    Some(match sqlstate {
+        "08001" => "sqlclient_unable_to_establish_sqlconnection",
+        "08006" => "connection_failure",
+        "22001" => "string_data_right_truncation",
+        "22003" => "numeric_value_out_of_range",
+        "22007" => "invalid_datetime_format",
+        "22012" => "division_by_zero",
+        "22P02" => "invalid_text_representation",
+        "23000" => "integrity_constraint_violation",
+        "23502" => "not_null_violation",
+        "23503" => "foreign_key_violation",
+        "23505" => "unique_violation",
+        "23514" => "check_violation",
+        "25P02" => "in_failed_sql_transaction",
+        "28000" => "invalid_authorization_specification",
+        "28P01" => "invalid_password",
+        "3D000" => "invalid_catalog_name",
+        "3F000" => "invalid_schema_name",
+        "40001" => "serialization_failure",
+        "40P01" => "deadlock_detected",
+        "42000" => "syntax_error_or_access_rule_violation",
+        "42501" => "insufficient_privilege",
+        "42601" => "syntax_error",
+        "42703" => "undefined_column",
+        "42702" => "ambiguous_column",
+        "42803" => "grouping_error",
+        "42804" => "datatype_mismatch",
+        "42883" => "undefined_function",
+        "42P01" => "undefined_table",
+        "42P07" => "duplicate_table",
+        "42S02" => "base_table_not_found",
+        "42S22" => "column_not_found",
+        "53300" => "too_many_connections",
+        "57014" => "query_canceled",
+        _ => return None,
+    })
+}
+
+/// MySQL error numbers: (ER_ name, SQLSTATE)
+fn mysql_errno(errno: &str) -> Option<(&'static str, &'static str)> {
+    Some(match errno {
+        "1045" => ("ER_ACCESS_DENIED_ERROR", "28000"),
+        "1048" => ("ER_BAD_NULL_ERROR", "23000"),
+        "1049" => ("ER_BAD_DB_ERROR", "42000"),
+        "1054" => ("ER_BAD_FIELD_ERROR", "42S22"),
+        "1062" => ("ER_DUP_ENTRY", "23000"),
+        "1064" => ("ER_PARSE_ERROR", "42000"),
+        "1146" => ("ER_NO_SUCH_TABLE", "42S02"),
+        "1205" => ("ER_LOCK_WAIT_TIMEOUT", "HY000"),
+        "1213" => ("ER_LOCK_DEADLOCK", "40001"),
+        "1364" => ("ER_NO_DEFAULT_FOR_FIELD", "HY000"),
+        "1406" => ("ER_DATA_TOO_LONG", "22001"),
+        "1451" => ("ER_ROW_IS_REFERENCED_2", "23000"),
+        "1452" => ("ER_NO_REFERENCED_ROW_2", "23000"),
+        _ => return None,
+    })
+}
+
+/// Prisma error codes: (meaning, equivalent SQLSTATE)
+fn prisma_code(code: &str) -> Option<(&'static str, Option<&'static str>)> {
+    Some(match code {
+        "P1000" => ("authentication failed", Some("28000")),
+        "P1001" => ("can't reach the database server", Some("08001")),
+        "P1003" => ("database does not exist", Some("3D000")),
+        "P2000" => ("value too long for column", Some("22001")),
+        "P2002" => ("unique constraint failed", Some("23505")),
+        "P2003" => ("foreign key constraint failed", Some("23503")),
+        "P2011" => ("null constraint violation", Some("23502")),
+        "P2021" => ("table does not exist", Some("42P01")),
+        "P2022" => ("column does not exist", Some("42703")),
+        "P2025" => ("record to update or delete was not found", None),
+        _ => return None,
+    })
+}
+
+/// SQLSTATE implied by well-known messages, for output that doesn't print one
+fn infer_sqlstate(message: &str) -> Option<&'static str> {
+    let lower = message.to_lowercase();
+    let rules: [(&str, &str); 18] = [
+        ("duplicate key value violates unique constraint", "23505"),
+        ("unique constraint failed", "23505"),
+        ("duplicate entry", "23000"),
+        ("violates foreign key constraint", "23503"),
+        ("foreign key constraint fail", "23503"),
+        ("violates not-null constraint", "23502"),
+        ("not null constraint failed", "23502"),
+        ("violates check constraint", "23514"),
+        ("check constraint failed", "23514"),
+        ("does not exist", ""),
+        ("no such table", "42P01"),
+        ("no such column", "42703"),
+        ("syntax error", "42601"),
+        ("deadlock detected", "40P01"),
+        ("permission denied for", "42501"),
+        ("password authentication failed", "28P01"),
+        ("too many connections", "53300"),
+        ("canceling statement", "57014"),
+    ];
+    for (needle, code) in rules {
+        if !lower.contains(needle) {
+            continue;
+        }
+        if !code.is_empty() {
+            return Some(code);
+        }
+        // "does not exist" depends on what doesn't
+        return if lower.starts_with("column") || lower.contains(" column \"") {
+            Some("42703")
+        } else if lower.starts_with("relation") || lower.contains("relation \"") {
+            Some("42P01")
+        } else if lower.starts_with("function") {
+            Some("42883")
+        } else if lower.starts_with("database") {
+            Some("3D000")
+        } else {
+            None
+        };
+    }
+    None
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    marker: Regex,
+    sqlalchemy: Regex,
+    pg_message: Regex,
+    mysql_client: Regex,
+    mysql_code: Regex,
+    driver_exception: Regex,
+    sqlite_message: Regex,
+    node_error: Regex,
+    sqlstate: Regex,
+    quoted_field: Regex,
+    errno: Regex,
+    prisma_code: Regex,
+    sqlite_code: Regex,
+    pg_line: Regex,
+    at_character: Regex,
+    mysql_near: Regex,
+    constraint: Regex,
+    table: Regex,
+    column: Regex,
+    qualified_column: Regex,
+    key_detail: Regex,
+    prisma_fields: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        marker: Regex::new(
+            &[
+                r"(?m)SQLSTATE[\s\[:=]*[0-9A-Z]{5}",
+                r"(?:^|\s)(?:ERROR|FATAL):  \S",
+                r"^ERROR \d{4} \([0-9A-Z]{5}\)",
+                r"\bER_[A-Z0-9_]{3,}\b",
+                r"sqlite3\.\w+Error:",
+                r"SQLITE_[A-Z]+",
+                r"SQLite3::\w+",
+                r"Parse error: near",
+                r"psycopg2?\.errors\.\w+",
+                r"asyncpg\.exceptions\.\w+",
+                r"PG::\w+:",
+                r"Mysql2::Error",
+                r"pymysql\.err\.\w+",
+                r"MySQLdb\.\w+",
+                r"sqlalchemy\.exc\.\w+Error",
+                r"PrismaClient\w*Error",
+                r"ActiveRecord::(?:RecordNotUnique|StatementInvalid|InvalidForeignKey|NotNullViolation|ValueTooLong|Deadlocked|LockWaitTimeout|NoDatabaseError|ConnectionNotEstablished)",
+                r"severity: 'ERROR'",
+            ]
+            .join("|"),
+        )
+        .unwrap(),
+        sqlalchemy: Regex::new(r"sqlalchemy\.exc\.(\w+): \(([\w.:]+)\) (.+)$").unwrap(),
+        pg_message: Regex::new(r"(?:^|\s)(?:ERROR|FATAL|PANIC):\s+(?:([0-9A-Z]{5}): )?(.+)$")
+            .unwrap(),
+        mysql_client: Regex::new(r"^ERROR (\d{4}) \(([0-9A-Z]{5})\)(?: at line \d+)?: (.+)$")
+            .unwrap(),
+        mysql_code: Regex::new(r"\b(ER_[A-Z0-9_]+): (.+)$").unwrap(),
+        driver_exception: Regex::new(
+            r"((?:psycopg2?\.errors|asyncpg\.exceptions|pymysql\.err|MySQLdb(?:\._exceptions)?|sqlite3|SQLite3|PG|Mysql2|ActiveRecord)(?:\.|::)\w+)(?::\s*(.*))?$",
+        )
+        .unwrap(),
+        sqlite_message: Regex::new(
+            r"(?:sqlite3\.\w+Error|SQLite3::\w+Exception|SqliteError|Parse error|Runtime error|Error)(?: \(code \d+\))?: (.+)$",
+        )
+        .unwrap(),
+        node_error: Regex::new(r"^(?:error|Error): (.+)$").unwrap(),
+        sqlstate: Regex::new(r"SQLSTATE[\s\[:=]*([0-9A-Z]{5})|sqlState: '([0-9A-Z]{5})'")
+            .unwrap(),
+        quoted_field: Regex::new(
+            r"^\s*(code|constraint|table|column|position|detail|hint): '([^']*)',?$",
+        )
+        .unwrap(),
+        errno: Regex::new(r"(?:^ERROR |errno: |\()(\d{4})\b").unwrap(),
+        prisma_code: Regex::new(r"\b(P[0-9]{4})\b").unwrap(),
+        sqlite_code: Regex::new(r"\b(SQLITE_[A-Z_]+)\b").unwrap(),
+        pg_line: Regex::new(r"^(LINE (\d+): )(.*)$").unwrap(),
+        at_character: Regex::new(r"at character (\d+)").unwrap(),
+        mysql_near: Regex::new(r"near '(.*)' at line (\d+)").unwrap(),
+        constraint: Regex::new(
+            r#"constraint "([^"]+)"|for key '([^']+)'|CONSTRAINT `([^`]+)`|constraint: '([^']+)'"#,
+        )
+        .unwrap(),
+        table: Regex::new(
+            r#"relation "([^"]+)"|on table "([^"]+)"|Table '([^']+)' doesn't exist|no such table: (\S+)|table: '([^']+)'|REFERENCES `([^`]+)`"#,
+        )
+        .unwrap(),
+        column: Regex::new(
+            r#"column "([^"]+)"|Unknown column '([^']+)'|no such column: (\S+)|Column '([^']+)' cannot be null|column: '([^']+)'"#,
+        )
+        .unwrap(),
+        qualified_column: Regex::new(r"constraint failed: (\w+)\.(\w+)").unwrap(),
+        key_detail: Regex::new(r"Key \((.+?)\)=\(").unwrap(),
+        prisma_fields: Regex::new(r"fields: \(`([^`]+)`\)").unwrap(),
+    })
+}
+
+/// Check whether input contains a database error
+pub fn is_database_error(input: &str) -> bool {
+    patterns().marker.is_match(input)
+}
+
+/// Parse a PostgreSQL, MySQL or SQLite error, possibly wrapped by an ORM
+pub fn parse_database_error(input: &str) -> Option<DatabaseError> {
+    let input = strip_ansi(input);
+    if !is_database_error(&input) {
+        return None;
+    }
+    let p = patterns();
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+
+    let mut error = DatabaseError {
+        engine: detect_engine(&input),
+        orm: detect_orm(&input),
+        ..Default::default()
+    };
+
+    extract_message(&mut error, &lines);
+    if error.message.is_empty() {
+        return None;
+    }
+
+    // Codes
+    if let Some(caps) = lines.iter().find_map(|l| p.sqlstate.captures(l)) {
+        error.sqlstate = caps
+            .get(1)
+            .or_else(|| caps.get(2))
+            .map(|m| m.as_str().to_string());
+    }
+    if error.engine == Some(DatabaseEngine::MySql) {
+        let errno = lines
+            .iter()
+            .find_map(|l| p.errno.captures(l))
+            .map(|c| c[1].to_string());
+        if let Some((name, sqlstate)) = errno.as_deref().and_then(mysql_errno) {
+            error.sqlstate.get_or_insert_with(|| sqlstate.to_string());
+            error
+                .vendor_code
+                .get_or_insert_with(|| format!("{} ({})", name, errno.unwrap_or_default()));
+        }
+    }
+    if error.engine == Some(DatabaseEngine::Sqlite) {
+        if let Some(caps) = p.sqlite_code.captures(&input) {
+            error.vendor_code.get_or_insert_with(|| caps[1].to_string());
+        }
+    }
+    if error.orm == Some(Orm::Prisma) {
+        if let Some(code) = p.prisma_code.captures(&input).map(|c| c[1].to_string()) {
+            if let Some((meaning, _)) = prisma_code(&code) {
+                error.vendor_code = Some(format!("{} ({})", code, meaning));
+            } else {
+                error.vendor_code = Some(code);
+            }
+        }
+    }
+
+    // Node drivers dump the error object's fields
+    for line in &lines {
+        if let Some(caps) = p.quoted_field.captures(line) {
+            let value = caps[2].to_string();
+            match &caps[1] {
+                "code" if value.len() == 5 && error.engine == Some(DatabaseEngine::Postgres) => {
+                    error.sqlstate.get_or_insert(value);
+                }
+                "position" => {
+                    error.position = error.position.or_else(|| value.parse().ok());
+                }
+                "detail" => {
+                    error.detail.get_or_insert(value);
+                }
+                "hint" => {
+                    error.hint.get_or_insert(value);
+                }
+                _ => {}
+            }
+        }
+    }
+
+    // DETAIL/HINT lines, prefixed like the ERROR line
+    for line in &lines {
+        if let Some((_, detail)) = line.split_once("DETAIL:") {
+            error
+                .detail
+                .get_or_insert_with(|| detail.trim().to_string());
+        } else if let Some((_, hint)) = line.split_once("HINT:") {
+            error.hint.get_or_insert_with(|| hint.trim().to_string());
+        }
+    }
+
+    extract_statement(&mut error, &lines);
+    extract_names(&mut error, &input);
+
+    // Categories come from the printed SQLSTATE, or one implied by the message
+    let effective = error
+        .sqlstate
+        .clone()
+        .or_else(|| {
+            error
+                .vendor_code
+                .as_deref()
+                .and_then(|c| prisma_code(c.split(' ').next().unwrap_or(c)))
+                .and_then(|(_, s)| s)
+                .map(str::to_string)
+        })
+        .or_else(|| infer_sqlstate(&error.message).map(str::to_string));
+    if let Some(code) = effective {
+        error.condition = sqlstate_condition(&code)
+            .or_else(|| infer_sqlstate(&error.message).and_then(sqlstate_condition))
+            .map(str::to_string);
+        error.category = sqlstate_category(&code).map(str::to_string);
+    }
+
+    Some(error)
+}
+
+fn detect_engine(input: &str) -> Option<DatabaseEngine> {
+    let lower = input.to_lowercase();
+    if lower.contains("psycopg")
+        || input.contains("PG::")
+        || lower.contains("asyncpg")
+        || lower.contains("postgres")
+        || input.contains("ERROR:  ")
+        || input.contains("relation \"")
+        || input.contains("severity: 'ERROR'")
+        || input.contains("(SQLSTATE ")
+    {
+        Some(DatabaseEngine::Postgres)
+    } else if input.contains("ER_")
+        || input.contains("Mysql2")
+        || lower.contains("pymysql")
+        || input.contains("MySQLdb")
+        || lower.contains("mysql")
+        || lower.contains("mariadb")
+        || patterns()
+            .mysql_client
+            .is_match(input.lines().next().unwrap_or_default())
+        || input.lines().any(|l| patterns().mysql_client.is_match(l))
+    {
+        Some(DatabaseEngine::MySql)
+    } else if lower.contains("sqlite") || input.contains("Parse error: near") {
+        Some(DatabaseEngine::Sqlite)
+    } else {
+        None
+    }
+}
+
+fn detect_orm(input: &str) -> Option<Orm> {
+    if input.contains("sqlalchemy.exc.") {
+        Some(Orm::SqlAlchemy)
+    } else if input.contains("PrismaClient") || input.contains("prisma.") {
+        Some(Orm::Prisma)
+    } else if input.contains("ActiveRecord::") {
+        Some(Orm::ActiveRecord)
+    } else {
+        None
+    }
+}
+
+fn extract_message(error: &mut DatabaseError, lines: &[&str]) {
+    let p = patterns();
+
+    if let Some(caps) = lines.iter().find_map(|l| p.sqlalchemy.captures(l)) {
+        error.exception = Some(caps[2].to_string());
+        error.message = caps[3].to_string();
+        return;
+    }
+    if let Some(caps) = lines.iter().find_map(|l| p.mysql_client.captures(l)) {
+        error.vendor_code = mysql_errno(&caps[1])
+            .map(|(name, _)| format!("{} ({})", name, &caps[1]))
+            .or_else(|| Some(caps[1].to_string()));
+        error.sqlstate = Some(caps[2].to_string());
+        error.message = caps[3].to_string();
+        return;
+    }
+    if let Some(caps) = lines.iter().find_map(|l| p.pg_message.captures(l)) {
+        error.sqlstate = caps.get(1).map(|m| m.as_str().to_string());
+        error.message = caps[2].trim().to_string();
+        if let Some(exception) = lines
+            .iter()
+            .find_map(|l| p.driver_exception.captures(l))
+            .map(|c| c[1].to_string())
+        {
+            error.exception = Some(exception);
+        }
+        return;
+    }
+    if let Some(caps) = lines.iter().find_map(|l| p.mysql_code.captures(l)) {
+        error.vendor_code = Some(caps[1].to_string());
+        error.message = caps[2].to_string();
+        return;
+    }
+    if error.orm == Some(Orm::Prisma) {
+        // The message follows the "Invalid `prisma.x.y()` invocation:" line
+        if let Some(index) = lines.iter().position(|l| l.contains("invocation")) {
+            if let Some(message) = lines[index + 1..].iter().find(|l| !l.trim().is_empty()) {
+                error.exception = lines.iter().find_map(|l| {
+                    l.split_whitespace()
+                        .find(|w| w.starts_with("PrismaClient"))
+                        .map(|w| w.trim_end_matches(':').to_string())
+                });
+                error.message = message.trim().to_string();
+                return;
+            }
+        }
+    }
+    if let Some(caps) = lines.iter().find_map(|l| p.driver_exception.captures(l)) {
+        if let Some(message) = caps.get(2).filter(|m| !m.as_str().is_empty()) {
+            error.exception = Some(caps[1].to_string());
+            error.message = message.as_str().to_string();
+            return;
+        }
+    }
+    if error.engine == Some(DatabaseEngine::Sqlite) {
+        if let Some(caps) = lines.iter().find_map(|l| p.sqlite_message.captures(l)) {
+            error.message = caps[1].to_string();
+            return;
+        }
+    }
+    if let Some(caps) = lines.iter().find_map(|l| p.node_error.captures(l)) {
+        error.message = caps[1].to_string();
+    }
+}
+
+fn extract_statement(error: &mut DatabaseError, lines: &[&str]) {
+    let p = patterns();
+
+    for (index, line) in lines.iter().enumerate() {
+        // psql: "LINE 1: SELECT foo FROM users;" followed by a caret line
+        if let Some(caps) = p.pg_line.captures(line) {
+            error.statement = Some(caps[3].to_string());
+            error.statement_line = caps[2].parse().ok();
+            if let Some(caret) = lines.get(index + 1).and_then(|l| l.find('^')) {
+                let prefix = caps[1].len();
+                error.position = Some((caret.saturating_sub(prefix) + 1) as u32);
+            }
+            return;
+        }
+
+        // sqlite3 CLI: the statement, then "^--- error here"
+        if let Some(caret) = line.find("^--- error here") {
+            if let Some(statement) = index.checked_sub(1).map(|i| lines[i]) {
+                let indent = statement.len() - statement.trim_start().len();
+                error.statement = Some(statement.trim().to_string());
+                error.position = Some((caret.saturating_sub(indent) + 1) as u32);
+            }
+            return;
+        }
+
+        // SQLAlchemy: "[SQL: ...]", possibly spanning lines
+        if let Some(rest) = line.trim_start().strip_prefix("[SQL: ") {
+            let mut statement = vec![rest.to_string()];
+            if !rest.ends_with(']') {
+                for next in &lines[index + 1..] {
+                    statement.push(next.to_string());
+                    if next.ends_with(']') {
+                        break;
+                    }
+                }
+            }
+            let statement = statement.join("\n");
+            error.statement = Some(statement.trim_end_matches(']').trim().to_string());
+            break;
+        }
+
+        // Server logs and node drivers
+        if let Some((_, statement)) = line.split_once("STATEMENT:") {
+            error.statement = Some(statement.trim().to_string());
+            break;
+        }
+        if let Some(statement) = line
+            .trim_start()
+            .strip_prefix("sql: '")
+            .map(|s| s.trim_end_matches(',').trim_end_matches('\''))
+        {
+            error.statement = Some(statement.to_string());
+            break;
+        }
+    }
+
+    // Character offsets into the whole statement become line and column
+    let Some(statement) = error.statement.clone() else {
+        return;
+    };
+    let offset = error
+        .position
+        .take()
+        .or_else(|| {
+            lines
+                .iter()
+                .find_map(|l| p.at_character.captures(l))
+                .and_then(|c| c[1].parse().ok())
+        })
+        .or_else(|| {
+            let caps = p.mysql_near.captures(&error.message)?;
+            let near = caps[1].lines().next().unwrap_or_default();
+            (!near.is_empty())
+                .then(|| statement.find(near))
+                .flatten()
+                .map(|i| statement[..i].chars().count() as u32 + 1)
+        });
+    if let Some(offset) = offset {
+        let mut remaining = offset;
+        for (number, line) in statement.lines().enumerate() {
+            let len = line.chars().count() as u32 + 1;
+            if remaining <= len {
+                error.statement_line = Some(number as u32 + 1);
+                error.position = Some(remaining);
+                return;
+            }
+            remaining -= len;
+        }
+    }
+}
+
+fn extract_names(error: &mut DatabaseError, input: &str) {
+    let p = patterns();
+    let first = |re: &Regex, text: &str| {
+        re.captures(text).and_then(|caps| {
+            caps.iter()
+                .skip(1)
+                .flatten()
+                .next()
+                .map(|m| m.as_str().to_string())
+        })
+    };
+
+    // The message names things most reliably; fall back to the whole output
+    let sources = [error.message.clone(), input.to_string()];
+    for text in &sources {
+        if error.constraint.is_none() {
+            error.constraint = first(&p.constraint, text);
+        }
+        if let Some(caps) = p.qualified_column.captures(text) {
+            error.table.get_or_insert_with(|| caps[1].to_string());
+            error.column.get_or_insert_with(|| caps[2].to_string());
+        }
+        if error.table.is_none() {
+            error.table = first(&p.table, text);
+        }
+        if error.column.is_none() {
+            error.column = first(&p.column, text)
+                .or_else(|| p.key_detail.captures(text).map(|c| c[1].to_string()))
+                .or_else(|| p.prisma_fields.captures(text).map(|c| c[1].to_string()));
+        }
+    }
+
+    // MySQL qualifies tables as db.table
+    if let Some(table) = &error.table {
+        if let Some((_, name)) = table.rsplit_once('.') {
+            error.table = Some(name.trim_matches('`').to_string());
+        }
+    }
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parses_psql_undefined_column() {
+        let input = "\
+ERROR:  column \"nmae\" does not exist
+LINE 1: SELECT nmae FROM users;
+               ^
+HINT:  Perhaps you meant to reference the column \"users.name\".";
+
+        assert!(is_database_error(input));
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.engine, Some(DatabaseEngine::Postgres));
+        assert_eq!(error.message, "column \"nmae\" does not exist");
+        assert!(error.sqlstate.is_none());
+        assert_eq!(error.condition.as_deref(), Some("undefined_column"));
+        assert_eq!(
+            error.category.as_deref(),
+            Some("syntax error or access rule violation")
+        );
+        assert_eq!(error.statement.as_deref(), Some("SELECT nmae FROM users;"));
+        assert_eq!(error.statement_line, Some(1));
+        assert_eq!(error.position, Some(8));
+        assert_eq!(error.column.as_deref(), Some("nmae"));
+        assert!(error.hint.as_deref().unwrap().contains("users.name"));
+
+        let summary = error.prompt_summary();
+        assert!(summary.contains("- condition: undefined_column"));
+        assert!(summary.contains("    SELECT nmae FROM users;\n           ^ (column 8)"));
+    }
+
+    #[test]
+    fn test_parses_sqlalchemy_unique_violation() {
+        let input = "\
+sqlalchemy.exc.IntegrityError: (psycopg2.errors.UniqueViolation) duplicate key value violates unique constraint \"users_email_key\"
+DETAIL:  Key (email)=(ann@example.com) already exists.
+
+[SQL: INSERT INTO users (email) VALUES (%(email)s) RETURNING users.id]
+[parameters: {'email': 'ann@example.com'}]
+(Background on this error at: https://sqlalche.me/e/20/gkpj)";
+
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.engine, Some(DatabaseEngine::Postgres));
+        assert_eq!(error.orm, Some(Orm::SqlAlchemy));
+        assert_eq!(
+            error.exception.as_deref(),
+            Some("psycopg2.errors.UniqueViolation")
+        );
+        assert_eq!(error.condition.as_deref(), Some("unique_violation"));
+        assert_eq!(
+            error.category.as_deref(),
+            Some("integrity constraint violation")
+        );
+        assert_eq!(error.constraint.as_deref(), Some("users_email_key"));
+        assert_eq!(error.column.as_deref(), Some("email"));
+        assert_eq!(
+            error.detail.as_deref(),
+            Some("Key (email)=(ann@example.com) already exists.")
+        );
+        assert_eq!(
+            error.statement.as_deref(),
+            Some("INSERT INTO users (email) VALUES (%(email)s) RETURNING users.id")
+        );
+    }
+
+    #[test]
+    fn test_parses_mysql_client_error() {
+        let input =
+            "ERROR 1062 (23000) at line 3: Duplicate entry 'ann@example.com' for key 'users.email'";
+
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.engine, Some(DatabaseEngine::MySql));
+        assert_eq!(error.sqlstate.as_deref(), Some("23000"));
+        assert_eq!(error.vendor_code.as_deref(), Some("ER_DUP_ENTRY (1062)"));
+        assert_eq!(error.constraint.as_deref(), Some("users.email"));
+        assert_eq!(
+            error.category.as_deref(),
+            Some("integrity constraint violation")
+        );
+    }
+
+    #[test]
+    fn test_parses_node_mysql_syntax_error() {
+        let input = "\
+Error: ER_PARSE_ERROR: You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'FORM users' at line 1
+    at Query.Sequence._packetToError (/app/node_modules/mysql/lib/protocol/sequences/Sequence.js:47:14)
+  code: 'ER_PARSE_ERROR',
+  errno: 1064,
+  sqlMessage: \"You have an error in your SQL syntax\",
+  sqlState: '42000',
+  sql: 'SELECT * FORM users'";
+
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.engine, Some(DatabaseEngine::MySql));
+        assert_eq!(error.vendor_code.as_deref(), Some("ER_PARSE_ERROR"));
+        assert_eq!(error.sqlstate.as_deref(), Some("42000"));
+        assert_eq!(error.statement.as_deref(), Some("SELECT * FORM users"));
+        assert_eq!(error.statement_line, Some(1));
+        assert_eq!(error.position, Some(10));
+    }
+
+    #[test]
+    fn test_parses_sqlite_errors() {
+        let input = "sqlite3.IntegrityError: UNIQUE constraint failed: users.email";
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.engine, Some(DatabaseEngine::Sqlite));
+        assert_eq!(error.message, "UNIQUE constraint failed: users.email");
+        assert_eq!(error.table.as_deref(), Some("users"));
+        assert_eq!(error.column.as_deref(), Some("email"));
+        assert_eq!(error.condition.as_deref(), Some("unique_violation"));
+
+        let input = "\
+Parse error: near \"SELEC\": syntax error
+  SELEC * FROM t;
+  ^--- error here";
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.engine, Some(DatabaseEngine::Sqlite));
+        assert_eq!(error.statement.as_deref(), Some("SELEC * FROM t;"));
+        assert_eq!(error.position, Some(1));
+        assert_eq!(error.condition.as_deref(), Some("syntax_error"));
+    }
+
+    #[test]
+    fn test_parses_prisma_known_request_error() {
+        let input = "\
+PrismaClientKnownRequestError:
+Invalid `prisma.user.create()` invocation:
+
+
+Unique constraint failed on the fields: (`email`)
+    at Object.request (/app/node_modules/@prisma/client/runtime/library.js:123:17) {
+  code: 'P2002',
+  clientVersion: '5.7.0',
+  meta: { target: [ 'email' ] }
+}";
+
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.orm, Some(Orm::Prisma));
+        assert_eq!(
+            error.message,
+            "Unique constraint failed on the fields: (`email`)"
+        );
+        assert_eq!(
+            error.vendor_code.as_deref(),
+            Some("P2002 (unique constraint failed)")
+        );
+        assert_eq!(error.column.as_deref(), Some("email"));
+        assert_eq!(error.condition.as_deref(), Some("unique_violation"));
+    }
+
+    #[test]
+    fn test_parses_activerecord_foreign_key_violation() {
+        let input = "\
+ActiveRecord::InvalidForeignKey: PG::ForeignKeyViolation: ERROR:  insert or update on table \"orders\" violates foreign key constraint \"fk_rails_f868b47f6a\"
+DETAIL:  Key (user_id)=(42) is not present in table \"users\".";
+
+        let error = parse_database_error(input).unwrap();
+        assert_eq!(error.orm, Some(Orm::ActiveRecord));
+        assert_eq!(error.engine, Some(DatabaseEngine::Postgres));
+        assert_eq!(
+            error.exception.as_deref(),
+            Some("ActiveRecord::InvalidForeignKey")
+        );
+        assert_eq!(error.constraint.as_deref(), Some("fk_rails_f868b47f6a"));
+        assert_eq!(error.table.as_deref(), Some("orders"));
+        assert_eq!(error.column.as_deref(), Some("user_id"));
+        assert_eq!(error.condition.as_deref(), Some("foreign_key_violation"));
+    }
+
+    #[test]
+    fn test_sqlstate_category() {
+        assert_eq!(sqlstate_category("08006"), Some("connection exception"));
+        assert_eq!(sqlstate_condition("40P01"), Some("deadlock_detected"));
+        assert_eq!(sqlstate_category("ZZ000"), None);
+    }
+}
@@ -0,0 +1,527 @@
+//! Layered error detection rules for watch and tee mode.
+//!
+//! Whether a line starts an error is decided by layers, checked from most to
+//! least specific: the `--pattern` flag, per-source rule sets, the include
+//! and exclude lists in the `[detect]` config section, named presets
+//! (`rust`, `node`, `nginx`, ...), and finally the built-in heuristics. The
+//! first layer with a matching rule decides, and within a layer an exclude
+//! beats an include. So noise like "Failed: 0" can be silenced, e.g. with the
+//! `zero-counts` preset, without losing the defaults. `why detect
+//! --explain-line` shows which rule matched.
+
+use anyhow::{bail, Context, Result};
+use regex::Regex;
+use serde::Serialize;
+
+use crate::config::DetectConfig;
+
+// ============================================================================
+// Presets
+// ============================================================================
+
+/// A named set of include and exclude patterns for one tool or runtime
+#[derive(Debug)]
+pub struct Preset {
+    pub name: &'static str,
+    pub include: &'static [&'static str],
+    pub exclude: &'static [&'static str],
+}
+
+/// Built-in presets, selected with `--preset` or `presets = [...]`
+pub const PRESETS: &[Preset] = &[
+    Preset {
+        name: "rust",
+        include: &[
+            r"^error(\[E\d+\])?: ",
+            r"^thread '.+' panicked at",
+            r"^test result: FAILED",
+        ],
+        exclude: &[
+            r"^\s*(Compiling|Checking|Finished|Fresh|Running|Downloaded|Downloading|Updating|Locking) ",
+            r"^test .+ \.\.\. ok$",
+            r"^test result: ok\.",
+        ],
+    },
+    Preset {
+        name: "node",
+        include: &[
+            r"^\w*Error( \[[A-Z_]+\])?: ",
+            r"^Uncaught ",
+            r"UnhandledPromiseRejection",
+            r"^npm ERR! ",
+            r"^\[nodemon\] app crashed",
+        ],
+        exclude: &[
+            r"^npm (WARN|notice) ",
+            r"^\(node:\d+\) \w*Warning: ",
+            r"^\[nodemon\] (starting|restarting|watching|to restart)",
+        ],
+    },
+    Preset {
+        name: "python",
+        include: &[
+            r"^Traceback \(most recent call last\):",
+            r"^\w+(Error|Exception)(: |$)",
+            r"^\s*(ERROR|CRITICAL)[: ]",
+        ],
+        exclude: &[r"^\s*(DEBUG|INFO|WARNING)[: ]", r"\w+Warning: "],
+    },
+    Preset {
+        name: "go",
+        include: &[
+            r"^panic: ",
+            r"^fatal error: ",
+            r"^--- FAIL: ",
+            r"^FAIL\s",
+            r"^goroutine \d+ \[running\]:",
+        ],
+        exclude: &[
+            r"^(ok|PASS)\b",
+            r"^--- (PASS|SKIP): ",
+            r"^=== (RUN|PAUSE|CONT) ",
+        ],
+    },
+    Preset {
+        name: "java",
+        include: &[
+            r#"^Exception in thread ""#,
+            r"^Caused by: ",
+            r"^[\w$.]+(Exception|Error)(: |$)",
+            r"\s(ERROR|FATAL|SEVERE)\s",
+        ],
+        exclude: &[r"\s(INFO|DEBUG|TRACE)\s"],
+    },
+    Preset {
+        name: "nginx",
+        include: &[r"\[(error|crit|alert|emerg)\]", r#"" 5\d\d \d+"#],
+        exclude: &[r"\[(warn|notice|info|debug)\]", r#"" [1-4]\d\d \d+"#],
+    },
+    Preset {
+        name: "postgres",
+        include: &[r"\b(ERROR|FATAL|PANIC): "],
+        exclude: &[r"\b(LOG|DETAIL|HINT|STATEMENT|CONTEXT|NOTICE|WARNING|INFO|DEBUG\d?): "],
+    },
+    // Summaries like "0 errors" or "error_count=0" that the heuristics catch
+    Preset {
+        name: "zero-counts",
+        include: &[],
+        exclude: &[
+            r"(?i)\b(0|no) (errors?|failures?|failed)\b",
+            r"(?i)\b(errors?|failures?|failed)(_count)?\s*[:=]\s*0\b",
+        ],
+    },
+];
+
+/// Look up a preset by name (case-insensitive)
+pub fn preset(name: &str) -> Option<&'static Preset> {
+    PRESETS.iter().find(|p| p.name.eq_ignore_ascii_case(name))
+}
+
+// ============================================================================
+// Built-in Heuristics
+// ============================================================================
+
+/// Line prefixes (lowercased) that start an error
+const BUILTIN_STARTS: &[&str] = &[
+    "error",
+    "e:",
+    "err:",
+    "fatal",
+    "panic",
+    "exception",
+    "traceback",
+];
+
+/// Substrings (lowercased) that mark an error anywhere in a line
+const BUILTIN_CONTAINS: &[&str] = &[
+    "error:",
+    "error[e",
+    "exception:",
+    "failed:",
+    ": error:",
+    "panic:",
+    "segmentation fault",
+    "sigsegv",
+    "sigabrt",
+    "undefined reference",
+    "cannot find",
+    "not found",
+    "no such file",
+];
+
+/// Which built-in heuristic, if any, flags the line as an error
+fn builtin_heuristic(line: &str) -> Option<String> {
+    let lower = line.to_lowercase();
+
+    if let Some(prefix) = BUILTIN_STARTS.iter().find(|p| lower.starts_with(*p)) {
+        return Some(format!("starts with \"{}\"", prefix));
+    }
+    if let Some(needle) = BUILTIN_CONTAINS.iter().find(|n| lower.contains(*n)) {
+        return Some(format!("contains \"{}\"", needle));
+    }
+
+    let is_stack_trace = line.trim().starts_with("at ")
+        || line.contains("File \"")
+        || line.contains("at /")
+        || lower.contains("traceback (most recent call last)");
+    is_stack_trace.then(|| "stack trace frame".to_string())
+}
+
+// ============================================================================
+// Rules
+// ============================================================================
+
+/// Whether a rule marks lines as errors or rules them out
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum RuleKind {
+    Include,
+    Exclude,
+}
+
+/// A rule that matched a line
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct RuleMatch {
+    /// Layer the rule belongs to, e.g. "preset rust" or "builtin"
+    pub layer: String,
+    pub kind: RuleKind,
+    /// The pattern, or a description of the built-in heuristic
+    pub rule: String,
+}
+
+/// How a line was classified, for `why detect --explain-line`
+#[derive(Debug, Clone, Serialize)]
+pub struct LineVerdict {
+    pub line: String,
+    pub is_error: bool,
+    /// The rule that decided, if any matched
+    pub decided_by: Option<RuleMatch>,
+    /// Every rule that matched, in precedence order
+    pub matches: Vec<RuleMatch>,
+}
+
+/// One layer of include and exclude patterns
+#[derive(Debug, Clone)]
+struct Layer {
+    name: String,
+    include: Vec<Regex>,
+    exclude: Vec<Regex>,
+}
+
+impl Layer {
+    fn new<S: AsRef<str>>(name: String, include: &[S], exclude: &[S]) -> Result<Self> {
+        let compile = |patterns: &[S]| -> Result<Vec<Regex>> {
+            patterns
+                .iter()
+                .map(|p| {
+                    Regex::new(p.as_ref())
+                        .with_context(|| format!("Invalid {} pattern: {}", name, p.as_ref()))
+                })
+                .collect()
+        };
+        Ok(Self {
+            include: compile(include)?,
+            exclude: compile(exclude)?,
+            name,
+        })
+    }
+
+    fn is_empty(&self) -> bool {
+        self.include.is_empty() && self.exclude.is_empty()
+    }
+
+    /// Every rule in this layer matching the line, excludes first
+    fn matches(&self, line: &str) -> Vec<RuleMatch> {
+        let hits = |patterns: &[Regex], kind: RuleKind| -> Vec<RuleMatch> {
+            patterns
+                .iter()
+                .filter(|re| re.is_match(line))
+                .map(|re| RuleMatch {
+                    layer: self.name.clone(),
+                    kind,
+                    rule: re.as_str().to_string(),
+                })
+                .collect()
+        };
+        let mut matches = hits(&self.exclude, RuleKind::Exclude);
+        matches.extend(hits(&self.include, RuleKind::Include));
+        matches
+    }
+}
+
+/// Compiled detection rules, most specific layer first
+#[derive(Debug, Clone)]
+pub struct DetectionRules {
+    layers: Vec<Layer>,
+    /// Fall back to the built-in heuristics
+    builtin: bool,
+}
+
+impl Default for DetectionRules {
+    fn default() -> Self {
+        Self {
+            layers: Vec::new(),
+            builtin: true,
+        }
+    }
+}
+
+impl DetectionRules {
+    /// Rules for a bare `--pattern`, which replaces the built-in heuristics
+    pub fn from_pattern(pattern: Option<Regex>) -> Self {
+        match pattern {
+            Some(re) => Self {
+                layers: vec![Layer {
+                    name: "--pattern".to_string(),
+                    include: vec![re],
+                    exclude: Vec::new(),
+                }],
+                builtin: false,
+            },
+            None => Self::default(),
+        }
+    }
+
+    /// Build the layers for a watch source from config and command-line flags
+    pub fn build(
+        config: &DetectConfig,
+        source: &str,
+        pattern: Option<&str>,
+        presets: &[String],
+    ) -> Result<Self> {
+        let mut layers = Vec::new();
+        if let Some(pattern) = pattern {
+            layers.push(Layer::new("--pattern".to_string(), &[pattern], &[])?);
+        }
+
+        let mut preset_names: Vec<String> = presets.to_vec();
+        for rules in &config.sources {
+            let matcher = Regex::new(&rules.source)
+                .with_context(|| format!("Invalid detect source match: {}", rules.source))?;
+            if !matcher.is_match(source) {
+                continue;
+            }
+            layers.push(Layer::new(
+                format!("source {}", rules.source),
+                &rules.include,
+                &rules.exclude,
+            )?);
+            preset_names.extend(rules.presets.iter().cloned());
+        }
+
+        layers.push(Layer::new(
+            "config".to_string(),
+            &config.include,
+            &config.exclude,
+        )?);
+
+        preset_names.extend(config.presets.iter().cloned());
+        let mut seen: Vec<&'static str> = Vec::new();
+        for name in &preset_names {
+            let Some(found) = preset(name) else {
+                let available: Vec<&str> = PRESETS.iter().map(|p| p.name).collect();
+                bail!(
+                    "Unknown detection preset '{}' (available: {})",
+                    name,
+                    available.join(", ")
+                );
+            };
+            if seen.contains(&found.name) {
+                continue;
+            }
+            seen.push(found.name);
+            layers.push(Layer::new(
+                format!("preset {}", found.name),
+                found.include,
+                found.exclude,
+            )?);
+        }
+
+        layers.retain(|layer| !layer.is_empty());
+        Ok(Self {
+            layers,
+            builtin: config.builtin && pattern.is_none(),
+        })
+    }
+
+    /// Check if a line starts an error
+    pub fn is_error_line(&self, line: &str) -> bool {
+        for layer in &self.layers {
+            if layer.exclude.iter().any(|re| re.is_match(line)) {
+                return false;
+            }
+            if layer.include.iter().any(|re| re.is_match(line)) {
+                return true;
+            }
+        }
+        self.builtin && builtin_heuristic(line).is_some()
+    }
+
+    /// Classify a line and list every rule that matched it
+    pub fn explain(&self, line: &str) -> LineVerdict {
+        let mut matches: Vec<RuleMatch> = self
+            .layers
+            .iter()
+            .flat_map(|layer| layer.matches(line))
+            .collect();
+
+        if let Some(rule) = builtin_heuristic(line).filter(|_| self.builtin) {
+            matches.push(RuleMatch {
+                layer: "builtin".to_string(),
+                kind: RuleKind::Include,
+                rule,
+            });
+        }
+
+        // Layers are in precedence order and each lists excludes first
+        let decided_by = matches.first().cloned();
+        LineVerdict {
+            line: line.to_string(),
+            is_error: decided_by
+                .as_ref()
+                .is_some_and(|m| m.kind == RuleKind::Include),
+            decided_by,
+            matches,
+        }
+    }
+
+    /// Layer names in precedence order, for banners
+    pub fn describe(&self) -> String {
+        let mut names: Vec<&str> = self.layers.iter().map(|l| l.name.as_str()).collect();
+        if self.builtin {
+            names.push("builtin");
+        }
+        if names.is_empty() {
+            "none".to_string()
+        } else {
+            names.join(" + ")
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{generate_default_config, Config};
+
+    #[test]
+    fn test_builtin_rules_match_watch_heuristics() {
+        let rules = DetectionRules::default();
+        assert!(rules.is_error_line("error[E0425]: cannot find value `x`"));
+        assert!(rules.is_error_line("Failed: connection reset"));
+        assert!(rules.is_error_line("Failed: 0"));
+        assert!(!rules.is_error_line("Compiling foo v0.1.0"));
+
+        let verdict = rules.explain("Failed: 0");
+        assert!(verdict.is_error);
+        assert_eq!(verdict.matches.len(), 1);
+        assert_eq!(verdict.matches[0].rule, "contains \"failed:\"");
+    }
+
+    #[test]
+    fn test_zero_counts_preset_skips_summaries() {
+        let config = DetectConfig::default();
+        let presets = ["zero-counts".to_string()];
+        let rules = DetectionRules::build(&config, "-", None, &presets).unwrap();
+        assert!(rules.is_error_line("Failed: connection reset"));
+        assert!(!rules.is_error_line("Failed: 0"));
+        assert!(!rules.is_error_line("Build finished with 0 errors"));
+        assert!(!rules.is_error_line("metrics error_count=0 latency=3ms"));
+
+        let verdict = rules.explain("Failed: 0");
+        assert!(!verdict.is_error);
+        let decided_by = verdict.decided_by.unwrap();
+        assert_eq!(decided_by.layer, "preset zero-counts");
+        assert_eq!(decided_by.kind, RuleKind::Exclude);
+        assert_eq!(verdict.matches[1].rule, "contains \"failed:\"");
+    }
+
+    #[test]
+    fn test_config_layers_take_precedence() {
+        let config = DetectConfig {
+            include: vec!["^CRITICAL".to_string()],
+            exclude: vec!["healthcheck".to_string()],
+            ..DetectConfig::default()
+        };
+        let rules = DetectionRules::build(&config, "app.log", None, &[]).unwrap();
+        assert_eq!(rules.describe(), "config + builtin");
+        assert!(rules.is_error_line("CRITICAL disk full"));
+        assert!(!rules.is_error_line("healthcheck failed: timeout"));
+        assert!(rules.is_error_line("Error: ENOSPC"));
+
+        let verdict = rules.explain("CRITICAL disk full");
+        assert_eq!(
+            verdict.decided_by,
+            Some(RuleMatch {
+                layer: "config".to_string(),
+                kind: RuleKind::Include,
+                rule: "^CRITICAL".to_string(),
+            })
+        );
+
+        // --pattern replaces the heuristics and outranks the config layer
+        let rules = DetectionRules::build(&config, "app.log", Some("OOPS"), &[]).unwrap();
+        assert_eq!(rules.describe(), "--pattern + config");
+        assert!(rules.is_error_line("OOPS healthcheck"));
+        assert!(rules.is_error_line("CRITICAL disk full"));
+        assert!(!rules.is_error_line("Error: ENOSPC"));
+    }
+
+    #[test]
+    fn test_presets_and_source_rules() {
+        let config: Config = toml::from_str(
+            r#"
+[detect]
+presets = ["rust"]
+
+[[detect.sources]]
+match = "nginx"
+presets = ["nginx"]
+exclude = ["/favicon.ico"]
+"#,
+        )
+        .unwrap();
+        let config = config.detect;
+        assert!(config.builtin);
+
+        let rules = DetectionRules::build(&config, "/var/log/nginx/error.log", None, &[]).unwrap();
+        assert_eq!(
+            rules.describe(),
+            "source nginx + preset nginx + preset rust + builtin"
+        );
+        assert!(rules.is_error_line(
+            "2024/05/01 10:00:00 [error] 31#31: *7 connect() failed (111: Connection refused)"
+        ));
+        assert!(!rules.is_error_line("10.0.0.1 - - \"GET /missing HTTP/1.1\" 404 153 \"-\""));
+        assert!(rules.is_error_line("10.0.0.1 - - \"GET /api HTTP/1.1\" 502 157 \"-\""));
+        assert!(!rules.is_error_line("[warn] 31#31: upstream response is buffered"));
+
+        let rules = DetectionRules::build(&config, "cargo test", None, &[]).unwrap();
+        assert_eq!(rules.describe(), "preset rust + builtin");
+        assert!(rules.is_error_line("test result: FAILED. 10 passed; 0 failed"));
+        assert!(!rules.is_error_line("   Compiling not-found-handler v0.1.0"));
+    }
+
+    #[test]
+    fn test_unknown_preset_and_bad_pattern() {
+        let config = DetectConfig::default();
+        let err = DetectionRules::build(&config, "-", None, &["cobol".to_string()]).unwrap_err();
+        assert!(err.to_string().contains("available: rust, node"));
+
+        let config = DetectConfig {
+            exclude: vec!["(unclosed".to_string()],
+            ..DetectConfig::default()
+        };
+        let err = DetectionRules::build(&config, "-", None, &[]).unwrap_err();
+        assert!(err.to_string().contains("Invalid config pattern"));
+
+        let defaults: Config = toml::from_str(&generate_default_config()).unwrap();
+        assert!(defaults.detect.builtin);
+        assert!(defaults.detect.sources.is_empty());
+
+        assert!(preset("Postgres").is_some());
+        for preset in PRESETS {
+            Layer::new(preset.name.to_string(), preset.include, preset.exclude).unwrap();
+        }
+    }
+}
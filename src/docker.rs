@@ -0,0 +1,752 @@
+//! Docker build failure extraction.
+//!
+//! Segments BuildKit (plain and tty progress) and legacy builder output by
+//! step, finds the step that failed, and maps it back to its Dockerfile
+//! line. The failing step's output is what the explanation should be about;
+//! the rest is progress noise.
+
+use regex::Regex;
+use serde::Serialize;
+use std::fmt;
+use std::sync::OnceLock;
+
+use crate::output::interpret_exit_code;
+use crate::stack_trace::{strip_ansi, StackTrace};
+
+/// Number of output lines kept from the failing step
+const OUTPUT_TAIL_LINES: usize = 40;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Which builder produced the output
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Builder {
+    /// BuildKit (`docker buildx build`, default since Docker 23)
+    BuildKit,
+    /// The legacy builder (`DOCKER_BUILDKIT=0`)
+    Legacy,
+}
+
+impl fmt::Display for Builder {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Builder::BuildKit => write!(f, "BuildKit"),
+            Builder::Legacy => write!(f, "legacy builder"),
+        }
+    }
+}
+
+/// A single build step
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct BuildStep {
+    /// BuildKit vertex number (`#12`)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u32>,
+    /// Stage name for multi-stage builds (e.g. "build")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub stage: Option<String>,
+    /// Step number within the build or stage
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub index: Option<u32>,
+    /// Total number of steps
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub total: Option<u32>,
+    /// The Dockerfile instruction (e.g. "RUN npm ci")
+    pub instruction: String,
+    /// Output of the step, with BuildKit timestamps removed
+    #[serde(skip)]
+    pub output: Vec<String>,
+    /// Error reported for the step
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+    /// Exit code of the step's process
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exit_code: Option<i32>,
+    /// Whether the step was served from cache
+    pub cached: bool,
+}
+
+impl BuildStep {
+    /// "[build 4/9] RUN npm ci"
+    pub fn label(&self) -> String {
+        let position = match (&self.stage, self.index, self.total) {
+            (Some(stage), Some(index), Some(total)) => format!("[{} {}/{}] ", stage, index, total),
+            (None, Some(index), Some(total)) => format!("[{}/{}] ", index, total),
+            (Some(stage), _, _) => format!("[{}] ", stage),
+            _ => String::new(),
+        };
+        format!("{}{}", position, self.instruction)
+    }
+
+    /// The command a RUN step executed
+    pub fn command(&self) -> Option<String> {
+        let p = patterns();
+        if let Some(caps) = self.error.as_deref().and_then(|e| {
+            p.process
+                .captures(e)
+                .or_else(|| p.legacy_command.captures(e))
+        }) {
+            return Some(caps[1].to_string());
+        }
+        let (keyword, rest) = self.instruction.split_once(' ')?;
+        keyword
+            .eq_ignore_ascii_case("RUN")
+            .then(|| rest.trim().to_string())
+    }
+
+    /// The last lines of output, joined
+    pub fn output_tail(&self) -> String {
+        let start = self.output.len().saturating_sub(OUTPUT_TAIL_LINES);
+        self.output[start..].join("\n")
+    }
+}
+
+/// Location of the failing instruction in the Dockerfile
+#[derive(Debug, Clone, Serialize)]
+pub struct DockerfileLocation {
+    /// Dockerfile path as printed by the builder
+    pub file: String,
+    /// 1-based line number
+    pub line: u32,
+    /// Snippet printed by BuildKit, with the failing line marked `>>>`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub snippet: Option<String>,
+}
+
+/// A parsed Docker build failure
+#[derive(Debug, Clone, Serialize)]
+pub struct DockerBuildReport {
+    /// Builder that produced the output
+    pub builder: Builder,
+    /// Number of steps seen
+    pub total_steps: usize,
+    /// Number of steps served from cache
+    pub cached_steps: usize,
+    /// The step that failed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub failed_step: Option<BuildStep>,
+    /// Final error line (e.g. "failed to solve: ...")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+    /// Where the failing instruction lives
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub location: Option<DockerfileLocation>,
+    /// Language-level parse of the failing step's output
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub nested: Option<Box<StackTrace>>,
+}
+
+impl DockerBuildReport {
+    /// Exit code of the failing step, or of the final error line
+    pub fn exit_code(&self) -> Option<i32> {
+        self.failed_step
+            .as_ref()
+            .and_then(|s| s.exit_code)
+            .or_else(|| {
+                self.error
+                    .as_deref()
+                    .and_then(|e| patterns().exit_code.captures(e))
+                    .and_then(|c| c[1].parse().ok())
+            })
+    }
+
+    /// One-line description of the failure
+    pub fn summary(&self) -> String {
+        let mut summary = match &self.failed_step {
+            Some(step) => format!("step {} failed", step.label()),
+            None => "build failed".to_string(),
+        };
+        if let Some(code) = self.exit_code() {
+            summary.push_str(&format!(" with exit code {}", code));
+        } else if let Some(error) = self.failed_step.as_ref().and_then(|s| s.error.as_ref()) {
+            summary.push_str(&format!(": {}", error));
+        } else if let Some(error) = &self.error {
+            summary.push_str(&format!(": {}", error));
+        }
+        summary
+    }
+
+    /// Condensed view of the build, used instead of the raw progress output
+    /// when building the prompt
+    pub fn focused_input(&self) -> String {
+        let mut out = vec![format!("Docker build failed ({})", self.builder)];
+        if let Some(step) = &self.failed_step {
+            out.push(format!("Failing step: {}", step.label()));
+            if let Some(location) = &self.location {
+                out.push(format!("Dockerfile: {}:{}", location.file, location.line));
+            }
+            if let Some(error) = &step.error {
+                out.push(format!("Error: {}", error));
+            }
+            if !step.output.is_empty() {
+                out.push("Step output:".to_string());
+                out.push(step.output_tail());
+            }
+        }
+        if let Some(error) = &self.error {
+            if self.failed_step.as_ref().and_then(|s| s.error.as_ref()) != Some(error) {
+                out.push(format!("Error: {}", error));
+            }
+        }
+        out.join("\n")
+    }
+
+    /// Render the interpretation block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = String::from("Docker build failure:\n");
+        out.push_str(&format!("- {}\n", self.summary()));
+        if let Some(step) = &self.failed_step {
+            if let Some(command) = step.command() {
+                out.push_str(&format!("- command: {}\n", command));
+            }
+        }
+        if let Some(code) = self.exit_code() {
+            out.push_str(&format!(
+                "- exit code {}: {}\n",
+                code,
+                interpret_exit_code(code)
+            ));
+        }
+        if let Some(location) = &self.location {
+            out.push_str(&format!(
+                "- instruction at {}:{}\n",
+                location.file, location.line
+            ));
+            if let Some(snippet) = &location.snippet {
+                for line in snippet.lines() {
+                    out.push_str(&format!("    {}\n", line));
+                }
+            }
+        }
+        out.push_str(&format!(
+            "- {} of {} steps were cached\n",
+            self.cached_steps, self.total_steps
+        ));
+
+        if let Some(nested) = &self.nested {
+            out.push_str(&format!(
+                "- the step's output contains a {} error: {}: {}\n",
+                nested.language, nested.error_type, nested.error_message
+            ));
+            if let Some(context) = nested.prompt_context() {
+                out.push_str(&context);
+                out.push('\n');
+            }
+        }
+
+        out.trim_end().to_string()
+    }
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    buildkit_line: Regex,
+    buildkit_header: Regex,
+    position: Regex,
+    timestamp: Regex,
+    summary_header: Regex,
+    dockerfile_line: Regex,
+    legacy_step: Regex,
+    legacy_failure: Regex,
+    legacy_command: Regex,
+    process: Regex,
+    exit_code: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        buildkit_line: Regex::new(r"^#(\d+) (.*)$").unwrap(),
+        buildkit_header: Regex::new(r"^\[([^\]]+)\] (.+)$").unwrap(),
+        position: Regex::new(r"^(?:(.+) )?(\d+)/(\d+)$").unwrap(),
+        timestamp: Regex::new(r"^\d+\.\d+ ?").unwrap(),
+        summary_header: Regex::new(r"^\s*> \[([^\]]+)\] (.+):$").unwrap(),
+        dockerfile_line: Regex::new(r"^(\S*Dockerfile\S*?):(\d+)$").unwrap(),
+        legacy_step: Regex::new(r"^Step (\d+)/(\d+) : (.+)$").unwrap(),
+        legacy_failure: Regex::new(r"^The command '.*' returned a non-zero code: (\d+)$").unwrap(),
+        legacy_command: Regex::new(r"^The command '(.*)' returned").unwrap(),
+        process: Regex::new(r#"process "(.*)" did not complete successfully"#).unwrap(),
+        exit_code: Regex::new(r"(?:exit code: |non-zero code: )(\d+)").unwrap(),
+    })
+}
+
+/// Check whether input looks like `docker build` output
+pub fn is_docker_build_output(input: &str) -> bool {
+    let p = patterns();
+    input.lines().any(|line| {
+        let line = line.trim_end();
+        p.legacy_step.is_match(line)
+            || line.starts_with("ERROR: failed to solve:")
+            || line.starts_with("ERROR: failed to build:")
+            || p.buildkit_line
+                .captures(line)
+                .is_some_and(|c| p.buildkit_header.is_match(&c[2]))
+    })
+}
+
+/// Check whether a command runs `docker build`
+///
+/// The legacy builder writes step output to stdout, so capture mode needs to
+/// keep it.
+pub fn is_docker_build_command(command: &[String]) -> bool {
+    let args: Vec<&str> = command.iter().map(|a| a.as_str()).collect();
+    let program = args.first().map(|a| a.rsplit('/').next().unwrap_or(a));
+    if program != Some("docker") {
+        return false;
+    }
+    matches!(
+        &args[1..],
+        ["build", ..] | ["buildx", "build", ..] | ["image", "build", ..]
+    )
+}
+
+/// Parse BuildKit or legacy builder output
+pub fn parse_docker_build(input: &str) -> Option<DockerBuildReport> {
+    let input = strip_ansi(input);
+    if input
+        .lines()
+        .any(|l| patterns().legacy_step.is_match(l.trim_end()))
+    {
+        parse_legacy(&input)
+    } else {
+        parse_buildkit(&input)
+    }
+}
+
+fn parse_buildkit(input: &str) -> Option<DockerBuildReport> {
+    let p = patterns();
+    let mut steps: Vec<BuildStep> = Vec::new();
+    let mut error = None;
+    let mut location: Option<DockerfileLocation> = None;
+
+    let lines: Vec<&str> = input.lines().collect();
+    let mut i = 0;
+    while i < lines.len() {
+        let line = lines[i].trim_end();
+        i += 1;
+
+        if let Some(caps) = p.buildkit_line.captures(line) {
+            let id: u32 = caps[1].parse().unwrap_or(0);
+            let rest = &caps[2];
+            if let Some(header) = p.buildkit_header.captures(rest) {
+                if !steps.iter().any(|s| s.id == Some(id)) {
+                    let mut step = BuildStep {
+                        id: Some(id),
+                        instruction: header[2].to_string(),
+                        ..Default::default()
+                    };
+                    set_position(&mut step, &header[1]);
+                    steps.push(step);
+                }
+                continue;
+            }
+            let Some(step) = steps.iter_mut().find(|s| s.id == Some(id)) else {
+                continue;
+            };
+            if let Some(message) = rest.strip_prefix("ERROR: ") {
+                step.exit_code = exit_code_in(message);
+                step.error = Some(message.to_string());
+            } else if rest == "CACHED" {
+                step.cached = true;
+            } else if !rest.starts_with("DONE ") && rest != "DONE" && !rest.starts_with("CANCELED")
+            {
+                step.output.push(p.timestamp.replace(rest, "").into_owned());
+            }
+            continue;
+        }
+
+        // Summary block printed after the progress:
+        //   ------
+        //    > [build 4/9] RUN npm ci:
+        //   0.512 npm ERR! ...
+        //   ------
+        if let Some(caps) = p.summary_header.captures(line) {
+            let mut output = Vec::new();
+            while i < lines.len() && !lines[i].trim_end().starts_with("------") {
+                output.push(p.timestamp.replace(lines[i].trim_end(), "").into_owned());
+                i += 1;
+            }
+
+            let mut candidate = BuildStep {
+                instruction: caps[2].to_string(),
+                ..Default::default()
+            };
+            set_position(&mut candidate, &caps[1]);
+            let label = candidate.label();
+            let step = match steps.iter().position(|s| s.label() == label) {
+                Some(index) => &mut steps[index],
+                None => {
+                    steps.push(candidate);
+                    steps.last_mut().unwrap()
+                }
+            };
+            if step.output.is_empty() {
+                step.output = output;
+            }
+            if step.error.is_none() {
+                step.error = Some("step failed".to_string());
+            }
+            continue;
+        }
+
+        if let Some(caps) = p.dockerfile_line.captures(line) {
+            let mut found = DockerfileLocation {
+                file: caps[1].to_string(),
+                line: caps[2].parse().unwrap_or(0),
+                snippet: None,
+            };
+            // The snippet sits between two dashed rulers
+            if lines.get(i).is_some_and(|l| l.starts_with("-----")) {
+                let mut snippet = Vec::new();
+                i += 1;
+                while i < lines.len() && !lines[i].starts_with("-----") {
+                    snippet.push(lines[i].trim_end());
+                    i += 1;
+                }
+                i += 1;
+                found.snippet = Some(snippet.join("\n"));
+            }
+            location = Some(found);
+            continue;
+        }
+
+        if let Some(message) = line
+            .strip_prefix("ERROR: ")
+            .or_else(|| line.strip_prefix("error: "))
+        {
+            error = Some(message.to_string());
+        }
+    }
+
+    if steps.is_empty() && error.is_none() {
+        return None;
+    }
+
+    // A step summary may only say "step failed"; the final error has the details
+    let failed_index = steps.iter().position(|s| s.error.is_some());
+    if let (Some(index), Some(message)) = (failed_index, error.as_deref()) {
+        let step = &mut steps[index];
+        if step.error.as_deref() == Some("step failed") {
+            step.exit_code = exit_code_in(message);
+            step.error = Some(message.to_string());
+        }
+    }
+
+    Some(DockerBuildReport {
+        builder: Builder::BuildKit,
+        total_steps: steps.iter().filter(|s| s.index.is_some()).count(),
+        cached_steps: steps.iter().filter(|s| s.cached).count(),
+        failed_step: failed_index.map(|index| steps[index].clone()),
+        error,
+        location,
+        nested: None,
+    })
+}
+
+fn parse_legacy(input: &str) -> Option<DockerBuildReport> {
+    let p = patterns();
+    let mut steps: Vec<BuildStep> = Vec::new();
+    let mut failed_index = None;
+    let mut error = None;
+
+    for line in input.lines() {
+        let line = line.trim_end();
+        if let Some(caps) = p.legacy_step.captures(line) {
+            steps.push(BuildStep {
+                index: caps[1].parse().ok(),
+                total: caps[2].parse().ok(),
+                instruction: caps[3].to_string(),
+                ..Default::default()
+            });
+            continue;
+        }
+        let Some(step) = steps.last_mut() else {
+            continue;
+        };
+
+        if line.trim() == "---> Using cache" {
+            step.cached = true;
+        } else if let Some(caps) = p.legacy_failure.captures(line) {
+            step.exit_code = caps[1].parse().ok();
+            step.error = Some(line.to_string());
+            failed_index = Some(steps.len() - 1);
+        } else if line.starts_with("ERROR: ")
+            || line.starts_with("COPY failed: ")
+            || line.starts_with("ADD failed: ")
+            || line.starts_with("failed to ")
+        {
+            error = Some(line.trim_start_matches("ERROR: ").to_string());
+            if failed_index.is_none() {
+                step.error = error.clone();
+                failed_index = Some(steps.len() - 1);
+            }
+        } else if !line.trim_start().starts_with("--->")
+            && !line.starts_with("Removing intermediate container")
+            && !line.starts_with("Sending build context")
+        {
+            step.output.push(line.to_string());
+        }
+    }
+
+    if steps.is_empty() {
+        return None;
+    }
+
+    let failed_step = failed_index.map(|index| steps[index].clone());
+    let location = failed_step.as_ref().and_then(locate_in_local_dockerfile);
+    Some(DockerBuildReport {
+        builder: Builder::Legacy,
+        total_steps: steps.len(),
+        cached_steps: steps.iter().filter(|s| s.cached).count(),
+        failed_step,
+        error,
+        location,
+        nested: None,
+    })
+}
+
+fn set_position(step: &mut BuildStep, position: &str) {
+    match patterns().position.captures(position) {
+        Some(caps) => {
+            step.stage = caps.get(1).map(|m| m.as_str().to_string());
+            step.index = caps[2].parse().ok();
+            step.total = caps[3].parse().ok();
+        }
+        None => step.stage = Some(position.to_string()),
+    }
+}
+
+fn exit_code_in(message: &str) -> Option<i32> {
+    patterns()
+        .exit_code
+        .captures(message)
+        .and_then(|c| c[1].parse().ok())
+}
+
+/// Map a legacy builder step to ./Dockerfile, if that is the file it came from
+fn locate_in_local_dockerfile(step: &BuildStep) -> Option<DockerfileLocation> {
+    let dockerfile = std::fs::read_to_string("Dockerfile").ok()?;
+    let line = find_instruction_line(&dockerfile, step.index?, &step.instruction)?;
+    Some(DockerfileLocation {
+        file: "Dockerfile".to_string(),
+        line,
+        snippet: None,
+    })
+}
+
+/// Find the line of the `step`-th instruction (1-based) in a Dockerfile
+///
+/// The legacy builder numbers every instruction, including `FROM`, across all
+/// stages, but does not print Dockerfile line numbers. Returns `None` if the
+/// instruction found there does not match `instruction`, e.g. when the build
+/// used a different Dockerfile.
+pub fn find_instruction_line(dockerfile: &str, step: u32, instruction: &str) -> Option<u32> {
+    let mut count = 0;
+    let mut continued = false;
+    for (index, line) in dockerfile.lines().enumerate() {
+        let trimmed = line.trim();
+        let starts_instruction = !continued && !trimmed.is_empty() && !trimmed.starts_with('#');
+        continued = trimmed.ends_with('\\') || (continued && trimmed.starts_with('#'));
+        if !starts_instruction {
+            continue;
+        }
+        count += 1;
+        if count == step {
+            let normalize = |s: &str| s.split_whitespace().collect::<Vec<_>>().join(" ");
+            let expected = normalize(instruction);
+            let found = normalize(trimmed.trim_end_matches('\\'));
+            let matches = expected.eq_ignore_ascii_case(&found)
+                || expected.to_lowercase().starts_with(&found.to_lowercase());
+            return matches.then_some(index as u32 + 1);
+        }
+    }
+    None
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const BUILDKIT: &str = "\
+#0 building with \"default\" instance using docker driver
+
+#1 [internal] load build definition from Dockerfile
+#1 transferring dockerfile: 412B done
+#1 DONE 0.0s
+
+#5 [build 1/4] FROM docker.io/library/node:20-alpine
+#5 CACHED
+
+#7 [build 2/4] WORKDIR /app
+#7 CACHED
+
+#8 [build 3/4] COPY package*.json ./
+#8 DONE 0.1s
+
+#9 [build 4/4] RUN npm ci
+#9 0.512 npm ERR! code ERESOLVE
+#9 0.513 npm ERR! ERESOLVE unable to resolve dependency tree
+#9 0.514 npm ERR! Found: react@18.2.0
+#9 ERROR: process \"/bin/sh -c npm ci\" did not complete successfully: exit code: 1
+------
+ > [build 4/4] RUN npm ci:
+0.512 npm ERR! code ERESOLVE
+0.513 npm ERR! ERESOLVE unable to resolve dependency tree
+0.514 npm ERR! Found: react@18.2.0
+------
+Dockerfile:9
+--------------------
+   7 |     COPY package*.json ./
+   8 |
+   9 | >>> RUN npm ci
+  10 |     COPY . .
+--------------------
+ERROR: failed to solve: process \"/bin/sh -c npm ci\" did not complete successfully: exit code: 1
+";
+
+    #[test]
+    fn test_parses_buildkit_failure() {
+        assert!(is_docker_build_output(BUILDKIT));
+        let report = parse_docker_build(BUILDKIT).unwrap();
+        assert_eq!(report.builder, Builder::BuildKit);
+        assert_eq!(report.total_steps, 4);
+        assert_eq!(report.cached_steps, 2);
+
+        let step = report.failed_step.as_ref().unwrap();
+        assert_eq!(step.label(), "[build 4/4] RUN npm ci");
+        assert_eq!(step.command().as_deref(), Some("/bin/sh -c npm ci"));
+        assert_eq!(step.exit_code, Some(1));
+        assert_eq!(step.output.len(), 3);
+        assert_eq!(step.output[0], "npm ERR! code ERESOLVE");
+
+        let location = report.location.as_ref().unwrap();
+        assert_eq!(location.file, "Dockerfile");
+        assert_eq!(location.line, 9);
+        assert!(location
+            .snippet
+            .as_ref()
+            .unwrap()
+            .contains(">>> RUN npm ci"));
+
+        let summary = report.prompt_summary();
+        assert!(summary.contains("- step [build 4/4] RUN npm ci failed with exit code 1"));
+        assert!(summary.contains("- instruction at Dockerfile:9"));
+
+        let focused = report.focused_input();
+        assert!(focused.contains("Failing step: [build 4/4] RUN npm ci"));
+        assert!(!focused.contains("load build definition"));
+    }
+
+    #[test]
+    fn test_tty_summary_without_progress_lines() {
+        let input = "\
+ => ERROR [3/3] RUN make                                                   0.4s
+------
+ > [3/3] RUN make:
+0.391 make: *** No targets specified and no makefile found.  Stop.
+------
+Dockerfile:3
+--------------------
+   1 |     FROM gcc:13
+   2 |     WORKDIR /src
+   3 | >>> RUN make
+--------------------
+ERROR: failed to solve: process \"/bin/sh -c make\" did not complete successfully: exit code: 2
+";
+
+        let report = parse_docker_build(input).unwrap();
+        let step = report.failed_step.as_ref().unwrap();
+        assert_eq!(step.label(), "[3/3] RUN make");
+        assert_eq!(step.exit_code, Some(2));
+        assert_eq!(
+            step.output,
+            vec!["make: *** No targets specified and no makefile found.  Stop."]
+        );
+        assert_eq!(report.location.as_ref().unwrap().line, 3);
+    }
+
+    #[test]
+    fn test_parses_legacy_builder_failure() {
+        let input = "\
+Sending build context to Docker daemon  3.072kB
+Step 1/3 : FROM python:3.12-slim
+ ---> 1a2b3c4d5e6f
+Step 2/3 : COPY app.py .
+ ---> Using cache
+ ---> 2b3c4d5e6f7a
+Step 3/3 : RUN python app.py
+ ---> Running in 3c4d5e6f7a8b
+Traceback (most recent call last):
+  File \"/app.py\", line 1, in <module>
+    import requests
+ModuleNotFoundError: No module named 'requests'
+The command '/bin/sh -c python app.py' returned a non-zero code: 1
+";
+
+        assert!(is_docker_build_output(input));
+        let report = parse_docker_build(input).unwrap();
+        assert_eq!(report.builder, Builder::Legacy);
+        assert_eq!(report.cached_steps, 1);
+
+        let step = report.failed_step.as_ref().unwrap();
+        assert_eq!(step.label(), "[3/3] RUN python app.py");
+        assert_eq!(step.command().as_deref(), Some("/bin/sh -c python app.py"));
+        assert_eq!(report.exit_code(), Some(1));
+        assert!(step.output_tail().starts_with("Traceback"));
+    }
+
+    #[test]
+    fn test_is_docker_build_command() {
+        let cmd = |args: &[&str]| args.iter().map(|s| s.to_string()).collect::<Vec<_>>();
+        assert!(is_docker_build_command(&cmd(&["docker", "build", "."])));
+        assert!(is_docker_build_command(&cmd(&[
+            "docker", "buildx", "build", "."
+        ])));
+        assert!(is_docker_build_command(&cmd(&[
+            "/usr/bin/docker",
+            "image",
+            "build",
+            "."
+        ])));
+        assert!(!is_docker_build_command(&cmd(&["docker", "run", "alpine"])));
+        assert!(!is_docker_build_command(&cmd(&["make", "build"])));
+    }
+
+    #[test]
+    fn test_find_instruction_line() {
+        let dockerfile = "\
+# syntax=docker/dockerfile:1
+FROM python:3.12-slim
+
+COPY app.py .
+RUN pip install \\
+    requests
+RUN python app.py
+";
+
+        assert_eq!(
+            find_instruction_line(dockerfile, 1, "FROM python:3.12-slim"),
+            Some(2)
+        );
+        assert_eq!(
+            find_instruction_line(dockerfile, 3, "RUN pip install requests"),
+            Some(5)
+        );
+        assert_eq!(
+            find_instruction_line(dockerfile, 4, "RUN python app.py"),
+            Some(7)
+        );
+        assert_eq!(find_instruction_line(dockerfile, 4, "RUN make"), None);
+        assert_eq!(find_instruction_line(dockerfile, 9, "RUN make"), None);
+    }
+}
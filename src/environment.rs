@@ -0,0 +1,564 @@
+//! Environment and toolchain context for explanations.
+//!
+//! Many errors only make sense next to a version: a syntax error that is valid
+//! Python 3.12 but not 3.8, an API that arrived in Node 20, a crate that needs
+//! a newer rustc. This module runs a few cheap local probes picked by the
+//! detected language and the failing command, reads the project manifest for
+//! the dependencies the error mentions, and records the OS and shell. It is
+//! opt-in (`--env` or `[context] environment = true`).
+
+use serde::Serialize;
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+
+use crate::stack_trace::Language;
+
+/// Dependencies listed at most
+const MAX_DEPENDENCIES: usize = 5;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// A dependency the error mentions, as the project manifest declares it
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Dependency {
+    /// Package, crate or module name
+    pub name: String,
+    /// Version requirement from the manifest
+    pub version: String,
+    /// Manifest it came from (e.g. "Cargo.toml")
+    pub manifest: String,
+}
+
+/// Everything collected about the machine and project
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct EnvironmentContext {
+    /// OS or distribution with architecture
+    pub os: String,
+    /// Login shell name
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub shell: Option<String>,
+    /// Tool versions as (tool, version line)
+    pub toolchain: Vec<(String, String)>,
+    /// Active Python virtualenv or conda environment
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub virtualenv: Option<String>,
+    /// Dependencies named in the error
+    pub dependencies: Vec<Dependency>,
+}
+
+impl EnvironmentContext {
+    /// Render the context as a compact block for the model prompt
+    pub fn prompt_text(&self) -> String {
+        let mut out = String::from("Environment:\n");
+        out.push_str(&format!("- OS: {}\n", self.os));
+        if let Some(shell) = &self.shell {
+            out.push_str(&format!("- shell: {}\n", shell));
+        }
+        for (tool, version) in &self.toolchain {
+            out.push_str(&format!("- {}: {}\n", tool, version));
+        }
+        if let Some(venv) = &self.virtualenv {
+            out.push_str(&format!("- virtualenv: {}\n", venv));
+        }
+        for dep in &self.dependencies {
+            out.push_str(&format!(
+                "- dependency {} {} ({})\n",
+                dep.name, dep.version, dep.manifest
+            ));
+        }
+        out.trim_end().to_string()
+    }
+}
+
+/// A version command to run
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+struct Probe {
+    /// Name shown in the prompt
+    tool: &'static str,
+    /// Programs to try, in order
+    programs: &'static [&'static str],
+    /// Arguments that print the version
+    args: &'static [&'static str],
+}
+
+const PYTHON: Probe = Probe {
+    tool: "python",
+    programs: &["python3", "python"],
+    args: &["--version"],
+};
+const NODE: Probe = Probe {
+    tool: "node",
+    programs: &["node"],
+    args: &["-v"],
+};
+const DENO: Probe = Probe {
+    tool: "deno",
+    programs: &["deno"],
+    args: &["--version"],
+};
+const BUN: Probe = Probe {
+    tool: "bun",
+    programs: &["bun"],
+    args: &["--version"],
+};
+const TSC: Probe = Probe {
+    tool: "tsc",
+    programs: &["tsc"],
+    args: &["-v"],
+};
+const RUSTC: Probe = Probe {
+    tool: "rustc",
+    programs: &["rustc"],
+    args: &["-V"],
+};
+const CARGO: Probe = Probe {
+    tool: "cargo",
+    programs: &["cargo"],
+    args: &["-V"],
+};
+const GO: Probe = Probe {
+    tool: "go",
+    programs: &["go"],
+    args: &["version"],
+};
+const JAVA: Probe = Probe {
+    tool: "java",
+    programs: &["java"],
+    args: &["-version"],
+};
+const CC: Probe = Probe {
+    tool: "cc",
+    programs: &["cc"],
+    args: &["--version"],
+};
+
+// ============================================================================
+// Probe Selection
+// ============================================================================
+
+/// Language a command's basename implies (e.g. "pytest" is Python)
+pub fn command_language(command: &str) -> Option<Language> {
+    let program = command.split_whitespace().next()?;
+    let name = program.rsplit('/').next().unwrap_or(program);
+    let language = match name {
+        n if n.starts_with("python") => Language::Python,
+        "pip" | "pip3" | "pytest" | "poetry" | "uv" | "pipenv" | "mypy" | "django-admin" => {
+            Language::Python
+        }
+        "node" | "npm" | "npx" | "yarn" | "pnpm" | "deno" | "bun" | "jest" | "vitest" => {
+            Language::JavaScript
+        }
+        "tsc" | "ts-node" | "tsx" => Language::TypeScript,
+        "cargo" | "rustc" | "rustup" => Language::Rust,
+        "go" => Language::Go,
+        "java" | "javac" | "mvn" | "gradle" | "gradlew" | "./gradlew" => Language::Java,
+        "gcc" | "g++" | "cc" | "c++" | "clang" | "clang++" | "make" | "cmake" => Language::Cpp,
+        _ => return None,
+    };
+    Some(language)
+}
+
+/// Version probes for a language, plus the runtime a command names
+fn probes_for(language: Option<Language>, command: Option<&str>) -> Vec<Probe> {
+    let mut probes = match language {
+        Some(Language::Python) => vec![PYTHON],
+        Some(Language::JavaScript) => vec![NODE],
+        Some(Language::TypeScript) => vec![NODE, TSC],
+        Some(Language::Rust) => vec![RUSTC, CARGO],
+        Some(Language::Go) => vec![GO],
+        Some(Language::Java) => vec![JAVA],
+        Some(Language::Cpp) => vec![CC],
+        _ => Vec::new(),
+    };
+    let program = command
+        .and_then(|c| c.split_whitespace().next())
+        .map(|p| p.rsplit('/').next().unwrap_or(p));
+    // Deno and Bun run JavaScript without node
+    let runtime = match program {
+        Some("deno") => DENO,
+        Some("bun") => BUN,
+        _ => return probes,
+    };
+    probes.retain(|p| *p != NODE);
+    probes.insert(0, runtime);
+    probes
+}
+
+/// Manifest files worth reading for a language
+fn manifests_for(language: Option<Language>) -> &'static [&'static str] {
+    match language {
+        Some(Language::Python) => &["pyproject.toml"],
+        Some(Language::JavaScript | Language::TypeScript) => &["package.json"],
+        Some(Language::Rust) => &["Cargo.toml"],
+        Some(Language::Go) => &["go.mod"],
+        Some(Language::Java | Language::Cpp) => &[],
+        _ => &["Cargo.toml", "package.json", "pyproject.toml", "go.mod"],
+    }
+}
+
+// ============================================================================
+// Collection
+// ============================================================================
+
+/// Collect the environment for an error
+///
+/// `language` comes from the parsed trace, `command` from capture or hook
+/// mode; either can pick the probes. Manifests are looked up from `dir`
+/// upward.
+pub fn collect(
+    language: Option<Language>,
+    command: Option<&str>,
+    error_text: &str,
+    dir: &Path,
+) -> EnvironmentContext {
+    let language = language
+        .filter(|l| *l != Language::Unknown)
+        .or_else(|| command.and_then(command_language));
+
+    let toolchain = probes_for(language, command)
+        .into_iter()
+        .filter_map(|probe| run_probe(&probe).map(|version| (probe.tool.to_string(), version)))
+        .collect();
+
+    let virtualenv = matches!(language, Some(Language::Python))
+        .then(|| {
+            std::env::var("VIRTUAL_ENV")
+                .or_else(|_| std::env::var("CONDA_DEFAULT_ENV"))
+                .ok()
+        })
+        .flatten();
+
+    let mut dependencies = Vec::new();
+    for name in manifests_for(language) {
+        let Some(path) = find_upward(dir, name) else {
+            continue;
+        };
+        let Ok(contents) = std::fs::read_to_string(&path) else {
+            continue;
+        };
+        dependencies.extend(
+            manifest_dependencies(name, &contents)
+                .into_iter()
+                .filter(|dep| mentions(error_text, &dep.name)),
+        );
+    }
+    dependencies.truncate(MAX_DEPENDENCIES);
+
+    EnvironmentContext {
+        os: os_description(),
+        shell: std::env::var("SHELL")
+            .ok()
+            .and_then(|s| s.rsplit('/').next().map(str::to_string))
+            .filter(|s| !s.is_empty()),
+        toolchain,
+        virtualenv,
+        dependencies,
+    }
+}
+
+/// First line a version command prints (java writes to stderr)
+fn run_probe(probe: &Probe) -> Option<String> {
+    probe.programs.iter().find_map(|program| {
+        let output = Command::new(program)
+            .args(probe.args)
+            .stdin(Stdio::null())
+            .output()
+            .ok()?;
+        if !output.status.success() {
+            return None;
+        }
+        let stdout = String::from_utf8_lossy(&output.stdout);
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        stdout
+            .lines()
+            .chain(stderr.lines())
+            .map(str::trim)
+            .find(|line| !line.is_empty())
+            .map(str::to_string)
+    })
+}
+
+/// Distribution name from os-release, or the OS family
+fn os_description() -> String {
+    let arch = std::env::consts::ARCH;
+    let name = std::fs::read_to_string("/etc/os-release")
+        .ok()
+        .and_then(|contents| os_release_name(&contents));
+    match name {
+        Some(name) => format!("{} ({})", name, arch),
+        None => format!("{} ({})", std::env::consts::OS, arch),
+    }
+}
+
+/// PRETTY_NAME from /etc/os-release
+fn os_release_name(contents: &str) -> Option<String> {
+    contents.lines().find_map(|line| {
+        line.strip_prefix("PRETTY_NAME=")
+            .map(|value| value.trim_matches('"').to_string())
+    })
+}
+
+fn find_upward(dir: &Path, name: &str) -> Option<PathBuf> {
+    dir.ancestors()
+        .map(|d| d.join(name))
+        .find(|path| path.is_file())
+}
+
+/// Whether `text` mentions `name` as a whole word
+fn mentions(text: &str, name: &str) -> bool {
+    let is_word = |c: char| c.is_alphanumeric() || c == '_' || c == '-';
+    text.match_indices(name).any(|(i, _)| {
+        let before = text[..i].chars().next_back();
+        let after = text[i + name.len()..].chars().next();
+        !before.is_some_and(is_word) && !after.is_some_and(is_word)
+    }) || (name.contains('-') && mentions(text, &name.replace('-', "_")))
+}
+
+// ============================================================================
+// Manifests
+// ============================================================================
+
+/// Declared dependencies of a manifest, by file name
+pub fn manifest_dependencies(manifest: &str, contents: &str) -> Vec<Dependency> {
+    let deps: Vec<(String, String)> = match manifest {
+        "Cargo.toml" => cargo_dependencies(contents),
+        "package.json" => npm_dependencies(contents),
+        "pyproject.toml" => pyproject_dependencies(contents),
+        "go.mod" => go_dependencies(contents),
+        _ => Vec::new(),
+    };
+    deps.into_iter()
+        .map(|(name, version)| Dependency {
+            name,
+            version,
+            manifest: manifest.to_string(),
+        })
+        .collect()
+}
+
+fn cargo_dependencies(contents: &str) -> Vec<(String, String)> {
+    let Ok(manifest) = contents.parse::<toml::Table>() else {
+        return Vec::new();
+    };
+    let mut deps = Vec::new();
+    for section in ["dependencies", "dev-dependencies", "build-dependencies"] {
+        let Some(table) = manifest.get(section).and_then(|v| v.as_table()) else {
+            continue;
+        };
+        for (name, spec) in table {
+            let version = match spec {
+                toml::Value::String(v) => v.clone(),
+                toml::Value::Table(t) => t
+                    .get("version")
+                    .and_then(|v| v.as_str())
+                    .map(str::to_string)
+                    .or_else(|| t.get("path").map(|_| "(path)".to_string()))
+                    .or_else(|| t.get("git").map(|_| "(git)".to_string()))
+                    .unwrap_or_else(|| "*".to_string()),
+                _ => "*".to_string(),
+            };
+            deps.push((name.clone(), version));
+        }
+    }
+    deps
+}
+
+fn npm_dependencies(contents: &str) -> Vec<(String, String)> {
+    let Ok(manifest) = serde_json::from_str::<serde_json::Value>(contents) else {
+        return Vec::new();
+    };
+    let mut deps = Vec::new();
+    for section in ["dependencies", "devDependencies", "peerDependencies"] {
+        let Some(map) = manifest.get(section).and_then(|v| v.as_object()) else {
+            continue;
+        };
+        for (name, version) in map {
+            deps.push((name.clone(), version.as_str().unwrap_or("*").to_string()));
+        }
+    }
+    deps
+}
+
+fn pyproject_dependencies(contents: &str) -> Vec<(String, String)> {
+    let Ok(manifest) = contents.parse::<toml::Table>() else {
+        return Vec::new();
+    };
+    let mut deps = Vec::new();
+
+    // PEP 621: "requests>=2.28", "numpy[extra]==1.26; python_version<'3.13'"
+    let pep621 = manifest
+        .get("project")
+        .and_then(|p| p.get("dependencies"))
+        .and_then(|d| d.as_array());
+    for spec in pep621.into_iter().flatten().filter_map(|s| s.as_str()) {
+        let spec = spec.split(';').next().unwrap_or(spec).trim();
+        let end = spec
+            .find(|c: char| !(c.is_alphanumeric() || c == '-' || c == '_' || c == '.'))
+            .unwrap_or(spec.len());
+        let (name, rest) = spec.split_at(end);
+        // Skip extras: "[email]"
+        let rest = match rest.strip_prefix('[') {
+            Some(extras) => extras.split_once(']').map_or("", |(_, after)| after),
+            None => rest,
+        };
+        let version = rest.trim();
+        let version = if version.is_empty() { "*" } else { version };
+        deps.push((name.to_string(), version.to_string()));
+    }
+
+    // Poetry: [tool.poetry.dependencies] requests = "^2.28"
+    let poetry = manifest
+        .get("tool")
+        .and_then(|t| t.get("poetry"))
+        .and_then(|p| p.get("dependencies"))
+        .and_then(|d| d.as_table());
+    for (name, spec) in poetry.into_iter().flatten() {
+        if name == "python" {
+            continue;
+        }
+        let version = match spec {
+            toml::Value::String(v) => v.clone(),
+            toml::Value::Table(t) => t
+                .get("version")
+                .and_then(|v| v.as_str())
+                .unwrap_or("*")
+                .to_string(),
+            _ => "*".to_string(),
+        };
+        deps.push((name.clone(), version));
+    }
+    deps
+}
+
+fn go_dependencies(contents: &str) -> Vec<(String, String)> {
+    let mut deps = Vec::new();
+    let mut in_block = false;
+    for line in contents.lines() {
+        let line = line.split("//").next().unwrap_or("").trim();
+        let spec = if in_block {
+            if line == ")" {
+                in_block = false;
+                continue;
+            }
+            line
+        } else if line == "require (" {
+            in_block = true;
+            continue;
+        } else if let Some(spec) = line.strip_prefix("require ") {
+            spec
+        } else {
+            continue;
+        };
+        let mut parts = spec.split_whitespace();
+        if let (Some(module), Some(version)) = (parts.next(), parts.next()) {
+            deps.push((module.to_string(), version.to_string()));
+        }
+    }
+    deps
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_command_language_and_probes() {
+        assert_eq!(
+            command_language("/usr/bin/python3.12 app.py"),
+            Some(Language::Python)
+        );
+        assert_eq!(command_language("pytest -x"), Some(Language::Python));
+        assert_eq!(command_language("cargo build"), Some(Language::Rust));
+        assert_eq!(command_language("ls -la"), None);
+
+        assert_eq!(probes_for(Some(Language::Rust), None), vec![RUSTC, CARGO]);
+        assert_eq!(
+            probes_for(Some(Language::JavaScript), Some("deno run main.ts")),
+            vec![DENO]
+        );
+        assert!(probes_for(Some(Language::Kubernetes), None).is_empty());
+    }
+
+    #[test]
+    fn test_manifest_dependencies() {
+        let cargo = r#"
+[dependencies]
+serde = { version = "1", features = ["derive"] }
+tokio = "1.38"
+local = { path = "../local" }
+"#;
+        let deps = manifest_dependencies("Cargo.toml", cargo);
+        assert!(deps.contains(&Dependency {
+            name: "tokio".to_string(),
+            version: "1.38".to_string(),
+            manifest: "Cargo.toml".to_string(),
+        }));
+        assert!(deps
+            .iter()
+            .any(|d| d.name == "local" && d.version == "(path)"));
+
+        let pyproject = r#"
+[project]
+dependencies = ["requests>=2.28", "pydantic[email]==1.10.2; python_version<'3.13'"]
+"#;
+        let deps = manifest_dependencies("pyproject.toml", pyproject);
+        assert_eq!(deps[0].name, "requests");
+        assert_eq!(deps[0].version, ">=2.28");
+        assert_eq!(deps[1].name, "pydantic");
+        assert_eq!(deps[1].version, "==1.10.2");
+
+        let gomod = "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/lib/pq v1.10.9 // indirect\n)\nrequire golang.org/x/sync v0.7.0\n";
+        let deps = manifest_dependencies("go.mod", gomod);
+        assert_eq!(deps.len(), 2);
+        assert_eq!(deps[0].name, "github.com/lib/pq");
+        assert_eq!(deps[1].version, "v0.7.0");
+
+        let package =
+            r#"{"dependencies": {"react": "^18.2.0"}, "devDependencies": {"vite": "5.0.0"}}"#;
+        assert_eq!(manifest_dependencies("package.json", package).len(), 2);
+    }
+
+    #[test]
+    fn test_collect_keeps_dependencies_the_error_names() {
+        let dir = std::env::temp_dir().join(format!("why-env-{}", std::process::id()));
+        let nested = dir.join("src").join("app");
+        std::fs::create_dir_all(&nested).unwrap();
+        std::fs::write(
+            dir.join("pyproject.toml"),
+            "[project]\ndependencies = [\"requests>=2.28\", \"pydantic==1.10.2\", \"rich\"]\n",
+        )
+        .unwrap();
+
+        let error = "ImportError: cannot import name 'field_validator' from 'pydantic'";
+        let context = collect(Some(Language::Python), None, error, &nested);
+        std::fs::remove_dir_all(&dir).ok();
+
+        assert_eq!(context.dependencies.len(), 1);
+        assert_eq!(context.dependencies[0].version, "==1.10.2");
+        assert!(context
+            .prompt_text()
+            .contains("- dependency pydantic ==1.10.2 (pyproject.toml)"));
+        assert!(context.prompt_text().starts_with("Environment:\n- OS: "));
+    }
+
+    #[test]
+    fn test_mentions_whole_words() {
+        assert!(mentions("No module named 'yaml'", "yaml"));
+        assert!(!mentions("No module named 'pyyaml_ext'", "yaml"));
+        assert!(mentions("error in serde_json::from_str", "serde_json"));
+        assert!(mentions(
+            "error[E0433]: use of undeclared crate `serde_json`",
+            "serde-json"
+        ));
+        assert_eq!(
+            os_release_name("NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 24.04.1 LTS\"\n").as_deref(),
+            Some("Ubuntu 24.04.1 LTS")
+        );
+    }
+}
@@ -0,0 +1,405 @@
+//! Flaky failure detection for repeated capture runs.
+//!
+//! `why --capture --repeat N` runs the command several times and gives each
+//! failing run a signature: a hash of its exit code and output, normalized
+//! like watch mode's deduplication so timestamps, line numbers, durations and
+//! addresses don't count as differences. From the runs the failure is
+//! classified as deterministic, flaky or environment-dependent, so a flaky
+//! timeout isn't explained as a logic bug.
+
+use regex::Regex;
+use serde::Serialize;
+use std::sync::OnceLock;
+use std::time::Duration;
+
+use crate::watch::DetectedError;
+
+/// Lines of output compared when diffing failures
+const MAX_DIFF_INPUT_LINES: usize = 400;
+
+/// Changed lines shown in a failure diff
+const MAX_DIFF_LINES: usize = 60;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// One run of the command
+#[derive(Debug, Clone, Serialize)]
+pub struct RunRecord {
+    /// 1-based run number
+    pub run: usize,
+    /// Exit code as a shell reports it
+    pub exit_code: i32,
+    /// Wall-clock time of the run
+    pub duration: Duration,
+    /// Failure signature, `None` for passing runs
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub signature: Option<u64>,
+    /// Captured output
+    #[serde(skip)]
+    pub output: String,
+}
+
+impl RunRecord {
+    pub fn new(run: usize, exit_code: i32, duration: Duration, output: String) -> Self {
+        let signature = (exit_code != 0).then(|| failure_signature(exit_code, &output));
+        Self {
+            run,
+            exit_code,
+            duration,
+            signature,
+            output,
+        }
+    }
+
+    pub fn passed(&self) -> bool {
+        self.signature.is_none()
+    }
+}
+
+/// How a failure behaves across runs
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Stability {
+    /// Every run passed
+    Passing,
+    /// Every run failed the same way
+    Deterministic,
+    /// Some runs passed
+    Flaky,
+    /// Every run failed, but not the same way
+    EnvironmentDependent,
+}
+
+impl Stability {
+    /// What the classification means for the explanation
+    pub fn meaning(&self) -> &'static str {
+        match self {
+            Stability::Passing => "the command passed every time",
+            Stability::Deterministic => {
+                "the command failed the same way every time, so this is a reproducible bug"
+            }
+            Stability::Flaky => {
+                "the command sometimes passes, so look for timing, ordering, randomness or \
+                 shared state rather than a logic error"
+            }
+            Stability::EnvironmentDependent => {
+                "the command always fails but with different errors, so look for timeouts, \
+                 resource limits, ports, network or other external state"
+            }
+        }
+    }
+}
+
+impl std::fmt::Display for Stability {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Stability::Passing => write!(f, "passing"),
+            Stability::Deterministic => write!(f, "deterministic"),
+            Stability::Flaky => write!(f, "flaky"),
+            Stability::EnvironmentDependent => write!(f, "environment-dependent"),
+        }
+    }
+}
+
+/// A distinct way the command failed
+#[derive(Debug, Clone, Serialize)]
+pub struct FailureGroup {
+    /// Signature shared by the runs
+    pub signature: u64,
+    /// Exit code of the first run
+    pub exit_code: i32,
+    /// Runs that failed this way
+    pub runs: Vec<usize>,
+}
+
+/// Runs of a command and what they say about its failure
+#[derive(Debug, Clone, Serialize)]
+pub struct RepeatReport {
+    pub stability: Stability,
+    pub runs: Vec<RunRecord>,
+    /// Distinct failures, most frequent first
+    pub failures: Vec<FailureGroup>,
+}
+
+impl RepeatReport {
+    /// Classify a set of runs
+    pub fn new(runs: Vec<RunRecord>) -> Self {
+        let mut failures: Vec<FailureGroup> = Vec::new();
+        for record in &runs {
+            let Some(signature) = record.signature else {
+                continue;
+            };
+            match failures.iter_mut().find(|g| g.signature == signature) {
+                Some(group) => group.runs.push(record.run),
+                None => failures.push(FailureGroup {
+                    signature,
+                    exit_code: record.exit_code,
+                    runs: vec![record.run],
+                }),
+            }
+        }
+        // Stable sort keeps first-seen order among equally common failures
+        failures.sort_by(|a, b| b.runs.len().cmp(&a.runs.len()));
+
+        let passed = runs.iter().filter(|r| r.passed()).count();
+        let stability = match (passed, failures.len()) {
+            (_, 0) => Stability::Passing,
+            (0, 1) => Stability::Deterministic,
+            (0, _) => Stability::EnvironmentDependent,
+            _ => Stability::Flaky,
+        };
+
+        Self {
+            stability,
+            runs,
+            failures,
+        }
+    }
+
+    /// Run to explain: the first run of the most common failure
+    pub fn representative(&self) -> Option<&RunRecord> {
+        let group = self.failures.first()?;
+        self.run(group.runs[0])
+    }
+
+    fn run(&self, run: usize) -> Option<&RunRecord> {
+        self.runs.iter().find(|r| r.run == run)
+    }
+
+    /// Render the runs, classification and failure diff for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let failed = self.runs.iter().filter(|r| !r.passed()).count();
+        let mut out = format!(
+            "Ran {} times: {} failed, {} passed. Classification: {} ({}).\n",
+            self.runs.len(),
+            failed,
+            self.runs.len() - failed,
+            self.stability,
+            self.stability.meaning()
+        );
+
+        for record in &self.runs {
+            let outcome = match record.signature {
+                None => "passed".to_string(),
+                Some(signature) => format!(
+                    "failed (exit {}, failure {})",
+                    record.exit_code,
+                    self.failure_label(signature)
+                ),
+            };
+            out.push_str(&format!(
+                "- run {}: {} in {:.1}s\n",
+                record.run,
+                outcome,
+                record.duration.as_secs_f64()
+            ));
+        }
+
+        if self.failures.len() > 1 {
+            let first = &self.failures[0];
+            for other in &self.failures[1..] {
+                let (Some(a), Some(b)) = (self.run(first.runs[0]), self.run(other.runs[0])) else {
+                    continue;
+                };
+                out.push_str(&format!(
+                    "Output of failure {} (run {}) compared to failure {} (run {}):\n",
+                    self.failure_label(other.signature),
+                    b.run,
+                    self.failure_label(first.signature),
+                    a.run
+                ));
+                out.push_str(&line_diff(&a.output, &b.output));
+            }
+        }
+
+        out.trim_end().to_string()
+    }
+
+    /// "A", "B", ... in order of frequency
+    fn failure_label(&self, signature: u64) -> String {
+        let index = self
+            .failures
+            .iter()
+            .position(|g| g.signature == signature)
+            .unwrap_or(0);
+        char::from(b'A' + (index % 26) as u8).to_string()
+    }
+}
+
+// ============================================================================
+// Signatures and Diffs
+// ============================================================================
+
+struct Patterns {
+    volatile: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        // Durations, addresses and process IDs change between identical failures
+        volatile: Regex::new(
+            r"(?i)\b\d+(?:\.\d+)?\s?(?:ns|µs|us|ms|s|sec|secs|seconds)\b|0x[0-9a-f]+|\bpid[ =:]?\d+|\(node:\d+\)",
+        )
+        .unwrap(),
+    })
+}
+
+/// Hash of a failure, ignoring what changes between identical failures
+pub fn failure_signature(exit_code: i32, output: &str) -> u64 {
+    let stable = patterns().volatile.replace_all(output, "");
+    DetectedError::compute_hash(&format!("exit {}\n{}", exit_code, stable))
+}
+
+/// Lines removed from `a` ("-") and added in `b` ("+"), without context
+pub fn line_diff(a: &str, b: &str) -> String {
+    let a: Vec<&str> = a.lines().take(MAX_DIFF_INPUT_LINES).collect();
+    let b: Vec<&str> = b.lines().take(MAX_DIFF_INPUT_LINES).collect();
+
+    // Longest common subsequence table, filled from the end
+    let mut lcs = vec![vec![0u16; b.len() + 1]; a.len() + 1];
+    for i in (0..a.len()).rev() {
+        for j in (0..b.len()).rev() {
+            lcs[i][j] = if a[i] == b[j] {
+                lcs[i + 1][j + 1] + 1
+            } else {
+                lcs[i + 1][j].max(lcs[i][j + 1])
+            };
+        }
+    }
+
+    let mut changes = Vec::new();
+    let (mut i, mut j) = (0, 0);
+    while i < a.len() || j < b.len() {
+        if i < a.len() && j < b.len() && a[i] == b[j] {
+            i += 1;
+            j += 1;
+        } else if i < a.len() && (j == b.len() || lcs[i + 1][j] >= lcs[i][j + 1]) {
+            changes.push(format!("- {}", a[i]));
+            i += 1;
+        } else {
+            changes.push(format!("+ {}", b[j]));
+            j += 1;
+        }
+    }
+
+    let total = changes.len();
+    let mut out = String::new();
+    for change in changes.into_iter().take(MAX_DIFF_LINES) {
+        out.push_str(&change);
+        out.push('\n');
+    }
+    if total > MAX_DIFF_LINES {
+        out.push_str(&format!(
+            "... ({} more changed lines)\n",
+            total - MAX_DIFF_LINES
+        ));
+    }
+    out
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn record(run: usize, exit_code: i32, output: &str) -> RunRecord {
+        RunRecord::new(
+            run,
+            exit_code,
+            Duration::from_millis(1500),
+            output.to_string(),
+        )
+    }
+
+    #[test]
+    fn test_signature_ignores_volatile_details() {
+        let a =
+            "2026-10-15 12:00:01 test api::login ... FAILED (0.42s)\npanicked at src/api.rs:42:5";
+        let b =
+            "2026-10-16 09:13:44 test api::login ... FAILED (1.07s)\npanicked at src/api.rs:43:5";
+        assert_eq!(failure_signature(101, a), failure_signature(101, b));
+        assert_ne!(failure_signature(101, a), failure_signature(1, a));
+        assert_ne!(
+            failure_signature(101, a),
+            failure_signature(101, "timed out")
+        );
+    }
+
+    #[test]
+    fn test_classification() {
+        let deterministic = RepeatReport::new(vec![
+            record(1, 1, "assertion failed: left == right"),
+            record(2, 1, "assertion failed: left == right"),
+        ]);
+        assert_eq!(deterministic.stability, Stability::Deterministic);
+
+        let flaky = RepeatReport::new(vec![
+            record(1, 0, "ok"),
+            record(2, 1, "timed out after 5s"),
+            record(3, 0, "ok"),
+        ]);
+        assert_eq!(flaky.stability, Stability::Flaky);
+        assert_eq!(flaky.representative().unwrap().run, 2);
+
+        let environment = RepeatReport::new(vec![
+            record(
+                1,
+                1,
+                "Error: listen EADDRINUSE: address already in use :::3000",
+            ),
+            record(2, 1, "Error: connect ETIMEDOUT 10.0.0.5:5432"),
+            record(3, 1, "Error: connect ETIMEDOUT 10.0.0.5:5432"),
+        ]);
+        assert_eq!(environment.stability, Stability::EnvironmentDependent);
+        assert_eq!(environment.failures[0].runs, vec![2, 3]);
+        assert_eq!(environment.representative().unwrap().run, 2);
+
+        let passing = RepeatReport::new(vec![record(1, 0, "")]);
+        assert_eq!(passing.stability, Stability::Passing);
+        assert!(passing.representative().is_none());
+    }
+
+    #[test]
+    fn test_prompt_summary_diffs_distinct_failures() {
+        let report = RepeatReport::new(vec![
+            record(
+                1,
+                1,
+                "running 3 tests\ntest db::query ... FAILED\nconnection refused",
+            ),
+            record(2, 0, "running 3 tests\nall passed"),
+            record(
+                3,
+                1,
+                "running 3 tests\ntest db::query ... FAILED\npool timed out",
+            ),
+            record(
+                4,
+                1,
+                "running 3 tests\ntest db::query ... FAILED\nconnection refused",
+            ),
+        ]);
+        let summary = report.prompt_summary();
+        assert!(summary.starts_with(
+            "Ran 4 times: 3 failed, 1 passed. Classification: flaky (the command sometimes passes"
+        ));
+        assert!(summary.contains("- run 1: failed (exit 1, failure A) in 1.5s\n"));
+        assert!(summary.contains("- run 2: passed in 1.5s\n"));
+        assert!(summary.contains("- run 3: failed (exit 1, failure B) in 1.5s\n"));
+        assert!(summary.contains(
+            "Output of failure B (run 3) compared to failure A (run 1):\n- connection refused\n+ pool timed out"
+        ));
+    }
+
+    #[test]
+    fn test_line_diff() {
+        assert_eq!(line_diff("a\nb\nc", "a\nc\nd"), "- b\n+ d\n");
+        assert_eq!(line_diff("same", "same"), "");
+    }
+}
@@ -0,0 +1,457 @@
+//! Git history around the failing line.
+//!
+//! When the root cause frame is in a tracked file, the most useful hint is
+//! often that the line changed yesterday, or hasn't been committed at all.
+//! This module gathers `git blame` for the lines around the failure, the
+//! uncommitted diff of the file and its last few commits, and trims them to
+//! the prompt's context budget. `--no-git` or `[context] git = false` turns
+//! it off.
+
+use anyhow::{bail, Context, Result};
+use serde::Serialize;
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Commits listed from the file's history
+const RECENT_COMMITS: usize = 3;
+
+/// Lines of blame on each side of the failing line, at most
+const MAX_BLAME_RADIUS: usize = 3;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Consecutive lines last changed by the same commit
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct BlameRange {
+    /// First line (1-based)
+    pub start: u32,
+    /// Last line (inclusive)
+    pub end: u32,
+    /// Abbreviated commit hash
+    pub commit: String,
+    /// Commit author
+    pub author: String,
+    /// Author time (Unix seconds)
+    pub time: i64,
+    /// Commit subject
+    pub summary: String,
+}
+
+impl BlameRange {
+    /// Whether the lines are changed in the working tree but not committed
+    pub fn is_uncommitted(&self) -> bool {
+        self.commit.chars().all(|c| c == '0')
+    }
+
+    /// "changed yesterday by Ann in 1a2b3c4 (Parse ports as u16)"
+    pub fn describe(&self, now: i64) -> String {
+        if self.is_uncommitted() {
+            return "uncommitted change".to_string();
+        }
+        format!(
+            "changed {} by {} in {} ({})",
+            relative_age(now - self.time),
+            self.author,
+            self.commit,
+            self.summary
+        )
+    }
+
+    fn lines(&self) -> String {
+        if self.start == self.end {
+            format!("line {}", self.start)
+        } else {
+            format!("lines {}-{}", self.start, self.end)
+        }
+    }
+}
+
+/// What git knows about the file and line a failure points at
+#[derive(Debug, Clone, Serialize)]
+pub struct GitContext {
+    /// File as resolved on disk
+    pub file: PathBuf,
+    /// Failing line
+    pub line: u32,
+    /// Blame for the lines around the failing line
+    pub blame: Vec<BlameRange>,
+    /// Uncommitted changes to the file, as a unified diff
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub diff: Option<String>,
+    /// Latest commits touching the file ("1a2b3c4 2026-10-15 Ann: subject")
+    pub commits: Vec<String>,
+}
+
+impl GitContext {
+    /// Blame for the failing line itself, for the Location block
+    pub fn line_summary(&self) -> Option<String> {
+        self.blame
+            .iter()
+            .find(|range| (range.start..=range.end).contains(&self.line))
+            .map(|range| range.describe(now()))
+    }
+
+    /// Render the context for the model prompt in at most `max_chars`
+    ///
+    /// Blame always fits; recent commits and then the diff are added while
+    /// there is room, the diff cut off at a line boundary.
+    pub fn prompt_text(&self, max_chars: usize) -> String {
+        let now = now();
+        let mut out = format!("Git history for {}:\n", self.file.display());
+        for range in &self.blame {
+            out.push_str(&format!("- {}: {}\n", range.lines(), range.describe(now)));
+        }
+
+        if !self.commits.is_empty() {
+            let mut section = String::from("Recent commits to this file:\n");
+            for commit in &self.commits {
+                section.push_str(&format!("- {}\n", commit));
+            }
+            if out.len() + section.len() <= max_chars {
+                out.push_str(&section);
+            }
+        }
+
+        if let Some(diff) = &self.diff {
+            let header = "Uncommitted changes:\n";
+            let marker = "... (diff truncated)\n";
+            let room = max_chars.saturating_sub(out.len() + header.len() + marker.len());
+            let mut shown = String::new();
+            for line in diff.lines() {
+                if shown.len() + line.len() + 1 > room {
+                    shown.push_str(marker);
+                    break;
+                }
+                shown.push_str(line);
+                shown.push('\n');
+            }
+            if shown.lines().count() > 1 {
+                out.push_str(header);
+                out.push_str(&shown);
+            }
+        }
+
+        out.trim_end().to_string()
+    }
+}
+
+// ============================================================================
+// Collection
+// ============================================================================
+
+/// Collect git context for `line` of `file`, or `None` if it isn't tracked
+///
+/// `radius` is the number of lines around the failing line to blame, capped
+/// at a few lines so the blame stays short.
+pub fn collect(file: &Path, line: u32, radius: usize) -> Option<GitContext> {
+    let dir = match file.parent() {
+        Some(parent) if !parent.as_os_str().is_empty() => parent,
+        _ => Path::new("."),
+    };
+    let name = file.file_name()?.to_str()?;
+
+    git(dir, &["ls-files", "--error-unmatch", "--", name]).ok()?;
+
+    // git blame rejects a range that runs past the end of the file
+    let line_count =
+        std::fs::read_to_string(file).map_or(0, |contents| contents.lines().count()) as u32;
+    let radius = radius.min(MAX_BLAME_RADIUS) as u32;
+    let start = line.saturating_sub(radius).max(1);
+    let end = (line + radius).min(line_count).max(line);
+    let range = format!("{},{}", start, end);
+    let porcelain = git(
+        dir,
+        &["blame", "--line-porcelain", "-L", &range, "--", name],
+    )
+    .unwrap_or_default();
+
+    let diff = git(
+        dir,
+        &["diff", "HEAD", "--no-color", "--no-ext-diff", "--", name],
+    )
+    .ok()
+    .filter(|diff| !diff.trim().is_empty());
+
+    let log_count = format!("-n{}", RECENT_COMMITS);
+    let commits = git(
+        dir,
+        &[
+            "log",
+            &log_count,
+            "--date=short",
+            "--format=%h %ad %an: %s",
+            "--",
+            name,
+        ],
+    )
+    .map(|log| log.lines().map(str::to_string).collect())
+    .unwrap_or_default();
+
+    Some(GitContext {
+        file: file.to_path_buf(),
+        line,
+        blame: parse_blame(&porcelain),
+        diff,
+        commits,
+    })
+}
+
+/// Run git in `dir`, returning stdout or failing with its stderr
+pub fn git(dir: &Path, args: &[&str]) -> Result<String> {
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(dir)
+        .args(args)
+        .stdin(Stdio::null())
+        .output()
+        .context("Failed to run git")?;
+    if !output.status.success() {
+        bail!(
+            "git {} failed: {}",
+            args.join(" "),
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).to_string())
+}
+
+/// Parse `git blame --line-porcelain` into ranges of lines per commit
+pub fn parse_blame(porcelain: &str) -> Vec<BlameRange> {
+    let mut ranges: Vec<BlameRange> = Vec::new();
+    let mut current: Option<BlameRange> = None;
+
+    for line in porcelain.lines() {
+        if let Some(_content) = line.strip_prefix('\t') {
+            // The source line ends each entry
+            let Some(entry) = current.take() else {
+                continue;
+            };
+            match ranges.last_mut() {
+                Some(last) if last.commit == entry.commit && last.end + 1 == entry.start => {
+                    last.end = entry.start;
+                }
+                _ => ranges.push(entry),
+            }
+            continue;
+        }
+
+        let Some(entry) = current.as_mut() else {
+            // Header: <sha> <orig line> <final line> [<group size>]
+            let mut parts = line.split_whitespace();
+            let (Some(sha), Some(_), Some(final_line)) = (parts.next(), parts.next(), parts.next())
+            else {
+                continue;
+            };
+            let Ok(final_line) = final_line.parse() else {
+                continue;
+            };
+            current = Some(BlameRange {
+                start: final_line,
+                end: final_line,
+                commit: sha.chars().take(7).collect(),
+                author: String::new(),
+                time: 0,
+                summary: String::new(),
+            });
+            continue;
+        };
+
+        if let Some(author) = line.strip_prefix("author ") {
+            entry.author = author.to_string();
+        } else if let Some(time) = line.strip_prefix("author-time ") {
+            entry.time = time.parse().unwrap_or(0);
+        } else if let Some(summary) = line.strip_prefix("summary ") {
+            entry.summary = summary.to_string();
+        }
+    }
+    ranges
+}
+
+fn now() -> i64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map_or(0, |d| d.as_secs() as i64)
+}
+
+/// "just now", "3 hours ago", "yesterday", "5 days ago", "2 months ago"
+pub fn relative_age(seconds: i64) -> String {
+    const HOUR: i64 = 60 * 60;
+    const DAY: i64 = 24 * HOUR;
+    let plural = |n: i64, unit: &str| {
+        if n == 1 {
+            format!("1 {} ago", unit)
+        } else {
+            format!("{} {}s ago", n, unit)
+        }
+    };
+    match seconds.max(0) {
+        s if s < 60 => "just now".to_string(),
+        s if s < HOUR => plural(s / 60, "minute"),
+        s if s < DAY => plural(s / HOUR, "hour"),
+        s if s < 2 * DAY => "yesterday".to_string(),
+        s if s < 30 * DAY => plural(s / DAY, "day"),
+        s if s < 365 * DAY => plural(s / (30 * DAY), "month"),
+        s => plural(s / (365 * DAY), "year"),
+    }
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+/// Scratch repository for tests that need real git history; removed on drop
+#[cfg(test)]
+pub(crate) struct TestRepo {
+    pub dir: PathBuf,
+}
+
+#[cfg(test)]
+impl TestRepo {
+    /// Initialize `<temp>/<name>-<pid>`, or `None` if git isn't installed
+    pub fn init(name: &str) -> Option<Self> {
+        let dir = std::env::temp_dir().join(format!("{}-{}", name, std::process::id()));
+        std::fs::create_dir_all(&dir).unwrap();
+        let repo = Self { dir };
+        repo.git(&["init", "-q"]).then_some(repo)
+    }
+
+    /// Run git in the repository as Ann Lee, returning whether it succeeded
+    pub fn git(&self, args: &[&str]) -> bool {
+        Command::new("git")
+            .arg("-C")
+            .arg(&self.dir)
+            .args([
+                "-c",
+                "user.name=Ann Lee",
+                "-c",
+                "user.email=ann@example.com",
+            ])
+            .args(args)
+            .output()
+            .is_ok_and(|o| o.status.success())
+    }
+}
+
+#[cfg(test)]
+impl Drop for TestRepo {
+    fn drop(&mut self) {
+        std::fs::remove_dir_all(&self.dir).ok();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const PORCELAIN: &str = "\
+1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b 11 40 2
+author Ann Lee
+author-mail <ann@example.com>
+author-time 1760000000
+author-tz +0000
+committer Ann Lee
+summary Parse ports as u16
+filename src/config.rs
+\tlet host = cfg.host;
+1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b 12 41
+author Ann Lee
+author-mail <ann@example.com>
+author-time 1760000000
+author-tz +0000
+summary Parse ports as u16
+filename src/config.rs
+\tlet port: u16 = cfg.port.parse()?;
+0000000000000000000000000000000000000000 42 42 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1760500000
+author-tz +0000
+summary Version of src/config.rs from src/config.rs
+filename src/config.rs
+\tlet addr = format!(\"{}:{}\", host, port).unwrap();
+";
+
+    #[test]
+    fn test_parse_blame_groups_lines_by_commit() {
+        let blame = parse_blame(PORCELAIN);
+        assert_eq!(blame.len(), 2);
+        assert_eq!(blame[0].start, 40);
+        assert_eq!(blame[0].end, 41);
+        assert_eq!(blame[0].commit, "1a2b3c4");
+        assert_eq!(blame[0].author, "Ann Lee");
+        assert_eq!(
+            blame[0].describe(1760000000 + 26 * 60 * 60),
+            "changed yesterday by Ann Lee in 1a2b3c4 (Parse ports as u16)"
+        );
+        assert!(blame[1].is_uncommitted());
+        assert_eq!(blame[1].describe(0), "uncommitted change");
+    }
+
+    #[test]
+    fn test_relative_age() {
+        assert_eq!(relative_age(5), "just now");
+        assert_eq!(relative_age(60), "1 minute ago");
+        assert_eq!(relative_age(3 * 60 * 60), "3 hours ago");
+        assert_eq!(relative_age(5 * 24 * 60 * 60), "5 days ago");
+        assert_eq!(relative_age(400 * 24 * 60 * 60), "1 year ago");
+    }
+
+    #[test]
+    fn test_prompt_text_fits_budget() {
+        let context = GitContext {
+            file: PathBuf::from("src/config.rs"),
+            line: 42,
+            blame: parse_blame(PORCELAIN),
+            diff: Some(format!("@@ -42 +42 @@\n{}", "+changed line\n".repeat(50))),
+            commits: vec!["1a2b3c4 2025-10-09 Ann Lee: Parse ports as u16".to_string()],
+        };
+        assert_eq!(
+            context.line_summary().as_deref(),
+            Some("uncommitted change")
+        );
+
+        let text = context.prompt_text(400);
+        assert!(text.len() <= 400);
+        assert!(text.starts_with("Git history for src/config.rs:\n- lines 40-41: changed "));
+        assert!(text.contains("- line 42: uncommitted change\n"));
+        assert!(text.contains("Recent commits to this file:\n- 1a2b3c4"));
+        assert!(text.ends_with("... (diff truncated)"));
+
+        let short = context.prompt_text(0);
+        assert!(!short.contains("Recent commits"));
+        assert!(!short.contains("Uncommitted changes"));
+    }
+
+    #[test]
+    fn test_collect_from_repository() {
+        let Some(repo) = TestRepo::init("why-git") else {
+            // git isn't installed
+            return;
+        };
+        let file = repo.dir.join("app.py");
+        std::fs::write(&file, "a = 1\nb = 2\nc = a / 0\n").unwrap();
+        assert!(repo.git(&["add", "app.py"]));
+        assert!(repo.git(&["commit", "-q", "-m", "Add app"]));
+        std::fs::write(&file, "a = 1\nb = 2\nc = a / b\n").unwrap();
+
+        assert!(collect(&repo.dir.join("untracked.py"), 1, 5).is_none());
+        assert!(git(&repo.dir, &["rev-parse", "--verify", "nope"])
+            .unwrap_err()
+            .to_string()
+            .starts_with("git rev-parse --verify nope failed"));
+
+        let context = collect(&file, 3, 5).unwrap();
+        assert_eq!(context.blame.len(), 2);
+        assert!(context.blame[0].describe(now()).contains("by Ann Lee"));
+        assert_eq!(
+            context.line_summary().as_deref(),
+            Some("uncommitted change")
+        );
+        assert!(context.diff.unwrap().contains("+c = a / b"));
+        assert_eq!(context.commits.len(), 1);
+        assert!(context.commits[0].ends_with("Ann Lee: Add app"));
+    }
+}
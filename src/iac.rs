@@ -0,0 +1,954 @@
+//! Infrastructure-as-code error parsing.
+//!
+//! Terraform diagnostics, Ansible task failures and Helm template errors all
+//! name a file and line, a resource or task, and often carry a JSON payload.
+//! These are pulled out so the file can be shown as the location, read for
+//! `--context` snippets, and summarized for the model instead of the full
+//! plan or play output.
+
+use regex::Regex;
+use serde::Serialize;
+use serde_json::Value;
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+use crate::stack_trace::{strip_ansi, Diagnostic, Severity, StackFrame};
+
+/// Lines kept from stdout/stderr fields of an Ansible task result
+const RESULT_TAIL_LINES: usize = 10;
+
+/// Lines of a multi-line Ansible result read while looking for valid JSON
+const MAX_RESULT_LINES: usize = 500;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Tool that produced the output
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum IacTool {
+    Terraform,
+    Ansible,
+    Helm,
+}
+
+impl fmt::Display for IacTool {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            IacTool::Terraform => write!(f, "Terraform"),
+            IacTool::Ansible => write!(f, "Ansible"),
+            IacTool::Helm => write!(f, "Helm"),
+        }
+    }
+}
+
+/// A single error or warning reported by the tool
+#[derive(Debug, Clone, Serialize)]
+pub struct IacError {
+    /// Error or warning
+    pub severity: Severity,
+    /// One-line summary (Terraform's "Error:" line, Ansible's `msg`)
+    pub summary: String,
+    /// Explanation printed below the summary
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub detail: Option<String>,
+    /// File the error is in
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<PathBuf>,
+    /// Line in that file (1-based)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<u32>,
+    /// Column in that line (1-based)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub column: Option<u32>,
+    /// Enclosing Terraform block (e.g. `resource "aws_instance" "web"`)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub block: Option<String>,
+    /// Terraform resource address (e.g. "module.app.aws_instance.web")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub address: Option<String>,
+    /// Source lines the tool printed with the error
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub snippet: Option<String>,
+    /// Ansible task name
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub task: Option<String>,
+    /// Ansible host
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub host: Option<String>,
+    /// Ansible loop item
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub item: Option<String>,
+    /// Ansible status ("FAILED", "UNREACHABLE")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub status: Option<String>,
+    /// Decoded Ansible task result
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub result: Option<Value>,
+    /// Helm template expression or Kubernetes schema path being evaluated
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub expression: Option<String>,
+    /// The output lines this error was parsed from
+    #[serde(skip)]
+    pub raw: Vec<String>,
+}
+
+impl IacError {
+    fn new(severity: Severity, summary: impl Into<String>) -> Self {
+        Self {
+            severity,
+            summary: summary.into(),
+            detail: None,
+            file: None,
+            line: None,
+            column: None,
+            block: None,
+            address: None,
+            snippet: None,
+            task: None,
+            host: None,
+            item: None,
+            status: None,
+            result: None,
+            expression: None,
+            raw: Vec::new(),
+        }
+    }
+
+    /// "main.tf:12", "site.yml:5:3"
+    pub fn location(&self) -> Option<String> {
+        let file = self.file.as_ref()?.display().to_string();
+        Some(match (self.line, self.column) {
+            (Some(line), Some(column)) => format!("{}:{}:{}", file, line, column),
+            (Some(line), None) => format!("{}:{}", file, line),
+            _ => file,
+        })
+    }
+
+    /// What the error is attached to: a resource, a task or an expression
+    pub fn subject(&self) -> Option<String> {
+        if let Some(address) = &self.address {
+            return Some(address.clone());
+        }
+        if let Some(task) = &self.task {
+            return Some(format!("TASK [{}]", task));
+        }
+        self.expression.clone().or_else(|| self.block.clone())
+    }
+
+    /// Convert the error location into a stack frame
+    pub fn to_frame(&self) -> Option<StackFrame> {
+        let mut frame = StackFrame::new().with_file(self.file.clone()?);
+        frame.line = self.line;
+        frame.column = self.column;
+        frame.function = self.subject();
+        frame.context = self.snippet.clone();
+        Some(frame)
+    }
+
+    /// Convert the error into a diagnostic
+    pub fn to_diagnostic(&self) -> Diagnostic {
+        let mut diagnostic = Diagnostic::new(self.severity, &self.summary);
+        if let Some(file) = &self.file {
+            diagnostic = diagnostic.with_location(file, self.line, self.column);
+        }
+        diagnostic.code_frame = self.snippet.clone();
+        diagnostic
+    }
+
+    fn result_str(&self, key: &str) -> Option<&str> {
+        self.result
+            .as_ref()?
+            .get(key)?
+            .as_str()
+            .filter(|s| !s.trim().is_empty())
+    }
+
+    fn result_lines(&self) -> Vec<String> {
+        let Some(result) = &self.result else {
+            return Vec::new();
+        };
+        let mut out = Vec::new();
+        if let Some(rc) = result.get("rc").and_then(Value::as_i64) {
+            out.push(format!("rc: {}", rc));
+        }
+        match result.get("cmd") {
+            Some(Value::String(cmd)) => out.push(format!("cmd: {}", cmd)),
+            Some(Value::Array(args)) => {
+                let args: Vec<&str> = args.iter().filter_map(Value::as_str).collect();
+                out.push(format!("cmd: {}", args.join(" ")));
+            }
+            _ => {}
+        }
+        for key in ["module_stderr", "stderr", "module_stdout", "stdout"] {
+            if key.ends_with("stdout") && out.iter().any(|l| l.contains("stderr:")) {
+                continue;
+            }
+            if let Some(text) = self.result_str(key) {
+                out.push(format!("{}:", key));
+                out.extend(tail(text, RESULT_TAIL_LINES).map(|l| format!("  {}", l)));
+            }
+        }
+        // Loops report "All items completed" and put the failures in results
+        if let Some(Value::Array(items)) = result.get("results") {
+            for item in items {
+                if item.get("failed").and_then(Value::as_bool) != Some(true) {
+                    continue;
+                }
+                let label = item
+                    .get("item")
+                    .map(|i| i.as_str().map(str::to_string).unwrap_or(i.to_string()))
+                    .unwrap_or_default();
+                let msg = item.get("msg").and_then(Value::as_str).unwrap_or("failed");
+                out.push(format!("item {}: {}", label, first_line(msg)));
+            }
+        }
+        out
+    }
+}
+
+/// Errors parsed from Terraform, Ansible or Helm output
+#[derive(Debug, Clone, Serialize)]
+pub struct IacReport {
+    /// Tool that produced the output
+    pub tool: IacTool,
+    /// Errors and warnings, in the order they were printed
+    pub errors: Vec<IacError>,
+}
+
+impl IacReport {
+    /// The first error, or the first warning if there were no errors
+    pub fn primary(&self) -> Option<&IacError> {
+        self.errors
+            .iter()
+            .find(|e| e.severity == Severity::Error)
+            .or_else(|| self.errors.first())
+    }
+
+    /// Stack trace error type for the report
+    pub fn error_type(&self) -> &'static str {
+        match self.tool {
+            IacTool::Terraform => "TerraformError",
+            IacTool::Ansible => {
+                let status = self.primary().and_then(|e| e.status.as_deref());
+                match status {
+                    Some("UNREACHABLE") => "AnsibleHostUnreachable",
+                    Some(_) => "AnsibleTaskFailed",
+                    None => "AnsibleError",
+                }
+            }
+            IacTool::Helm => "HelmError",
+        }
+    }
+
+    /// Location frames, one per error that names a file
+    pub fn frames(&self) -> Vec<StackFrame> {
+        self.errors.iter().filter_map(IacError::to_frame).collect()
+    }
+
+    /// The error blocks alone, without the plan or play output around them
+    pub fn focused_input(&self) -> String {
+        self.errors
+            .iter()
+            .map(|e| e.raw.join("\n"))
+            .collect::<Vec<_>>()
+            .join("\n\n")
+    }
+
+    /// Render the report as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = format!("{} errors:\n", self.tool);
+        for error in &self.errors {
+            let mut heading = format!("- {}: {}", error.severity, error.summary);
+            if let (Some(host), Some(status)) = (&error.host, &error.status) {
+                heading.push_str(&format!(" ({} on {})", status, host));
+            }
+            out.push_str(&heading);
+            out.push('\n');
+
+            let mut place = Vec::new();
+            if let Some(location) = error.location() {
+                place.push(format!("at {}", location));
+            }
+            if let Some(block) = &error.block {
+                place.push(format!("in {}", block));
+            }
+            if let Some(subject) = error.subject().filter(|s| Some(s) != error.block.as_ref()) {
+                place.push(format!("for {}", subject));
+            }
+            if let Some(item) = &error.item {
+                place.push(format!("item {}", item));
+            }
+            if !place.is_empty() {
+                out.push_str(&format!("  {}\n", place.join(" ")));
+            }
+            if let Some(snippet) = &error.snippet {
+                for line in snippet.lines() {
+                    out.push_str(&format!("    {}\n", line));
+                }
+            }
+            if let Some(detail) = &error.detail {
+                for line in detail.lines() {
+                    out.push_str(&format!("  {}\n", line));
+                }
+            }
+            for line in error.result_lines() {
+                out.push_str(&format!("  {}\n", line));
+            }
+        }
+        out.trim_end().to_string()
+    }
+}
+
+fn tail(text: &str, count: usize) -> impl Iterator<Item = &str> {
+    let lines: Vec<&str> = text.trim_end().lines().collect();
+    let start = lines.len().saturating_sub(count);
+    lines.into_iter().skip(start)
+}
+
+fn first_line(text: &str) -> &str {
+    text.lines().next().unwrap_or_default()
+}
+
+// ============================================================================
+// Detection
+// ============================================================================
+
+struct Patterns {
+    terraform: Regex,
+    terraform_header: Regex,
+    terraform_on: Regex,
+    terraform_source: Regex,
+    ansible: Regex,
+    ansible_task: Regex,
+    ansible_task_path: Regex,
+    ansible_failure: Regex,
+    ansible_location: Regex,
+    helm: Regex,
+    helm_template: Regex,
+    helm_at: Regex,
+    helm_yaml: Regex,
+    helm_values: Regex,
+    helm_validation: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        terraform: Regex::new(
+            r"(?m)^│ (?:Error|Warning): |^\s*on \S+\.(?:tf|tfvars|tf\.json|hcl) line \d+",
+        )
+        .unwrap(),
+        terraform_header: Regex::new(r"^(Error|Warning): (.+)$").unwrap(),
+        terraform_on: Regex::new(r"^\s*on (.+?) line (\d+)(?:, in (.+?))?:$").unwrap(),
+        terraform_source: Regex::new(r"^\s*\d+:(?: .*)?$|^\s+[│├└]").unwrap(),
+        ansible: Regex::new(
+            r"(?m)^(?:fatal|failed): \[[^\]]+\](?:: (?:FAILED|UNREACHABLE)!| \(item=.*\)) => |^ERROR! ",
+        )
+        .unwrap(),
+        ansible_task: Regex::new(r"^(?:TASK|RUNNING HANDLER) \[(.+?)\]").unwrap(),
+        ansible_task_path: Regex::new(r"^task path: (.+):(\d+)$").unwrap(),
+        ansible_failure: Regex::new(
+            r"^(?:fatal|failed): \[([^\]]+)\](?:: (FAILED|UNREACHABLE)!| \(item=(.*)\)) => ?(.*)$",
+        )
+        .unwrap(),
+        ansible_location: Regex::new(
+            r"The error appears to be in '([^']+)': line (\d+), column (\d+)",
+        )
+        .unwrap(),
+        helm: Regex::new(
+            r"(?m)^Error: (?:[A-Z]+ FAILED: |template: \S+:\d+|parse error at \(|execution error at \(|YAML parse error on |failed to parse \S*values\S*\.yaml)",
+        )
+        .unwrap(),
+        helm_template: Regex::new(
+            r#"template: ([^\s:]+):(\d+)(?::(\d+))?: (?:executing "[^"]*" at <([^>]+)>: )?(.+)$"#,
+        )
+        .unwrap(),
+        helm_at: Regex::new(
+            r"(parse|execution) error at \(([^\s:]+):(\d+)(?::(\d+))?\): (.+)$",
+        )
+        .unwrap(),
+        helm_yaml: Regex::new(
+            r"YAML parse error on (\S+?): (?:error converting YAML to JSON: )?(?:yaml: )?(.+)$",
+        )
+        .unwrap(),
+        helm_values: Regex::new(
+            r"failed to parse (\S+): (?:error converting YAML to JSON: )?yaml: line (\d+): (.+)$",
+        )
+        .unwrap(),
+        helm_validation: Regex::new(r"ValidationError\(([^)]+)\): (.+)$").unwrap(),
+    })
+}
+
+/// Check whether input contains a Terraform, Ansible or Helm error
+pub fn is_iac_error(input: &str) -> bool {
+    let p = patterns();
+    p.terraform.is_match(input) || p.ansible.is_match(input) || p.helm.is_match(input)
+}
+
+/// Parse Terraform, Ansible or Helm error output
+pub fn parse_iac_error(input: &str) -> Option<IacReport> {
+    let input = strip_ansi(input);
+    let p = patterns();
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+
+    let (tool, errors) = if p.terraform.is_match(&input) {
+        (IacTool::Terraform, parse_terraform(&lines))
+    } else if p.ansible.is_match(&input) {
+        (IacTool::Ansible, parse_ansible(&lines))
+    } else if p.helm.is_match(&input) {
+        (IacTool::Helm, parse_helm(&lines))
+    } else {
+        return None;
+    };
+
+    if errors.is_empty() {
+        return None;
+    }
+    Some(IacReport { tool, errors })
+}
+
+// ============================================================================
+// Terraform
+// ============================================================================
+
+/// Parse Terraform's diagnostic blocks:
+///
+/// ```text
+/// ╷
+/// │ Error: Unsupported argument
+/// │
+/// │   on main.tf line 12, in resource "aws_instance" "web":
+/// │   12:   ami_id = "ami-123"
+/// │
+/// │ An argument named "ami_id" is not expected here.
+/// ╵
+/// ```
+///
+/// With `-no-color` the box characters are missing and blocks run until the
+/// next diagnostic.
+fn parse_terraform(lines: &[&str]) -> Vec<IacError> {
+    let p = patterns();
+    let mut errors = Vec::new();
+    let mut current: Option<IacError> = None;
+    let mut detail: Vec<String> = Vec::new();
+    let mut blank_run = 0;
+
+    let finish = |error: Option<IacError>, detail: &mut Vec<String>, errors: &mut Vec<IacError>| {
+        if let Some(mut error) = error {
+            let text = detail.join("\n").trim().to_string();
+            if !text.is_empty() {
+                error.detail = Some(text);
+            }
+            if error.address.is_none() {
+                error.address = error.block.as_deref().and_then(block_address);
+            }
+            errors.push(error);
+        }
+        detail.clear();
+    };
+
+    for line in lines {
+        let boxed = line.starts_with('│');
+        let text = line.trim_start_matches('│');
+        let text = text.strip_prefix(' ').unwrap_or(text);
+
+        if line.starts_with('╷') || line.starts_with('╵') {
+            finish(current.take(), &mut detail, &mut errors);
+            continue;
+        }
+
+        if let Some(caps) = p.terraform_header.captures(text) {
+            finish(current.take(), &mut detail, &mut errors);
+            let severity = if &caps[1] == "Error" {
+                Severity::Error
+            } else {
+                Severity::Warning
+            };
+            let mut error = IacError::new(severity, caps[2].trim());
+            error.raw.push(text.to_string());
+            current = Some(error);
+            blank_run = 0;
+            continue;
+        }
+
+        let Some(error) = current.as_mut() else {
+            continue;
+        };
+
+        if text.trim().is_empty() {
+            blank_run += 1;
+            // Unboxed blocks have no end marker; two blank lines end them
+            if !boxed && blank_run >= 2 {
+                finish(current.take(), &mut detail, &mut errors);
+            } else {
+                detail.push(String::new());
+            }
+            continue;
+        }
+        blank_run = 0;
+        error.raw.push(text.to_string());
+
+        if let Some(address) = text
+            .trim()
+            .strip_prefix("with ")
+            .and_then(|a| a.strip_suffix(','))
+        {
+            error.address = Some(address.to_string());
+        } else if let Some(caps) = p.terraform_on.captures(text) {
+            error.file = Some(PathBuf::from(&caps[1]));
+            error.line = caps[2].parse().ok();
+            error.block = caps.get(3).map(|m| m.as_str().to_string());
+        } else if error.file.is_some()
+            && detail.iter().all(String::is_empty)
+            && p.terraform_source.is_match(text)
+        {
+            let snippet = error.snippet.get_or_insert_with(String::new);
+            if !snippet.is_empty() {
+                snippet.push('\n');
+            }
+            snippet.push_str(text.trim_start());
+        } else {
+            detail.push(text.to_string());
+        }
+    }
+    finish(current.take(), &mut detail, &mut errors);
+
+    errors
+}
+
+/// Address of a Terraform block: `resource "aws_instance" "web"` is
+/// "aws_instance.web", `module "vpc"` is "module.vpc"
+fn block_address(block: &str) -> Option<String> {
+    let parts: Vec<&str> = block
+        .split_whitespace()
+        .map(|p| p.trim_matches('"'))
+        .collect();
+    Some(match parts.as_slice() {
+        ["resource", kind, name] => format!("{}.{}", kind, name),
+        ["data", kind, name] => format!("data.{}.{}", kind, name),
+        ["module", name] => format!("module.{}", name),
+        ["variable", name] => format!("var.{}", name),
+        ["output", name] => format!("output.{}", name),
+        ["provider", name] => format!("provider[\"{}\"]", name),
+        _ => return None,
+    })
+}
+
+// ============================================================================
+// Ansible
+// ============================================================================
+
+/// Parse `fatal:`/`failed:` task results and `ERROR!` load errors
+fn parse_ansible(lines: &[&str]) -> Vec<IacError> {
+    let p = patterns();
+    let mut errors = Vec::new();
+    let mut task: Option<String> = None;
+    let mut task_path: Option<(PathBuf, u32)> = None;
+
+    let mut index = 0;
+    while index < lines.len() {
+        let line = lines[index];
+        index += 1;
+
+        if let Some(caps) = p.ansible_task.captures(line) {
+            task = Some(caps[1].to_string());
+            task_path = None;
+            continue;
+        }
+        if let Some(caps) = p.ansible_task_path.captures(line) {
+            task_path = caps[2].parse().ok().map(|l| (PathBuf::from(&caps[1]), l));
+            continue;
+        }
+
+        if let Some(caps) = p.ansible_failure.captures(line) {
+            // The result is usually one line of JSON, but callbacks and -v
+            // can spread it over several
+            let mut raw = vec![line.to_string()];
+            let mut payload = caps[4].to_string();
+            let mut result = serde_json::from_str::<Value>(&payload).ok();
+            if result.is_none() && payload.trim_start().starts_with('{') {
+                let mut end = index;
+                while end < lines.len() && end - index < MAX_RESULT_LINES {
+                    payload.push('\n');
+                    payload.push_str(lines[end]);
+                    end += 1;
+                    if let Ok(value) = serde_json::from_str::<Value>(&payload) {
+                        raw.extend(lines[index..end].iter().map(|l| l.to_string()));
+                        result = Some(value);
+                        index = end;
+                        break;
+                    }
+                }
+            }
+            // "...ignoring" means the play carried on
+            if lines.get(index).is_some_and(|l| l.trim() == "...ignoring") {
+                continue;
+            }
+
+            let summary = result
+                .as_ref()
+                .and_then(|r| r.get("msg"))
+                .and_then(Value::as_str)
+                .map(|m| first_line(m).to_string())
+                .unwrap_or_else(|| first_line(payload.trim()).to_string());
+            let mut error = IacError::new(Severity::Error, summary);
+            error.host = Some(caps[1].to_string());
+            error.status = Some(caps.get(2).map_or("FAILED", |m| m.as_str()).to_string());
+            error.item = caps.get(3).map(|m| m.as_str().to_string());
+            error.task = task.clone();
+            if let Some((file, line)) = &task_path {
+                error.file = Some(file.clone());
+                error.line = Some(*line);
+            }
+            if let Some(msg) = result
+                .as_ref()
+                .and_then(|r| r.get("msg"))
+                .and_then(Value::as_str)
+            {
+                let rest = msg.lines().skip(1).collect::<Vec<_>>().join("\n");
+                if !rest.trim().is_empty() {
+                    error.detail = Some(rest.trim().to_string());
+                }
+            }
+            error.result = result;
+            error.raw = raw;
+            errors.push(error);
+            continue;
+        }
+
+        if let Some(message) = line.strip_prefix("ERROR! ") {
+            // The message runs until the "offending line" snippet ends
+            let start = index - 1;
+            let mut end = index;
+            let mut in_snippet = false;
+            while end < lines.len() {
+                let next = lines[end];
+                if next.starts_with("ERROR! ") || p.ansible_task.is_match(next) {
+                    break;
+                }
+                end += 1;
+                if next.contains("The offending line appears to be:") {
+                    in_snippet = true;
+                } else if in_snippet && next.trim_start().starts_with('^') {
+                    break;
+                }
+            }
+            let block = &lines[start..end];
+            let text = block.join("\n");
+
+            let mut error = IacError::new(Severity::Error, message.trim());
+            if let Some(caps) = p.ansible_location.captures(&text.replace('\n', " ")) {
+                error.file = Some(PathBuf::from(&caps[1]));
+                error.line = caps[2].parse().ok();
+                error.column = caps[3].parse().ok();
+            }
+            if let Some(offset) = block
+                .iter()
+                .position(|l| l.contains("The offending line appears to be:"))
+            {
+                let snippet: Vec<&str> = block[offset + 1..]
+                    .iter()
+                    .copied()
+                    .skip_while(|l| l.trim().is_empty())
+                    .collect();
+                if !snippet.is_empty() {
+                    error.snippet = Some(snippet.join("\n"));
+                }
+            }
+            let detail: Vec<&str> = block[1..]
+                .iter()
+                .copied()
+                .take_while(|l| !l.starts_with("The error appears to be in"))
+                .collect();
+            let detail = detail.join("\n").trim().to_string();
+            if !detail.is_empty() {
+                error.detail = Some(detail);
+            }
+            error.raw = block.iter().map(|l| l.to_string()).collect();
+            errors.push(error);
+            index = end;
+        }
+    }
+
+    errors
+}
+
+// ============================================================================
+// Helm
+// ============================================================================
+
+/// Parse Helm's single-line template, YAML and validation errors
+fn parse_helm(lines: &[&str]) -> Vec<IacError> {
+    let p = patterns();
+    let mut errors = Vec::new();
+
+    for line in lines {
+        let Some(message) = line.strip_prefix("Error: ") else {
+            continue;
+        };
+        let mut error = IacError::new(Severity::Error, message.trim());
+        error.raw.push(line.to_string());
+
+        if let Some(caps) = p.helm_template.captures(message) {
+            error.file = Some(chart_path(&caps[1]));
+            error.line = caps[2].parse().ok();
+            error.column = caps.get(3).and_then(|m| m.as_str().parse().ok());
+            error.expression = caps.get(4).map(|m| format!("<{}>", m.as_str()));
+            error.detail = Some(caps[5].to_string());
+        } else if let Some(caps) = p.helm_at.captures(message) {
+            error.file = Some(chart_path(&caps[2]));
+            error.line = caps[3].parse().ok();
+            error.column = caps.get(4).and_then(|m| m.as_str().parse().ok());
+            error.detail = Some(format!("{} error: {}", &caps[1], &caps[5]));
+        } else if let Some(caps) = p.helm_values.captures(message) {
+            error.file = Some(PathBuf::from(&caps[1]));
+            error.line = caps[2].parse().ok();
+            error.detail = Some(caps[3].to_string());
+        } else if let Some(caps) = p.helm_yaml.captures(message) {
+            // The YAML line is in the rendered manifest, not the template
+            error.file = Some(chart_path(&caps[1]));
+            error.detail = Some(format!("in the rendered manifest: {}", &caps[2]));
+        } else if let Some(caps) = p.helm_validation.captures(message) {
+            error.expression = Some(caps[1].to_string());
+            error.detail = Some(caps[2].to_string());
+        }
+        errors.push(error);
+    }
+
+    errors
+}
+
+/// Helm names templates from the chart's parent directory
+/// ("mychart/templates/x.yaml"); when run from inside the chart, use the path
+/// that exists
+fn chart_path(name: &str) -> PathBuf {
+    let path = PathBuf::from(name);
+    if path.exists() {
+        return path;
+    }
+    let mut components = Path::new(name).components();
+    components.next();
+    let inner = components.as_path();
+    if !inner.as_os_str().is_empty() && inner.exists() {
+        inner.to_path_buf()
+    } else {
+        path
+    }
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parses_terraform_boxed_diagnostics() {
+        let input = "\
+aws_security_group.web: Refreshing state... [id=sg-0123]
+
+Planning failed. Terraform encountered an error while generating this plan.
+
+╷
+│ Error: Unsupported argument
+│
+│   on main.tf line 12, in resource \"aws_instance\" \"web\":
+│   12:   ami_id        = \"ami-0c55b159cbfafe1f0\"
+│
+│ An argument named \"ami_id\" is not expected here. Did you mean \"ami\"?
+╵
+╷
+│ Warning: Deprecated attribute
+│
+│   with module.network.aws_subnet.private,
+│   on modules/network/main.tf line 30, in resource \"aws_subnet\" \"private\":
+│   30:   map_public_ip_on_launch = var.public
+│
+│ The attribute is deprecated.
+╵";
+
+        assert!(is_iac_error(input));
+        let report = parse_iac_error(input).unwrap();
+        assert_eq!(report.tool, IacTool::Terraform);
+        assert_eq!(report.errors.len(), 2);
+
+        let error = &report.errors[0];
+        assert_eq!(error.severity, Severity::Error);
+        assert_eq!(error.summary, "Unsupported argument");
+        assert_eq!(error.file.as_deref(), Some(Path::new("main.tf")));
+        assert_eq!(error.line, Some(12));
+        assert_eq!(
+            error.block.as_deref(),
+            Some("resource \"aws_instance\" \"web\"")
+        );
+        assert_eq!(error.address.as_deref(), Some("aws_instance.web"));
+        assert_eq!(
+            error.snippet.as_deref(),
+            Some("12:   ami_id        = \"ami-0c55b159cbfafe1f0\"")
+        );
+        assert!(error
+            .detail
+            .as_deref()
+            .unwrap()
+            .contains("Did you mean \"ami\"?"));
+
+        let warning = &report.errors[1];
+        assert_eq!(warning.severity, Severity::Warning);
+        assert_eq!(
+            warning.address.as_deref(),
+            Some("module.network.aws_subnet.private")
+        );
+
+        let frames = report.frames();
+        assert_eq!(frames.len(), 2);
+        assert_eq!(frames[0].function.as_deref(), Some("aws_instance.web"));
+        assert!(!report.focused_input().contains("Refreshing state"));
+    }
+
+    #[test]
+    fn test_parses_terraform_without_color() {
+        let input = "\
+Error: creating EC2 Instance: InvalidAMIID.NotFound: The image id '[ami-123]' does not exist
+
+  with aws_instance.web,
+  on main.tf line 3, in resource \"aws_instance\" \"web\":
+   3: resource \"aws_instance\" \"web\" {
+";
+
+        let report = parse_iac_error(input).unwrap();
+        let error = &report.errors[0];
+        assert!(error.summary.starts_with("creating EC2 Instance"));
+        assert_eq!(error.address.as_deref(), Some("aws_instance.web"));
+        assert_eq!(error.line, Some(3));
+        assert_eq!(
+            error.snippet.as_deref(),
+            Some("3: resource \"aws_instance\" \"web\" {")
+        );
+        assert!(error.detail.is_none());
+    }
+
+    #[test]
+    fn test_parses_ansible_task_failure() {
+        let input = r#"PLAY [web] *********************************************************************
+
+TASK [Gathering Facts] *********************************************************
+ok: [web1]
+
+TASK [Install packages] ********************************************************
+task path: /home/deploy/site.yml:14
+fatal: [web1]: FAILED! => {"changed": false, "cmd": ["apt-get", "install", "-y", "nginxx"], "msg": "non-zero return code", "rc": 100, "stderr": "E: Unable to locate package nginxx\n", "stdout": ""}
+
+PLAY RECAP *********************************************************************
+web1                       : ok=1    changed=0    unreachable=0    failed=1"#;
+
+        assert!(is_iac_error(input));
+        let report = parse_iac_error(input).unwrap();
+        assert_eq!(report.tool, IacTool::Ansible);
+        assert_eq!(report.error_type(), "AnsibleTaskFailed");
+
+        let error = &report.errors[0];
+        assert_eq!(error.summary, "non-zero return code");
+        assert_eq!(error.task.as_deref(), Some("Install packages"));
+        assert_eq!(error.host.as_deref(), Some("web1"));
+        assert_eq!(
+            error.file.as_deref(),
+            Some(Path::new("/home/deploy/site.yml"))
+        );
+        assert_eq!(error.line, Some(14));
+        assert_eq!(error.result.as_ref().unwrap()["rc"], 100);
+
+        let summary = report.prompt_summary();
+        assert!(summary.contains("- error: non-zero return code (FAILED on web1)"));
+        assert!(summary.contains("at /home/deploy/site.yml:14 for TASK [Install packages]"));
+        assert!(summary.contains("cmd: apt-get install -y nginxx"));
+        assert!(summary.contains("  E: Unable to locate package nginxx"));
+    }
+
+    #[test]
+    fn test_parses_ansible_unreachable_and_ignored() {
+        let input = r#"TASK [Check] *******************************************************************
+fatal: [db1]: FAILED! => {"changed": false, "msg": "optional check"}
+...ignoring
+
+TASK [Ping] ********************************************************************
+fatal: [web2]: UNREACHABLE! => {"changed": false, "msg": "Failed to connect to the host via ssh: ssh: connect to host 10.0.0.5 port 22: Connection timed out", "unreachable": true}"#;
+
+        let report = parse_iac_error(input).unwrap();
+        assert_eq!(report.errors.len(), 1);
+        assert_eq!(report.error_type(), "AnsibleHostUnreachable");
+        assert_eq!(report.errors[0].task.as_deref(), Some("Ping"));
+        assert!(report.errors[0].file.is_none());
+    }
+
+    #[test]
+    fn test_parses_ansible_syntax_error() {
+        let input = "\
+ERROR! Syntax Error while loading YAML.
+  did not find expected key
+
+The error appears to be in '/home/deploy/site.yml': line 7, column 5, but may
+be elsewhere in the file depending on the exact syntax problem.
+
+The offending line appears to be:
+
+    - name: Install packages
+    apt:
+    ^ here";
+
+        let report = parse_iac_error(input).unwrap();
+        let error = &report.errors[0];
+        assert_eq!(error.summary, "Syntax Error while loading YAML.");
+        assert_eq!(error.detail.as_deref(), Some("did not find expected key"));
+        assert_eq!(error.line, Some(7));
+        assert_eq!(error.column, Some(5));
+        assert!(error.snippet.as_deref().unwrap().ends_with("^ here"));
+        assert_eq!(report.error_type(), "AnsibleError");
+    }
+
+    #[test]
+    fn test_parses_helm_template_error() {
+        let input = "Error: INSTALLATION FAILED: template: mychart/templates/deployment.yaml:21:24: executing \"mychart/templates/deployment.yaml\" at <.Values.image.repo>: nil pointer evaluating interface {}.repo";
+
+        assert!(is_iac_error(input));
+        let report = parse_iac_error(input).unwrap();
+        assert_eq!(report.tool, IacTool::Helm);
+        let error = &report.errors[0];
+        assert_eq!(
+            error.file.as_deref(),
+            Some(Path::new("mychart/templates/deployment.yaml"))
+        );
+        assert_eq!(error.line, Some(21));
+        assert_eq!(error.column, Some(24));
+        assert_eq!(error.expression.as_deref(), Some("<.Values.image.repo>"));
+        assert_eq!(
+            error.detail.as_deref(),
+            Some("nil pointer evaluating interface {}.repo")
+        );
+
+        let input = "Error: UPGRADE FAILED: YAML parse error on mychart/templates/service.yaml: error converting YAML to JSON: yaml: line 12: did not find expected key";
+        let error = &parse_iac_error(input).unwrap().errors[0];
+        assert!(error.line.is_none());
+        assert_eq!(
+            error.detail.as_deref(),
+            Some("in the rendered manifest: line 12: did not find expected key")
+        );
+    }
+
+    #[test]
+    fn test_block_address() {
+        assert_eq!(
+            block_address("data \"aws_ami\" \"ubuntu\"").as_deref(),
+            Some("data.aws_ami.ubuntu")
+        );
+        assert_eq!(
+            block_address("module \"vpc\"").as_deref(),
+            Some("module.vpc")
+        );
+        assert_eq!(block_address("locals"), None);
+    }
+}
@@ -0,0 +1,825 @@
+//! JVM thread dump and OutOfMemoryError analysis.
+//!
+//! Splits jstack / `kill -3` thread dumps into per-thread stacks with their
+//! states and the locks each thread holds or waits for, reads the JVM's own
+//! deadlock report, and finds stacks shared by many busy threads. For
+//! OutOfMemoryError it pulls the heap and metaspace regions from the GC
+//! summary so the explanation can say which region filled up.
+
+use regex::Regex;
+use serde::Serialize;
+use std::sync::OnceLock;
+
+use crate::stack_trace::strip_ansi;
+
+/// Frames compared when grouping threads into hot stacks
+const HOT_STACK_DEPTH: usize = 5;
+
+/// Hot stacks kept in the report
+const MAX_HOT_STACKS: usize = 3;
+
+// ============================================================================
+// Thread Dumps
+// ============================================================================
+
+/// A monitor or `java.util.concurrent` lock
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct LockRef {
+    /// Object address (e.g. "0x000000076ab62208")
+    pub address: String,
+    /// Class of the lock object (e.g. "java.lang.Object")
+    pub class: String,
+}
+
+impl LockRef {
+    /// "<0x000000076ab62208> (java.lang.Object)"
+    pub fn describe(&self) -> String {
+        format!("<{}> ({})", self.address, self.class)
+    }
+}
+
+/// One thread from a thread dump
+#[derive(Debug, Clone, Serialize)]
+pub struct JavaThread {
+    /// Thread name
+    pub name: String,
+    /// Whether it's a daemon thread
+    pub daemon: bool,
+    /// `java.lang.Thread.State` (e.g. "BLOCKED (on object monitor)")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub state: Option<String>,
+    /// Frames, innermost first, as printed after "at "
+    pub frames: Vec<String>,
+    /// Monitors and ownable synchronizers the thread holds
+    pub holds: Vec<LockRef>,
+    /// Lock the thread is blocked on or waiting for
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub waiting_for: Option<LockRef>,
+}
+
+impl JavaThread {
+    /// "BLOCKED" from "BLOCKED (on object monitor)"
+    pub fn state_name(&self) -> &str {
+        self.state
+            .as_deref()
+            .and_then(|s| s.split_whitespace().next())
+            .unwrap_or("UNKNOWN")
+    }
+}
+
+/// One edge of a deadlock cycle: a thread, the lock it wants and its owner
+#[derive(Debug, Clone, Serialize)]
+pub struct DeadlockEdge {
+    /// Waiting thread
+    pub thread: String,
+    /// Lock it's waiting for
+    pub waiting_for: LockRef,
+    /// Thread holding that lock
+    pub held_by: String,
+}
+
+/// A stack shared by several busy threads
+#[derive(Debug, Clone, Serialize)]
+pub struct HotStack {
+    /// State the threads are in
+    pub state: String,
+    /// Top frames of the shared stack
+    pub frames: Vec<String>,
+    /// Threads with this stack
+    pub threads: Vec<String>,
+}
+
+/// A parsed thread dump
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ThreadDump {
+    /// VM banner (e.g. "OpenJDK 64-Bit Server VM (17.0.2+8 mixed mode)")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub vm: Option<String>,
+    /// Every thread with a stack, in dump order
+    pub threads: Vec<JavaThread>,
+    /// Deadlock cycles the JVM reported
+    pub deadlocks: Vec<Vec<DeadlockEdge>>,
+    /// Stacks shared by two or more RUNNABLE or BLOCKED threads
+    pub hot_stacks: Vec<HotStack>,
+}
+
+impl ThreadDump {
+    /// Find a thread by name
+    pub fn thread(&self, name: &str) -> Option<&JavaThread> {
+        self.threads.iter().find(|t| t.name == name)
+    }
+
+    /// Number of threads in each state, most common first
+    pub fn state_counts(&self) -> Vec<(String, usize)> {
+        let mut counts: Vec<(String, usize)> = Vec::new();
+        for thread in &self.threads {
+            let state = thread.state_name();
+            match counts.iter_mut().find(|(s, _)| s == state) {
+                Some((_, count)) => *count += 1,
+                None => counts.push((state.to_string(), 1)),
+            }
+        }
+        counts.sort_by(|a, b| b.1.cmp(&a.1));
+        counts
+    }
+
+    /// "threads "A" and "B" deadlock on locks <0x1> (X) / <0x2> (Y)"
+    pub fn deadlock_summary(&self) -> Option<String> {
+        let cycle = self.deadlocks.first()?;
+        let names: Vec<String> = cycle.iter().map(|e| format!("\"{}\"", e.thread)).collect();
+        let locks: Vec<String> = cycle.iter().map(|e| e.waiting_for.describe()).collect();
+        let names = match names.len() {
+            0 | 1 => names.join(""),
+            2 => format!("{} and {}", names[0], names[1]),
+            n => format!("{} and {}", names[..n - 1].join(", "), names[n - 1]),
+        };
+        Some(format!(
+            "threads {} deadlock on locks {}",
+            names,
+            locks.join(" / ")
+        ))
+    }
+
+    /// "42 threads: 30 WAITING, 8 RUNNABLE, 4 BLOCKED"
+    pub fn summary(&self) -> String {
+        let counts: Vec<String> = self
+            .state_counts()
+            .iter()
+            .map(|(state, count)| format!("{} {}", count, state))
+            .collect();
+        format!("{} threads: {}", self.threads.len(), counts.join(", "))
+    }
+
+    /// The thread an explanation should start from: the first deadlocked
+    /// thread, then the busiest hot stack, then "main"
+    pub fn focus_thread(&self) -> Option<&JavaThread> {
+        self.deadlocks
+            .first()
+            .and_then(|cycle| cycle.first())
+            .and_then(|edge| self.thread(&edge.thread))
+            .or_else(|| {
+                self.hot_stacks
+                    .first()
+                    .and_then(|hot| self.thread(&hot.threads[0]))
+            })
+            .or_else(|| self.thread("main"))
+    }
+}
+
+// ============================================================================
+// OutOfMemoryError
+// ============================================================================
+
+/// A heap or metaspace region from the GC summary
+#[derive(Debug, Clone, Serialize)]
+pub struct MemoryRegion {
+    /// Region name (e.g. "ParOldGen", "eden space", "Metaspace")
+    pub name: String,
+    /// Capacity in KB
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub total_kb: Option<u64>,
+    /// Used KB
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub used_kb: Option<u64>,
+    /// Committed KB (Metaspace)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub committed_kb: Option<u64>,
+    /// Reserved KB (Metaspace)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reserved_kb: Option<u64>,
+    /// Percent used
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub percent_used: Option<u32>,
+}
+
+impl MemoryRegion {
+    /// "ParOldGen: 174000K used of 175104K (99%)"
+    pub fn describe(&self) -> String {
+        let mut out = format!("{}:", self.name);
+        match (self.used_kb, self.total_kb.or(self.committed_kb)) {
+            (Some(used), Some(total)) => out.push_str(&format!(" {}K used of {}K", used, total)),
+            (Some(used), None) => out.push_str(&format!(" {}K used", used)),
+            (None, Some(total)) => out.push_str(&format!(" {}K", total)),
+            (None, None) => {}
+        }
+        if let Some(reserved) = self.reserved_kb {
+            out.push_str(&format!(", {}K reserved", reserved));
+        }
+        if let Some(percent) = self.percent_used {
+            out.push_str(&format!(" ({}%)", percent));
+        }
+        out
+    }
+}
+
+/// An OutOfMemoryError with whatever memory state was printed with it
+#[derive(Debug, Clone, Serialize)]
+pub struct OutOfMemory {
+    /// What ran out (e.g. "Java heap space", "Metaspace")
+    pub kind: String,
+    /// Thread that hit the error
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub thread: Option<String>,
+    /// Heap dump written by -XX:+HeapDumpOnOutOfMemoryError
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub heap_dump: Option<String>,
+    /// Regions from the GC heap summary
+    pub regions: Vec<MemoryRegion>,
+    /// Last GC pause logged before the error
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_gc: Option<String>,
+}
+
+impl OutOfMemory {
+    /// What this kind of OutOfMemoryError means
+    pub fn meaning(&self) -> Option<&'static str> {
+        let kind = self.kind.to_lowercase();
+        Some(if kind.contains("java heap space") {
+            "the heap (-Xmx) is full of reachable objects: a leak, a large \
+             allocation, or a heap too small for the workload"
+        } else if kind.contains("gc overhead limit") {
+            "the JVM spent nearly all its time in GC while freeing almost \
+             nothing; the heap is effectively full"
+        } else if kind.contains("compressed class space") {
+            "compressed class pointers ran out of space \
+             (-XX:CompressedClassSpaceSize)"
+        } else if kind.contains("metaspace") {
+            "class metadata filled -XX:MaxMetaspaceSize, usually a classloader \
+             leak or many generated classes"
+        } else if kind.contains("native thread") {
+            "the OS refused a new thread: process or container thread limits \
+             (ulimit -u, pids.max) or no memory left for thread stacks"
+        } else if kind.contains("direct buffer memory") {
+            "NIO direct buffers exhausted -XX:MaxDirectMemorySize"
+        } else if kind.contains("requested array size") {
+            "an array larger than the VM allows was requested"
+        } else {
+            return None;
+        })
+    }
+}
+
+// ============================================================================
+// Report
+// ============================================================================
+
+/// Thread dump and/or OutOfMemoryError details
+#[derive(Debug, Clone, Serialize)]
+pub struct JvmReport {
+    /// Parsed thread dump
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub dump: Option<ThreadDump>,
+    /// OutOfMemoryError details
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub oom: Option<OutOfMemory>,
+}
+
+impl JvmReport {
+    /// Render the report as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = String::new();
+
+        if let Some(oom) = &self.oom {
+            out.push_str(&format!("OutOfMemoryError: {}\n", oom.kind));
+            if let Some(meaning) = oom.meaning() {
+                out.push_str(&format!("- meaning: {}\n", meaning));
+            }
+            if let Some(thread) = &oom.thread {
+                out.push_str(&format!("- thread: \"{}\"\n", thread));
+            }
+            for region in &oom.regions {
+                out.push_str(&format!("- {}\n", region.describe()));
+            }
+            if let Some(gc) = &oom.last_gc {
+                out.push_str(&format!("- last GC: {}\n", gc));
+            }
+            if let Some(dump) = &oom.heap_dump {
+                out.push_str(&format!("- heap dump written to {}\n", dump));
+            }
+        }
+
+        if let Some(dump) = &self.dump {
+            out.push_str(&format!("Thread dump: {}\n", dump.summary()));
+            for cycle in &dump.deadlocks {
+                out.push_str("- deadlock:\n");
+                for edge in cycle {
+                    let held = dump
+                        .thread(&edge.thread)
+                        .map(|t| {
+                            t.holds
+                                .iter()
+                                .map(LockRef::describe)
+                                .collect::<Vec<_>>()
+                                .join(", ")
+                        })
+                        .filter(|h| !h.is_empty())
+                        .map(|h| format!(" while holding {}", h))
+                        .unwrap_or_default();
+                    out.push_str(&format!(
+                        "    \"{}\" waits for {} held by \"{}\"{}\n",
+                        edge.thread,
+                        edge.waiting_for.describe(),
+                        edge.held_by,
+                        held
+                    ));
+                    if let Some(frame) = dump.thread(&edge.thread).and_then(|t| t.frames.first()) {
+                        out.push_str(&format!("      at {}\n", frame));
+                    }
+                }
+            }
+            for hot in &dump.hot_stacks {
+                out.push_str(&format!(
+                    "- {} {} threads share this stack:\n",
+                    hot.threads.len(),
+                    hot.state
+                ));
+                for frame in &hot.frames {
+                    out.push_str(&format!("    at {}\n", frame));
+                }
+            }
+        }
+
+        out.trim_end().to_string()
+    }
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    marker: Regex,
+    vm: Regex,
+    thread: Regex,
+    state: Regex,
+    frame: Regex,
+    lock: Regex,
+    ownable: Regex,
+    deadlock_thread: Regex,
+    deadlock_monitor: Regex,
+    deadlock_held_by: Regex,
+    oom: Regex,
+    heap_dump: Regex,
+    region_total: Regex,
+    region_space: Regex,
+    region_meta: Regex,
+    gc_pause: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        marker: Regex::new(
+            r"(?m)^Full thread dump |java\.lang\.Thread\.State: |^Found one Java-level deadlock|java\.lang\.OutOfMemoryError",
+        )
+        .unwrap(),
+        vm: Regex::new(r"^Full thread dump (.+?):?$").unwrap(),
+        thread: Regex::new(r#"^"(.*?)"(?: #\d+)?( daemon)?\s.*\bnid=0x"#).unwrap(),
+        state: Regex::new(r"^\s+java\.lang\.Thread\.State: (.+)$").unwrap(),
+        frame: Regex::new(r"^\s+at (.+)$").unwrap(),
+        lock: Regex::new(
+            r"^\s+- (waiting to lock|waiting to re-lock in wait\(\)|parking to wait for|waiting on|locked)\s+<(0x[0-9a-fA-F]+)> \(a ([^)]+)\)",
+        )
+        .unwrap(),
+        ownable: Regex::new(r"^\s+- <(0x[0-9a-fA-F]+)> \(a ([^)]+)\)$").unwrap(),
+        deadlock_thread: Regex::new(r#"^"(.+)":$"#).unwrap(),
+        deadlock_monitor: Regex::new(
+            r"waiting (?:to lock monitor \S+ ?\(object (0x[0-9a-fA-F]+), a ([^)]+)\)|for ownable synchronizer (0x[0-9a-fA-F]+), \(a ([^)]+)\))",
+        )
+        .unwrap(),
+        deadlock_held_by: Regex::new(r#"which is held by "(.+)""#).unwrap(),
+        oom: Regex::new(
+            r#"(?:Exception in thread "(.+?)" )?java\.lang\.OutOfMemoryError(?:: (.+))?$"#,
+        )
+        .unwrap(),
+        heap_dump: Regex::new(r"Dumping heap to (\S+) \.\.\.").unwrap(),
+        region_total: Regex::new(r"^\s*(\S.*?)\s+total (\d+)K, used (\d+)K").unwrap(),
+        region_space: Regex::new(r"^\s*(eden|from|to|object) space (\d+)K, (\d+)% used").unwrap(),
+        region_meta: Regex::new(
+            r"^\s*(Metaspace|class space)\s+used (\d+)K, (?:capacity \d+K, )?committed (\d+)K, reserved (\d+)K",
+        )
+        .unwrap(),
+        gc_pause: Regex::new(r"\bGC\(\d+\) (Pause .+)$").unwrap(),
+    })
+}
+
+/// Check whether input contains a thread dump or an OutOfMemoryError
+pub fn is_jvm_report(input: &str) -> bool {
+    patterns().marker.is_match(input)
+}
+
+/// Parse a thread dump and/or OutOfMemoryError
+pub fn parse_jvm_report(input: &str) -> Option<JvmReport> {
+    let input = strip_ansi(input);
+    if !is_jvm_report(&input) {
+        return None;
+    }
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+
+    let dump = parse_thread_dump(&lines);
+    let oom = parse_out_of_memory(&lines);
+    if dump.is_none() && oom.is_none() {
+        return None;
+    }
+    Some(JvmReport { dump, oom })
+}
+
+fn parse_thread_dump(lines: &[&str]) -> Option<ThreadDump> {
+    let p = patterns();
+    let mut dump = ThreadDump::default();
+    let mut current: Option<JavaThread> = None;
+    let mut in_deadlock = false;
+    let mut in_ownable = false;
+    let mut cycle: Vec<DeadlockEdge> = Vec::new();
+    let mut pending: Option<(String, LockRef)> = None;
+
+    for line in lines {
+        if let Some(caps) = p.vm.captures(line) {
+            dump.vm = Some(caps[1].to_string());
+            continue;
+        }
+
+        // The deadlock report names each thread again; its stacks are copies
+        if line.starts_with("Found one Java-level deadlock") {
+            dump.threads.extend(current.take());
+            if !cycle.is_empty() {
+                dump.deadlocks.push(std::mem::take(&mut cycle));
+            }
+            in_deadlock = true;
+            continue;
+        }
+        if in_deadlock {
+            // The stacks repeated after the report have no thread headers, so
+            // with no current thread they're skipped below
+            if line.starts_with("Java stack information") || line.starts_with("Found ") {
+                if !cycle.is_empty() {
+                    dump.deadlocks.push(std::mem::take(&mut cycle));
+                }
+                in_deadlock = false;
+                continue;
+            }
+            if let Some(caps) = p.deadlock_thread.captures(line) {
+                let lock = LockRef {
+                    address: String::new(),
+                    class: String::new(),
+                };
+                pending = Some((caps[1].to_string(), lock));
+            } else if let Some(caps) = p.deadlock_monitor.captures(line) {
+                if let Some((_, lock)) = pending.as_mut() {
+                    let group = |a: usize, b: usize| {
+                        caps.get(a)
+                            .or_else(|| caps.get(b))
+                            .map_or("", |m| m.as_str())
+                    };
+                    lock.address = group(1, 3).to_string();
+                    lock.class = group(2, 4).to_string();
+                }
+            }
+            if let Some(caps) = p.deadlock_held_by.captures(line) {
+                if let Some((thread, waiting_for)) = pending.take() {
+                    cycle.push(DeadlockEdge {
+                        thread,
+                        waiting_for,
+                        held_by: caps[1].to_string(),
+                    });
+                }
+            }
+            continue;
+        }
+
+        if let Some(caps) = p.thread.captures(line) {
+            dump.threads.extend(current.take());
+            in_ownable = false;
+            // Threads named again under "Java stack information" aren't new
+            if dump.threads.iter().any(|t| t.name == caps[1]) {
+                continue;
+            }
+            current = Some(JavaThread {
+                name: caps[1].to_string(),
+                daemon: caps.get(2).is_some(),
+                state: None,
+                frames: Vec::new(),
+                holds: Vec::new(),
+                waiting_for: None,
+            });
+            continue;
+        }
+
+        let Some(thread) = current.as_mut() else {
+            continue;
+        };
+        if let Some(caps) = p.state.captures(line) {
+            thread.state = Some(caps[1].to_string());
+        } else if let Some(caps) = p.frame.captures(line) {
+            thread.frames.push(caps[1].to_string());
+        } else if let Some(caps) = p.lock.captures(line) {
+            let lock = LockRef {
+                address: caps[2].to_string(),
+                class: caps[3].to_string(),
+            };
+            if &caps[1] == "locked" {
+                // Object.wait() releases the monitor it was called on
+                if thread.waiting_for.as_ref() != Some(&lock) {
+                    thread.holds.push(lock);
+                }
+            } else if thread.waiting_for.is_none() {
+                thread.waiting_for = Some(lock);
+            }
+        } else if line.trim() == "Locked ownable synchronizers:" {
+            in_ownable = true;
+        } else if let Some(caps) = p.ownable.captures(line).filter(|_| in_ownable) {
+            thread.holds.push(LockRef {
+                address: caps[1].to_string(),
+                class: caps[2].to_string(),
+            });
+        }
+    }
+    dump.threads.extend(current.take());
+    if !cycle.is_empty() {
+        dump.deadlocks.push(cycle);
+    }
+
+    if dump.threads.is_empty() && dump.deadlocks.is_empty() {
+        return None;
+    }
+    dump.hot_stacks = hot_stacks(&dump.threads);
+    Some(dump)
+}
+
+/// Group RUNNABLE and BLOCKED threads by their top frames
+fn hot_stacks(threads: &[JavaThread]) -> Vec<HotStack> {
+    let mut stacks: Vec<HotStack> = Vec::new();
+    for thread in threads {
+        let state = thread.state_name();
+        if !matches!(state, "RUNNABLE" | "BLOCKED") || thread.frames.is_empty() {
+            continue;
+        }
+        let top: Vec<String> = thread
+            .frames
+            .iter()
+            .take(HOT_STACK_DEPTH)
+            .cloned()
+            .collect();
+        match stacks
+            .iter_mut()
+            .find(|s| s.state == state && s.frames == top)
+        {
+            Some(stack) => stack.threads.push(thread.name.clone()),
+            None => stacks.push(HotStack {
+                state: state.to_string(),
+                frames: top,
+                threads: vec![thread.name.clone()],
+            }),
+        }
+    }
+    stacks.retain(|s| s.threads.len() >= 2);
+    stacks.sort_by(|a, b| b.threads.len().cmp(&a.threads.len()));
+    stacks.truncate(MAX_HOT_STACKS);
+    stacks
+}
+
+fn parse_out_of_memory(lines: &[&str]) -> Option<OutOfMemory> {
+    let p = patterns();
+    let caps = lines.iter().find_map(|l| p.oom.captures(l))?;
+    let mut oom = OutOfMemory {
+        kind: caps
+            .get(2)
+            .map_or("unknown", |m| m.as_str())
+            .trim()
+            .to_string(),
+        thread: caps.get(1).map(|m| m.as_str().to_string()),
+        heap_dump: None,
+        regions: Vec::new(),
+        last_gc: None,
+    };
+
+    for line in lines {
+        if let Some(caps) = p.heap_dump.captures(line) {
+            oom.heap_dump = Some(caps[1].to_string());
+        } else if let Some(caps) = p.gc_pause.captures(line) {
+            oom.last_gc = Some(caps[1].to_string());
+        } else if let Some(caps) = p.region_meta.captures(line) {
+            oom.regions.push(MemoryRegion {
+                name: caps[1].to_string(),
+                total_kb: None,
+                used_kb: caps[2].parse().ok(),
+                committed_kb: caps[3].parse().ok(),
+                reserved_kb: caps[4].parse().ok(),
+                percent_used: None,
+            });
+        } else if let Some(caps) = p.region_space.captures(line) {
+            oom.regions.push(MemoryRegion {
+                name: format!("{} space", &caps[1]),
+                total_kb: caps[2].parse().ok(),
+                used_kb: None,
+                committed_kb: None,
+                reserved_kb: None,
+                percent_used: caps[3].parse().ok(),
+            });
+        } else if let Some(caps) = p.region_total.captures(line) {
+            let total: Option<u64> = caps[2].parse().ok();
+            let used: Option<u64> = caps[3].parse().ok();
+            oom.regions.push(MemoryRegion {
+                name: caps[1].to_string(),
+                total_kb: total,
+                used_kb: used,
+                committed_kb: None,
+                reserved_kb: None,
+                percent_used: match (used, total) {
+                    (Some(used), Some(total)) if total > 0 => Some((used * 100 / total) as u32),
+                    _ => None,
+                },
+            });
+        }
+    }
+
+    Some(oom)
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const DEADLOCK_DUMP: &str = "\
+2024-03-01 12:00:00
+Full thread dump OpenJDK 64-Bit Server VM (17.0.2+8-86 mixed mode, sharing):
+
+\"main\" #1 prio=5 os_prio=0 cpu=40.12ms elapsed=12.30s tid=0x00007f3c5c028000 nid=0x1 in Object.wait()  [0x00007f3c62a1e000]
+   java.lang.Thread.State: WAITING (on object monitor)
+\tat java.lang.Object.wait(java.base@17.0.2/Native Method)
+\t- waiting on <0x000000076ab00010> (a java.lang.Thread)
+\tat java.lang.Thread.join(java.base@17.0.2/Thread.java:1304)
+\t- locked <0x000000076ab00010> (a java.lang.Thread)
+\tat com.example.Deadlock.main(Deadlock.java:30)
+
+\"worker-1\" #12 prio=5 os_prio=0 cpu=1.02ms elapsed=12.20s tid=0x00007f3c5c1a2000 nid=0x2b waiting for monitor entry  [0x00007f3c30ffe000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+\tat com.example.Deadlock.transfer(Deadlock.java:15)
+\t- waiting to lock <0x000000076ab62218> (a com.example.Account)
+\t- locked <0x000000076ab62208> (a com.example.Account)
+\tat com.example.Deadlock.lambda$main$0(Deadlock.java:24)
+\tat java.lang.Thread.run(java.base@17.0.2/Thread.java:833)
+
+\"worker-2\" #13 prio=5 os_prio=0 cpu=0.98ms elapsed=12.20s tid=0x00007f3c5c1a4000 nid=0x2c waiting for monitor entry  [0x00007f3c30efd000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+\tat com.example.Deadlock.transfer(Deadlock.java:15)
+\t- waiting to lock <0x000000076ab62208> (a com.example.Account)
+\t- locked <0x000000076ab62218> (a com.example.Account)
+\tat com.example.Deadlock.lambda$main$1(Deadlock.java:25)
+\tat java.lang.Thread.run(java.base@17.0.2/Thread.java:833)
+
+\"VM Thread\" os_prio=0 cpu=2.31ms elapsed=12.31s tid=0x00007f3c5c0b1000 nid=0x8 runnable
+
+JNI global refs: 15, weak refs: 0
+
+
+Found one Java-level deadlock:
+=============================
+\"worker-1\":
+  waiting to lock monitor 0x00007f3c38003f08 (object 0x000000076ab62218, a com.example.Account),
+  which is held by \"worker-2\"
+\"worker-2\":
+  waiting to lock monitor 0x00007f3c38006a08 (object 0x000000076ab62208, a com.example.Account),
+  which is held by \"worker-1\"
+
+Java stack information for the threads listed above:
+===================================================
+\"worker-1\":
+\tat com.example.Deadlock.transfer(Deadlock.java:15)
+\t- waiting to lock <0x000000076ab62218> (a com.example.Account)
+\t- locked <0x000000076ab62208> (a com.example.Account)
+
+Found 1 deadlock.
+";
+
+    #[test]
+    fn test_parses_thread_dump_with_deadlock() {
+        assert!(is_jvm_report(DEADLOCK_DUMP));
+        let report = parse_jvm_report(DEADLOCK_DUMP).unwrap();
+        assert!(report.oom.is_none());
+        let dump = report.dump.unwrap();
+
+        assert_eq!(
+            dump.vm.as_deref(),
+            Some("OpenJDK 64-Bit Server VM (17.0.2+8-86 mixed mode, sharing)")
+        );
+        assert_eq!(dump.threads.len(), 4);
+
+        let main = dump.thread("main").unwrap();
+        assert_eq!(main.state_name(), "WAITING");
+        assert!(main.holds.is_empty());
+        assert_eq!(main.frames.len(), 3);
+
+        let worker = dump.thread("worker-1").unwrap();
+        assert_eq!(worker.state_name(), "BLOCKED");
+        assert_eq!(
+            worker.waiting_for.as_ref().unwrap().address,
+            "0x000000076ab62218"
+        );
+        assert_eq!(worker.holds[0].address, "0x000000076ab62208");
+
+        assert_eq!(dump.deadlocks.len(), 1);
+        assert_eq!(dump.deadlocks[0].len(), 2);
+        assert_eq!(dump.deadlocks[0][0].held_by, "worker-2");
+        assert_eq!(
+            dump.deadlock_summary().unwrap(),
+            "threads \"worker-1\" and \"worker-2\" deadlock on locks \
+             <0x000000076ab62218> (com.example.Account) / \
+             <0x000000076ab62208> (com.example.Account)"
+        );
+        assert_eq!(dump.focus_thread().unwrap().name, "worker-1");
+
+        let summary = dump.summary();
+        assert_eq!(summary, "4 threads: 2 BLOCKED, 1 WAITING, 1 UNKNOWN");
+    }
+
+    #[test]
+    fn test_finds_hot_stacks() {
+        let mut input = String::from("Full thread dump OpenJDK 64-Bit Server VM:\n\n");
+        for i in 0..3 {
+            input.push_str(&format!(
+                "\"http-nio-{}\" #{} daemon prio=5 tid=0x1 nid=0x{} runnable\n   \
+                 java.lang.Thread.State: RUNNABLE\n\
+                 \tat java.util.regex.Pattern$Loop.match(java.base@17/Pattern.java:5048)\n\
+                 \tat com.example.Validator.check(Validator.java:42)\n\n",
+                i,
+                i + 20,
+                i + 40
+            ));
+        }
+        input.push_str(
+            "\"idle\" #30 prio=5 tid=0x1 nid=0x50 waiting on condition\n   \
+             java.lang.Thread.State: WAITING (parking)\n\
+             \tat jdk.internal.misc.Unsafe.park(java.base@17/Native Method)\n",
+        );
+
+        let dump = parse_jvm_report(&input).unwrap().dump.unwrap();
+        assert_eq!(dump.threads.len(), 4);
+        assert!(dump.threads[0].daemon);
+        assert_eq!(dump.hot_stacks.len(), 1);
+        assert_eq!(dump.hot_stacks[0].threads.len(), 3);
+        assert_eq!(dump.hot_stacks[0].state, "RUNNABLE");
+        assert_eq!(dump.focus_thread().unwrap().name, "http-nio-0");
+        assert!(parse_jvm_report(&input)
+            .unwrap()
+            .prompt_summary()
+            .contains("- 3 RUNNABLE threads share this stack:"));
+    }
+
+    #[test]
+    fn test_parses_out_of_memory_with_heap_summary() {
+        let input = "\
+[11.902s][info][gc] GC(41) Pause Full (Ergonomics) 249M->248M(256M) 210.410ms
+java.lang.OutOfMemoryError: Java heap space
+Dumping heap to java_pid4242.hprof ...
+Heap dump file created [268435456 bytes in 0.812 secs]
+Exception in thread \"main\" java.lang.OutOfMemoryError: Java heap space
+\tat java.base/java.util.Arrays.copyOf(Arrays.java:3512)
+\tat com.example.Leak.main(Leak.java:9)
+Heap
+ PSYoungGen      total 76288K, used 65536K [0x00000007b5580000, 0x00000007c0000000, 0x00000007c0000000)
+  eden space 65536K, 100% used [0x00000007b5580000,0x00000007b9580000,0x00000007b9580000)
+ ParOldGen       total 175104K, used 174950K [0x00000006a0000000, 0x00000006aab00000, 0x00000007b5580000)
+  object space 175104K, 99% used [0x00000006a0000000,0x00000006aaad9a50,0x00000006aab00000)
+ Metaspace       used 3213K, committed 3392K, reserved 1056768K
+  class space    used 300K, committed 384K, reserved 1048576K";
+
+        let report = parse_jvm_report(input).unwrap();
+        assert!(report.dump.is_none());
+        let oom = report.oom.as_ref().unwrap();
+        assert_eq!(oom.kind, "Java heap space");
+        assert_eq!(oom.heap_dump.as_deref(), Some("java_pid4242.hprof"));
+        assert_eq!(
+            oom.last_gc.as_deref(),
+            Some("Pause Full (Ergonomics) 249M->248M(256M) 210.410ms")
+        );
+        assert_eq!(oom.regions.len(), 6);
+        assert_eq!(
+            oom.regions[2].describe(),
+            "ParOldGen: 174950K used of 175104K (99%)"
+        );
+        assert_eq!(
+            oom.regions[4].describe(),
+            "Metaspace: 3213K used of 3392K, 1056768K reserved"
+        );
+
+        let summary = report.prompt_summary();
+        assert!(summary.starts_with("OutOfMemoryError: Java heap space\n- meaning: the heap"));
+        assert!(summary.contains("- eden space: 65536K (100%)"));
+    }
+
+    #[test]
+    fn test_metaspace_meaning() {
+        let report = parse_jvm_report(
+            "Exception in thread \"pool-1-thread-3\" java.lang.OutOfMemoryError: Metaspace",
+        )
+        .unwrap();
+        let oom = report.oom.unwrap();
+        assert_eq!(oom.thread.as_deref(), Some("pool-1-thread-3"));
+        assert!(oom.meaning().unwrap().contains("MaxMetaspaceSize"));
+    }
+}
@@ -0,0 +1,756 @@
+//! Kernel log (dmesg) crash line interpretation.
+//!
+//! When a process dies without printing anything, the evidence usually lives
+//! in the kernel log: segfault lines with the faulting address and module,
+//! `traps:` lines for general protection faults and invalid opcodes, OOM
+//! killer reports, and hung task warnings. This module parses those lines and
+//! can look up the entry for a process that just died.
+
+use regex::Regex;
+use serde::Serialize;
+use std::fmt;
+use std::fs;
+use std::process::{Command, Stdio};
+use std::sync::OnceLock;
+use std::time::Duration;
+
+use crate::stack_trace::StackFrame;
+
+/// Longest process name the kernel records (TASK_COMM_LEN - 1)
+const COMM_MAX_LEN: usize = 15;
+
+/// How old a kernel event may be and still be attributed to a command that
+/// just exited, when we cannot match it by PID
+const RECENT_EVENT_WINDOW: Duration = Duration::from_secs(120);
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Kind of kernel log event
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum KernelEventKind {
+    /// `app[1234]: segfault at ...`
+    Segfault,
+    /// `traps: app[1234] general protection fault ...`
+    Trap,
+    /// `Out of memory: Killed process 1234 (app) ...`
+    OomKill,
+    /// `INFO: task app:1234 blocked for more than 120 seconds.`
+    HungTask,
+}
+
+impl fmt::Display for KernelEventKind {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            KernelEventKind::Segfault => write!(f, "segfault"),
+            KernelEventKind::Trap => write!(f, "trap"),
+            KernelEventKind::OomKill => write!(f, "oom-kill"),
+            KernelEventKind::HungTask => write!(f, "hung task"),
+        }
+    }
+}
+
+/// Memory details from an OOM killer report
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct OomDetails {
+    /// Virtual memory size of the killed process
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub total_vm_kb: Option<u64>,
+    /// Anonymous resident memory of the killed process
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub anon_rss_kb: Option<u64>,
+    /// File-backed resident memory of the killed process
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file_rss_kb: Option<u64>,
+    /// Shared memory resident in the killed process
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub shmem_rss_kb: Option<u64>,
+    /// OOM score adjustment of the killed process
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub oom_score_adj: Option<i64>,
+    /// Why the OOM killer ran (e.g. CONSTRAINT_NONE, CONSTRAINT_MEMCG)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub constraint: Option<String>,
+    /// Memory cgroup that ran out of memory, for cgroup OOMs
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cgroup: Option<String>,
+    /// Cgroup memory usage when the OOM killer ran
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cgroup_usage_kb: Option<u64>,
+    /// Cgroup memory limit
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cgroup_limit_kb: Option<u64>,
+    /// The process whose allocation triggered the OOM killer
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub invoked_by: Option<String>,
+}
+
+/// A single crash or kill recorded in the kernel log
+#[derive(Debug, Clone, Serialize)]
+pub struct KernelEvent {
+    /// What happened
+    pub kind: KernelEventKind,
+    /// Process name as recorded by the kernel (truncated to 15 characters)
+    pub process: String,
+    /// Process ID
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub pid: Option<u32>,
+    /// One-line human readable description
+    pub description: String,
+    /// Seconds since boot, when the log line carried a timestamp
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub timestamp: Option<f64>,
+    /// Address the process tried to access (segfaults)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub fault_address: Option<u64>,
+    /// Instruction pointer at the time of the fault
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub instruction_pointer: Option<u64>,
+    /// Page fault / trap error code
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error_code: Option<u32>,
+    /// Binary or library containing the faulting instruction
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub module: Option<String>,
+    /// Offset of the faulting instruction inside `module`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub module_offset: Option<u64>,
+    /// Memory details for OOM kills
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub oom: Option<OomDetails>,
+    /// How long a hung task had been blocked
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub blocked_seconds: Option<u64>,
+    /// Kernel call trace (hung tasks)
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub call_trace: Vec<StackFrame>,
+    /// The log lines this event was built from
+    pub raw: String,
+}
+
+impl KernelEvent {
+    fn new(kind: KernelEventKind, process: impl Into<String>, raw: &str) -> Self {
+        Self {
+            kind,
+            process: process.into(),
+            pid: None,
+            description: String::new(),
+            timestamp: None,
+            fault_address: None,
+            instruction_pointer: None,
+            error_code: None,
+            module: None,
+            module_offset: None,
+            oom: None,
+            blocked_seconds: None,
+            call_trace: Vec::new(),
+            raw: raw.trim().to_string(),
+        }
+    }
+
+    /// A frame pointing at the faulting module and offset, if known
+    pub fn fault_frame(&self) -> Option<StackFrame> {
+        let module = self.module.as_ref()?;
+        let mut frame = StackFrame::new().with_is_user_code(!is_system_library(module));
+        frame.function = Some(match self.module_offset {
+            Some(offset) => format!("{}+{:#x}", module, offset),
+            None => module.clone(),
+        });
+        Some(frame)
+    }
+
+    /// Render the event as a line for the model prompt
+    fn prompt_line(&self) -> String {
+        let pid = self
+            .pid
+            .map(|p| format!(" (pid {})", p))
+            .unwrap_or_default();
+        let mut line = format!(
+            "- {}: {}{}: {}",
+            self.kind, self.process, pid, self.description
+        );
+
+        if let (Some(module), Some(offset)) = (&self.module, self.module_offset) {
+            line.push_str(&format!(
+                "; resolve the faulting line with `addr2line -f -e <path to {}> {:#x}`",
+                module, offset
+            ));
+        }
+
+        if let Some(oom) = &self.oom {
+            let mut memory = Vec::new();
+            if let Some(kb) = oom.anon_rss_kb {
+                memory.push(format!("anon-rss {}", format_kb(kb)));
+            }
+            if let Some(kb) = oom.total_vm_kb {
+                memory.push(format!("total-vm {}", format_kb(kb)));
+            }
+            if let (Some(usage), Some(limit)) = (oom.cgroup_usage_kb, oom.cgroup_limit_kb) {
+                memory.push(format!(
+                    "cgroup usage {} of limit {}",
+                    format_kb(usage),
+                    format_kb(limit)
+                ));
+            }
+            if let Some(cgroup) = &oom.cgroup {
+                memory.push(format!("cgroup {}", cgroup));
+            }
+            if let Some(invoker) = &oom.invoked_by {
+                memory.push(format!("triggered by an allocation in {}", invoker));
+            }
+            if !memory.is_empty() {
+                line.push_str(&format!(" ({})", memory.join(", ")));
+            }
+        }
+
+        if !self.call_trace.is_empty() {
+            let trace: Vec<&str> = self
+                .call_trace
+                .iter()
+                .filter_map(|f| f.function.as_deref())
+                .take(6)
+                .collect();
+            line.push_str(&format!("; call trace: {}", trace.join(" <- ")));
+        }
+
+        line
+    }
+}
+
+/// All kernel events found in a log excerpt
+#[derive(Debug, Clone, Serialize)]
+pub struct KernelReport {
+    /// Events in log order
+    pub events: Vec<KernelEvent>,
+}
+
+impl KernelReport {
+    /// The event the explanation should focus on (the first one logged)
+    pub fn primary(&self) -> Option<&KernelEvent> {
+        self.events.first()
+    }
+
+    /// Render the report as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = String::from("Kernel log events:\n");
+        for event in self.events.iter().take(5) {
+            out.push_str(&event.prompt_line());
+            out.push('\n');
+        }
+        if self.events.len() > 5 {
+            out.push_str(&format!(
+                "- {} more event(s) omitted\n",
+                self.events.len() - 5
+            ));
+        }
+        out.trim_end().to_string()
+    }
+
+    /// The most recent event for a process that just exited
+    ///
+    /// An event matches when its PID equals `pid`, or when the process name
+    /// matches the command's program and the event was logged within the
+    /// last couple of minutes (`uptime` is seconds since boot).
+    pub fn latest_for(
+        &self,
+        command: &str,
+        pid: Option<u32>,
+        uptime: Option<f64>,
+    ) -> Option<&KernelEvent> {
+        let comm = command_comm(command);
+        self.events.iter().rev().find(|event| {
+            if pid.is_some() && event.pid == pid {
+                return true;
+            }
+            let recent = match (event.timestamp, uptime) {
+                (Some(ts), Some(now)) => now - ts <= RECENT_EVENT_WINDOW.as_secs_f64(),
+                _ => false,
+            };
+            recent && comm.as_deref() == Some(event.process.as_str())
+        })
+    }
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    timestamp: Regex,
+    segfault: Regex,
+    trap: Regex,
+    oom_invoked: Regex,
+    oom_killed: Regex,
+    oom_kill_info: Regex,
+    memcg_usage: Regex,
+    hung_task: Regex,
+    call_trace_frame: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        timestamp: Regex::new(r"^\[\s*(\d+\.\d+)\]").unwrap(),
+        segfault: Regex::new(
+            r"(\S+?)\[(\d+)\]: segfault at ([0-9a-fA-F]+) ip ([0-9a-fA-F]+) sp [0-9a-fA-F]+ error ([0-9a-fA-F]+)(?: in ([^\[\s]+)\[([0-9a-fA-F]+)\+[0-9a-fA-F]+\])?",
+        )
+        .unwrap(),
+        trap: Regex::new(
+            r"traps: (\S+?)\[(\d+)\] (?:trap )?(.+?) ip:([0-9a-fA-F]+) sp:[0-9a-fA-F]+ error:([0-9a-fA-F]+)(?: in ([^\[\s]+)\[([0-9a-fA-F]+)\+[0-9a-fA-F]+\])?",
+        )
+        .unwrap(),
+        oom_invoked: Regex::new(r"(\S+) invoked oom-killer:").unwrap(),
+        oom_killed: Regex::new(r"Killed process (\d+) \(([^)]+)\)(.*)$").unwrap(),
+        oom_kill_info: Regex::new(r"oom-kill:(\S+)").unwrap(),
+        memcg_usage: Regex::new(r"memory: usage (\d+)kB, limit (\d+)kB").unwrap(),
+        hung_task: Regex::new(r"INFO: task (.+):(\d+) blocked for more than (\d+) seconds").unwrap(),
+        call_trace_frame: Regex::new(
+            r"^\s*(?:\[<[0-9a-fA-F]+>\]\s*)?(?:\? )?([A-Za-z_][\w.]*)\+0x[0-9a-fA-F]+/0x[0-9a-fA-F]+(?: \[(\w+)\])?",
+        )
+        .unwrap(),
+    })
+}
+
+/// Check whether input contains kernel crash lines
+pub fn is_kernel_log(input: &str) -> bool {
+    let p = patterns();
+    p.segfault.is_match(input)
+        || p.trap.is_match(input)
+        || p.oom_killed.is_match(input)
+        || p.hung_task.is_match(input)
+}
+
+/// Parse all segfault, trap, OOM kill and hung task events from kernel log text
+pub fn parse_kernel_log(input: &str) -> Option<KernelReport> {
+    let p = patterns();
+    let mut events: Vec<KernelEvent> = Vec::new();
+    // OOM details are spread over several lines logged before "Killed process"
+    let mut pending_oom = OomDetails::default();
+    // Hung task call traces follow the warning line
+    let mut in_call_trace = false;
+
+    for line in input.lines() {
+        let timestamp = p
+            .timestamp
+            .captures(line)
+            .and_then(|c| c[1].parse::<f64>().ok());
+
+        if let Some(caps) = p.segfault.captures(line) {
+            in_call_trace = false;
+            let mut event = KernelEvent::new(KernelEventKind::Segfault, &caps[1], line);
+            event.pid = caps[2].parse().ok();
+            event.timestamp = timestamp;
+            event.fault_address = parse_hex(&caps[3]);
+            event.instruction_pointer = parse_hex(&caps[4]);
+            event.error_code = parse_hex(&caps[5]).map(|c| c as u32);
+            set_module(&mut event, caps.get(6), caps.get(7));
+            event.description = describe_segfault(&event);
+            events.push(event);
+            continue;
+        }
+
+        if let Some(caps) = p.trap.captures(line) {
+            in_call_trace = false;
+            let mut event = KernelEvent::new(KernelEventKind::Trap, &caps[1], line);
+            event.pid = caps[2].parse().ok();
+            event.timestamp = timestamp;
+            event.instruction_pointer = parse_hex(&caps[4]);
+            event.error_code = parse_hex(&caps[5]).map(|c| c as u32);
+            set_module(&mut event, caps.get(6), caps.get(7));
+            event.description = match &event.module {
+                Some(module) => format!("{} in {}", &caps[3], module),
+                None => caps[3].to_string(),
+            };
+            events.push(event);
+            continue;
+        }
+
+        if let Some(caps) = p.oom_invoked.captures(line) {
+            pending_oom.invoked_by = Some(caps[1].to_string());
+            continue;
+        }
+
+        if let Some(caps) = p.memcg_usage.captures(line) {
+            pending_oom.cgroup_usage_kb = caps[1].parse().ok();
+            pending_oom.cgroup_limit_kb = caps[2].parse().ok();
+            continue;
+        }
+
+        if let Some(caps) = p.oom_kill_info.captures(line) {
+            for field in caps[1].split(',') {
+                match field.split_once('=') {
+                    Some(("constraint", value)) => pending_oom.constraint = Some(value.to_string()),
+                    Some(("oom_memcg", value)) => pending_oom.cgroup = Some(value.to_string()),
+                    _ => {}
+                }
+            }
+            continue;
+        }
+
+        if let Some(caps) = p.oom_killed.captures(line) {
+            in_call_trace = false;
+            let mut oom = std::mem::take(&mut pending_oom);
+            let details = &caps[3];
+            oom.total_vm_kb = kb_field(details, "total-vm");
+            oom.anon_rss_kb = kb_field(details, "anon-rss");
+            oom.file_rss_kb = kb_field(details, "file-rss");
+            oom.shmem_rss_kb = kb_field(details, "shmem-rss");
+            oom.oom_score_adj = details
+                .split_once("oom_score_adj:")
+                .and_then(|(_, rest)| rest.split(|c: char| c.is_whitespace() || c == ',').next())
+                .and_then(|v| v.parse().ok());
+            if oom.constraint.is_none() && line.contains("Memory cgroup out of memory") {
+                oom.constraint = Some("CONSTRAINT_MEMCG".to_string());
+            }
+
+            let mut event = KernelEvent::new(KernelEventKind::OomKill, &caps[2], line);
+            event.pid = caps[1].parse().ok();
+            event.timestamp = timestamp;
+            event.description = if oom.constraint.as_deref() == Some("CONSTRAINT_MEMCG") {
+                "killed by the OOM killer after its memory cgroup hit its limit".to_string()
+            } else {
+                "killed by the OOM killer because the system ran out of memory".to_string()
+            };
+            event.oom = Some(oom);
+            events.push(event);
+            continue;
+        }
+
+        if let Some(caps) = p.hung_task.captures(line) {
+            let mut event = KernelEvent::new(KernelEventKind::HungTask, &caps[1], line);
+            event.pid = caps[2].parse().ok();
+            event.timestamp = timestamp;
+            event.blocked_seconds = caps[3].parse().ok();
+            event.description = format!(
+                "blocked in uninterruptible sleep (D state) for more than {} seconds",
+                &caps[3]
+            );
+            events.push(event);
+            in_call_trace = true;
+            continue;
+        }
+
+        if in_call_trace {
+            let body = strip_log_prefix(line).trim();
+            if let Some(caps) = p.call_trace_frame.captures(body) {
+                if let Some(event) = events.last_mut() {
+                    let mut frame = StackFrame::new().with_function(&caps[1]);
+                    // Frames inside loadable modules are the likely culprit;
+                    // core kernel scheduling frames are just where it waits
+                    frame.is_user_code = caps.get(2).is_some();
+                    event.call_trace.push(frame);
+                    event.raw.push('\n');
+                    event.raw.push_str(line.trim());
+                }
+            } else if !(body.is_empty()
+                || body.starts_with("Call Trace")
+                || body.starts_with('<')
+                || body.starts_with("task:")
+                || body.starts_with("Not tainted")
+                || body.starts_with("Tainted:")
+                || body.contains("hung_task_timeout_secs"))
+            {
+                in_call_trace = false;
+            }
+        }
+    }
+
+    if events.is_empty() {
+        None
+    } else {
+        Some(KernelReport { events })
+    }
+}
+
+/// Read recent kernel log output, trying `dmesg` first and the journal second
+///
+/// Both usually need elevated privileges on hardened systems; failures are
+/// silent because the log is only supporting evidence.
+pub fn read_kernel_log() -> Option<String> {
+    let attempts: [(&str, &[&str]); 2] = [
+        ("dmesg", &[]),
+        (
+            "journalctl",
+            &[
+                "-k",
+                "-n",
+                "500",
+                "-o",
+                "short-monotonic",
+                "--no-pager",
+                "-q",
+            ],
+        ),
+    ];
+
+    for (program, args) in attempts {
+        let output = Command::new(program)
+            .args(args)
+            .stdin(Stdio::null())
+            .stderr(Stdio::null())
+            .output();
+        if let Ok(output) = output {
+            if output.status.success() && !output.stdout.is_empty() {
+                return Some(String::from_utf8_lossy(&output.stdout).into_owned());
+            }
+        }
+    }
+
+    None
+}
+
+/// Find the kernel log entry for a command that just died, if readable
+pub fn find_recent_event(command: &str, pid: Option<u32>) -> Option<KernelEvent> {
+    let log = read_kernel_log()?;
+    let report = parse_kernel_log(&log)?;
+    report.latest_for(command, pid, system_uptime()).cloned()
+}
+
+/// Seconds since boot, matching dmesg timestamps
+fn system_uptime() -> Option<f64> {
+    fs::read_to_string("/proc/uptime")
+        .ok()?
+        .split_whitespace()
+        .next()?
+        .parse()
+        .ok()
+}
+
+/// The name the kernel records for a command's program
+fn command_comm(command: &str) -> Option<String> {
+    let program = command.split_whitespace().next()?;
+    let name = program.rsplit('/').next().unwrap_or(program);
+    Some(name.chars().take(COMM_MAX_LEN).collect())
+}
+
+/// Remove dmesg/journal prefixes ("[  12.345] ", "host kernel: ")
+fn strip_log_prefix(line: &str) -> &str {
+    let line = match line.find("] ") {
+        Some(pos) if line.starts_with('[') => &line[pos + 2..],
+        _ => line,
+    };
+    match line.find("kernel: ") {
+        Some(pos) => &line[pos + 8..],
+        None => line,
+    }
+}
+
+fn set_module(event: &mut KernelEvent, module: Option<regex::Match>, base: Option<regex::Match>) {
+    let Some(module) = module else {
+        return;
+    };
+    event.module = Some(module.as_str().to_string());
+    if let (Some(ip), Some(base)) = (
+        event.instruction_pointer,
+        base.and_then(|b| parse_hex(b.as_str())),
+    ) {
+        event.module_offset = ip.checked_sub(base);
+    }
+}
+
+/// Describe a segfault from its address and x86 page fault error code
+fn describe_segfault(event: &KernelEvent) -> String {
+    let mut parts = Vec::new();
+
+    if let Some(address) = event.fault_address {
+        parts.push(match address {
+            0 => "segfault at address 0x0 (null pointer dereference)".to_string(),
+            1..=0xfff => format!(
+                "segfault at address {:#x} (near null: likely a field of a null pointer)",
+                address
+            ),
+            _ => format!("segfault at address {:#x}", address),
+        });
+    }
+
+    if let Some(code) = event.error_code {
+        let mode = if code & 0x4 != 0 {
+            "user-mode"
+        } else {
+            "kernel-mode"
+        };
+        let access = if code & 0x10 != 0 {
+            "instruction fetch"
+        } else if code & 0x2 != 0 {
+            "write"
+        } else {
+            "read"
+        };
+        let page = if code & 0x1 != 0 {
+            "protected page"
+        } else {
+            "unmapped page"
+        };
+        parts.push(format!("{} {} of {}", mode, access, page));
+    }
+
+    if let Some(module) = &event.module {
+        match event.module_offset {
+            Some(offset) => parts.push(format!(
+                "faulting instruction in {} at offset {:#x}",
+                module, offset
+            )),
+            None => parts.push(format!("faulting instruction in {}", module)),
+        }
+    }
+
+    parts.join("; ")
+}
+
+/// Shared libraries that are rarely the actual bug
+fn is_system_library(module: &str) -> bool {
+    module.starts_with("libc.so")
+        || module.starts_with("libc-")
+        || module.starts_with("ld-")
+        || module.starts_with("libpthread")
+        || module.starts_with("libstdc++")
+        || module.starts_with("libm.so")
+}
+
+fn parse_hex(text: &str) -> Option<u64> {
+    u64::from_str_radix(text.trim_start_matches("0x"), 16).ok()
+}
+
+fn kb_field(details: &str, name: &str) -> Option<u64> {
+    let start = details.find(&format!("{}:", name))? + name.len() + 1;
+    let rest = &details[start..];
+    let end = rest.find("kB")?;
+    rest[..end].trim().parse().ok()
+}
+
+fn format_kb(kb: u64) -> String {
+    if kb >= 1024 * 1024 {
+        format!("{:.1} GiB", kb as f64 / (1024.0 * 1024.0))
+    } else if kb >= 1024 {
+        format!("{:.1} MiB", kb as f64 / 1024.0)
+    } else {
+        format!("{} kB", kb)
+    }
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parses_segfault_line() {
+        let input = "[12345.678901] app[1234]: segfault at 0 ip 000055d4c3a0b1c9 sp 00007ffd4f5e2a10 error 4 in app[55d4c3a0a000+1000] likely on CPU 3 (core 3, socket 0)";
+
+        let report = parse_kernel_log(input).unwrap();
+        let event = report.primary().unwrap();
+        assert_eq!(event.kind, KernelEventKind::Segfault);
+        assert_eq!(event.process, "app");
+        assert_eq!(event.pid, Some(1234));
+        assert_eq!(event.timestamp, Some(12345.678901));
+        assert_eq!(event.fault_address, Some(0));
+        assert_eq!(event.module.as_deref(), Some("app"));
+        assert_eq!(event.module_offset, Some(0x11c9));
+        assert!(event.description.contains("null pointer dereference"));
+        assert!(event
+            .description
+            .contains("user-mode read of unmapped page"));
+
+        let frame = event.fault_frame().unwrap();
+        assert_eq!(frame.function.as_deref(), Some("app+0x11c9"));
+        assert!(frame.is_user_code);
+    }
+
+    #[test]
+    fn test_parses_trap_line() {
+        let input = "Oct 16 10:00:00 host kernel: traps: worker[4321] general protection fault ip:7f1c2a3b4c5d sp:7ffc00001000 error:0 in libc.so.6[7f1c2a300000+195000]";
+
+        let event = parse_kernel_log(input).unwrap().events.remove(0);
+        assert_eq!(event.kind, KernelEventKind::Trap);
+        assert_eq!(event.process, "worker");
+        assert_eq!(event.description, "general protection fault in libc.so.6");
+        assert_eq!(event.module_offset, Some(0xb4c5d));
+        assert!(!event.fault_frame().unwrap().is_user_code);
+    }
+
+    #[test]
+    fn test_parses_cgroup_oom_kill() {
+        let input = "\
+[ 900.100000] java invoked oom-killer: gfp_mask=0xcc0(GFP_KERNEL), order=0, oom_score_adj=0
+[ 900.100100] memory: usage 524288kB, limit 524288kB, failcnt 42
+[ 900.100200] oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=abc,mems_allowed=0,oom_memcg=/kubepods/pod1,task_memcg=/kubepods/pod1/c1,task=java,pid=1234,uid=1000
+[ 900.100300] Memory cgroup out of memory: Killed process 1234 (java) total-vm:8123456kB, anon-rss:520000kB, file-rss:1200kB, shmem-rss:0kB, UID:1000 pgtables:2000kB oom_score_adj:-998";
+
+        let event = parse_kernel_log(input).unwrap().events.remove(0);
+        assert_eq!(event.kind, KernelEventKind::OomKill);
+        assert_eq!(event.process, "java");
+        assert_eq!(event.pid, Some(1234));
+
+        let oom = event.oom.as_ref().unwrap();
+        assert_eq!(oom.total_vm_kb, Some(8123456));
+        assert_eq!(oom.anon_rss_kb, Some(520000));
+        assert_eq!(oom.oom_score_adj, Some(-998));
+        assert_eq!(oom.constraint.as_deref(), Some("CONSTRAINT_MEMCG"));
+        assert_eq!(oom.cgroup.as_deref(), Some("/kubepods/pod1"));
+        assert_eq!(oom.cgroup_limit_kb, Some(524288));
+        assert_eq!(oom.invoked_by.as_deref(), Some("java"));
+        assert!(event.description.contains("memory cgroup"));
+
+        let summary = parse_kernel_log(input).unwrap().prompt_summary();
+        assert!(summary.contains("cgroup usage 512.0 MiB of limit 512.0 MiB"));
+    }
+
+    #[test]
+    fn test_parses_hung_task_call_trace() {
+        let input = "\
+[ 2400.000001] INFO: task backup:777 blocked for more than 120 seconds.
+[ 2400.000002]       Not tainted 6.1.0 #1
+[ 2400.000003] \"echo 0 > /proc/sys/kernel/hung_task_timeout_secs\" disables this message.
+[ 2400.000004] task:backup          state:D stack:0     pid:777   ppid:1      flags:0x00004000
+[ 2400.000005] Call Trace:
+[ 2400.000006]  <TASK>
+[ 2400.000007]  __schedule+0x2e5/0x890
+[ 2400.000008]  schedule+0x5e/0xd0
+[ 2400.000009]  rpc_wait_bit_killable+0x1e/0x90 [sunrpc]
+[ 2400.000010]  </TASK>
+[ 2400.000011] eth0: link up";
+
+        let event = parse_kernel_log(input).unwrap().events.remove(0);
+        assert_eq!(event.kind, KernelEventKind::HungTask);
+        assert_eq!(event.process, "backup");
+        assert_eq!(event.blocked_seconds, Some(120));
+        assert_eq!(event.call_trace.len(), 3);
+        assert!(!event.call_trace[0].is_user_code);
+        assert!(event.call_trace[2].is_user_code);
+        assert!(!event.raw.contains("link up"));
+    }
+
+    #[test]
+    fn test_latest_for_matches_pid_or_recent_name() {
+        let input = "\
+[  100.000000] app[10]: segfault at 0 ip 0000000000401000 sp 00007ffd00000000 error 4 in app[400000+1000]
+[ 1000.000000] app[20]: segfault at 8 ip 0000000000401000 sp 00007ffd00000000 error 6 in app[400000+1000]";
+
+        let report = parse_kernel_log(input).unwrap();
+        assert_eq!(
+            report.latest_for("./app", Some(10), None).unwrap().pid,
+            Some(10)
+        );
+        assert_eq!(
+            report
+                .latest_for("/usr/bin/app --flag", None, Some(1010.0))
+                .unwrap()
+                .pid,
+            Some(20)
+        );
+        // Too old to be attributed without a PID
+        assert!(report.latest_for("app", None, Some(5000.0)).is_none());
+        assert!(report.latest_for("other", None, Some(1010.0)).is_none());
+    }
+
+    #[test]
+    fn test_plain_text_is_not_kernel_log() {
+        assert!(!is_kernel_log("Segmentation fault (core dumped)"));
+        assert!(parse_kernel_log("error: build failed").is_none());
+    }
+}
@@ -0,0 +1,1201 @@
+//! Kubernetes pod failure parsing.
+//!
+//! Understands `kubectl describe pod`, `kubectl get events` and
+//! `kubectl get pod -o yaml|json` output. Container states, the last
+//! termination reason and exit code, restart counts and events are pulled
+//! out so the explanation can focus on why the pod is failing rather than on
+//! the hundred lines of labels, mounts and tolerations around it.
+
+use regex::Regex;
+use serde::Serialize;
+use serde_json::Value;
+use std::collections::BTreeMap;
+use std::sync::OnceLock;
+
+use crate::output::interpret_exit_code;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Status of a single container in a pod
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ContainerStatus {
+    /// Container name
+    pub name: String,
+    /// Whether this is an init container
+    pub init: bool,
+    /// Image reference
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub image: Option<String>,
+    /// Current state (Waiting, Running, Terminated)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub state: Option<String>,
+    /// Reason for the current state (e.g. CrashLoopBackOff)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+    /// Message for the current state
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+    /// Exit code, if the container is terminated
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exit_code: Option<i32>,
+    /// Previous state, usually Terminated for restarting containers
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_state: Option<String>,
+    /// Reason of the last termination (e.g. OOMKilled, Error)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_reason: Option<String>,
+    /// Message of the last termination
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_message: Option<String>,
+    /// Exit code of the last termination
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_exit_code: Option<i32>,
+    /// Number of restarts
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub restart_count: Option<u32>,
+    /// Whether the container passes its readiness checks
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub ready: Option<bool>,
+    /// Probe definitions (e.g. "liveness: http-get http://:8080/healthz ...")
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub probes: Vec<String>,
+}
+
+impl ContainerStatus {
+    /// The reason that best explains this container's failure, if any
+    fn failure_reason(&self) -> Option<&str> {
+        let current = self.reason.as_deref().filter(|r| is_failure_reason(r));
+        let last = self.last_reason.as_deref().filter(|r| is_failure_reason(r));
+        current.or(last).or_else(|| {
+            // "Error" and friends only count with a non-zero exit code
+            match (self.exit_code, self.last_exit_code) {
+                (Some(code), _) if code != 0 => self.reason.as_deref().or(Some("Error")),
+                (_, Some(code)) if code != 0 => self.last_reason.as_deref().or(Some("Error")),
+                _ => None,
+            }
+        })
+    }
+
+    fn render(&self) -> String {
+        let kind = if self.init {
+            "Init container"
+        } else {
+            "Container"
+        };
+        let mut parts = Vec::new();
+        if let Some(state) = &self.state {
+            parts.push(format!(
+                "state {}",
+                describe_state(state, self.reason.as_deref(), self.exit_code)
+            ));
+        }
+        if let Some(message) = &self.message {
+            parts.push(format!("message \"{}\"", message.trim()));
+        }
+        if let Some(last) = &self.last_state {
+            parts.push(format!(
+                "last state {}",
+                describe_state(last, self.last_reason.as_deref(), self.last_exit_code)
+            ));
+        }
+        if let Some(message) = &self.last_message {
+            parts.push(format!("last message \"{}\"", message.trim()));
+        }
+        if let Some(restarts) = self.restart_count {
+            parts.push(format!("{} restart(s)", restarts));
+        }
+        if let Some(ready) = self.ready {
+            parts.push(format!("ready {}", ready));
+        }
+
+        let image = self
+            .image
+            .as_ref()
+            .map(|i| format!(" (image {})", i))
+            .unwrap_or_default();
+        let mut out = format!("{} {}{}: {}", kind, self.name, image, parts.join("; "));
+        for probe in &self.probes {
+            out.push_str(&format!("\n  {}", probe));
+        }
+        out
+    }
+}
+
+/// A Kubernetes event
+#[derive(Debug, Clone, Serialize)]
+pub struct KubeEvent {
+    /// Normal or Warning
+    pub event_type: String,
+    /// Short machine-readable reason (e.g. BackOff, Unhealthy)
+    pub reason: String,
+    /// Object the event is about (e.g. pod/web-1)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub object: Option<String>,
+    /// Human readable message
+    pub message: String,
+    /// How many times the event was seen
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub count: Option<u32>,
+}
+
+impl KubeEvent {
+    /// Whether this is a Warning event
+    pub fn is_warning(&self) -> bool {
+        self.event_type.eq_ignore_ascii_case("warning")
+    }
+
+    fn render(&self) -> String {
+        let count = self.count.map(|c| format!(" (x{})", c)).unwrap_or_default();
+        format!(
+            "{} {}{}: {}",
+            self.event_type, self.reason, count, self.message
+        )
+    }
+}
+
+/// Status of a single pod
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct PodStatus {
+    /// Pod name
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    /// Pod namespace
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    /// Pod phase (Pending, Running, Succeeded, Failed)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub phase: Option<String>,
+    /// Pod-level reason (e.g. Evicted)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+    /// Pod-level message
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+    /// Init and app container statuses
+    pub containers: Vec<ContainerStatus>,
+}
+
+impl PodStatus {
+    fn display_name(&self) -> String {
+        match (&self.namespace, &self.name) {
+            (Some(ns), Some(name)) => format!("{}/{}", ns, name),
+            (None, Some(name)) => name.clone(),
+            _ => "(unnamed pod)".to_string(),
+        }
+    }
+}
+
+/// Everything parsed from kubectl output
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct KubernetesReport {
+    /// Pods with their container statuses
+    pub pods: Vec<PodStatus>,
+    /// Events, in the order printed
+    pub events: Vec<KubeEvent>,
+}
+
+/// The single most relevant failure in a report
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct KubeFailure {
+    /// Reason used as the error type (e.g. CrashLoopBackOff)
+    pub reason: String,
+    /// One-line description of the failure
+    pub summary: String,
+}
+
+impl KubernetesReport {
+    /// Whether the report shows anything wrong (failed containers, evictions
+    /// or warning events)
+    pub fn has_failure(&self) -> bool {
+        self.primary_failure().is_some()
+    }
+
+    /// The failure the explanation should focus on
+    ///
+    /// Container failures win over pod-level reasons, which win over warning
+    /// events. Within containers, app containers win over init containers.
+    pub fn primary_failure(&self) -> Option<KubeFailure> {
+        let mut candidates: Vec<(&PodStatus, &ContainerStatus, &str)> = Vec::new();
+        for pod in &self.pods {
+            for container in &pod.containers {
+                if let Some(reason) = container.failure_reason() {
+                    candidates.push((pod, container, reason));
+                }
+            }
+        }
+        candidates.sort_by_key(|(_, c, _)| c.init);
+
+        if let Some((pod, container, reason)) = candidates.first() {
+            let mut summary = format!(
+                "container {} in pod {}: {}",
+                container.name,
+                pod.display_name(),
+                describe_state(
+                    container.state.as_deref().unwrap_or("Unknown"),
+                    container.reason.as_deref(),
+                    container.exit_code
+                )
+            );
+            if let Some(last) = &container.last_state {
+                summary.push_str(&format!(
+                    ", last {}",
+                    describe_state(
+                        last,
+                        container.last_reason.as_deref(),
+                        container.last_exit_code
+                    )
+                ));
+            }
+            if let Some(restarts) = container.restart_count.filter(|r| *r > 0) {
+                summary.push_str(&format!(", {} restart(s)", restarts));
+            }
+            return Some(KubeFailure {
+                reason: reason.to_string(),
+                summary,
+            });
+        }
+
+        if let Some(pod) = self
+            .pods
+            .iter()
+            .find(|p| p.reason.is_some() || p.phase.as_deref() == Some("Failed"))
+        {
+            let reason = pod.reason.clone().unwrap_or_else(|| "Failed".to_string());
+            let message = pod.message.as_deref().unwrap_or("pod failed");
+            return Some(KubeFailure {
+                summary: format!("pod {}: {}: {}", pod.display_name(), reason, message),
+                reason,
+            });
+        }
+
+        self.events
+            .iter()
+            .find(|e| e.is_warning())
+            .map(|event| KubeFailure {
+                reason: event.reason.clone(),
+                summary: event.render(),
+            })
+    }
+
+    /// Condensed view of the parsed status, used instead of the raw kubectl
+    /// output when building the prompt
+    pub fn focused_input(&self) -> String {
+        let mut out = Vec::new();
+        for pod in &self.pods {
+            let mut header = format!("Pod {}", pod.display_name());
+            if let Some(phase) = &pod.phase {
+                header.push_str(&format!(" (phase {})", phase));
+            }
+            if let Some(reason) = &pod.reason {
+                header.push_str(&format!(": {}", reason));
+            }
+            if let Some(message) = &pod.message {
+                header.push_str(&format!(" - {}", message));
+            }
+            out.push(header);
+            for container in &pod.containers {
+                out.push(container.render());
+            }
+        }
+
+        // Normal events are mostly noise ("Pulled", "Created"); keep the
+        // warnings plus the kubelet's kill notices
+        let events: Vec<&KubeEvent> = self
+            .events
+            .iter()
+            .filter(|e| e.is_warning() || e.reason == "Killing")
+            .collect();
+        if !events.is_empty() {
+            out.push("Events:".to_string());
+            for event in events.iter().take(10) {
+                out.push(format!("  {}", event.render()));
+            }
+        }
+
+        out.join("\n")
+    }
+
+    /// Render the interpretation block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = String::from("Kubernetes pod status:\n");
+        if let Some(failure) = self.primary_failure() {
+            out.push_str(&format!("- primary problem: {}\n", failure.summary));
+        }
+
+        let mut reasons: Vec<&str> = Vec::new();
+        for container in self.pods.iter().flat_map(|p| p.containers.iter()) {
+            reasons.extend(container.reason.as_deref());
+            reasons.extend(container.last_reason.as_deref());
+        }
+        reasons.extend(self.pods.iter().filter_map(|p| p.reason.as_deref()));
+        reasons.extend(
+            self.events
+                .iter()
+                .filter(|e| e.is_warning())
+                .map(|e| e.reason.as_str()),
+        );
+
+        let mut seen = Vec::new();
+        for reason in reasons {
+            if seen.contains(&reason) {
+                continue;
+            }
+            seen.push(reason);
+            if let Some(meaning) = reason_meaning(reason) {
+                out.push_str(&format!("- {}: {}\n", reason, meaning));
+            }
+        }
+
+        for container in self.pods.iter().flat_map(|p| p.containers.iter()) {
+            for code in [container.exit_code, container.last_exit_code]
+                .into_iter()
+                .flatten()
+                .filter(|c| *c != 0)
+            {
+                out.push_str(&format!(
+                    "- exit code {} in {}: {}\n",
+                    code,
+                    container.name,
+                    interpret_exit_code(code)
+                ));
+            }
+        }
+
+        out.trim_end().to_string()
+    }
+}
+
+/// Container and pod reasons that indicate a failure
+fn is_failure_reason(reason: &str) -> bool {
+    matches!(
+        reason,
+        "CrashLoopBackOff"
+            | "OOMKilled"
+            | "ImagePullBackOff"
+            | "ErrImagePull"
+            | "ErrImageNeverPull"
+            | "InvalidImageName"
+            | "CreateContainerConfigError"
+            | "CreateContainerError"
+            | "RunContainerError"
+            | "ContainerCannotRun"
+            | "StartError"
+            | "DeadlineExceeded"
+            | "Evicted"
+    )
+}
+
+/// What a kubelet or scheduler reason means and where to look next
+fn reason_meaning(reason: &str) -> Option<&'static str> {
+    Some(match reason {
+        "CrashLoopBackOff" => {
+            "the container keeps exiting and the kubelet waits longer before each restart; \
+             the last termination reason and exit code show why it exits"
+        }
+        "OOMKilled" => {
+            "the container used more memory than its limit (resources.limits.memory) and \
+             was killed by the kernel with exit code 137"
+        }
+        "ImagePullBackOff" | "ErrImagePull" => {
+            "the image could not be pulled; check the image name and tag, registry \
+             credentials (imagePullSecrets) and whether the node can reach the registry"
+        }
+        "InvalidImageName" => "the image reference is malformed",
+        "CreateContainerConfigError" => {
+            "a ConfigMap, Secret or key referenced by env, envFrom or volumes does not exist"
+        }
+        "CreateContainerError" | "RunContainerError" | "ContainerCannotRun" | "StartError" => {
+            "the runtime could not start the container, usually a missing command or \
+             entrypoint, a bad working directory or a failed mount"
+        }
+        "Error" => "the container's main process exited with a non-zero exit code",
+        "Completed" => {
+            "the process exited successfully; with restartPolicy Always a container that \
+             finishes is restarted and can end up in CrashLoopBackOff"
+        }
+        "Evicted" => {
+            "the kubelet evicted the pod because the node ran low on memory, disk or \
+             ephemeral storage"
+        }
+        "DeadlineExceeded" => "the pod ran longer than its activeDeadlineSeconds",
+        "Unhealthy" => {
+            "a liveness, readiness or startup probe failed; failed liveness probes make the \
+             kubelet restart the container"
+        }
+        "BackOff" => "the kubelet is backing off after repeated failures",
+        "FailedScheduling" => {
+            "no node satisfies the pod's resource requests, node selectors, affinity rules \
+             or taint tolerations"
+        }
+        "FailedMount" | "FailedAttachVolume" => {
+            "a volume could not be mounted: a missing PVC, Secret or ConfigMap, or a storage \
+             attach problem"
+        }
+        "FailedCreatePodSandBox" => {
+            "the pod sandbox could not be created, usually a CNI networking problem on the node"
+        }
+        _ => return None,
+    })
+}
+
+/// "Terminated (OOMKilled, exit code 137)"
+fn describe_state(state: &str, reason: Option<&str>, exit_code: Option<i32>) -> String {
+    let mut details = Vec::new();
+    if let Some(reason) = reason {
+        details.push(reason.to_string());
+    }
+    if let Some(code) = exit_code {
+        details.push(format!("exit code {}", code));
+    }
+    if details.is_empty() {
+        state.to_string()
+    } else {
+        format!("{} ({})", state, details.join(", "))
+    }
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    column_gap: Regex,
+    event_count: Regex,
+    container_path: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        column_gap: Regex::new(r"\s{2,}").unwrap(),
+        event_count: Regex::new(r"\(x(\d+) over").unwrap(),
+        container_path: Regex::new(
+            r"^((?:items\[\d+\]\.)?)status\.(containerStatuses|initContainerStatuses)\[(\d+)\]\.(.+)$",
+        )
+        .unwrap(),
+    })
+}
+
+/// Check whether input looks like kubectl pod or event output
+pub fn is_kubectl_output(input: &str) -> bool {
+    input.contains("containerStatuses")
+        || (has_top_level(input, "Containers:")
+            && (input.contains("Restart Count:") || input.contains("State:")))
+        || input.lines().any(is_events_header)
+}
+
+/// Check whether a command runs kubectl (or an equivalent CLI)
+pub fn is_kubectl_command(command: &[String]) -> bool {
+    let program = |arg: &String| arg.rsplit('/').next().unwrap_or(arg).to_string();
+    match command.first().map(program).as_deref() {
+        Some("kubectl") | Some("oc") => true,
+        Some("microk8s") | Some("k3s") => command.get(1).map(|a| a.as_str()) == Some("kubectl"),
+        _ => false,
+    }
+}
+
+/// Parse kubectl describe, get events, or get pod -o yaml|json output
+pub fn parse_kubectl_output(input: &str) -> Option<KubernetesReport> {
+    let trimmed = input.trim_start();
+    let report = if trimmed.starts_with('{') {
+        let value: Value = serde_json::from_str(trimmed).ok()?;
+        let mut fields = Vec::new();
+        flatten_json(&value, String::new(), &mut fields);
+        parse_structured(&fields)
+    } else if input.contains("containerStatuses:") {
+        parse_structured(&flatten_yaml(input))
+    } else {
+        parse_describe(input)
+    };
+
+    if report.pods.is_empty() && report.events.is_empty() {
+        None
+    } else {
+        Some(report)
+    }
+}
+
+/// Parse `kubectl describe pod` text, plus any `kubectl get events` tables
+fn parse_describe(input: &str) -> KubernetesReport {
+    #[derive(PartialEq)]
+    enum Section {
+        Other,
+        Containers { init: bool },
+        Events,
+    }
+
+    let p = patterns();
+    let mut report = KubernetesReport::default();
+    let mut pod: Option<PodStatus> = None;
+    let mut section = Section::Other;
+    let mut columns: Vec<String> = Vec::new();
+    // Whether Reason/Exit Code/Message lines belong to "Last State"
+    let mut in_last_state = false;
+
+    for line in input.lines() {
+        if line.trim().is_empty() {
+            continue;
+        }
+
+        if is_events_header(line) {
+            section = Section::Events;
+            columns = p
+                .column_gap
+                .split(line.trim())
+                .map(|c| c.to_uppercase())
+                .collect();
+            continue;
+        }
+
+        // `kubectl get events` rows start at column 0, describe rows are indented
+        if section == Section::Events {
+            if line.trim().starts_with("----") {
+                continue;
+            }
+            if let Some(event) = parse_event_row(line.trim(), &columns) {
+                report.events.push(event);
+                continue;
+            }
+        }
+
+        let indent = line.len() - line.trim_start().len();
+        let (key, value) = split_field(line.trim());
+
+        if indent == 0 {
+            section = Section::Other;
+            match key {
+                "Name" => {
+                    if let Some(done) = pod.take() {
+                        report.pods.push(done);
+                    }
+                    pod = Some(PodStatus {
+                        name: value.map(str::to_string),
+                        ..Default::default()
+                    });
+                }
+                "Namespace" => set_pod_field(&mut pod, |p| &mut p.namespace, value),
+                "Status" => set_pod_field(&mut pod, |p| &mut p.phase, value),
+                "Reason" => set_pod_field(&mut pod, |p| &mut p.reason, value),
+                "Message" => set_pod_field(&mut pod, |p| &mut p.message, value),
+                "Containers" => section = Section::Containers { init: false },
+                "Init Containers" => section = Section::Containers { init: true },
+                "Events" => section = Section::Events,
+                _ => {}
+            }
+            continue;
+        }
+
+        match section {
+            Section::Containers { init } => {
+                let Some(pod) = pod.as_mut() else {
+                    continue;
+                };
+                if indent == 2 && value.is_none() && line.trim_end().ends_with(':') {
+                    pod.containers.push(ContainerStatus {
+                        name: key.to_string(),
+                        init,
+                        ..Default::default()
+                    });
+                    in_last_state = false;
+                    continue;
+                }
+                let Some(container) = pod.containers.last_mut() else {
+                    continue;
+                };
+                let value = value.map(str::to_string);
+                match key {
+                    "Image" => container.image = value,
+                    "State" => {
+                        container.state = value;
+                        in_last_state = false;
+                    }
+                    "Last State" => {
+                        container.last_state = value;
+                        in_last_state = true;
+                    }
+                    "Reason" if in_last_state => container.last_reason = value,
+                    "Reason" => container.reason = value,
+                    "Message" if in_last_state => container.last_message = value,
+                    "Message" => container.message = value,
+                    "Exit Code" if in_last_state => {
+                        container.last_exit_code = value.and_then(|v| v.parse().ok())
+                    }
+                    "Exit Code" => container.exit_code = value.and_then(|v| v.parse().ok()),
+                    "Ready" => container.ready = value.map(|v| v.eq_ignore_ascii_case("true")),
+                    "Restart Count" => container.restart_count = value.and_then(|v| v.parse().ok()),
+                    "Liveness" | "Readiness" | "Startup" => {
+                        if let Some(value) = value {
+                            container.probes.push(format!(
+                                "{} probe: {}",
+                                key.to_lowercase(),
+                                value
+                            ));
+                        }
+                    }
+                    _ => {}
+                }
+            }
+            Section::Events | Section::Other => {}
+        }
+    }
+
+    if let Some(done) = pod.take() {
+        report.pods.push(done);
+    }
+    report
+}
+
+fn set_pod_field(
+    pod: &mut Option<PodStatus>,
+    field: impl Fn(&mut PodStatus) -> &mut Option<String>,
+    value: Option<&str>,
+) {
+    if let (Some(pod), Some(value)) = (pod.as_mut(), value) {
+        *field(pod) = Some(value.to_string());
+    }
+}
+
+/// Split a "Key:   value" describe line
+fn split_field(line: &str) -> (&str, Option<&str>) {
+    match line.split_once(':') {
+        Some((key, value)) => {
+            let value = value.trim();
+            let value = (!value.is_empty() && value != "<none>").then_some(value);
+            (key.trim(), value)
+        }
+        None => (line, None),
+    }
+}
+
+/// Header of a describe Events table or `kubectl get events`
+fn is_events_header(line: &str) -> bool {
+    let trimmed = line.trim();
+    (trimmed.starts_with("Type") && trimmed.contains("Reason") && trimmed.ends_with("Message"))
+        || ((trimmed.starts_with("LAST SEEN") || trimmed.starts_with("NAMESPACE"))
+            && trimmed.contains("REASON")
+            && trimmed.contains("MESSAGE"))
+}
+
+fn parse_event_row(line: &str, columns: &[String]) -> Option<KubeEvent> {
+    let p = patterns();
+    // Messages may contain runs of spaces; splitn keeps them in the last column
+    if columns.is_empty() {
+        return None;
+    }
+    let cells: Vec<&str> = p.column_gap.splitn(line, columns.len()).collect();
+    if cells.len() < columns.len().min(3) {
+        return None;
+    }
+
+    let cell = |name: &str| {
+        columns
+            .iter()
+            .position(|c| c == name)
+            .and_then(|i| cells.get(i))
+            .map(|v| v.to_string())
+    };
+
+    let event_type = cell("TYPE")?;
+    if !matches!(event_type.as_str(), "Normal" | "Warning") {
+        return None;
+    }
+
+    let count = cell("COUNT").and_then(|c| c.parse().ok()).or_else(|| {
+        cell("AGE")
+            .or_else(|| cell("LAST SEEN"))
+            .and_then(|age| p.event_count.captures(&age).and_then(|c| c[1].parse().ok()))
+    });
+
+    Some(KubeEvent {
+        event_type,
+        reason: cell("REASON").unwrap_or_default(),
+        object: cell("OBJECT"),
+        message: cell("MESSAGE").unwrap_or_default(),
+        count,
+    })
+}
+
+/// Build a report from flattened `path = value` pairs of a Pod (or List of Pods)
+fn parse_structured(fields: &[(String, String)]) -> KubernetesReport {
+    let p = patterns();
+    let lookup: BTreeMap<&str, &str> = fields
+        .iter()
+        .map(|(k, v)| (k.as_str(), v.as_str()))
+        .collect();
+
+    // prefix ("" or "items[N].") -> (kind, index) -> container
+    let mut pods: BTreeMap<String, BTreeMap<(String, usize), ContainerStatus>> = BTreeMap::new();
+
+    for (path, value) in fields {
+        let Some(caps) = p.container_path.captures(path) else {
+            continue;
+        };
+        let prefix = caps[1].to_string();
+        let kind = caps[2].to_string();
+        let index: usize = caps[3].parse().unwrap_or(0);
+        let container = pods
+            .entry(prefix)
+            .or_default()
+            .entry((kind.clone(), index))
+            .or_insert_with(|| ContainerStatus {
+                init: kind == "initContainerStatuses",
+                ..Default::default()
+            });
+
+        let value = value.clone();
+        match &caps[4] {
+            "name" => container.name = value,
+            "image" => container.image = Some(value),
+            "ready" => container.ready = Some(value == "true"),
+            "restartCount" => container.restart_count = value.parse().ok(),
+            field => {
+                let Some((which, rest)) = field.split_once('.') else {
+                    continue;
+                };
+                let Some((state, attr)) = rest.split_once('.') else {
+                    continue;
+                };
+                let state = capitalize(state);
+                let last = which == "lastState";
+                if which != "state" && !last {
+                    continue;
+                }
+                if last {
+                    container.last_state = Some(state);
+                } else {
+                    container.state = Some(state);
+                }
+                match (attr, last) {
+                    ("reason", false) => container.reason = Some(value),
+                    ("reason", true) => container.last_reason = Some(value),
+                    ("message", false) => container.message = Some(value),
+                    ("message", true) => container.last_message = Some(value),
+                    ("exitCode", false) => container.exit_code = value.parse().ok(),
+                    ("exitCode", true) => container.last_exit_code = value.parse().ok(),
+                    _ => {}
+                }
+            }
+        }
+    }
+
+    let field = |prefix: &str, name: &str| {
+        lookup
+            .get(format!("{}{}", prefix, name).as_str())
+            .map(|v| v.to_string())
+    };
+
+    let mut report = KubernetesReport::default();
+    for (prefix, containers) in pods {
+        let mut containers: Vec<ContainerStatus> = containers.into_values().collect();
+        // Init containers run first; list them first like kubectl describe
+        containers.sort_by_key(|c| !c.init);
+        report.pods.push(PodStatus {
+            name: field(&prefix, "metadata.name"),
+            namespace: field(&prefix, "metadata.namespace"),
+            phase: field(&prefix, "status.phase"),
+            reason: field(&prefix, "status.reason"),
+            message: field(&prefix, "status.message"),
+            containers,
+        });
+    }
+    report
+}
+
+fn capitalize(text: &str) -> String {
+    let mut chars = text.chars();
+    match chars.next() {
+        Some(first) => first.to_uppercase().chain(chars).collect(),
+        None => String::new(),
+    }
+}
+
+/// Flatten JSON into `a.b[0].c = value` pairs
+fn flatten_json(value: &Value, path: String, out: &mut Vec<(String, String)>) {
+    match value {
+        Value::Object(map) => {
+            for (key, child) in map {
+                let child_path = if path.is_empty() {
+                    key.clone()
+                } else {
+                    format!("{}.{}", path, key)
+                };
+                flatten_json(child, child_path, out);
+            }
+        }
+        Value::Array(items) => {
+            for (i, child) in items.iter().enumerate() {
+                flatten_json(child, format!("{}[{}]", path, i), out);
+            }
+        }
+        Value::String(s) => out.push((path, s.clone())),
+        Value::Null => {}
+        other => out.push((path, other.to_string())),
+    }
+}
+
+/// Flatten the block-style YAML kubectl prints into `a.b[0].c = value` pairs
+///
+/// This is not a general YAML parser: it handles nested maps, lists of maps
+/// or scalars, quoted scalars and `|` block scalars, which is all that
+/// `kubectl get -o yaml` emits for pod status.
+fn flatten_yaml(input: &str) -> Vec<(String, String)> {
+    struct Entry {
+        indent: usize,
+        segment: String,
+        is_item: bool,
+    }
+
+    fn path_of(stack: &[Entry]) -> String {
+        let mut path = String::new();
+        for entry in stack {
+            if entry.is_item || path.is_empty() {
+                path.push_str(&entry.segment);
+            } else {
+                path.push('.');
+                path.push_str(&entry.segment);
+            }
+        }
+        path
+    }
+
+    let mut out: Vec<(String, String)> = Vec::new();
+    let mut stack: Vec<Entry> = Vec::new();
+    let mut item_counts: BTreeMap<String, usize> = BTreeMap::new();
+    // Block scalar being collected: (key indent, path, lines)
+    let mut block: Option<(usize, String, Vec<String>)> = None;
+
+    for line in input.lines() {
+        let indent = line.len() - line.trim_start().len();
+        let content = line.trim();
+
+        if let Some((block_indent, _, lines)) = block.as_mut() {
+            if content.is_empty() || indent > *block_indent {
+                lines.push(content.to_string());
+                continue;
+            }
+            let (_, path, lines) = block.take().unwrap();
+            out.push((path, lines.join("\n").trim().to_string()));
+        }
+
+        if content.is_empty() || content.starts_with('#') || content == "---" {
+            continue;
+        }
+
+        let (mut indent, mut content) = (indent, content);
+        if let Some(rest) =
+            content
+                .strip_prefix("- ")
+                .or(if content == "-" { Some("") } else { None })
+        {
+            while stack
+                .last()
+                .is_some_and(|e| e.indent > indent || (e.indent == indent && e.is_item))
+            {
+                stack.pop();
+            }
+            let parent = path_of(&stack);
+            let count = item_counts.entry(parent.clone()).or_insert(0);
+            let segment = format!("[{}]", count);
+            *count += 1;
+            stack.push(Entry {
+                indent,
+                segment,
+                is_item: true,
+            });
+            indent += 2;
+            content = rest.trim();
+            if content.is_empty() {
+                continue;
+            }
+            if !content.contains(": ") && !content.ends_with(':') {
+                out.push((path_of(&stack), unquote(content)));
+                continue;
+            }
+        }
+
+        let Some((key, value)) = content
+            .split_once(": ")
+            .or_else(|| content.strip_suffix(':').map(|k| (k, "")))
+        else {
+            continue;
+        };
+
+        while stack.last().is_some_and(|e| e.indent >= indent) {
+            stack.pop();
+        }
+
+        let value = value.trim();
+        let mut path = path_of(&stack);
+        if !path.is_empty() {
+            path.push('.');
+        }
+        path.push_str(key.trim());
+
+        if value.is_empty() {
+            stack.push(Entry {
+                indent,
+                segment: key.trim().to_string(),
+                is_item: false,
+            });
+        } else if value.starts_with('|') || value.starts_with('>') {
+            block = Some((indent, path, Vec::new()));
+        } else {
+            out.push((path, unquote(value)));
+        }
+    }
+
+    if let Some((_, path, lines)) = block {
+        out.push((path, lines.join("\n").trim().to_string()));
+    }
+
+    out
+}
+
+fn unquote(value: &str) -> String {
+    let value = value.trim();
+    if value.len() >= 2
+        && ((value.starts_with('"') && value.ends_with('"'))
+            || (value.starts_with('\'') && value.ends_with('\'')))
+    {
+        value[1..value.len() - 1].to_string()
+    } else {
+        value.to_string()
+    }
+}
+
+fn has_top_level(input: &str, line: &str) -> bool {
+    input.lines().any(|l| l.trim_end() == line)
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const DESCRIBE: &str = "\
+Name:             web-7d4b9c-xk2lp
+Namespace:        shop
+Priority:         0
+Node:             node-1/10.0.0.4
+Status:           Running
+IP:               10.244.1.7
+Init Containers:
+  migrate:
+    Container ID:   containerd://111
+    Image:          shop/migrate:1.4
+    State:          Terminated
+      Reason:       Completed
+      Exit Code:    0
+    Ready:          True
+    Restart Count:  0
+Containers:
+  web:
+    Container ID:   containerd://abc
+    Image:          shop/web:1.4
+    Port:           8080/TCP
+    State:          Waiting
+      Reason:       CrashLoopBackOff
+    Last State:     Terminated
+      Reason:       OOMKilled
+      Exit Code:    137
+      Started:      Mon, 13 Oct 2026 10:00:00 +0000
+      Finished:     Mon, 13 Oct 2026 10:00:42 +0000
+    Ready:          False
+    Restart Count:  5
+    Liveness:       http-get http://:8080/healthz delay=10s timeout=1s period=10s #success=1 #failure=3
+    Environment:    <none>
+Conditions:
+  Type              Status
+  Ready             False
+Events:
+  Type     Reason     Age                  From               Message
+  ----     ------     ----                 ----               -------
+  Normal   Scheduled  10m                  default-scheduler  Successfully assigned shop/web-7d4b9c-xk2lp to node-1
+  Normal   Pulled     8m (x5 over 10m)     kubelet            Container image \"shop/web:1.4\" already present on machine
+  Warning  BackOff    2m (x20 over 9m)     kubelet            Back-off restarting failed container web in pod web-7d4b9c-xk2lp_shop(1a2b)
+";
+
+    #[test]
+    fn test_parses_describe_pod() {
+        let report = parse_kubectl_output(DESCRIBE).unwrap();
+        assert_eq!(report.pods.len(), 1);
+
+        let pod = &report.pods[0];
+        assert_eq!(pod.name.as_deref(), Some("web-7d4b9c-xk2lp"));
+        assert_eq!(pod.namespace.as_deref(), Some("shop"));
+        assert_eq!(pod.phase.as_deref(), Some("Running"));
+        assert_eq!(pod.containers.len(), 2);
+        assert!(pod.containers[0].init);
+
+        let web = &pod.containers[1];
+        assert_eq!(web.state.as_deref(), Some("Waiting"));
+        assert_eq!(web.reason.as_deref(), Some("CrashLoopBackOff"));
+        assert_eq!(web.last_reason.as_deref(), Some("OOMKilled"));
+        assert_eq!(web.last_exit_code, Some(137));
+        assert_eq!(web.restart_count, Some(5));
+        assert_eq!(web.ready, Some(false));
+        assert_eq!(web.probes.len(), 1);
+
+        assert_eq!(report.events.len(), 3);
+        assert_eq!(report.events[1].count, Some(5));
+        assert!(report.events[2].is_warning());
+        assert_eq!(report.events[2].reason, "BackOff");
+    }
+
+    #[test]
+    fn test_primary_failure_and_prompt() {
+        let report = parse_kubectl_output(DESCRIBE).unwrap();
+        let failure = report.primary_failure().unwrap();
+        assert_eq!(failure.reason, "CrashLoopBackOff");
+        assert!(failure
+            .summary
+            .contains("container web in pod shop/web-7d4b9c-xk2lp"));
+        assert!(failure
+            .summary
+            .contains("last Terminated (OOMKilled, exit code 137)"));
+        assert!(failure.summary.contains("5 restart(s)"));
+
+        let summary = report.prompt_summary();
+        assert!(summary.contains("- OOMKilled: the container used more memory"));
+        assert!(summary.contains("- exit code 137 in web: Killed (SIGKILL)"));
+
+        let focused = report.focused_input();
+        assert!(focused.contains("Container web (image shop/web:1.4)"));
+        assert!(focused.contains("Warning BackOff (x20)"));
+        assert!(!focused.contains("Successfully assigned"));
+    }
+
+    #[test]
+    fn test_parses_get_events_table() {
+        let input = "\
+LAST SEEN   TYPE      REASON             OBJECT                 MESSAGE
+5m          Normal    Scheduled          pod/api-0              Successfully assigned default/api-0 to node-2
+4m          Warning   Failed             pod/api-0              Failed to pull image \"registry.local/api:2.0\": not found
+3m          Warning   Failed             pod/api-0              Error: ImagePullBackOff";
+
+        let report = parse_kubectl_output(input).unwrap();
+        assert!(report.pods.is_empty());
+        assert_eq!(report.events.len(), 3);
+        assert_eq!(report.events[1].object.as_deref(), Some("pod/api-0"));
+        assert!(report.events[1].message.starts_with("Failed to pull image"));
+        assert_eq!(report.primary_failure().unwrap().reason, "Failed");
+    }
+
+    #[test]
+    fn test_parses_pod_yaml() {
+        let input = "\
+apiVersion: v1
+kind: Pod
+metadata:
+  name: worker-0
+  namespace: jobs
+spec:
+  containers:
+  - name: worker
+    image: jobs/worker:3
+status:
+  containerStatuses:
+  - containerID: containerd://def
+    image: jobs/worker:3
+    lastState:
+      terminated:
+        exitCode: 1
+        message: |
+          Traceback (most recent call last):
+          KeyError: 'QUEUE_URL'
+        reason: Error
+    name: worker
+    ready: false
+    restartCount: 3
+    state:
+      waiting:
+        message: back-off 40s restarting failed container=worker pod=worker-0_jobs(1)
+        reason: CrashLoopBackOff
+  phase: Running
+";
+
+        let report = parse_kubectl_output(input).unwrap();
+        let pod = &report.pods[0];
+        assert_eq!(pod.name.as_deref(), Some("worker-0"));
+        assert_eq!(pod.namespace.as_deref(), Some("jobs"));
+        assert_eq!(pod.phase.as_deref(), Some("Running"));
+
+        let worker = &pod.containers[0];
+        assert_eq!(worker.name, "worker");
+        assert_eq!(worker.state.as_deref(), Some("Waiting"));
+        assert_eq!(worker.reason.as_deref(), Some("CrashLoopBackOff"));
+        assert_eq!(worker.last_state.as_deref(), Some("Terminated"));
+        assert_eq!(worker.last_exit_code, Some(1));
+        assert!(worker
+            .last_message
+            .as_deref()
+            .unwrap()
+            .contains("KeyError: 'QUEUE_URL'"));
+        assert_eq!(worker.restart_count, Some(3));
+    }
+
+    #[test]
+    fn test_parses_pod_json() {
+        let input = r#"{
+  "kind": "Pod",
+  "metadata": {"name": "cache-1", "namespace": "default"},
+  "status": {
+    "phase": "Pending",
+    "containerStatuses": [{
+      "name": "redis",
+      "image": "redis:99",
+      "ready": false,
+      "restartCount": 0,
+      "state": {"waiting": {"reason": "ImagePullBackOff", "message": "Back-off pulling image \"redis:99\""}}
+    }]
+  }
+}"#;
+
+        let report = parse_kubectl_output(input).unwrap();
+        let failure = report.primary_failure().unwrap();
+        assert_eq!(failure.reason, "ImagePullBackOff");
+        assert!(failure.summary.contains("pod default/cache-1"));
+        assert!(report.prompt_summary().contains("imagePullSecrets"));
+    }
+
+    #[test]
+    fn test_is_kubectl_command() {
+        let cmd = |args: &[&str]| args.iter().map(|s| s.to_string()).collect::<Vec<_>>();
+        assert!(is_kubectl_command(&cmd(&[
+            "kubectl", "describe", "pod", "x"
+        ])));
+        assert!(is_kubectl_command(&cmd(&[
+            "/usr/local/bin/kubectl",
+            "get",
+            "events"
+        ])));
+        assert!(is_kubectl_command(&cmd(&[
+            "microk8s", "kubectl", "get", "pods"
+        ])));
+        assert!(!is_kubectl_command(&cmd(&["cargo", "build"])));
+    }
+
+    #[test]
+    fn test_healthy_pod_has_no_failure() {
+        let input = "\
+Name:         ok-pod
+Namespace:    default
+Status:       Running
+Containers:
+  app:
+    State:          Running
+      Started:      Mon, 13 Oct 2026 10:00:00 +0000
+    Ready:          True
+    Restart Count:  0
+Events:       <none>
+";
+
+        let report = parse_kubectl_output(input).unwrap();
+        assert!(!report.has_failure());
+    }
+}
@@ -3,13 +3,34 @@
 //! This library provides the core functionality for the `why` CLI tool,
 //! including stack trace parsing, model inference, and error explanation.
 
+pub mod bisect;
+pub mod build_system;
 pub mod cli;
 pub mod config;
+pub mod core_dump;
 pub mod daemon;
+pub mod database;
+pub mod detect;
+pub mod docker;
+pub mod environment;
+pub mod flaky;
+pub mod git_context;
 pub mod hooks;
+pub mod iac;
+pub mod jvm;
+pub mod kernel;
+pub mod kubernetes;
+pub mod lint;
+pub mod log_diff;
 pub mod model;
 pub mod output;
+pub mod package_manager;
+pub mod sanitizer;
+pub mod sinks;
 pub mod stack_trace;
+pub mod syntax;
+pub mod syscall_trace;
+pub mod temp;
 pub mod watch;
 
 // Re-export commonly used types
@@ -0,0 +1,939 @@
+//! Linter and type-checker output parsing.
+//!
+//! Reads the text and JSON formats of ESLint, Ruff, flake8, Pylint, mypy,
+//! Pyright, golangci-lint, go vet and Clippy into diagnostics carrying a rule
+//! ID, severity and location, then groups them by rule so each rule can be
+//! explained once with all of its locations listed.
+
+use regex::Regex;
+use serde::Serialize;
+use serde_json::Value;
+use std::fmt;
+use std::sync::OnceLock;
+
+use crate::stack_trace::{strip_ansi, Diagnostic, Severity};
+
+/// Locations listed per rule in the model input
+const MAX_LISTED_FINDINGS: usize = 20;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Tool that produced the findings
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "kebab-case")]
+pub enum Linter {
+    Eslint,
+    Ruff,
+    Flake8,
+    Pylint,
+    Mypy,
+    Pyright,
+    GolangciLint,
+    GoVet,
+    Clippy,
+}
+
+impl fmt::Display for Linter {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Linter::Eslint => write!(f, "ESLint"),
+            Linter::Ruff => write!(f, "Ruff"),
+            Linter::Flake8 => write!(f, "flake8"),
+            Linter::Pylint => write!(f, "Pylint"),
+            Linter::Mypy => write!(f, "mypy"),
+            Linter::Pyright => write!(f, "Pyright"),
+            Linter::GolangciLint => write!(f, "golangci-lint"),
+            Linter::GoVet => write!(f, "go vet"),
+            Linter::Clippy => write!(f, "Clippy"),
+        }
+    }
+}
+
+/// All findings for one rule
+#[derive(Debug, Clone, Serialize)]
+pub struct RuleGroup {
+    /// Rule ID (e.g. "no-unused-vars", "F401", "clippy::needless_return"),
+    /// or the message itself for tools that don't name rules
+    pub rule: String,
+    /// Highest severity among the findings
+    pub severity: Severity,
+    /// Findings in the order they were printed
+    pub findings: Vec<Diagnostic>,
+}
+
+impl RuleGroup {
+    /// "no-unused-vars (error, 3 locations)"
+    pub fn heading(&self) -> String {
+        let count = self.findings.len();
+        format!(
+            "{} ({}, {} location{})",
+            self.rule,
+            self.severity,
+            count,
+            plural(count)
+        )
+    }
+
+    /// "src/app.js:3:7" for each finding
+    pub fn locations(&self) -> Vec<String> {
+        self.findings.iter().filter_map(finding_location).collect()
+    }
+
+    /// Text for the model: the rule, then each location with its message
+    pub fn prompt_input(&self, tool: Linter) -> String {
+        let mut out = format!("{} reported {}:\n", tool, self.heading());
+        for finding in self.findings.iter().take(MAX_LISTED_FINDINGS) {
+            match finding_location(finding) {
+                Some(location) => out.push_str(&format!("{}: {}\n", location, finding.message)),
+                None => out.push_str(&format!("{}\n", finding.message)),
+            }
+        }
+        if self.findings.len() > MAX_LISTED_FINDINGS {
+            out.push_str(&format!(
+                "... and {} more\n",
+                self.findings.len() - MAX_LISTED_FINDINGS
+            ));
+        }
+        out.trim_end().to_string()
+    }
+}
+
+/// Findings from a linter or type checker, grouped by rule
+#[derive(Debug, Clone, Serialize)]
+pub struct LintReport {
+    /// Tool that produced the findings
+    pub tool: Linter,
+    /// One group per rule, errors first, then in order of first appearance
+    pub rules: Vec<RuleGroup>,
+}
+
+impl LintReport {
+    /// Group findings by rule
+    pub fn new(tool: Linter, findings: Vec<Diagnostic>) -> Self {
+        let mut rules: Vec<RuleGroup> = Vec::new();
+        for finding in findings {
+            let rule = finding
+                .code
+                .clone()
+                .unwrap_or_else(|| finding.message.clone());
+            match rules.iter_mut().find(|g| g.rule == rule) {
+                Some(group) => {
+                    if severity_rank(finding.severity) > severity_rank(group.severity) {
+                        group.severity = finding.severity;
+                    }
+                    group.findings.push(finding);
+                }
+                None => rules.push(RuleGroup {
+                    rule,
+                    severity: finding.severity,
+                    findings: vec![finding],
+                }),
+            }
+        }
+        rules.sort_by_key(|g| std::cmp::Reverse(severity_rank(g.severity)));
+        Self { tool, rules }
+    }
+
+    /// All findings, grouped by rule
+    pub fn findings(&self) -> impl Iterator<Item = &Diagnostic> {
+        self.rules.iter().flat_map(|g| g.findings.iter())
+    }
+
+    /// "ESLint: 12 findings for 3 rules (2 errors, 10 warnings)"
+    pub fn summary(&self) -> String {
+        let total = self.findings().count();
+        let errors = self
+            .findings()
+            .filter(|f| f.severity == Severity::Error)
+            .count();
+        let warnings = self
+            .findings()
+            .filter(|f| f.severity == Severity::Warning)
+            .count();
+        format!(
+            "{}: {} finding{} for {} rule{} ({} error{}, {} warning{})",
+            self.tool,
+            total,
+            plural(total),
+            self.rules.len(),
+            plural(self.rules.len()),
+            errors,
+            plural(errors),
+            warnings,
+            plural(warnings)
+        )
+    }
+
+    /// Every rule with its locations, in place of the raw tool output
+    pub fn focused_input(&self) -> String {
+        let mut out = vec![self.summary()];
+        for group in &self.rules {
+            out.push(group.prompt_input(self.tool));
+        }
+        out.join("\n\n")
+    }
+
+    /// Render the report as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = format!("Lint findings by rule ({}):\n", self.tool);
+        for group in &self.rules {
+            let message = &group.findings[0].message;
+            out.push_str(&format!(
+                "- {}: {}\n",
+                group.heading(),
+                message.lines().next().unwrap_or_default()
+            ));
+        }
+        out.push_str("Explain each rule once; its locations are listed above.");
+        out
+    }
+}
+
+fn plural(count: usize) -> &'static str {
+    if count == 1 {
+        ""
+    } else {
+        "s"
+    }
+}
+
+fn severity_rank(severity: Severity) -> u8 {
+    match severity {
+        Severity::Error => 2,
+        Severity::Warning => 1,
+        Severity::Info => 0,
+    }
+}
+
+fn finding_location(finding: &Diagnostic) -> Option<String> {
+    let file = finding.file.as_ref()?.display().to_string();
+    Some(match (finding.line, finding.column) {
+        (Some(line), Some(column)) => format!("{}:{}:{}", file, line, column),
+        (Some(line), None) => format!("{}:{}", file, line),
+        _ => file,
+    })
+}
+
+// ============================================================================
+// Detection
+// ============================================================================
+
+struct Patterns {
+    marker: Regex,
+    json_marker: Regex,
+    eslint: Regex,
+    eslint_file: Regex,
+    pylint: Regex,
+    flake8: Regex,
+    ruff_header: Regex,
+    ruff_arrow: Regex,
+    mypy: Regex,
+    pyright: Regex,
+    golangci: Regex,
+    go_vet: Regex,
+    rust_header: Regex,
+    rust_arrow: Regex,
+    rust_lint: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        marker: Regex::new(
+            &[
+                r"(?m)^\s+\d+:\d+\s+(?:error|warning)\s+\S",
+                r"^\S+?\.pyi?:\d+:\d+: (?:[A-Z]{1,4}\d{1,4} |[CRWEFI]\d{4}: )",
+                r"^\S+?\.pyi?:\d+(?::\d+)?: (?:error|warning): ",
+                r"^\s+\S.*?\.pyi?:\d+:\d+ - (?:error|warning|information): ",
+                r"^\S+?\.go:\d+(?::\d+)?: .+ \([a-z0-9][a-z0-9-]*\)$",
+                r"^[A-Z]{1,4}\d{1,4} .+\n\s*--> \S+?\.pyi?:\d+:\d+$",
+                r"^vet: \S+?\.go:\d+",
+                r"#\[(?:warn|deny)\(clippy::",
+            ]
+            .join("|"),
+        )
+        .unwrap(),
+        json_marker: Regex::new(
+            r#""(?:ruleId|generalDiagnostics|FromLinter|message-id|noqa_row|posn)"|"reason":\s*"compiler-message""#,
+        )
+        .unwrap(),
+        eslint: Regex::new(r"^\s+(\d+):(\d+)\s+(error|warning)\s+(.+?)(?:\s{2,}(\S+))?$").unwrap(),
+        eslint_file: Regex::new(r"^(?:[A-Za-z]:)?[/\\.]?\S+\.[A-Za-z]+$").unwrap(),
+        pylint: Regex::new(r"^(\S+?\.py):(\d+):(\d+): ([CRWEFI]\d{4}): (.+?) \(([a-z0-9-]+)\)$")
+            .unwrap(),
+        flake8: Regex::new(r"^(\S+?\.pyi?):(\d+):(\d+): ([A-Z]{1,4}\d{1,4}) (?:\[\*\] )?(.+)$")
+            .unwrap(),
+        ruff_header: Regex::new(r"^([A-Z]{1,4}\d{1,4}) (?:\[\*\] )?(.+)$").unwrap(),
+        ruff_arrow: Regex::new(r"^\s*--> (\S+?\.pyi?):(\d+):(\d+)$").unwrap(),
+        mypy: Regex::new(
+            r"^(\S+?\.pyi?):(\d+)(?::(\d+))?: (error|warning|note): (.+?)(?:  \[([a-z0-9-]+)\])?$",
+        )
+        .unwrap(),
+        pyright: Regex::new(
+            r"^\s+(\S.*?\.pyi?):(\d+):(\d+) - (error|warning|information): (.+?)(?: \((report\w+)\))?$",
+        )
+        .unwrap(),
+        golangci: Regex::new(r"^(\S+?\.go):(\d+)(?::(\d+))?: (.+) \(([a-z0-9][a-z0-9-]*)\)$")
+            .unwrap(),
+        go_vet: Regex::new(r"^vet: (\S+?\.go):(\d+)(?::(\d+))?: (.+)$").unwrap(),
+        rust_header: Regex::new(r"^(warning|error)(?:\[(\w+)\])?: (.+)$").unwrap(),
+        rust_arrow: Regex::new(r"^\s*--> (\S+?):(\d+):(\d+)$").unwrap(),
+        rust_lint: Regex::new(r"#\[(?:warn|deny|forbid)\(([\w:]+)\)\]|index\.html#(\w+)$").unwrap(),
+    })
+}
+
+/// Check whether input looks like linter or type-checker output
+pub fn is_lint_output(input: &str) -> bool {
+    let p = patterns();
+    p.marker.is_match(input) || p.json_marker.is_match(input)
+}
+
+/// Parse linter or type-checker output into findings grouped by rule
+pub fn parse_lint_output(input: &str) -> Option<LintReport> {
+    let input = strip_ansi(input);
+    if !is_lint_output(&input) {
+        return None;
+    }
+
+    let (tool, findings) = parse_json(&input).or_else(|| parse_text(&input))?;
+    if findings.is_empty() {
+        return None;
+    }
+    Some(LintReport::new(tool, findings))
+}
+
+fn severity_from(text: &str) -> Severity {
+    match text.to_lowercase().as_str() {
+        "error" | "fatal" | "2" => Severity::Error,
+        "warning" | "warn" | "1" => Severity::Warning,
+        _ => Severity::Info,
+    }
+}
+
+/// flake8/Ruff codes: E and F are errors, everything else is style
+fn code_severity(code: &str) -> Severity {
+    if code.starts_with('E') || code.starts_with('F') {
+        Severity::Error
+    } else {
+        Severity::Warning
+    }
+}
+
+/// Pylint message IDs start with their category letter
+fn pylint_severity(message_id: &str) -> Severity {
+    match message_id.chars().next() {
+        Some('E') | Some('F') => Severity::Error,
+        Some('W') => Severity::Warning,
+        _ => Severity::Info,
+    }
+}
+
+// ============================================================================
+// Text Formats
+// ============================================================================
+
+fn parse_text(input: &str) -> Option<(Linter, Vec<Diagnostic>)> {
+    let p = patterns();
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+    let mut tool: Option<Linter> = None;
+    let mut findings: Vec<Diagnostic> = Vec::new();
+    let mut eslint_file: Option<&str> = None;
+    let is_clippy = input.contains("clippy::") || input.contains("rust-clippy");
+
+    let mut index = 0;
+    while index < lines.len() {
+        let line = lines[index];
+        index += 1;
+
+        if let Some(caps) = p.pylint.captures(line) {
+            tool.get_or_insert(Linter::Pylint);
+            findings.push(
+                Diagnostic::new(pylint_severity(&caps[4]), &caps[5])
+                    .with_code(format!("{} ({})", &caps[6], &caps[4]))
+                    .with_location(
+                        &caps[1],
+                        caps[2].parse().ok(),
+                        // Pylint columns are 0-based
+                        caps[3].parse::<u32>().ok().map(|c| c + 1),
+                    ),
+            );
+            continue;
+        }
+
+        if let Some(caps) = p.flake8.captures(line) {
+            let ruff = input.contains("[*]") || input.contains("fixable with");
+            tool.get_or_insert(if ruff { Linter::Ruff } else { Linter::Flake8 });
+            findings.push(
+                Diagnostic::new(code_severity(&caps[4]), &caps[5])
+                    .with_code(&caps[4])
+                    .with_location(&caps[1], caps[2].parse().ok(), caps[3].parse().ok()),
+            );
+            continue;
+        }
+
+        // Ruff's full format puts the location on the next line
+        if let Some(caps) = p.ruff_header.captures(line) {
+            if let Some(arrow) = lines.get(index).and_then(|l| p.ruff_arrow.captures(l)) {
+                tool.get_or_insert(Linter::Ruff);
+                findings.push(
+                    Diagnostic::new(code_severity(&caps[1]), &caps[2])
+                        .with_code(&caps[1])
+                        .with_location(&arrow[1], arrow[2].parse().ok(), arrow[3].parse().ok()),
+                );
+                index += 1;
+                continue;
+            }
+        }
+
+        if let Some(caps) = p.mypy.captures(line) {
+            tool.get_or_insert(Linter::Mypy);
+            let file = &caps[1];
+            let line_num = caps[2].parse().ok();
+            // Notes elaborate on the error before them
+            if &caps[4] == "note" {
+                if let Some(previous) = findings
+                    .last_mut()
+                    .filter(|f| f.line == line_num && f.file.as_deref() == Some(file.as_ref()))
+                {
+                    previous.message.push_str(&format!("\nnote: {}", &caps[5]));
+                }
+                continue;
+            }
+            let mut finding = Diagnostic::new(severity_from(&caps[4]), &caps[5]).with_location(
+                file,
+                line_num,
+                caps.get(3).and_then(|m| m.as_str().parse().ok()),
+            );
+            if let Some(code) = caps.get(6) {
+                finding = finding.with_code(code.as_str());
+            }
+            findings.push(finding);
+            continue;
+        }
+
+        if let Some(caps) = p.pyright.captures(line) {
+            tool.get_or_insert(Linter::Pyright);
+            let mut finding = Diagnostic::new(severity_from(&caps[4]), &caps[5]).with_location(
+                &caps[1],
+                caps[2].parse().ok(),
+                caps[3].parse().ok(),
+            );
+            if let Some(rule) = caps.get(6) {
+                finding = finding.with_code(rule.as_str());
+            }
+            findings.push(finding);
+            continue;
+        }
+
+        if let Some(caps) = p.golangci.captures(line) {
+            tool.get_or_insert(Linter::GolangciLint);
+            findings.push(
+                Diagnostic::new(Severity::Warning, &caps[4])
+                    .with_code(&caps[5])
+                    .with_location(
+                        &caps[1],
+                        caps[2].parse().ok(),
+                        caps.get(3).and_then(|m| m.as_str().parse().ok()),
+                    ),
+            );
+            continue;
+        }
+
+        if let Some(caps) = p.go_vet.captures(line) {
+            tool.get_or_insert(Linter::GoVet);
+            findings.push(Diagnostic::new(Severity::Warning, &caps[4]).with_location(
+                &caps[1],
+                caps[2].parse().ok(),
+                caps.get(3).and_then(|m| m.as_str().parse().ok()),
+            ));
+            continue;
+        }
+
+        // Clippy: "warning: msg", " --> file:line:col", then notes naming the lint
+        if is_clippy {
+            if let Some(caps) = p.rust_header.captures(line) {
+                let Some(arrow) = lines.get(index).and_then(|l| p.rust_arrow.captures(l)) else {
+                    continue;
+                };
+                tool.get_or_insert(Linter::Clippy);
+                let mut finding = Diagnostic::new(severity_from(&caps[1]), &caps[3]).with_location(
+                    &arrow[1],
+                    arrow[2].parse().ok(),
+                    arrow[3].parse().ok(),
+                );
+                if let Some(code) = caps.get(2) {
+                    finding = finding.with_code(code.as_str());
+                }
+                let body = lines[index..]
+                    .iter()
+                    .take_while(|l| !l.is_empty() && !p.rust_header.is_match(l));
+                for body_line in body {
+                    if let Some(lint) = p.rust_lint.captures(body_line) {
+                        let name = lint.get(1).or_else(|| lint.get(2)).unwrap().as_str();
+                        let name = if lint.get(2).is_some() {
+                            format!("clippy::{}", name)
+                        } else {
+                            name.to_string()
+                        };
+                        finding = finding.with_code(name);
+                        break;
+                    }
+                }
+                findings.push(finding);
+                continue;
+            }
+        }
+
+        // ESLint's stylish format: a file path, then indented findings
+        if let Some(caps) = p.eslint.captures(line) {
+            let Some(file) = eslint_file else {
+                continue;
+            };
+            tool.get_or_insert(Linter::Eslint);
+            let mut finding = Diagnostic::new(severity_from(&caps[3]), caps[4].trim())
+                .with_location(file, caps[1].parse().ok(), caps[2].parse().ok());
+            if let Some(rule) = caps.get(5) {
+                finding = finding.with_code(rule.as_str());
+            }
+            findings.push(finding);
+            continue;
+        }
+        if p.eslint_file.is_match(line) {
+            eslint_file = Some(line);
+        }
+    }
+
+    Some((tool?, findings))
+}
+
+// ============================================================================
+// JSON Formats
+// ============================================================================
+
+fn parse_json(input: &str) -> Option<(Linter, Vec<Diagnostic>)> {
+    let trimmed = input.trim();
+
+    // cargo --message-format=json prints one object per line
+    if trimmed.contains("\"reason\"") && trimmed.contains("compiler-message") {
+        let findings = parse_cargo_json(trimmed);
+        if !findings.is_empty() {
+            return Some((Linter::Clippy, findings));
+        }
+    }
+
+    // go vet -json prints "# package" headers between objects
+    if trimmed.contains("\"posn\"") {
+        let findings = parse_go_vet_json(trimmed);
+        if !findings.is_empty() {
+            return Some((Linter::GoVet, findings));
+        }
+    }
+
+    let start = trimmed.find(['[', '{'])?;
+    let value: Value = serde_json::from_str(&trimmed[start..]).ok()?;
+
+    if let Some(issues) = value.get("Issues").and_then(Value::as_array) {
+        return Some((Linter::GolangciLint, parse_golangci_json(issues)));
+    }
+    if let Some(diagnostics) = value.get("generalDiagnostics").and_then(Value::as_array) {
+        return Some((Linter::Pyright, parse_pyright_json(diagnostics)));
+    }
+
+    let items = value.as_array()?;
+    let first = items.first()?;
+    if first.get("messages").is_some() {
+        Some((Linter::Eslint, parse_eslint_json(items)))
+    } else if first.get("message-id").is_some() {
+        Some((Linter::Pylint, parse_pylint_json(items)))
+    } else if first.get("filename").is_some() && first.get("location").is_some() {
+        Some((Linter::Ruff, parse_ruff_json(items)))
+    } else {
+        None
+    }
+}
+
+fn str_field<'a>(value: &'a Value, key: &str) -> Option<&'a str> {
+    value.get(key).and_then(Value::as_str)
+}
+
+fn u32_field(value: &Value, key: &str) -> Option<u32> {
+    value.get(key).and_then(Value::as_u64).map(|n| n as u32)
+}
+
+fn parse_eslint_json(files: &[Value]) -> Vec<Diagnostic> {
+    let mut findings = Vec::new();
+    for file in files {
+        let path = str_field(file, "filePath").unwrap_or_default();
+        for message in file
+            .get("messages")
+            .and_then(Value::as_array)
+            .into_iter()
+            .flatten()
+        {
+            let severity = match message.get("severity").and_then(Value::as_u64) {
+                Some(2) => Severity::Error,
+                Some(1) => Severity::Warning,
+                _ => Severity::Info,
+            };
+            let mut finding =
+                Diagnostic::new(severity, str_field(message, "message").unwrap_or_default())
+                    .with_location(
+                        path,
+                        u32_field(message, "line"),
+                        u32_field(message, "column"),
+                    );
+            if let Some(rule) = str_field(message, "ruleId") {
+                finding = finding.with_code(rule);
+            }
+            findings.push(finding);
+        }
+    }
+    findings
+}
+
+fn parse_ruff_json(items: &[Value]) -> Vec<Diagnostic> {
+    items
+        .iter()
+        .map(|item| {
+            let code = str_field(item, "code").unwrap_or("syntax-error");
+            let location = item.get("location");
+            Diagnostic::new(
+                code_severity(code),
+                str_field(item, "message").unwrap_or_default(),
+            )
+            .with_code(code)
+            .with_location(
+                str_field(item, "filename").unwrap_or_default(),
+                location.and_then(|l| u32_field(l, "row")),
+                location.and_then(|l| u32_field(l, "column")),
+            )
+        })
+        .collect()
+}
+
+fn parse_pylint_json(items: &[Value]) -> Vec<Diagnostic> {
+    items
+        .iter()
+        .map(|item| {
+            let id = str_field(item, "message-id").unwrap_or_default();
+            let symbol = str_field(item, "symbol").unwrap_or(id);
+            Diagnostic::new(
+                pylint_severity(id),
+                str_field(item, "message").unwrap_or_default(),
+            )
+            .with_code(format!("{} ({})", symbol, id))
+            .with_location(
+                str_field(item, "path").unwrap_or_default(),
+                u32_field(item, "line"),
+                u32_field(item, "column").map(|c| c + 1),
+            )
+        })
+        .collect()
+}
+
+fn parse_pyright_json(diagnostics: &[Value]) -> Vec<Diagnostic> {
+    diagnostics
+        .iter()
+        .map(|item| {
+            // Pyright ranges are 0-based
+            let start = item.get("range").and_then(|r| r.get("start"));
+            let mut finding = Diagnostic::new(
+                severity_from(str_field(item, "severity").unwrap_or_default()),
+                str_field(item, "message").unwrap_or_default(),
+            )
+            .with_location(
+                str_field(item, "file").unwrap_or_default(),
+                start.and_then(|s| u32_field(s, "line")).map(|l| l + 1),
+                start.and_then(|s| u32_field(s, "character")).map(|c| c + 1),
+            );
+            if let Some(rule) = str_field(item, "rule") {
+                finding = finding.with_code(rule);
+            }
+            finding
+        })
+        .collect()
+}
+
+fn parse_golangci_json(issues: &[Value]) -> Vec<Diagnostic> {
+    issues
+        .iter()
+        .map(|issue| {
+            let severity = match str_field(issue, "Severity") {
+                Some(severity) if !severity.is_empty() => severity_from(severity),
+                _ => Severity::Warning,
+            };
+            let pos = issue.get("Pos");
+            let mut finding =
+                Diagnostic::new(severity, str_field(issue, "Text").unwrap_or_default())
+                    .with_location(
+                        pos.and_then(|p| str_field(p, "Filename"))
+                            .unwrap_or_default(),
+                        pos.and_then(|p| u32_field(p, "Line")),
+                        pos.and_then(|p| u32_field(p, "Column")),
+                    );
+            if let Some(linter) = str_field(issue, "FromLinter") {
+                finding = finding.with_code(linter);
+            }
+            finding
+        })
+        .collect()
+}
+
+/// `{"pkg": {"analyzer": [{"posn": "file:line:col", "message": "..."}]}}`
+fn parse_go_vet_json(input: &str) -> Vec<Diagnostic> {
+    let json: String = input
+        .lines()
+        .filter(|l| !l.starts_with('#'))
+        .collect::<Vec<_>>()
+        .join("\n");
+    let mut findings = Vec::new();
+    let stream = serde_json::Deserializer::from_str(&json).into_iter::<Value>();
+    for value in stream.flatten() {
+        let Some(packages) = value.as_object() else {
+            continue;
+        };
+        for analyzers in packages.values().filter_map(Value::as_object) {
+            for (analyzer, results) in analyzers {
+                for result in results.as_array().into_iter().flatten() {
+                    let posn = str_field(result, "posn").unwrap_or_default();
+                    let mut parts = posn.rsplitn(3, ':');
+                    let column = parts.next().and_then(|c| c.parse().ok());
+                    let line = parts.next().and_then(|l| l.parse().ok());
+                    let file = parts.next().unwrap_or(posn);
+                    findings.push(
+                        Diagnostic::new(
+                            Severity::Warning,
+                            str_field(result, "message").unwrap_or_default(),
+                        )
+                        .with_code(analyzer)
+                        .with_location(file, line, column),
+                    );
+                }
+            }
+        }
+    }
+    findings
+}
+
+fn parse_cargo_json(input: &str) -> Vec<Diagnostic> {
+    let mut findings = Vec::new();
+    for line in input.lines() {
+        let Ok(value) = serde_json::from_str::<Value>(line.trim()) else {
+            continue;
+        };
+        if str_field(&value, "reason") != Some("compiler-message") {
+            continue;
+        }
+        let Some(message) = value.get("message") else {
+            continue;
+        };
+        let level = str_field(message, "level").unwrap_or_default();
+        // The closing "N warnings emitted" summaries have no spans
+        let spans = message.get("spans").and_then(Value::as_array);
+        let Some(span) = spans.and_then(|spans| {
+            spans
+                .iter()
+                .find(|s| s.get("is_primary").and_then(Value::as_bool) == Some(true))
+        }) else {
+            continue;
+        };
+        let mut finding = Diagnostic::new(
+            severity_from(level),
+            str_field(message, "message").unwrap_or_default(),
+        )
+        .with_location(
+            str_field(span, "file_name").unwrap_or_default(),
+            u32_field(span, "line_start"),
+            u32_field(span, "column_start"),
+        );
+        if let Some(code) = message.get("code").and_then(|c| str_field(c, "code")) {
+            finding = finding.with_code(code);
+        }
+        findings.push(finding);
+    }
+    findings
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::Path;
+
+    #[test]
+    fn test_parses_eslint_stylish_and_groups_by_rule() {
+        let input = "
+/home/dev/app/src/index.js
+   3:7   error    'x' is assigned a value but never used  no-unused-vars
+  10:1   warning  Unexpected console statement            no-console
+
+/home/dev/app/src/util.js
+  1:10  error  'y' is assigned a value but never used  no-unused-vars
+
+✖ 3 problems (2 errors, 1 warning)
+";
+
+        assert!(is_lint_output(input));
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Eslint);
+        assert_eq!(report.rules.len(), 2);
+
+        let unused = &report.rules[0];
+        assert_eq!(unused.rule, "no-unused-vars");
+        assert_eq!(unused.severity, Severity::Error);
+        assert_eq!(
+            unused.locations(),
+            vec![
+                "/home/dev/app/src/index.js:3:7",
+                "/home/dev/app/src/util.js:1:10"
+            ]
+        );
+        assert_eq!(unused.heading(), "no-unused-vars (error, 2 locations)");
+        assert_eq!(report.rules[1].rule, "no-console");
+        assert_eq!(
+            report.summary(),
+            "ESLint: 3 findings for 2 rules (2 errors, 1 warning)"
+        );
+    }
+
+    #[test]
+    fn test_parses_python_linters() {
+        let input = "\
+app/main.py:1:8: F401 [*] `os` imported but unused
+app/main.py:3:1: E302 Expected 2 blank lines, found 1
+app/util.py:2:8: F401 [*] `sys` imported but unused
+Found 3 errors.
+[*] 2 fixable with the `--fix` option.";
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Ruff);
+        assert_eq!(report.rules[0].rule, "F401");
+        assert_eq!(report.rules[0].findings.len(), 2);
+
+        let input = "\
+************* Module app
+app.py:1:0: C0114: Missing module docstring (missing-module-docstring)
+app.py:5:4: W0612: Unused variable 'x' (unused-variable)";
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Pylint);
+        assert_eq!(report.rules[0].rule, "unused-variable (W0612)");
+        assert_eq!(report.rules[1].findings[0].column, Some(1));
+        assert_eq!(report.rules[1].severity, Severity::Info);
+    }
+
+    #[test]
+    fn test_parses_type_checkers() {
+        let input = "\
+src/app.py:12: error: Incompatible types in assignment (expression has type \"str\", variable has type \"int\")  [assignment]
+src/app.py:12: note: Consider using a different variable name
+src/app.py:20:5: error: Name \"foo\" is not defined  [name-defined]
+Found 2 errors in 1 file (checked 3 source files)";
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Mypy);
+        assert_eq!(report.rules.len(), 2);
+        assert_eq!(report.rules[0].rule, "assignment");
+        assert!(report.rules[0].findings[0]
+            .message
+            .ends_with("note: Consider using a different variable name"));
+        assert_eq!(report.rules[1].findings[0].column, Some(5));
+
+        let input = "\
+/home/dev/app/main.py
+  /home/dev/app/main.py:4:7 - error: \"foo\" is not defined (reportUndefinedVariable)
+1 error, 0 warnings, 0 informations";
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Pyright);
+        assert_eq!(report.rules[0].rule, "reportUndefinedVariable");
+    }
+
+    #[test]
+    fn test_parses_golangci_lint() {
+        let input = "\
+main.go:12:2: ineffectual assignment to err (ineffassign)
+	err = run()
+	^
+main.go:20:9: Error return value of `f.Close` is not checked (errcheck)";
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::GolangciLint);
+        assert_eq!(report.rules.len(), 2);
+        assert_eq!(report.rules[1].rule, "errcheck");
+        assert_eq!(
+            report.rules[1].findings[0].file.as_deref(),
+            Some(Path::new("main.go"))
+        );
+    }
+
+    #[test]
+    fn test_parses_json_formats() {
+        let input = r#"[{"filePath":"/app/src/a.js","messages":[{"ruleId":"eqeqeq","severity":2,"message":"Expected '===' and instead saw '=='.","line":4,"column":9}],"errorCount":1}]"#;
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Eslint);
+        assert_eq!(report.rules[0].rule, "eqeqeq");
+        assert_eq!(report.rules[0].locations(), vec!["/app/src/a.js:4:9"]);
+
+        let input = r#"{"Issues":[{"FromLinter":"govet","Text":"printf: fmt.Printf format %d has arg s of wrong type string","Severity":"","Pos":{"Filename":"main.go","Line":9,"Column":2}}]}"#;
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::GolangciLint);
+        assert_eq!(report.rules[0].rule, "govet");
+
+        let input = r#"{"generalDiagnostics":[{"file":"/app/main.py","severity":"error","message":"\"foo\" is not defined","range":{"start":{"line":3,"character":6},"end":{"line":3,"character":9}},"rule":"reportUndefinedVariable"}]}"#;
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.rules[0].findings[0].line, Some(4));
+        assert_eq!(report.rules[0].findings[0].column, Some(7));
+    }
+
+    #[test]
+    fn test_parses_clippy_json_lines() {
+        let input = r#"{"reason":"compiler-artifact","package_id":"why 0.1.0"}
+{"reason":"compiler-message","package_id":"why 0.1.0","message":{"$message_type":"diagnostic","code":{"code":"clippy::needless_return","explanation":null},"level":"warning","message":"unneeded `return` statement","spans":[{"file_name":"src/main.rs","line_start":3,"column_start":5,"is_primary":true}],"children":[]}}
+{"reason":"compiler-message","package_id":"why 0.1.0","message":{"$message_type":"diagnostic","code":null,"level":"warning","message":"1 warning emitted","spans":[],"children":[]}}
+{"reason":"build-finished","success":true}"#;
+
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Clippy);
+        assert_eq!(report.rules.len(), 1);
+        assert_eq!(report.rules[0].rule, "clippy::needless_return");
+        assert_eq!(report.rules[0].locations(), vec!["src/main.rs:3:5"]);
+    }
+
+    #[test]
+    fn test_parses_clippy_text() {
+        let input = "\
+warning: unneeded `return` statement
+ --> src/main.rs:3:5
+  |
+3 |     return x;
+  |     ^^^^^^^^^
+  |
+  = help: for further information visit https://rust-lang.github.io/rust-clippy/master/index.html#needless_return
+  = note: `#[warn(clippy::needless_return)]` on by default
+
+warning: `why` (bin \"why\") generated 1 warning";
+
+        let report = parse_lint_output(input).unwrap();
+        assert_eq!(report.tool, Linter::Clippy);
+        assert_eq!(report.rules.len(), 1);
+        assert_eq!(report.rules[0].rule, "clippy::needless_return");
+    }
+
+    #[test]
+    fn test_prompt_input_caps_listed_locations() {
+        let findings = (1..=25)
+            .map(|line| {
+                Diagnostic::new(Severity::Warning, "line too long")
+                    .with_code("E501")
+                    .with_location("a.py", Some(line), Some(89))
+            })
+            .collect();
+        let report = LintReport::new(Linter::Flake8, findings);
+        let input = report.rules[0].prompt_input(report.tool);
+        assert!(input.starts_with("flake8 reported E501 (warning, 25 locations):"));
+        assert!(input.contains("a.py:20:89: line too long"));
+        assert!(!input.contains("a.py:21:89"));
+        assert!(input.ends_with("... and 5 more"));
+    }
+}
@@ -0,0 +1,488 @@
+//! Log diff mode: what's new in a failing log compared to a passing one.
+//!
+//! `why diff good.log bad.log` normalizes both logs the way watch mode
+//! normalizes errors for deduplication (timestamps, line numbers), plus the
+//! IDs, addresses, durations and temp paths that differ between any two runs.
+//! It then aligns them line by line and keeps the error and warning lines
+//! that only the failing log has. CI logs (GitHub Actions timestamps, GitLab
+//! section markers, ANSI colors) and terminal transcripts with `\r` progress
+//! lines are cleaned up first.
+
+use anyhow::{Context, Result};
+use regex::Regex;
+use serde::Serialize;
+use std::collections::HashMap;
+use std::path::Path;
+use std::sync::OnceLock;
+
+use crate::stack_trace::strip_ansi;
+use crate::watch::{DetectedError, ErrorDetector};
+
+/// Largest alignment table (good lines x bad lines) before falling back to
+/// matching lines without regard to order
+const MAX_ALIGN_CELLS: usize = 4_000_000;
+
+/// Longest input built for the model
+const MAX_INPUT_CHARS: usize = 6000;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// How serious a new line is
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum DiffSeverity {
+    Error,
+    Warning,
+}
+
+/// An error or warning line only the failing log has
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Finding {
+    /// 1-based line number in the failing log
+    pub line: usize,
+    pub severity: DiffSeverity,
+    /// Line as it appears in the log, cleaned of ANSI codes and CI prefixes
+    pub text: String,
+}
+
+/// A run of lines that differ between the logs
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct DiffHunk {
+    /// 1-based line in the passing log where the hunk starts
+    pub good_start: usize,
+    /// Lines only the passing log has
+    pub good: Vec<String>,
+    /// 1-based line in the failing log where the hunk starts
+    pub bad_start: usize,
+    /// Lines only the failing log has
+    pub bad: Vec<String>,
+}
+
+impl DiffHunk {
+    /// Whether the hunk contains the failing log's line `line`
+    fn contains_bad_line(&self, line: usize) -> bool {
+        line >= self.bad_start && line < self.bad_start + self.bad.len()
+    }
+
+    fn render(&self, good_name: &str, bad_name: &str) -> String {
+        let mut out = format!(
+            "@@ {}:{} {}:{} @@\n",
+            good_name, self.good_start, bad_name, self.bad_start
+        );
+        for line in &self.good {
+            out.push_str(&format!("- {}\n", line));
+        }
+        for line in &self.bad {
+            out.push_str(&format!("+ {}\n", line));
+        }
+        out
+    }
+}
+
+/// Comparison of a passing and a failing log
+#[derive(Debug, Clone, Serialize)]
+pub struct LogDiff {
+    /// Name of the passing log
+    pub good_name: String,
+    /// Name of the failing log
+    pub bad_name: String,
+    pub good_lines: usize,
+    pub bad_lines: usize,
+    /// Differing regions, in log order
+    pub hunks: Vec<DiffHunk>,
+    /// Error and warning lines only in the failing log
+    pub findings: Vec<Finding>,
+}
+
+impl LogDiff {
+    /// Compare two logs
+    pub fn compare(good_name: &str, good: &str, bad_name: &str, bad: &str) -> Self {
+        let good: Vec<String> = good.lines().map(clean_line).collect();
+        let bad: Vec<String> = bad.lines().map(clean_line).collect();
+        let good_keys: Vec<String> = good.iter().map(|l| normalize_line(l)).collect();
+        let bad_keys: Vec<String> = bad.iter().map(|l| normalize_line(l)).collect();
+
+        let hunks = align(&good_keys, &bad_keys)
+            .into_iter()
+            .map(|(good_range, bad_range)| DiffHunk {
+                good_start: good_range.start + 1,
+                good: good[good_range].to_vec(),
+                bad_start: bad_range.start + 1,
+                bad: bad[bad_range].to_vec(),
+            })
+            .filter(|hunk| {
+                // Blank-line-only changes aren't worth showing
+                hunk.good
+                    .iter()
+                    .chain(&hunk.bad)
+                    .any(|l| !l.trim().is_empty())
+            })
+            .collect::<Vec<_>>();
+
+        let detector = ErrorDetector::new(None, 0);
+        let findings = hunks
+            .iter()
+            .flat_map(|hunk| {
+                hunk.bad
+                    .iter()
+                    .enumerate()
+                    .map(move |(i, text)| (hunk.bad_start + i, text))
+            })
+            .filter_map(|(line, text)| {
+                let severity = if detector.is_error_line(text) {
+                    DiffSeverity::Error
+                } else if is_warning_line(text) {
+                    DiffSeverity::Warning
+                } else {
+                    return None;
+                };
+                Some(Finding {
+                    line,
+                    severity,
+                    text: text.trim().to_string(),
+                })
+            })
+            .collect();
+
+        Self {
+            good_name: good_name.to_string(),
+            bad_name: bad_name.to_string(),
+            good_lines: good.len(),
+            bad_lines: bad.len(),
+            hunks,
+            findings,
+        }
+    }
+
+    /// Read and compare two log files
+    pub fn from_files(good: &Path, bad: &Path) -> Result<Self> {
+        let read = |path: &Path| {
+            std::fs::read(path)
+                .map(|bytes| String::from_utf8_lossy(&bytes).to_string())
+                .with_context(|| format!("Failed to read {}", path.display()))
+        };
+        Ok(Self::compare(
+            &good.display().to_string(),
+            &read(good)?,
+            &bad.display().to_string(),
+            &read(bad)?,
+        ))
+    }
+
+    /// Whether the logs differ at all once normalized
+    pub fn is_empty(&self) -> bool {
+        self.hunks.is_empty()
+    }
+
+    /// New lines of one severity
+    pub fn findings_of(&self, severity: DiffSeverity) -> impl Iterator<Item = &Finding> {
+        self.findings.iter().filter(move |f| f.severity == severity)
+    }
+
+    /// Hunks worth showing: those with findings, or all when there are none
+    pub fn relevant_hunks(&self) -> Vec<&DiffHunk> {
+        if self.findings.is_empty() {
+            return self.hunks.iter().collect();
+        }
+        self.hunks
+            .iter()
+            .filter(|hunk| self.findings.iter().any(|f| hunk.contains_bad_line(f.line)))
+            .collect()
+    }
+
+    /// Build the input to explain
+    pub fn to_input(&self) -> String {
+        let mut input = format!(
+            "Comparing a passing log ({}, {} lines) with a failing log ({}, {} lines) \
+             of the same job.\n",
+            self.good_name, self.good_lines, self.bad_name, self.bad_lines
+        );
+
+        for (severity, title) in [
+            (DiffSeverity::Error, "Errors only in the failing log:"),
+            (DiffSeverity::Warning, "Warnings only in the failing log:"),
+        ] {
+            let lines: Vec<String> = self
+                .findings_of(severity)
+                .map(|f| format!("- line {}: {}", f.line, f.text))
+                .collect();
+            if !lines.is_empty() {
+                input.push_str(&format!("\n{}\n{}\n", title, lines.join("\n")));
+            }
+        }
+        if self.findings.is_empty() {
+            input.push_str("\nNo error or warning lines are new in the failing log.\n");
+        }
+
+        input.push_str("\nDifferences (- passing log, + failing log):\n");
+        let mut left_out = 0;
+        for hunk in self.relevant_hunks() {
+            let rendered = hunk.render(&self.good_name, &self.bad_name);
+            if input.len() + rendered.len() > MAX_INPUT_CHARS {
+                left_out += 1;
+                continue;
+            }
+            input.push_str(&rendered);
+        }
+        if left_out > 0 {
+            input.push_str(&format!("... ({} more differences left out)\n", left_out));
+        }
+        input.trim_end().to_string()
+    }
+}
+
+// ============================================================================
+// Normalization
+// ============================================================================
+
+struct Patterns {
+    ci_timestamp: Regex,
+    gitlab_section: Regex,
+    volatile: Regex,
+    warning: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        // GitHub Actions: "2026-10-15T12:00:01.1234567Z "
+        ci_timestamp: Regex::new(r"^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?Z\s").unwrap(),
+        gitlab_section: Regex::new(r"section_(?:start|end):\d+:[\w.-]+").unwrap(),
+        volatile: Regex::new(concat!(
+            r"(?i)\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?",
+            r"|\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b",
+            r"|0x[0-9a-f]+|\b[0-9a-f]{12,}\b",
+            r"|\b\d+(?:\.\d+)?\s?(?:ns|µs|us|ms|s|sec|secs|seconds|minutes)\b",
+            r"|/tmp/[\w.-]+|\bpid[ =:]?\d+|\(node:\d+\)",
+        ))
+        .unwrap(),
+        warning: Regex::new(
+            r"(?i)^\s*(?:\[?warn(?:ing)?\]?[:\s]|w:)|\bwarning(?:\[\w+\])?:|deprecat",
+        )
+        .unwrap(),
+    })
+}
+
+/// Strip ANSI codes, CI prefixes and overwritten `\r` progress output
+fn clean_line(line: &str) -> String {
+    let p = patterns();
+    let line = strip_ansi(line);
+    let line = line.trim_end_matches('\r');
+    let line = line.rsplit('\r').next().unwrap_or(line);
+    let line = p.ci_timestamp.replace(line, "");
+    p.gitlab_section
+        .replace_all(&line, "")
+        .trim_end()
+        .to_string()
+}
+
+/// Comparison key for a cleaned line
+fn normalize_line(line: &str) -> String {
+    let stable = patterns().volatile.replace_all(line, "");
+    DetectedError::normalize_for_hash(&stable)
+}
+
+fn is_warning_line(line: &str) -> bool {
+    patterns().warning.is_match(line)
+}
+
+// ============================================================================
+// Alignment
+// ============================================================================
+
+type Hunk = (std::ops::Range<usize>, std::ops::Range<usize>);
+
+/// Differing ranges of `good` and `bad`, in order
+fn align(good: &[String], bad: &[String]) -> Vec<Hunk> {
+    // Logs of the same job share long heads and tails
+    let prefix = good.iter().zip(bad).take_while(|(a, b)| a == b).count();
+    let suffix = good[prefix..]
+        .iter()
+        .rev()
+        .zip(bad[prefix..].iter().rev())
+        .take_while(|(a, b)| a == b)
+        .count();
+    let good_mid = &good[prefix..good.len() - suffix];
+    let bad_mid = &bad[prefix..bad.len() - suffix];
+
+    let hunks = if good_mid.len() * bad_mid.len() <= MAX_ALIGN_CELLS {
+        align_lcs(good_mid, bad_mid)
+    } else {
+        align_unordered(good_mid, bad_mid)
+    };
+    hunks
+        .into_iter()
+        .map(|(g, b)| {
+            (
+                g.start + prefix..g.end + prefix,
+                b.start + prefix..b.end + prefix,
+            )
+        })
+        .collect()
+}
+
+/// Exact alignment by longest common subsequence
+fn align_lcs(good: &[String], bad: &[String]) -> Vec<Hunk> {
+    let width = bad.len() + 1;
+    let mut lcs = vec![0u32; (good.len() + 1) * width];
+    for i in (0..good.len()).rev() {
+        for j in (0..bad.len()).rev() {
+            lcs[i * width + j] = if good[i] == bad[j] {
+                lcs[(i + 1) * width + j + 1] + 1
+            } else {
+                lcs[(i + 1) * width + j].max(lcs[i * width + j + 1])
+            };
+        }
+    }
+
+    let mut hunks: Vec<Hunk> = Vec::new();
+    let mut open: Option<Hunk> = None;
+    let (mut i, mut j) = (0, 0);
+    while i < good.len() || j < bad.len() {
+        if i < good.len() && j < bad.len() && good[i] == bad[j] {
+            hunks.extend(open.take());
+            i += 1;
+            j += 1;
+            continue;
+        }
+        let hunk = open.get_or_insert((i..i, j..j));
+        if i < good.len() && (j == bad.len() || lcs[(i + 1) * width + j] >= lcs[i * width + j + 1])
+        {
+            i += 1;
+            hunk.0.end = i;
+        } else {
+            j += 1;
+            hunk.1.end = j;
+        }
+    }
+    hunks.extend(open);
+    hunks
+}
+
+/// Fallback for huge logs: lines of `bad` not matched by an equal line
+/// anywhere in `good`, grouped into consecutive runs
+fn align_unordered(good: &[String], bad: &[String]) -> Vec<Hunk> {
+    let mut counts: HashMap<&str, usize> = HashMap::new();
+    for line in good {
+        *counts.entry(line.as_str()).or_default() += 1;
+    }
+    let mut hunks: Vec<Hunk> = Vec::new();
+    for (j, line) in bad.iter().enumerate() {
+        match counts.get_mut(line.as_str()) {
+            Some(count) if *count > 0 => *count -= 1,
+            _ => match hunks.last_mut() {
+                Some((_, b)) if b.end == j => b.end = j + 1,
+                _ => hunks.push((0..0, j..j + 1)),
+            },
+        }
+    }
+    hunks
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const GOOD: &str = "2026-10-14T09:00:01.1234567Z Run cargo test
+2026-10-14T09:00:02.0000000Z    Compiling app v0.1.0 (/tmp/build-a1b2)
+2026-10-14T09:00:30.0000000Z     Finished test profile in 28.1s
+2026-10-14T09:00:31.0000000Z running 2 tests
+2026-10-14T09:00:31.0000000Z test api::login ... ok
+2026-10-14T09:00:31.0000000Z test db::query ... ok
+2026-10-14T09:00:31.0000000Z test result: ok. 2 passed; 0 failed; finished in 0.42s";
+
+    const BAD: &str = "2026-10-15T10:11:01.7654321Z Run cargo test
+2026-10-15T10:11:02.0000000Z    Compiling app v0.1.0 (/tmp/build-z9y8)
+2026-10-15T10:11:04.0000000Z warning: use of deprecated function `db::connect_legacy`
+2026-10-15T10:11:31.0000000Z     Finished test profile in 29.0s
+2026-10-15T10:11:32.0000000Z running 2 tests
+2026-10-15T10:11:32.0000000Z test api::login ... ok
+2026-10-15T10:11:37.0000000Z test db::query ... FAILED
+2026-10-15T10:11:37.0000000Z thread 'db::query' panicked at src/db.rs:88:9:
+2026-10-15T10:11:37.0000000Z error: pool timed out while waiting for an open connection
+2026-10-15T10:11:37.0000000Z test result: FAILED. 1 passed; 1 failed; finished in 5.01s";
+
+    #[test]
+    fn test_finds_new_errors_and_warnings() {
+        let diff = LogDiff::compare("good.log", GOOD, "bad.log", BAD);
+        assert_eq!(diff.good_lines, 7);
+        assert_eq!(diff.bad_lines, 10);
+
+        let errors: Vec<_> = diff
+            .findings_of(DiffSeverity::Error)
+            .map(|f| f.line)
+            .collect();
+        assert_eq!(errors, vec![9]);
+        let warnings: Vec<_> = diff.findings_of(DiffSeverity::Warning).collect();
+        assert_eq!(warnings.len(), 1);
+        assert_eq!(warnings[0].line, 3);
+        assert_eq!(
+            warnings[0].text,
+            "warning: use of deprecated function `db::connect_legacy`"
+        );
+
+        // Timestamps, temp paths and durations don't count as differences
+        assert!(diff.hunks.iter().all(|h| h
+            .bad
+            .iter()
+            .all(|l| !l.contains("Compiling") && !l.contains("Finished"))));
+    }
+
+    #[test]
+    fn test_to_input() {
+        let diff = LogDiff::compare("good.log", GOOD, "bad.log", BAD);
+        let input = diff.to_input();
+        assert!(input.starts_with(
+            "Comparing a passing log (good.log, 7 lines) with a failing log (bad.log, 10 lines)"
+        ));
+        assert!(input.contains(
+            "Errors only in the failing log:\n- line 9: error: pool timed out while waiting for an open connection\n"
+        ));
+        assert!(input
+            .contains("@@ good.log:6 bad.log:7 @@\n- test db::query ... ok\n- test result: ok."));
+        assert!(input.contains("+ thread 'db::query' panicked at src/db.rs:88:9:\n"));
+    }
+
+    #[test]
+    fn test_clean_line() {
+        assert_eq!(
+            clean_line(
+                "\x1b[0Ksection_start:1760000000:build_script\r\x1b[0K\x1b[32;1m$ make\x1b[0;m"
+            ),
+            "$ make"
+        );
+        assert_eq!(
+            clean_line("Downloading 10%\rDownloading 100%\r"),
+            "Downloading 100%"
+        );
+        assert_eq!(
+            normalize_line("pid=4242 took 12ms at 0x7ffd1234"),
+            normalize_line("pid=99 took 3ms at 0x1")
+        );
+    }
+
+    #[test]
+    fn test_unordered_fallback_matches_lcs_for_insertions() {
+        let good: Vec<String> = ["a", "b", "c"].iter().map(|s| s.to_string()).collect();
+        let bad: Vec<String> = ["a", "x", "b", "c", "y"]
+            .iter()
+            .map(|s| s.to_string())
+            .collect();
+        let expected = vec![(1..1, 1..2), (3..3, 4..5)];
+        assert_eq!(align_lcs(&good, &bad), expected);
+        assert_eq!(
+            align_unordered(&good, &bad)
+                .into_iter()
+                .map(|(_, b)| b)
+                .collect::<Vec<_>>(),
+            vec![1..2, 4..5]
+        );
+        assert!(align(&good, &good).is_empty());
+    }
+}
@@ -15,7 +15,6 @@ use llama_cpp_2::{send_logs_to_tracing, LogOptions};
 use notify::{
     Config as NotifyConfig, Event as NotifyEvent, RecommendedWatcher, RecursiveMode, Watcher,
 };
-use regex::Regex;
 use std::env;
 use std::fs::File;
 use std::io::{self, BufRead, BufReader, IsTerminal, Read, Seek, SeekFrom, Write};
@@ -34,24 +33,42 @@ use std::time::{SystemTime, UNIX_EPOCH};
 use std::os::unix::net::{UnixListener, UnixStream};
 
 // Import from the library crate
-use why::cli::{Cli, DaemonCommand};
+use why::bisect;
+use why::cli::{Cli, Commands, DaemonCommand};
 use why::config::{print_hook_config, Config};
+use why::core_dump;
 use why::daemon::{
     get_pid_path, get_socket_path, DaemonAction, DaemonRequest, DaemonResponse, DaemonResponseType,
     DaemonStats, ErrorExplanationResponse,
 };
+use why::detect::DetectionRules;
+use why::docker;
+use why::environment::{self, EnvironmentContext};
+use why::flaky::{RepeatReport, RunRecord};
+use why::git_context;
 use why::hooks::{install_hook, uninstall_hook};
+use why::kernel::{self, KernelEvent};
+use why::kubernetes;
+use why::lint::LintReport;
+use why::log_diff::LogDiff;
 use why::model::{
-    build_prompt, detect_model_family, get_model_path, is_degenerate_response, is_echo_response,
-    run_inference_with_callback, ModelFamily, ModelPathInfo, SamplingParams, TokenCallback,
-    MAX_RETRIES,
+    build_prompt, build_prompt_with_trace, detect_model_family, get_model_path,
+    is_degenerate_response, is_echo_response, run_inference_with_callback, InferenceStats,
+    ModelFamily, ModelPathInfo, SamplingParams, TokenCallback, MAX_RETRIES,
 };
 use why::output::{
-    contains_error_patterns, format_file_line, interpret_exit_code, parse_response, print_colored,
-    print_debug_section, print_frames, print_stats,
+    contains_error_patterns, exit_code_hint, format_file_line, interpret_exit_code, parse_response,
+    print_colored, print_debug_section, print_frames, print_line_verdict, print_log_diff,
+    print_stats, shell_exit_code, write_colored,
 };
-use why::stack_trace::{StackTraceJson, StackTraceParserRegistry};
-use why::watch::{DetectedError, ErrorDeduplicator, ErrorDetector, WatchConfig};
+use why::sinks::{ErrorReport, Notifier};
+use why::stack_trace::{
+    extract_stack_trace_context, resolve_source_path, SourceContextConfig, StackTrace,
+    StackTraceJson, StackTraceParserRegistry, TraceDetails,
+};
+use why::syscall_trace::{self, TraceSummary};
+use why::temp;
+use why::watch::{self, DetectedError, ErrorDeduplicator, ErrorDetector, WatchConfig, WatchSource};
 
 fn prompt_confirm(command: &str, exit_code: i32, stderr: &str) -> bool {
     // If stderr contains obvious error patterns, suggest yes
@@ -97,8 +114,10 @@ fn prompt_confirm(command: &str, exit_code: i32, stderr: &str) -> bool {
 struct CaptureResult {
     /// Command that was run (for display)
     command: String,
-    /// Exit code from the command
+    /// Exit code from the command (128 + signal if it was killed)
     exit_code: i32,
+    /// Process ID of the command, for matching kernel log entries
+    pid: u32,
     /// Captured stdout (if capture_all is enabled)
     stdout: String,
     /// Captured stderr
@@ -106,8 +125,13 @@ struct CaptureResult {
 }
 
 /// Run a command and capture its output
-/// Passes through stdout/stderr in real-time while also buffering
-fn run_capture_command(command: &[String], capture_all: bool) -> Result<CaptureResult> {
+/// Passes through stdout/stderr in real-time while also buffering.
+/// With `trace_log`, the command runs under strace, which writes there.
+fn run_capture_command(
+    command: &[String],
+    capture_all: bool,
+    trace_log: Option<&Path>,
+) -> Result<CaptureResult> {
     if command.is_empty() {
         bail!("No command specified. Use: why --capture -- <command>");
     }
@@ -116,13 +140,22 @@ fn run_capture_command(command: &[String], capture_all: bool) -> Result<CaptureR
     let cmd_args = &command[1..];
     let command_str = command.join(" ");
 
+    let mut cmd = match trace_log {
+        Some(log) => syscall_trace::traced_command(command, log)?,
+        None => {
+            let mut cmd = Command::new(cmd_name);
+            cmd.args(cmd_args);
+            cmd
+        }
+    };
+
     // Spawn the command with piped outputs
-    let mut child = Command::new(cmd_name)
-        .args(cmd_args)
+    let mut child = cmd
         .stdout(Stdio::piped())
         .stderr(Stdio::piped())
         .spawn()
         .with_context(|| format!("Failed to run command: {}", command_str))?;
+    let pid = child.id();
 
     // Capture buffers
     let stdout_buffer = Arc::new(Mutex::new(Vec::new()));
@@ -198,16 +231,113 @@ fn run_capture_command(command: &[String], capture_all: bool) -> Result<CaptureR
     let stdout = String::from_utf8_lossy(&stdout_buffer.lock().unwrap()).to_string();
     let stderr = String::from_utf8_lossy(&stderr_buffer.lock().unwrap()).to_string();
 
-    let exit_code = status.code().unwrap_or(-1);
+    let exit_code = shell_exit_code(&status);
 
     Ok(CaptureResult {
         command: command_str,
         exit_code,
+        pid,
         stdout,
         stderr,
     })
 }
 
+/// Run a command up to `times` times in capture mode
+///
+/// Stops early with --until-pass or --until-fail, or when a run is
+/// interrupted with Ctrl-C. Returns the run to explain, if any failed.
+fn run_repeated_capture(
+    cli: &Cli,
+    capture_all: bool,
+    times: u32,
+) -> Result<(Option<CaptureResult>, RepeatReport)> {
+    let mut results = Vec::new();
+    let mut records = Vec::new();
+    for run in 1..=times as usize {
+        eprintln!(
+            "{}",
+            format!("Run {}/{}: {}", run, times, cli.error.join(" ")).dimmed()
+        );
+        let start = Instant::now();
+        let result = run_capture_command(&cli.error, capture_all, None)?;
+        if result.exit_code == 130 {
+            break;
+        }
+        let output = if capture_all && !result.stdout.is_empty() {
+            format!("{}\n{}", result.stdout, result.stderr)
+        } else {
+            result.stderr.clone()
+        };
+        let record = RunRecord::new(run, result.exit_code, start.elapsed(), output);
+        let passed = record.passed();
+        records.push(record);
+        results.push(result);
+        if (cli.until_pass && passed) || (cli.until_fail && !passed) {
+            break;
+        }
+    }
+
+    let report = RepeatReport::new(records);
+    let result = report
+        .representative()
+        .map(|record| results.swap_remove(record.run - 1));
+    Ok((result, report))
+}
+
+/// Environment and toolchain context, when enabled by --env or the config
+fn environment_context(
+    cli: &Cli,
+    config: &Config,
+    trace: Option<&StackTrace>,
+    command: Option<&str>,
+    error_text: &str,
+) -> Option<EnvironmentContext> {
+    if !(cli.env || config.context.environment) {
+        return None;
+    }
+    let dir = cli
+        .context_root
+        .clone()
+        .or_else(|| env::current_dir().ok())
+        .unwrap_or_default();
+    Some(environment::collect(
+        trace.map(|t| t.language),
+        command,
+        error_text,
+        &dir,
+    ))
+}
+
+/// Kernel log evidence for a command killed by SIGKILL or SIGSEGV
+///
+/// Returns the matching dmesg entry when it can be read, otherwise a hint on
+/// where to look. Other exit codes return `None`.
+fn kernel_log_context(command: &str, pid: Option<u32>, exit_code: i32) -> Option<KernelContext> {
+    let hint = exit_code_hint(exit_code)?;
+    Some(match kernel::find_recent_event(command, pid) {
+        Some(event) => KernelContext::Event(Box::new(event)),
+        None => KernelContext::Hint(hint),
+    })
+}
+
+/// What `kernel_log_context` found
+enum KernelContext {
+    /// The kernel log entry for the failed process
+    Event(Box<KernelEvent>),
+    /// No readable entry; where the user should look instead
+    Hint(&'static str),
+}
+
+impl KernelContext {
+    /// Text appended to the model input
+    fn prompt_text(&self) -> String {
+        match self {
+            KernelContext::Event(event) => format!("Kernel log:\n{}", event.raw),
+            KernelContext::Hint(hint) => format!("Hint: {}", hint),
+        }
+    }
+}
+
 // ============================================================================
 // Watch Mode (Feature 2)
 
@@ -347,6 +477,75 @@ impl CommandWatcher {
     }
 }
 
+/// Stream watcher for stdin, named pipes and character devices
+pub struct StreamWatcher {
+    /// Lines read by the background reader
+    lines: mpsc::Receiver<String>,
+}
+
+impl StreamWatcher {
+    /// Start reading a stream source on a background thread.
+    ///
+    /// Lines are echoed to stdout as they arrive, since unlike a file the
+    /// stream can't be read again. Named pipes are reopened when their writer
+    /// exits, so `mkfifo` setups survive a restarted producer.
+    pub fn new(source: &WatchSource) -> Result<Self> {
+        let path = match source {
+            WatchSource::Stdin => {
+                if io::stdin().is_terminal() {
+                    bail!(
+                        "--watch - reads from a pipe. Usage: kubectl logs -f pod | why --watch -"
+                    );
+                }
+                None
+            }
+            WatchSource::Fifo(path) | WatchSource::Device(path) => Some(path.clone()),
+            _ => bail!("Not a stream source: {}", source.label()),
+        };
+        let reopen = matches!(source, WatchSource::Fifo(_));
+
+        let (tx, rx) = mpsc::channel::<String>();
+        thread::spawn(move || loop {
+            let reader: Box<dyn BufRead> = match path {
+                None => Box::new(io::stdin().lock()),
+                // Blocks until a writer opens the pipe
+                Some(ref path) => match File::open(path) {
+                    Ok(file) => Box::new(BufReader::new(file)),
+                    Err(_) => break,
+                },
+            };
+            if !forward_lines(reader, &tx) || !reopen {
+                break;
+            }
+        });
+
+        Ok(Self { lines: rx })
+    }
+
+    /// Receiver for lines read from the stream; disconnects at EOF
+    pub fn lines(&self) -> &mpsc::Receiver<String> {
+        &self.lines
+    }
+}
+
+/// Echo and forward lines until EOF; returns false once nobody is listening
+fn forward_lines(mut reader: Box<dyn BufRead>, tx: &mpsc::Sender<String>) -> bool {
+    let mut buf = Vec::new();
+    loop {
+        buf.clear();
+        match reader.read_until(b'\n', &mut buf) {
+            Ok(0) | Err(_) => return true,
+            Ok(_) => {}
+        }
+        let line = String::from_utf8_lossy(&buf);
+        let line = line.trim_end_matches(['\n', '\r']);
+        println!("{}", line);
+        if tx.send(line.to_string()).is_err() {
+            return false;
+        }
+    }
+}
+
 /// Watch mode session state
 pub struct WatchSession {
     /// Configuration
@@ -363,24 +562,30 @@ pub struct WatchSession {
     paused: bool,
     /// Running flag
     running: Arc<AtomicBool>,
+    /// Delivers explained errors to the configured sinks
+    notifier: Option<Notifier>,
 }
 
 impl WatchSession {
     /// Create a new watch session
     pub fn new(config: WatchConfig) -> Self {
-        let pattern = config.pattern.clone();
         let max_lines = config.max_aggregation_lines;
         let ttl = config.dedup_ttl;
         let dedup = config.dedup;
+        let detector = ErrorDetector::new(None, max_lines)
+            .with_rules(config.rules.clone())
+            .with_pre_context(config.pre_context_lines, config.pre_context_window);
+        let notifier = Notifier::new(config.sinks.clone());
 
         Self {
             config,
-            detector: ErrorDetector::new(pattern, max_lines),
+            detector,
             deduplicator: ErrorDeduplicator::new(if dedup { ttl } else { Duration::ZERO }),
             error_count: 0,
             explained_count: 0,
             paused: false,
             running: Arc::new(AtomicBool::new(true)),
+            notifier,
         }
     }
 
@@ -452,6 +657,13 @@ impl WatchSession {
         self.explained_count += 1;
     }
 
+    /// Send an explained error to the notification sinks
+    pub fn notify(&self, report: ErrorReport) {
+        if let Some(ref notifier) = self.notifier {
+            notifier.send(report);
+        }
+    }
+
     /// Get status string
     pub fn status(&self) -> String {
         format!(
@@ -481,8 +693,10 @@ fn print_watch_banner(target: &str, config: &WatchConfig) {
             "disabled"
         }
     );
-    if let Some(ref pattern) = config.pattern {
-        println!("  {} {}", "Pattern:".blue().bold(), pattern.as_str());
+    println!("  {} {}", "Rules:".blue().bold(), config.rules.describe());
+    if !config.sinks.is_empty() {
+        let sinks: Vec<&str> = config.sinks.iter().map(|s| s.kind.name()).collect();
+        println!("  {} {}", "Notify:".blue().bold(), sinks.join(", "));
     }
     println!();
     println!(
@@ -507,16 +721,17 @@ fn clear_waiting_line() {
 }
 
 /// Print error separator with timestamp
-fn print_error_separator(count: usize) {
+fn print_error_separator(out: &mut dyn Write, count: usize) -> io::Result<()> {
     let now = chrono_lite_now();
-    println!();
-    println!(
+    writeln!(out)?;
+    writeln!(
+        out,
         "{} {} {}",
         "─".repeat(10).dimmed(),
         format!("[{}] Error #{}", now, count).yellow(),
         "─".repeat(10).dimmed()
-    );
-    println!();
+    )?;
+    writeln!(out)
 }
 
 /// Simple timestamp without chrono dependency
@@ -854,6 +1069,122 @@ fn run_command_watch(
     Ok(())
 }
 
+/// Run watch mode for stdin, a named pipe or a character device
+fn run_stream_watch(
+    source: &WatchSource,
+    config: WatchConfig,
+    cli: &Cli,
+    model_info: &ModelPathInfo,
+) -> Result<()> {
+    let stream_watcher = StreamWatcher::new(source)?;
+    let mut session = WatchSession::new(config.clone());
+
+    if !config.quiet {
+        print_watch_banner(&source.label(), &config);
+    }
+
+    // Keyboard controls still work when stdin is the stream, as long as
+    // there is a controlling terminal to read keys from
+    let _running = session.running_flag();
+    let is_tty = io::stdin().is_terminal() || File::open("/dev/tty").is_ok();
+
+    if is_tty {
+        terminal::enable_raw_mode().ok();
+    }
+
+    // Streams like `kubectl logs -f` can go quiet for a long time, so a
+    // pending error is flushed once no line has arrived for the debounce time
+    let idle = Duration::from_millis(config.debounce_ms);
+    let mut last_line = Instant::now();
+
+    // Main loop
+    while session.is_running() {
+        // Check for keyboard input
+        if is_tty && event::poll(Duration::from_millis(50))? {
+            if let Event::Key(key_event) = event::read()? {
+                match key_event.code {
+                    KeyCode::Char('q') => {
+                        session.stop();
+                        break;
+                    }
+                    KeyCode::Char('c') if key_event.modifiers.contains(KeyModifiers::CONTROL) => {
+                        session.stop();
+                        break;
+                    }
+                    KeyCode::Char('p') => {
+                        session.toggle_pause();
+                        if !config.quiet {
+                            if session.is_paused() {
+                                println!("{}", "Paused (output continues, errors not explained). Press 'p' to resume.".yellow());
+                            } else {
+                                println!("{}", "Resumed.".green());
+                            }
+                        }
+                    }
+                    KeyCode::Char('d') => {
+                        session.toggle_dedup();
+                        if !config.quiet {
+                            println!(
+                                "Dedup {}",
+                                if session.config().dedup {
+                                    "enabled".green()
+                                } else {
+                                    "disabled".red()
+                                }
+                            );
+                        }
+                    }
+                    _ => {}
+                }
+            }
+        }
+
+        // Process incoming lines
+        let mut ended = false;
+        loop {
+            match stream_watcher.lines().try_recv() {
+                Ok(line) => {
+                    last_line = Instant::now();
+                    if let Some(error) = session.process_line(&line) {
+                        process_detected_error(&error, &mut session, cli, model_info, &config)?;
+                    }
+                }
+                Err(mpsc::TryRecvError::Empty) => break,
+                Err(mpsc::TryRecvError::Disconnected) => {
+                    ended = true;
+                    break;
+                }
+            }
+        }
+
+        if ended || last_line.elapsed() >= idle {
+            if let Some(error) = session.flush() {
+                process_detected_error(&error, &mut session, cli, model_info, &config)?;
+            }
+        }
+        if ended {
+            break;
+        }
+
+        // Small sleep to avoid busy loop
+        thread::sleep(Duration::from_millis(10));
+    }
+
+    // Cleanup
+    if is_tty {
+        terminal::disable_raw_mode().ok();
+    }
+
+    if !config.quiet {
+        println!();
+        println!("{} {}", "▸".green(), "Watch mode ended".green().bold());
+        println!("  {}", session.status());
+        println!();
+    }
+
+    Ok(())
+}
+
 /// Process a detected error in watch mode
 fn process_detected_error(
     error: &DetectedError,
@@ -861,14 +1192,32 @@ fn process_detected_error(
     cli: &Cli,
     model_info: &ModelPathInfo,
     config: &WatchConfig,
+) -> Result<()> {
+    explain_detected_error(&mut io::stdout(), error, session, cli, model_info, config)
+}
+
+/// Explain a detected error, writing everything to `out`
+fn explain_detected_error(
+    out: &mut dyn Write,
+    error: &DetectedError,
+    session: &mut WatchSession,
+    cli: &Cli,
+    model_info: &ModelPathInfo,
+    config: &WatchConfig,
 ) -> Result<()> {
     if config.clear {
-        print!("\x1B[2J\x1B[1;1H");
-        io::stdout().flush().ok();
+        write!(out, "\x1B[2J\x1B[1;1H")?;
+        out.flush().ok();
     }
 
     if !config.quiet {
-        print_error_separator(session.error_count);
+        print_error_separator(out, session.error_count)?;
+        // Lines leading up to the error are dimmed so the error stands out
+        for line in &error.pre_context {
+            writeln!(out, "{}", line.dimmed())?;
+        }
+        writeln!(out, "{}", error.content.red())?;
+        writeln!(out)?;
     }
 
     // Run inference on the error
@@ -882,13 +1231,19 @@ fn process_detected_error(
         (detected, "auto".to_string())
     };
 
-    let prompt = build_prompt(&error.content, model_family);
+    let parsed_stack_trace = StackTraceParserRegistry::with_builtins().parse(&error.content);
+    let prompt = build_prompt_with_trace(
+        &error.prompt_input(),
+        parsed_stack_trace.as_ref(),
+        model_family,
+    );
 
     // Run inference with streaming if enabled
-    let callback: Option<TokenCallback> = if cli.stream && !cli.json {
+    let streamed = cli.stream && !cli.json;
+    let callback: Option<TokenCallback> = if streamed {
         Some(Box::new(|token: &str| {
-            print!("{}", token);
-            io::stdout().flush().ok();
+            write!(out, "{}", token)?;
+            out.flush().ok();
             Ok(true)
         }))
     } else {
@@ -896,27 +1251,30 @@ fn process_detected_error(
     };
 
     let params = SamplingParams::default();
-    match run_inference_with_callback(model_path, &prompt, &params, callback) {
+    let inference = run_inference_with_callback(model_path, &prompt, &params, callback);
+    match inference {
         Ok((response, _stats)) => {
-            if !cli.stream || cli.json {
-                let result = parse_response(&error.content, &response);
+            let result = parse_response(&error.content, &response);
+            if !streamed {
                 if cli.json {
                     let payload = serde_json::json!({
                         "input": error.content,
+                        "pre_context": error.pre_context,
                         "error": result.error,
                         "summary": result.summary,
                         "explanation": result.explanation,
                         "suggestion": result.suggestion
                     });
-                    println!("{}", serde_json::to_string_pretty(&payload)?);
+                    writeln!(out, "{}", serde_json::to_string_pretty(&payload)?)?;
                 } else {
-                    print_colored(&result);
+                    write_colored(out, &result)?;
                 }
             } else {
                 // Streaming mode - output already printed
-                println!();
+                writeln!(out)?;
             }
             session.mark_explained();
+            session.notify(ErrorReport::new(&config.source, error, &result));
         }
         Err(e) => {
             eprintln!(
@@ -930,30 +1288,122 @@ fn process_detected_error(
     Ok(())
 }
 
-/// Determine if watch target is a file or command
-fn is_file_target(target: &str) -> bool {
-    let path = Path::new(target);
-    // If it contains path separators or exists as a file, treat as file
-    path.exists() || target.contains('/') || target.contains('\\')
-}
+/// Build the watch configuration shared by watch and tee mode
+fn watch_config(cli: &Cli, source: &str) -> Result<WatchConfig> {
+    let config = Config::load();
+    let rules = DetectionRules::build(&config.detect, source, cli.pattern.as_deref(), &cli.preset)?;
 
-/// Run watch mode
-fn run_watch_mode(target: &str, cli: &Cli, model_info: &ModelPathInfo) -> Result<()> {
-    let config = WatchConfig {
+    Ok(WatchConfig {
         debounce_ms: cli.debounce,
         dedup: !cli.no_dedup,
         dedup_ttl: Duration::from_secs(300),
-        pattern: cli.pattern.as_ref().map(|p| Regex::new(p)).transpose()?,
+        rules,
         clear: cli.clear,
         quiet: cli.quiet,
         max_aggregation_lines: 50,
+        pre_context_lines: cli.pre_context,
+        pre_context_window: cli.pre_context_secs.map(Duration::from_secs),
+        source: source.to_string(),
+        sinks: if cli.no_notify {
+            Vec::new()
+        } else {
+            config.sinks
+        },
+    })
+}
+
+/// Run watch mode
+fn run_watch_mode(target: &str, cli: &Cli, model_info: &ModelPathInfo) -> Result<()> {
+    let config = watch_config(cli, target)?;
+
+    match WatchSource::from_target(target) {
+        WatchSource::File(path) => run_file_watch(path, config, cli, model_info),
+        WatchSource::Command(command) => run_command_watch(&command, config, cli, model_info),
+        source => run_stream_watch(&source, config, cli, model_info),
+    }
+}
+
+/// Run tee mode: copy piped input to stdout and explain errors as they complete.
+///
+/// Stdout stays byte-for-byte identical to the input so the next command in
+/// the pipeline is unaffected; explanations and the final summary go to
+/// stderr. Always reads to EOF and exits successfully, so the upstream
+/// command never sees SIGPIPE and `set -o pipefail` reports its exit status
+/// rather than ours.
+fn run_tee_mode(cli: &Cli) -> Result<()> {
+    if io::stdin().is_terminal() {
+        bail!(
+            "{} {}\n{} {}",
+            "Error:".red().bold(),
+            "--tee reads from a pipe. Usage: command 2>&1 | why --tee",
+            "Tip:".blue().bold(),
+            "Add `set -o pipefail` to keep the command's exit status".dimmed()
+        );
+    }
+
+    let config = watch_config(cli, "-")?;
+    let mut session = WatchSession::new(config.clone());
+
+    // Without a model we still pass input through; the build output matters more
+    let model_info = match get_model_path(cli.model.as_ref()) {
+        Ok(info) => Some(info),
+        Err(e) => {
+            eprintln!(
+                "{} Passing input through without explanations\n{}",
+                "Warning:".yellow().bold(),
+                e
+            );
+            None
+        }
     };
 
-    if is_file_target(target) {
-        run_file_watch(PathBuf::from(target), config, cli, model_info)
-    } else {
-        run_command_watch(target, config, cli, model_info)
+    let (lines, reader) = watch::spawn_passthrough(BufReader::new(io::stdin()), io::stdout());
+    let idle = Duration::from_millis(config.debounce_ms);
+    let mut detected: Vec<String> = Vec::new();
+    let mut err = io::stderr();
+
+    // Keep reading after --first so the upstream command runs to completion
+    watch::drain_lines(&lines, idle, |line| {
+        let error = match line {
+            Some(line) => session.process_line(line),
+            None => session.flush(),
+        };
+        if let Some(error) = error {
+            if let Some(info) = model_info
+                .as_ref()
+                .filter(|_| !cli.first || detected.is_empty())
+            {
+                explain_detected_error(&mut err, &error, &mut session, cli, info, &config)?;
+            }
+            detected.push(first_line(&error.content));
+        }
+        Ok(())
+    })?;
+
+    let lines = reader.join().unwrap_or(0);
+
+    if !config.quiet {
+        eprintln!();
+        eprintln!("{} {}", "▸".green(), "Input ended".green().bold());
+        eprintln!("  {} lines passed through", lines);
+        eprintln!("  {}", session.status());
+        for summary in &detected {
+            eprintln!("  {} {}", "•".yellow(), summary);
+        }
+        eprintln!();
     }
+
+    Ok(())
+}
+
+/// First non-blank line of an error block, for summaries
+fn first_line(content: &str) -> String {
+    content
+        .lines()
+        .map(str::trim)
+        .find(|line| !line.is_empty())
+        .unwrap_or_default()
+        .to_string()
 }
 
 // ============================================================================
@@ -1403,8 +1853,10 @@ fn handle_daemon_connection(
                     }
                 };
 
-                // Build prompt
-                let prompt = build_prompt(&input, model_family);
+                // Build prompt, including any structured context from the input
+                let parsed_stack_trace = StackTraceParserRegistry::with_builtins().parse(&input);
+                let prompt =
+                    build_prompt_with_trace(&input, parsed_stack_trace.as_ref(), model_family);
 
                 // Create context
                 let ctx_params = LlamaContextParams::default()
@@ -1872,6 +2324,181 @@ fn daemon_uninstall_service() -> Result<()> {
     Ok(())
 }
 
+/// Run inference, retrying with tighter sampling when the output degenerates
+///
+/// Returns the response, its stats and the number of retries used.
+fn run_inference_with_retries(
+    model_path: &PathBuf,
+    prompt: &str,
+    cli: &Cli,
+    stream: bool,
+) -> Result<(String, InferenceStats, usize)> {
+    let mut params = SamplingParams::default();
+    let mut response;
+    let mut stats;
+    let mut retries = 0;
+
+    loop {
+        // Create streaming callback if streaming mode is enabled
+        let callback: Option<TokenCallback> = if stream {
+            Some(Box::new(|token: &str| {
+                print!("{}", token);
+                io::stdout().flush().ok();
+                Ok(true)
+            }))
+        } else {
+            None
+        };
+
+        (response, stats) = run_inference_with_callback(model_path, prompt, &params, callback)?;
+
+        // Check for degenerate output (repetitive patterns)
+        if is_degenerate_response(&response) {
+            retries += 1;
+            if retries > MAX_RETRIES {
+                if cli.debug {
+                    eprintln!(
+                        "{}",
+                        format!(
+                            "Degenerate output detected after {} retries, giving up",
+                            retries
+                        )
+                        .yellow()
+                    );
+                }
+                break;
+            }
+
+            // Adjust sampling parameters for retry
+            // Lower temperature and use a different seed to get more focused output
+            params.temperature = 0.5 - (retries as f32 * 0.15); // 0.35, then 0.2
+            params.temperature = params.temperature.max(0.1);
+            params.top_p = 0.8;
+            params.seed = Some(retries as u32 * 12345 + 42);
+
+            if cli.debug {
+                eprintln!(
+                    "{}",
+                    format!(
+                        "Degenerate output detected, retrying ({}/{}) with temp={:.2}",
+                        retries, MAX_RETRIES, params.temperature
+                    )
+                    .yellow()
+                );
+            } else {
+                eprintln!(
+                    "{}",
+                    format!("Retrying inference ({}/{})...", retries, MAX_RETRIES).dimmed()
+                );
+            }
+            continue;
+        }
+
+        break;
+    }
+
+    Ok((response, stats, retries))
+}
+
+/// Lint rules explained individually; the rest are only counted
+const MAX_EXPLAINED_RULES: usize = 5;
+
+/// Locations printed under each explained lint rule
+const MAX_LISTED_LOCATIONS: usize = 10;
+
+/// Explain each lint rule once, listing every location it was reported at
+///
+/// Rules are explained one prompt at a time so the small model isn't asked
+/// to cover several unrelated problems in one answer. Output isn't streamed.
+fn explain_lint_rules(
+    input: &str,
+    trace: &StackTrace,
+    report: &LintReport,
+    model_path: &PathBuf,
+    model_family: ModelFamily,
+    cli: &Cli,
+) -> Result<()> {
+    let mut explained = Vec::new();
+    for group in report.rules.iter().take(MAX_EXPLAINED_RULES) {
+        let rule_input = group.prompt_input(report.tool);
+        let prompt = build_prompt(&rule_input, model_family);
+        if cli.debug {
+            print_debug_section(
+                &format!("Prompt ({})", group.rule),
+                &prompt,
+                Some(format!("({} chars)", prompt.len())),
+            );
+        }
+        let (response, stats, _) = run_inference_with_retries(model_path, &prompt, cli, false)?;
+        let mut result = parse_response(&rule_input, &response);
+        result.error = group.heading();
+        explained.push((group, result, stats));
+    }
+    let unexplained = report.rules.len().saturating_sub(explained.len());
+
+    if cli.json {
+        let mut rules = Vec::new();
+        for (group, result, stats) in &explained {
+            let mut rule = serde_json::json!({
+                "rule": group.rule,
+                "severity": group.severity,
+                "locations": group.locations(),
+                "summary": result.summary,
+                "explanation": result.explanation,
+                "suggestion": result.suggestion
+            });
+            if cli.stats {
+                rule["stats"] = serde_json::to_value(stats)?;
+            }
+            rules.push(rule);
+        }
+        let payload = serde_json::json!({
+            "input": input,
+            "summary": report.summary(),
+            "rules": rules,
+            "stack_trace": StackTraceJson::from(trace)
+        });
+        println!("{}", serde_json::to_string_pretty(&payload)?);
+        return Ok(());
+    }
+
+    println!();
+    println!("{} {}", "▸".cyan(), report.summary().cyan().bold());
+    for (group, result, stats) in &explained {
+        println!();
+        println!("{} {}", "▸".cyan(), "Locations".cyan().bold());
+        let located: Vec<_> = group.findings.iter().filter(|f| f.file.is_some()).collect();
+        for finding in located.iter().take(MAX_LISTED_LOCATIONS) {
+            if let Some(ref file) = finding.file {
+                println!("  {}", format_file_line(file, finding.line, finding.column));
+            }
+        }
+        if located.len() > MAX_LISTED_LOCATIONS {
+            println!(
+                "  {}",
+                format!("... and {} more", located.len() - MAX_LISTED_LOCATIONS).dimmed()
+            );
+        }
+        print_colored(result);
+        if cli.stats {
+            print_stats(stats);
+        }
+    }
+    if unexplained > 0 {
+        println!(
+            "  {}",
+            format!(
+                "{} more rule(s) not explained; use --json to list every finding",
+                unexplained
+            )
+            .dimmed()
+        );
+        println!();
+    }
+
+    Ok(())
+}
+
 fn get_input(cli: &Cli) -> Result<String> {
     // If error args provided, use them
     if !cli.error.is_empty() {
@@ -2078,14 +2705,37 @@ fn main() -> Result<()> {
         return uninstall_hook(shell);
     }
 
+    // Handle detect subcommand
+    if let Some(Commands::Detect(ref args)) = cli.command {
+        let config = Config::load();
+        let rules = DetectionRules::build(
+            &config.detect,
+            &args.source,
+            args.pattern.as_deref(),
+            &args.preset,
+        )?;
+        let verdict = rules.explain(&args.explain_line);
+        if cli.json {
+            println!("{}", serde_json::to_string_pretty(&verdict)?);
+        } else {
+            print_line_verdict(&verdict, &rules.describe());
+        }
+        return Ok(());
+    }
+
     // Handle --watch mode
     if let Some(ref target) = cli.watch {
         let model_info = get_model_path(cli.model.as_ref())?;
         return run_watch_mode(target, &cli, &model_info);
     }
 
+    // Handle --tee mode
+    if cli.tee {
+        return run_tee_mode(&cli);
+    }
+
     // Handle daemon subcommand
-    if let Some(ref daemon_cmd) = cli.daemon {
+    if let Some(Commands::Daemon(ref daemon_cmd)) = cli.command {
         return handle_daemon_command(daemon_cmd, &cli);
     }
 
@@ -2101,10 +2751,59 @@ fn main() -> Result<()> {
 
     // Handle --capture mode
     if cli.capture {
-        let result = run_capture_command(&cli.error, cli.capture_all)?;
+        // kubectl reports pod failures on stdout and exits 0, so always keep
+        // its stdout and explain it when it shows a failing pod. The legacy
+        // docker builder also writes step output to stdout.
+        let kubectl = kubernetes::is_kubectl_command(&cli.error);
+        let capture_stdout =
+            cli.capture_all || kubectl || docker::is_docker_build_command(&cli.error);
+        // Created up front so strace writes into our own file, not wherever a
+        // symlink at a guessable path points
+        let trace_log = if cli.trace {
+            Some(temp::create_file("why-strace-", ".log")?.1)
+        } else {
+            None
+        };
+        let (mut result, repeat_report) = match cli.repeat {
+            Some(times) => match run_repeated_capture(&cli, capture_stdout, times)? {
+                (Some(result), report) => (result, Some(report)),
+                (None, report) => {
+                    if !report.runs.is_empty() {
+                        println!();
+                        println!(
+                            "{} {}",
+                            "✓".green(),
+                            format!("Passed all {} runs", report.runs.len())
+                                .green()
+                                .bold()
+                        );
+                    }
+                    return Ok(());
+                }
+            },
+            None => (
+                run_capture_command(&cli.error, capture_stdout, trace_log.as_deref())?,
+                None,
+            ),
+        };
+        // strace's own PID isn't the command's; the trace knows the real one
+        let trace_summary: Option<TraceSummary> = match trace_log {
+            Some(ref log) => {
+                let summary = syscall_trace::read_trace(log);
+                std::fs::remove_file(log).ok();
+                let summary = summary?;
+                if let Some(pid) = summary.pid {
+                    result.pid = pid;
+                }
+                Some(summary)
+            }
+            None => None,
+        };
+        let pod_failure = kubectl
+            && kubernetes::parse_kubectl_output(&result.stdout).is_some_and(|r| r.has_failure());
 
         // Check if this exit code should be skipped (from config)
-        if config.should_skip_exit_code(result.exit_code) {
+        if config.should_skip_exit_code(result.exit_code) && !pod_failure {
             return Ok(());
         }
 
@@ -2114,23 +2813,42 @@ fn main() -> Result<()> {
         }
 
         // Build input from captured output
-        let captured_output = if cli.capture_all && !result.stdout.is_empty() {
+        let mut captured_output = if capture_stdout && !result.stdout.is_empty() {
             format!("{}\n{}", result.stdout, result.stderr)
         } else {
             result.stderr.clone()
         };
 
-        // If no output captured, just report the exit code
+        // Processes killed by the kernel often leave their only evidence in dmesg
+        let mut kernel_context =
+            kernel_log_context(&result.command, Some(result.pid), result.exit_code);
+
+        // If no output captured, explain the kernel log entry or just report the exit code
+        // A silent failure is still worth explaining when strace saw why, or
+        // when repeated runs show how it behaves
         if captured_output.trim().is_empty() {
-            let interpretation = interpret_exit_code(result.exit_code);
-            println!();
-            println!(
-                "{} {} (exit {})",
-                "Command failed:".red().bold(),
-                interpretation,
-                result.exit_code
-            );
-            return Ok(());
+            match kernel_context.take() {
+                Some(KernelContext::Event(event)) => captured_output = event.raw,
+                None if repeat_report.is_some()
+                    || trace_summary.as_ref().is_some_and(|t| t.has_findings()) =>
+                {
+                    captured_output = "(no output)".to_string()
+                }
+                other => {
+                    let interpretation = interpret_exit_code(result.exit_code);
+                    println!();
+                    println!(
+                        "{} {} (exit {})",
+                        "Command failed:".red().bold(),
+                        interpretation,
+                        result.exit_code
+                    );
+                    if let Some(KernelContext::Hint(hint)) = other {
+                        println!("  {}", format!("Hint: {}", hint).dimmed());
+                    }
+                    return Ok(());
+                }
+            }
         }
 
         // Handle confirmation mode
@@ -2157,18 +2875,36 @@ fn main() -> Result<()> {
         }
 
         // Build enhanced input with command context
-        let input = format!(
+        let mut input = format!(
             "Command: {}\nExit code: {} ({})\n\nOutput:\n{}",
             result.command,
             result.exit_code,
             interpret_exit_code(result.exit_code),
             captured_output.trim()
         );
+        if let Some(ref context) = kernel_context {
+            input.push_str(&format!("\n\n{}", context.prompt_text()));
+        }
+        if let Some(ref summary) = trace_summary {
+            input.push_str(&format!(
+                "\n\nSystem call trace (strace -f):\n{}",
+                summary.prompt_summary()
+            ));
+        }
+        if let Some(ref report) = repeat_report {
+            input.push_str(&format!("\n\nRepeated runs:\n{}", report.prompt_summary()));
+        }
 
         // Now run the normal explanation flow with this input
-        // Parse stack trace from captured output
+        // Parse stack trace from captured output, falling back to the kernel log
         let registry = StackTraceParserRegistry::with_builtins();
-        let parsed_stack_trace = registry.parse(&captured_output);
+        let parsed_stack_trace =
+            registry
+                .parse(&captured_output)
+                .or_else(|| match kernel_context {
+                    Some(KernelContext::Event(ref event)) => registry.parse(&event.raw),
+                    _ => None,
+                });
 
         // If --show-frames is requested, display parsed frames
         if cli.show_frames {
@@ -2177,6 +2913,20 @@ fn main() -> Result<()> {
             }
         }
 
+        if let Some(context) = environment_context(
+            &cli,
+            &config,
+            parsed_stack_trace.as_ref(),
+            Some(&result.command),
+            &captured_output,
+        ) {
+            let text = context.prompt_text();
+            if cli.debug {
+                print_debug_section("Environment", &text, None);
+            }
+            input.push_str(&format!("\n\n{}", text));
+        }
+
         let model_info = get_model_path(cli.model.as_ref())?;
         let model_path = &model_info.path;
 
@@ -2193,7 +2943,7 @@ fn main() -> Result<()> {
             (detected, format!("auto-detected from '{}'", filename))
         };
 
-        let prompt = build_prompt(&input, model_family);
+        let prompt = build_prompt_with_trace(&input, parsed_stack_trace.as_ref(), model_family);
 
         // Run inference
         let callback: Option<TokenCallback> = if cli.stream && !cli.json {
@@ -2231,6 +2981,12 @@ fn main() -> Result<()> {
             if let Some(ref trace) = parsed_stack_trace {
                 payload["stack_trace"] = serde_json::to_value(StackTraceJson::from(trace))?;
             }
+            if let Some(ref summary) = trace_summary {
+                payload["syscall_trace"] = serde_json::to_value(summary)?;
+            }
+            if let Some(ref report) = repeat_report {
+                payload["repeat"] = serde_json::to_value(report)?;
+            }
             if cli.stats {
                 payload["stats"] = serde_json::to_value(&stats)?;
             }
@@ -2275,16 +3031,47 @@ fn main() -> Result<()> {
         return Ok(());
     }
 
-    // Build input - enhanced for hook mode
-    let input = if let (Some(exit_code), Some(ref command)) = (cli.exit_code, &cli.last_command) {
+    // Compare a passing and a failing log
+    let log_diff = match cli.command {
+        Some(Commands::Diff { ref good, ref bad }) => {
+            let diff = LogDiff::from_files(good, bad)?;
+            if !cli.json {
+                print_log_diff(&diff);
+            }
+            if diff.is_empty() {
+                if cli.json {
+                    println!("{}", serde_json::to_string_pretty(&diff)?);
+                }
+                return Ok(());
+            }
+            Some(diff)
+        }
+        _ => None,
+    };
+
+    // Build input - new lines in a failing log, the first bad commit's
+    // failure, a core dump's backtrace, or enhanced for hook mode
+    let input = if let Some(ref diff) = log_diff {
+        diff.to_input()
+    } else if let Some(Commands::Bisect(ref args)) = cli.command {
+        let dir = env::current_dir()?;
+        bisect::run(&args.test_command, &args.good, &args.bad, &dir, &config)?.to_input()
+    } else if let Some(ref core) = cli.core {
+        // A bare --core means the latest crash coredumpctl recorded
+        core_dump::analyze(core.as_deref(), cli.binary.as_deref())?.to_input()
+    } else if let (Some(exit_code), Some(ref command)) = (cli.exit_code, &cli.last_command) {
         // Hook mode: build enhanced prompt with command context
         let interpretation = interpret_exit_code(exit_code);
-        format!(
+        let mut input = format!(
             "Command: {}\nExit code: {} ({})\n\nExplain why this command failed.",
             command.trim(),
             exit_code,
             interpretation
-        )
+        );
+        if let Some(context) = kernel_log_context(command.trim(), None, exit_code) {
+            input.push_str(&format!("\n\n{}", context.prompt_text()));
+        }
+        input
     } else if cli.exit_code.is_some() || cli.last_command.is_some() {
         // Partial hook mode - try to use what we have
         if let Some(exit_code) = cli.exit_code {
@@ -2345,7 +3132,60 @@ fn main() -> Result<()> {
             .unwrap_or("unknown");
         (detected, format!("auto-detected from '{}'", filename))
     };
-    let prompt = build_prompt(&input, model_family);
+
+    // Lint output gets one explanation per rule instead of one for everything
+    if let Some(trace) = &parsed_stack_trace {
+        if let Some(TraceDetails::Lint(report)) = &trace.details {
+            if report.rules.len() > 1 {
+                return explain_lint_rules(&input, trace, report, model_path, model_family, &cli);
+            }
+        }
+    }
+
+    // Source around the crashing frames, for --context and core dumps
+    let context_config = SourceContextConfig {
+        context_lines: cli.context_lines,
+        context_root: cli.context_root.clone(),
+        ..SourceContextConfig::default()
+    };
+    let with_context = cli.context || cli.core.is_some();
+    let source_context = parsed_stack_trace
+        .as_ref()
+        .filter(|_| with_context)
+        .map(|trace| extract_stack_trace_context(trace, &context_config))
+        .filter(|context| !context.trim().is_empty());
+    let mut prompt_input = match source_context {
+        Some(ref context) => format!("{}\n\nSource context:{}", input, context),
+        None => input.clone(),
+    };
+
+    // Who last touched the failing line, and what's changed since
+    let git = parsed_stack_trace
+        .as_ref()
+        .filter(|_| with_context && config.context.git && !cli.no_git)
+        .and_then(|trace| trace.root_cause_frame())
+        .and_then(|frame| {
+            let file = resolve_source_path(frame.file.as_ref()?, &cli.context_root)?;
+            git_context::collect(&file, frame.line?, cli.context_lines)
+        });
+    if let Some(ref git) = git {
+        let used = source_context.as_ref().map_or(0, String::len);
+        let budget = context_config.max_context_chars.saturating_sub(used);
+        prompt_input.push_str(&format!("\n\n{}", git.prompt_text(budget)));
+    }
+    let environment = environment_context(
+        &cli,
+        &config,
+        parsed_stack_trace.as_ref(),
+        cli.last_command.as_deref(),
+        &input,
+    )
+    .map(|context| context.prompt_text());
+    if let Some(ref text) = environment {
+        prompt_input.push_str(&format!("\n\n{}", text));
+    }
+
+    let prompt = build_prompt_with_trace(&prompt_input, parsed_stack_trace.as_ref(), model_family);
 
     if cli.debug {
         print_debug_section(
@@ -2357,6 +3197,9 @@ fn main() -> Result<()> {
                 input.lines().count()
             )),
         );
+        if let Some(ref text) = environment {
+            print_debug_section("Environment", text, None);
+        }
         print_debug_section("Prompt", &prompt, Some(format!("({} chars)", prompt.len())));
         eprintln!("{}", "=== DEBUG: Model ===".yellow().bold());
         eprintln!(
@@ -2388,69 +3231,8 @@ fn main() -> Result<()> {
     }
 
     // Run inference with retry logic for degenerate outputs
-    let mut params = SamplingParams::default();
-    let mut response;
-    let mut stats;
-    let mut retries = 0;
-
-    loop {
-        // Create streaming callback if streaming mode is enabled
-        let callback: Option<TokenCallback> = if cli.stream && !cli.json {
-            Some(Box::new(|token: &str| {
-                print!("{}", token);
-                io::stdout().flush().ok();
-                Ok(true)
-            }))
-        } else {
-            None
-        };
-
-        (response, stats) = run_inference_with_callback(model_path, &prompt, &params, callback)?;
-
-        // Check for degenerate output (repetitive patterns)
-        if is_degenerate_response(&response) {
-            retries += 1;
-            if retries > MAX_RETRIES {
-                if cli.debug {
-                    eprintln!(
-                        "{}",
-                        format!(
-                            "Degenerate output detected after {} retries, giving up",
-                            retries
-                        )
-                        .yellow()
-                    );
-                }
-                break;
-            }
-
-            // Adjust sampling parameters for retry
-            // Lower temperature and use a different seed to get more focused output
-            params.temperature = 0.5 - (retries as f32 * 0.15); // 0.35, then 0.2
-            params.temperature = params.temperature.max(0.1);
-            params.top_p = 0.8;
-            params.seed = Some(retries as u32 * 12345 + 42);
-
-            if cli.debug {
-                eprintln!(
-                    "{}",
-                    format!(
-                        "Degenerate output detected, retrying ({}/{}) with temp={:.2}",
-                        retries, MAX_RETRIES, params.temperature
-                    )
-                    .yellow()
-                );
-            } else {
-                eprintln!(
-                    "{}",
-                    format!("Retrying inference ({}/{})...", retries, MAX_RETRIES).dimmed()
-                );
-            }
-            continue;
-        }
-
-        break;
-    }
+    let (response, stats, retries) =
+        run_inference_with_retries(model_path, &prompt, &cli, cli.stream && !cli.json)?;
 
     // Add newline after streaming output
     if cli.stream && !cli.json {
@@ -2566,6 +3348,12 @@ fn main() -> Result<()> {
         if let Some(ref trace) = parsed_stack_trace {
             payload["stack_trace"] = serde_json::to_value(StackTraceJson::from(trace))?;
         }
+        if let Some(ref git) = git {
+            payload["git"] = serde_json::to_value(git)?;
+        }
+        if let Some(ref diff) = log_diff {
+            payload["log_diff"] = serde_json::to_value(diff)?;
+        }
         if cli.stats {
             payload["stats"] = serde_json::to_value(&stats)?;
         }
@@ -2581,6 +3369,9 @@ fn main() -> Result<()> {
                         "  {}",
                         format_file_line(file, root_frame.line, root_frame.column)
                     );
+                    if let Some(blame) = git.as_ref().and_then(|g| g.line_summary()) {
+                        println!("  {}", blame.dimmed());
+                    }
                 }
             }
         }
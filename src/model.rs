@@ -19,6 +19,8 @@ use std::num::NonZeroU32;
 use std::path::{Path, PathBuf};
 use std::time::{Instant, SystemTime, UNIX_EPOCH};
 
+use crate::stack_trace::StackTrace;
+
 /// Token callback type for streaming output
 /// Returns Ok(true) to continue, Ok(false) to stop, or Err to abort
 pub type TokenCallback<'a> = Box<dyn FnMut(&str) -> Result<bool> + 'a>;
@@ -267,6 +269,34 @@ pub fn build_prompt(error: &str, family: ModelFamily) -> String {
     template.replace("{error}", error.trim())
 }
 
+/// Build a prompt, appending structured context parsed from the error (if any)
+///
+/// When the parser produced a condensed view of the input, it replaces the
+/// raw trace text so long tool output still fits in the context window.
+pub fn build_prompt_with_trace(
+    error: &str,
+    trace: Option<&StackTrace>,
+    family: ModelFamily,
+) -> String {
+    let Some(trace) = trace else {
+        return build_prompt(error, family);
+    };
+
+    let error = match trace.focused_input() {
+        Some(focus) if !trace.raw_text.trim().is_empty() => {
+            error.replacen(trace.raw_text.trim(), &focus, 1)
+        }
+        _ => error.to_string(),
+    };
+    match trace.prompt_context() {
+        Some(context) => build_prompt(
+            &format!("{}\n\nParsed context:\n{}", error.trim(), context),
+            family,
+        ),
+        None => build_prompt(&error, family),
+    }
+}
+
 /// Check if the response contains degenerate patterns (repetitive characters/sequences)
 pub fn is_degenerate_response(response: &str) -> bool {
     let response = response.trim();
@@ -573,6 +603,46 @@ mod tests {
         assert!(!prompt.contains("<|im_start|>"));
     }
 
+    #[test]
+    fn test_build_prompt_with_trace_appends_context() {
+        use crate::stack_trace::StackTraceParserRegistry;
+
+        let input = "/app/ub.c:5:12: runtime error: signed integer overflow: 1 + 2147483647";
+        let trace = StackTraceParserRegistry::with_builtins().parse(input);
+        let prompt = build_prompt_with_trace(input, trace.as_ref(), ModelFamily::Qwen);
+        assert!(prompt.contains(input));
+        assert!(prompt.contains("Parsed context:"));
+        assert!(prompt.contains("UndefinedBehaviorSanitizer report"));
+
+        let plain = build_prompt_with_trace("segmentation fault", None, ModelFamily::Qwen);
+        assert_eq!(plain, build_prompt("segmentation fault", ModelFamily::Qwen));
+    }
+
+    #[test]
+    fn test_build_prompt_with_trace_uses_focused_input() {
+        use crate::stack_trace::StackTraceParserRegistry;
+
+        let input = "Name:         web-0
+Namespace:    default
+Node:         node-1/10.0.0.4
+Labels:       app=web
+Containers:
+  web:
+    Image:          web:1.0
+    State:          Waiting
+      Reason:       CrashLoopBackOff
+    Last State:     Terminated
+      Reason:       OOMKilled
+      Exit Code:    137
+    Restart Count:  7
+";
+        let trace = StackTraceParserRegistry::with_builtins().parse(input);
+        let prompt = build_prompt_with_trace(input, trace.as_ref(), ModelFamily::Qwen);
+        assert!(!prompt.contains("Labels:"));
+        assert!(prompt.contains("Container web (image web:1.0)"));
+        assert!(prompt.contains("OOMKilled"));
+    }
+
     #[test]
     fn test_detect_model_family_qwen() {
         let path = PathBuf::from("/path/to/qwen2.5-coder-0.5b-instruct-q8_0.gguf");
@@ -2,8 +2,12 @@
 
 use colored::Colorize;
 use serde::Serialize;
+use std::io::{self, Write};
 use std::path::Path;
+use std::process::ExitStatus;
 
+use crate::detect::{LineVerdict, RuleKind};
+use crate::log_diff::{DiffSeverity, LogDiff};
 use crate::model::InferenceStats;
 use crate::stack_trace::StackTrace;
 
@@ -104,6 +108,16 @@ pub fn parse_response(error: &str, response: &str) -> ErrorExplanation {
 
 /// Render markdown text to terminal with colored output.
 pub fn render_markdown(text: &str, width: usize, indent: &str) {
+    let _ = write_markdown(&mut io::stdout().lock(), text, width, indent);
+}
+
+/// Render markdown text with colored output to any writer
+pub fn write_markdown(
+    out: &mut dyn Write,
+    text: &str,
+    width: usize,
+    indent: &str,
+) -> io::Result<()> {
     let mut in_code_block = false;
     let mut code_block_content: Vec<String> = Vec::new();
 
@@ -113,7 +127,7 @@ pub fn render_markdown(text: &str, width: usize, indent: &str) {
         if trimmed.starts_with("```") {
             if in_code_block {
                 for code_line in &code_block_content {
-                    println!("{indent}  {}", code_line.cyan());
+                    writeln!(out, "{indent}  {}", code_line.cyan())?;
                 }
                 code_block_content.clear();
                 in_code_block = false;
@@ -131,15 +145,16 @@ pub fn render_markdown(text: &str, width: usize, indent: &str) {
         let processed = render_inline_markdown(line);
 
         for wrapped_line in textwrap::wrap(&processed, width.saturating_sub(indent.len())) {
-            println!("{indent}{wrapped_line}");
+            writeln!(out, "{indent}{wrapped_line}")?;
         }
     }
 
     if in_code_block {
         for code_line in &code_block_content {
-            println!("{indent}  {}", code_line.cyan());
+            writeln!(out, "{indent}  {}", code_line.cyan())?;
         }
     }
+    Ok(())
 }
 
 /// Process inline markdown: `code`, **bold**, *italic*
@@ -193,31 +208,38 @@ pub fn render_inline_markdown(text: &str) -> String {
 }
 
 pub fn print_colored(result: &ErrorExplanation) {
+    let _ = write_colored(&mut io::stdout().lock(), result);
+}
+
+/// Write a colored explanation to any writer, e.g. stderr when stdout is
+/// carrying other output
+pub fn write_colored(out: &mut dyn Write, result: &ErrorExplanation) -> io::Result<()> {
     let width = textwrap::termwidth().min(100);
 
-    println!();
-    println!("{} {}", "●".red(), result.error.bold());
-    println!();
+    writeln!(out)?;
+    writeln!(out, "{} {}", "●".red(), result.error.bold())?;
+    writeln!(out)?;
 
     if !result.summary.is_empty() {
         let processed = render_inline_markdown(&result.summary);
         for line in textwrap::wrap(&processed, width) {
-            println!("{}", line.white().bold());
+            writeln!(out, "{}", line.white().bold())?;
         }
-        println!();
+        writeln!(out)?;
     }
 
     if !result.explanation.is_empty() {
-        println!("{} {}", "▸".blue(), "Explanation".blue().bold());
-        render_markdown(&result.explanation, width, "  ");
-        println!();
+        writeln!(out, "{} {}", "▸".blue(), "Explanation".blue().bold())?;
+        write_markdown(out, &result.explanation, width, "  ")?;
+        writeln!(out)?;
     }
 
     if !result.suggestion.is_empty() {
-        println!("{} {}", "▸".green(), "Suggestion".green().bold());
-        render_markdown(&result.suggestion, width, "  ");
-        println!();
+        writeln!(out, "{} {}", "▸".green(), "Suggestion".green().bold())?;
+        write_markdown(out, &result.suggestion, width, "  ")?;
+        writeln!(out)?;
     }
+    Ok(())
 }
 
 pub fn print_stats(stats: &InferenceStats) {
@@ -318,7 +340,12 @@ pub fn print_frames(trace: &StackTrace) {
             marker.normal()
         };
 
-        let function = frame.function.as_deref().unwrap_or("<unknown>").to_string();
+        let function = frame.function.as_deref().unwrap_or("<unknown>");
+        let function = if frame.is_async {
+            format!("async {}", function)
+        } else {
+            function.to_string()
+        };
         let function_display = if function.len() > 38 {
             format!("{}...", &function[..35])
         } else {
@@ -381,6 +408,113 @@ pub fn print_frames(trace: &StackTrace) {
     }
 }
 
+/// Print a log comparison: new findings, then the hunks around them side by side
+pub fn print_log_diff(diff: &LogDiff) {
+    println!();
+    println!("{} {}", "▸".cyan(), "Log Diff".cyan().bold());
+    println!(
+        "  {} {} ({} lines)  {} {} ({} lines)",
+        "Passing:".blue().bold(),
+        diff.good_name.bright_white(),
+        diff.good_lines,
+        "Failing:".blue().bold(),
+        diff.bad_name.bright_white(),
+        diff.bad_lines
+    );
+    println!();
+
+    if diff.is_empty() {
+        println!("  {}", "The logs match once normalized.".dimmed());
+        return;
+    }
+    for finding in &diff.findings {
+        let label = match finding.severity {
+            DiffSeverity::Error => "error".red().bold(),
+            DiffSeverity::Warning => "warning".yellow().bold(),
+        };
+        println!(
+            "  {} {} {}",
+            format!("{:>5}", finding.line).dimmed(),
+            label,
+            finding.text
+        );
+    }
+    if diff.findings.is_empty() {
+        println!("  {}", "No new errors or warnings.".dimmed());
+    }
+    println!();
+
+    // Two columns of equal width with a separator
+    let column = (textwrap::termwidth().min(160).saturating_sub(5) / 2).max(20);
+    let cell = |text: &str| {
+        let text: String = text.chars().take(column).collect();
+        format!("{:width$}", text, width = column)
+    };
+    for hunk in diff.relevant_hunks() {
+        println!(
+            "  {}",
+            format!(
+                "{}:{} | {}:{}",
+                diff.good_name, hunk.good_start, diff.bad_name, hunk.bad_start
+            )
+            .dimmed()
+        );
+        for row in 0..hunk.good.len().max(hunk.bad.len()) {
+            let left = hunk.good.get(row).map_or("", String::as_str);
+            let bad_line = hunk.bad_start + row;
+            let right = match hunk.bad.get(row) {
+                Some(text) => match diff.findings.iter().find(|f| f.line == bad_line) {
+                    Some(f) if f.severity == DiffSeverity::Error => cell(text).red().bold(),
+                    Some(_) => cell(text).yellow(),
+                    None => cell(text).red(),
+                },
+                None => cell("").normal(),
+            };
+            println!("  {} {} {}", cell(left).green(), "│".dimmed(), right);
+        }
+        println!();
+    }
+}
+
+/// Print how detection rules classified a line, deciding rule first
+pub fn print_line_verdict(verdict: &LineVerdict, layers: &str) {
+    println!();
+    println!("{} {}", "▸".cyan(), "Detection".cyan().bold());
+    println!(
+        "  {} {}",
+        "Line:".blue().bold(),
+        verdict.line.bright_white()
+    );
+    println!("  {} {}", "Layers:".blue().bold(), layers);
+    let result = if verdict.is_error {
+        "starts an error".red().bold()
+    } else {
+        "not an error".green().bold()
+    };
+    println!("  {} {}", "Result:".blue().bold(), result);
+    println!();
+
+    if verdict.matches.is_empty() {
+        println!("  {}", "No rule matched.".dimmed());
+        println!();
+        return;
+    }
+    for (i, rule) in verdict.matches.iter().enumerate() {
+        let kind = match rule.kind {
+            RuleKind::Include => "include".red(),
+            RuleKind::Exclude => "exclude".green(),
+        };
+        let line = format!("{} {} {}", rule.layer.blue(), kind, rule.rule);
+        if i == 0 {
+            println!("  {} {}", "→".bold(), line);
+        } else {
+            // Overridden by the deciding rule
+            println!("    {}", line.dimmed());
+        }
+    }
+    println!();
+}
+
 /// Format a file:line location with color highlighting for terminal output
 pub fn format_file_line(file: &Path, line: Option<u32>, column: Option<u32>) -> String {
     let mut result = file.display().to_string().cyan().to_string();
@@ -451,6 +585,33 @@ pub fn interpret_exit_code(code: i32) -> &'static str {
     }
 }
 
+/// Where to look next when an exit code's cause is only recorded by the kernel
+pub fn exit_code_hint(code: i32) -> Option<&'static str> {
+    match code {
+        137 => Some(
+            "SIGKILL with no error output is usually the kernel OOM killer; \
+             check `dmesg | grep -i 'killed process'` or `journalctl -k`",
+        ),
+        139 => Some(
+            "the kernel logs the faulting address and library for segfaults; \
+             check `dmesg | tail` or `journalctl -k`",
+        ),
+        _ => None,
+    }
+}
+
+/// Exit code as a shell reports it: 128 + signal number for killed processes
+pub fn shell_exit_code(status: &ExitStatus) -> i32 {
+    #[cfg(unix)]
+    {
+        use std::os::unix::process::ExitStatusExt;
+        if let Some(signal) = status.signal() {
+            return 128 + signal;
+        }
+    }
+    status.code().unwrap_or(-1)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -757,6 +918,13 @@ mod tests {
         assert!(interpret_exit_code(139).contains("Segmentation fault"));
     }
 
+    #[test]
+    fn test_exit_code_hint_points_at_kernel_log() {
+        assert!(exit_code_hint(137).unwrap().contains("OOM killer"));
+        assert!(exit_code_hint(139).unwrap().contains("dmesg"));
+        assert!(exit_code_hint(1).is_none());
+    }
+
     #[test]
     fn test_interpret_exit_code_unknown() {
         assert_eq!(interpret_exit_code(42), "Unknown error");
@@ -0,0 +1,1030 @@
+//! Package manager failure parsing.
+//!
+//! Extracts the conflicting packages, version constraints and who requires
+//! what from npm/pnpm/yarn, pip, cargo and go module errors, plus the
+//! underlying native build error when a wheel, node-gyp addon or cargo build
+//! script fails to compile.
+
+use regex::Regex;
+use serde::Serialize;
+use std::fmt;
+use std::sync::OnceLock;
+
+use crate::stack_trace::strip_ansi;
+
+/// Maximum number of native build error lines kept
+const MAX_NATIVE_LINES: usize = 10;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// Package manager that produced the output
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum PackageManager {
+    Npm,
+    Pnpm,
+    Yarn,
+    Pip,
+    Cargo,
+    Go,
+}
+
+impl fmt::Display for PackageManager {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            PackageManager::Npm => write!(f, "npm"),
+            PackageManager::Pnpm => write!(f, "pnpm"),
+            PackageManager::Yarn => write!(f, "yarn"),
+            PackageManager::Pip => write!(f, "pip"),
+            PackageManager::Cargo => write!(f, "cargo"),
+            PackageManager::Go => write!(f, "go"),
+        }
+    }
+}
+
+/// What went wrong
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum FailureKind {
+    /// Version constraints cannot all be satisfied
+    Conflict,
+    /// A package or version does not exist in the registry
+    NotFound,
+    /// A native extension (wheel, node-gyp, build script) failed to compile
+    BuildFailed,
+    /// Checksum, lockfile or other module errors
+    Other,
+}
+
+impl FailureKind {
+    /// Name used as the stack trace error type
+    pub fn error_type(&self) -> &'static str {
+        match self {
+            FailureKind::Conflict => "DependencyConflict",
+            FailureKind::NotFound => "PackageNotFound",
+            FailureKind::BuildFailed => "NativeBuildFailed",
+            FailureKind::Other => "PackageError",
+        }
+    }
+}
+
+/// A version constraint and who imposes it
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Requirement {
+    /// Package being constrained
+    pub package: String,
+    /// Version constraint (e.g. "^17.0.0", ">=2.25")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub constraint: Option<String>,
+    /// Who requires it (e.g. "react-beautiful-dnd@13.1.1", "the root project")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub required_by: Option<String>,
+    /// Whether this is a peer dependency
+    pub peer: bool,
+}
+
+impl Requirement {
+    fn render(&self) -> String {
+        let who = self.required_by.as_deref().unwrap_or("something");
+        let kind = if self.peer { " as a peer" } else { "" };
+        match &self.constraint {
+            Some(constraint) => format!("{} requires {} {}{}", who, self.package, constraint, kind),
+            None => format!("{} requires {}{}", who, self.package, kind),
+        }
+    }
+}
+
+/// Compiler or tool error from a native extension build
+#[derive(Debug, Clone, Serialize)]
+pub struct NativeBuildError {
+    /// Build tool (node-gyp, wheel, build script)
+    pub tool: String,
+    /// Package whose native build failed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub package: Option<String>,
+    /// Error lines from the build output
+    pub errors: Vec<String>,
+}
+
+/// A parsed package manager failure
+#[derive(Debug, Clone, Serialize)]
+pub struct PackageFailure {
+    /// Package manager that failed
+    pub manager: PackageManager,
+    /// What kind of failure it is
+    pub kind: FailureKind,
+    /// Package the failure is about
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub package: Option<String>,
+    /// Version that is installed or was selected first
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub selected: Option<String>,
+    /// Conflicting or unsatisfied requirements
+    pub requirements: Vec<Requirement>,
+    /// Native build error, for wheel/node-gyp/build script failures
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub native: Option<NativeBuildError>,
+    /// The package manager's own error message
+    pub message: String,
+}
+
+impl PackageFailure {
+    fn new(manager: PackageManager, kind: FailureKind) -> Self {
+        Self {
+            manager,
+            kind,
+            package: None,
+            selected: None,
+            requirements: Vec::new(),
+            native: None,
+            message: String::new(),
+        }
+    }
+
+    fn add_requirement(&mut self, requirement: Requirement) {
+        if !self.requirements.contains(&requirement) {
+            self.requirements.push(requirement);
+        }
+    }
+
+    /// One-line description of the failure
+    pub fn summary(&self) -> String {
+        let package = self.package.as_deref().unwrap_or("a dependency");
+        match self.kind {
+            FailureKind::Conflict => format!(
+                "{}: cannot resolve {} ({} conflicting requirement(s))",
+                self.manager,
+                package,
+                self.requirements.len()
+            ),
+            FailureKind::NotFound => format!("{}: {} not found", self.manager, package),
+            FailureKind::BuildFailed => {
+                let tool = self
+                    .native
+                    .as_ref()
+                    .map(|n| n.tool.as_str())
+                    .unwrap_or("native build");
+                format!("{}: {} failed for {}", self.manager, tool, package)
+            }
+            FailureKind::Other => format!("{}: {}", self.manager, self.message),
+        }
+    }
+
+    /// Render the concise conflict summary for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = format!("Package manager failure ({}):\n", self.manager);
+        out.push_str(&format!("- {}\n", self.summary()));
+        if !self.message.is_empty() && self.kind != FailureKind::Other {
+            out.push_str(&format!("- message: {}\n", self.message));
+        }
+        if let Some(selected) = &self.selected {
+            out.push_str(&format!("- installed/selected: {}\n", selected));
+        }
+        if !self.requirements.is_empty() {
+            out.push_str("- requirements:\n");
+            for requirement in &self.requirements {
+                out.push_str(&format!("  - {}\n", requirement.render()));
+            }
+        }
+        if let Some(native) = &self.native {
+            out.push_str(&format!("- {} errors:\n", native.tool));
+            for line in &native.errors {
+                out.push_str(&format!("  {}\n", line));
+            }
+        }
+        if let Some(hint) = self.hint() {
+            out.push_str(&format!("- {}\n", hint));
+        }
+        out.trim_end().to_string()
+    }
+
+    fn hint(&self) -> Option<&'static str> {
+        let node = matches!(
+            self.manager,
+            PackageManager::Npm | PackageManager::Pnpm | PackageManager::Yarn
+        );
+        Some(match (self.manager, self.kind) {
+            (_, FailureKind::Conflict) if node => {
+                "no version satisfies every range; upgrade or replace the package with the \
+                 outdated range (an `overrides`/`resolutions` entry or --legacy-peer-deps only \
+                 hides the conflict)"
+            }
+            (PackageManager::Pip, FailureKind::Conflict) => {
+                "no version satisfies every pin; loosen one of the conflicting pins so a common \
+                 version exists"
+            }
+            (PackageManager::Cargo, FailureKind::Conflict) => {
+                "cargo allows only one semver-compatible version of a crate; relax the exact \
+                 (`=`) requirement or run `cargo update -p <crate>`"
+            }
+            (PackageManager::Go, FailureKind::NotFound) => {
+                "check the module path and version; private modules need GOPRIVATE and git \
+                 credentials"
+            }
+            (PackageManager::Go, FailureKind::Other) if self.message.contains("go.sum") => {
+                "run `go mod tidy` to add the missing go.sum entries"
+            }
+            (PackageManager::Pip, FailureKind::BuildFailed) => {
+                "the package has no prebuilt wheel for this Python/platform; install the missing \
+                 system headers or tools, or use a version with wheels"
+            }
+            (_, FailureKind::BuildFailed) if node => {
+                "node-gyp needs Python, make and a C++ compiler; a newer package version may ship \
+                 prebuilt binaries for this Node.js version"
+            }
+            (PackageManager::Cargo, FailureKind::BuildFailed) => {
+                "a build script failed, usually because a system library or its pkg-config file \
+                 is missing"
+            }
+            _ => return None,
+        })
+    }
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    npm_prefix: Regex,
+    npm_requirement: Regex,
+    npm_not_found: Regex,
+    pnpm_code: Regex,
+    pnpm_parent: Regex,
+    pnpm_unmet: Regex,
+    yarn_not_found: Regex,
+    yarn_unsatisfied: Regex,
+    pip_user_requested: Regex,
+    pip_depends_on: Regex,
+    pip_no_version: Regex,
+    pip_wheel: Regex,
+    cargo_select: Regex,
+    cargo_required_by: Regex,
+    cargo_satisfies: Regex,
+    cargo_meets: Regex,
+    cargo_selected: Regex,
+    cargo_no_package: Regex,
+    cargo_build_script: Regex,
+    go_requires: Regex,
+    go_module_version: Regex,
+    go_missing_package: Regex,
+    native_error: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        npm_prefix: Regex::new(r"^(?:npm (?:ERR!|error|warn)|gyp (?:ERR!|info|http))\s?").unwrap(),
+        npm_requirement: Regex::new(
+            r#"^(peer |peerOptional |dev |optional )?(@?[^@\s"]+)@"([^"]*)" from (.+)$"#,
+        )
+        .unwrap(),
+        npm_not_found: Regex::new(
+            r"(?:'(@?[^@\s']+)@?[^']*' is not in (?:this|the npm) registry|No matching version found for (@?[^@\s]+)@(\S+?)\.?$)",
+        )
+        .unwrap(),
+        pnpm_code: Regex::new(r"ERR_PNPM_(\w+)\s+(.+)$").unwrap(),
+        pnpm_parent: Regex::new(r"┬ (@?[^\s@]+) (\S+)$").unwrap(),
+        pnpm_unmet: Regex::new(r#"✕ unmet peer (@?[^@\s]+)@"?([^":]+?)"?: found (\S+)"#).unwrap(),
+        yarn_not_found: Regex::new(
+            r#"Couldn't find package "(@?[^@"]+)@?([^"]*)"(?: required by "([^"]+)")?"#,
+        )
+        .unwrap(),
+        yarn_unsatisfied: Regex::new(
+            r"(\S+) is listed by your project with version (\S+), which doesn't satisfy what (\S+)(?: and other dependencies)? requests? \(([^)]+)\)",
+        )
+        .unwrap(),
+        pip_user_requested: Regex::new(
+            r"^\s*The user requested (\S+?)((?:[=<>!~]=?|===).+)?$",
+        )
+        .unwrap(),
+        pip_depends_on: Regex::new(
+            r"^\s*(\S+ \S+) depends on (\S+?)((?:[=<>!~]=?|===)[^;]+)?(?:;.*)?$",
+        )
+        .unwrap(),
+        pip_no_version: Regex::new(
+            r"(?:Could not find a version that satisfies the requirement|No matching distribution found for) (\S+?)((?:[=<>!~]=?)\S+)?(?: \(|$)",
+        )
+        .unwrap(),
+        pip_wheel: Regex::new(r"(?:Failed building wheel for|Building wheel for) (\S+)").unwrap(),
+        cargo_select: Regex::new(
+            r"failed to select a version for (?:the requirement )?`([^`=\s]+)",
+        )
+        .unwrap(),
+        cargo_required_by: Regex::new(r"required by package `([^`]+)`").unwrap(),
+        cargo_satisfies: Regex::new(
+            r#"which satisfies dependency `(\S+) = "([^"]+)"` of package `([^`]+)`"#,
+        )
+        .unwrap(),
+        cargo_meets: Regex::new(r"versions that meet the requirements `([^`]+)`").unwrap(),
+        cargo_selected: Regex::new(r"previously selected package `([^`]+)`").unwrap(),
+        cargo_no_package: Regex::new(r"no matching package (?:named|found) `([^`]+)`").unwrap(),
+        cargo_build_script: Regex::new(
+            r"failed to run custom build command for `([^`]+)`",
+        )
+        .unwrap(),
+        go_requires: Regex::new(r"^(?:go: )?(\S+@\S+) requires$").unwrap(),
+        go_module_version: Regex::new(r"^\s*(?:go: )?(\S+?)@(\S+?): (.+)$").unwrap(),
+        go_missing_package: Regex::new(r"cannot find module providing package (\S+)").unwrap(),
+        native_error: Regex::new(
+            r"(?i)\berror\b[:\s]|fatal error|not found|no such file|undefined reference|command not found",
+        )
+        .unwrap(),
+    })
+}
+
+/// Check whether input contains a package manager failure
+pub fn is_package_failure(input: &str) -> bool {
+    // go prints the module that failed on an indented line below "go: ..."
+    let go = input.lines().any(|l| l.starts_with("go: "));
+    input.lines().any(|line| {
+        let line = line.trim();
+        line.starts_with("npm ERR! code ")
+            || line.starts_with("npm error code ")
+            || line.contains("ERR_PNPM_")
+            || line.starts_with("error Couldn't find package")
+            || (line.contains("YN0") && line.contains("doesn't satisfy what"))
+            || line.contains("ResolutionImpossible")
+            || line.contains("Could not find a version that satisfies the requirement")
+            || line.contains("Failed building wheel for")
+            || line.contains("failed to select a version for")
+            || line.contains("no matching package named `")
+            || line.contains("failed to run custom build command for `")
+            || (go
+                && (line.contains("cannot find module providing package")
+                    || line.contains("unknown revision")
+                    || line.contains("invalid version")
+                    || line.contains("checksum mismatch")))
+            || line.contains("missing go.sum entry for module")
+    })
+}
+
+/// Parse a package manager failure
+pub fn parse_package_failure(input: &str) -> Option<PackageFailure> {
+    let input = strip_ansi(input);
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+
+    if lines
+        .iter()
+        .any(|l| l.starts_with("npm ERR!") || l.starts_with("npm error"))
+    {
+        parse_npm(&lines)
+    } else if input.contains("ERR_PNPM_") {
+        parse_pnpm(&lines)
+    } else if input.contains("Couldn't find package") || input.contains("YN0") {
+        parse_yarn(&lines)
+    } else if input.contains("failed to select a version for")
+        || input.contains("no matching package")
+        || input.contains("failed to run custom build command")
+    {
+        parse_cargo(&lines)
+    } else if lines.iter().any(|l| l.trim_start().starts_with("go: "))
+        || input.contains("missing go.sum entry")
+    {
+        parse_go(&lines)
+    } else {
+        parse_pip(&lines)
+    }
+}
+
+fn parse_npm(lines: &[&str]) -> Option<PackageFailure> {
+    let p = patterns();
+    let body: Vec<String> = lines
+        .iter()
+        .map(|l| {
+            // gyp lines are nested inside npm ERR! lines
+            let once = p.npm_prefix.replace(l, "");
+            p.npm_prefix.replace(&once, "").into_owned()
+        })
+        .collect();
+
+    let code = body
+        .iter()
+        .find_map(|l| l.strip_prefix("code ").map(str::to_string))
+        .unwrap_or_default();
+    let is_gyp = lines.iter().any(|l| l.contains("gyp ERR!"));
+
+    let kind = match code.as_str() {
+        "ERESOLVE" => FailureKind::Conflict,
+        "E404" | "ETARGET" => FailureKind::NotFound,
+        _ if is_gyp => FailureKind::BuildFailed,
+        _ => FailureKind::Other,
+    };
+    let mut failure = PackageFailure::new(PackageManager::Npm, kind);
+    failure.message = body
+        .iter()
+        .find(|l| !l.is_empty() && !l.starts_with("code ") && !l.starts_with("errno "))
+        .cloned()
+        .unwrap_or_else(|| code.clone());
+
+    let mut in_could_not_resolve = false;
+    for line in &body {
+        let trimmed = line.trim();
+        if let Some(found) = trimmed.strip_prefix("Found: ") {
+            failure.selected = Some(found.to_string());
+            if failure.package.is_none() {
+                failure.package = found.rsplit_once('@').map(|(name, _)| name.to_string());
+            }
+        } else if trimmed.starts_with("Could not resolve dependency:")
+            || trimmed.starts_with("Conflicting peer dependency:")
+        {
+            in_could_not_resolve = true;
+        } else if let Some(caps) = p.npm_requirement.captures(trimmed) {
+            // Only the top-level lines of each block are requirements; the
+            // indented ones repeat how the requiring package got installed
+            if line.starts_with("  ") && in_could_not_resolve {
+                continue;
+            }
+            failure.add_requirement(Requirement {
+                package: caps[2].to_string(),
+                constraint: Some(caps[3].to_string()),
+                required_by: Some(caps[4].to_string()),
+                peer: caps.get(1).is_some_and(|m| m.as_str().starts_with("peer")),
+            });
+        } else if let Some(caps) = p.npm_not_found.captures(trimmed) {
+            match (caps.get(1), caps.get(2)) {
+                (Some(name), _) => failure.package = Some(name.as_str().to_string()),
+                (None, Some(name)) => {
+                    failure.package = Some(name.as_str().to_string());
+                    failure.add_requirement(Requirement {
+                        package: name.as_str().to_string(),
+                        constraint: caps.get(3).map(|m| m.as_str().to_string()),
+                        required_by: None,
+                        peer: false,
+                    });
+                }
+                _ => {}
+            }
+        } else if let Some(name) = trimmed.strip_prefix("404 Not Found - GET ") {
+            if failure.package.is_none() {
+                failure.package = name
+                    .split_whitespace()
+                    .next()
+                    .and_then(|url| url.rsplit_once(".org/"))
+                    .map(|(_, pkg)| pkg.replace("%2f", "/").replace("%2F", "/"));
+            }
+        }
+    }
+
+    if kind == FailureKind::BuildFailed {
+        if let Some(command) = body
+            .iter()
+            .find_map(|l| l.strip_prefix("command ").filter(|c| *c != "failed"))
+        {
+            failure.message = format!("`{}` failed", command);
+        }
+        let package = body
+            .iter()
+            .find_map(|l| l.strip_prefix("path "))
+            .and_then(|path| path.rsplit_once("node_modules/"))
+            .map(|(_, pkg)| pkg.trim().to_string());
+        failure.package = package.clone();
+        failure.native = Some(NativeBuildError {
+            tool: "node-gyp".to_string(),
+            package,
+            errors: native_errors(body.iter().map(String::as_str)),
+        });
+    }
+
+    // The conflicting package is the one every requirement is about
+    if failure.package.is_none() {
+        failure.package = failure.requirements.first().map(|r| r.package.clone());
+    }
+    Some(failure)
+}
+
+fn parse_pnpm(lines: &[&str]) -> Option<PackageFailure> {
+    let p = patterns();
+    let (code, message) = lines
+        .iter()
+        .find_map(|l| p.pnpm_code.captures(l))
+        .map(|c| (c[1].to_string(), c[2].trim().to_string()))?;
+
+    let kind = match code.as_str() {
+        "PEER_DEP_ISSUES" => FailureKind::Conflict,
+        "NO_MATCHING_VERSION" | "FETCH_404" => FailureKind::NotFound,
+        _ if lines.iter().any(|l| l.contains("gyp ERR!")) => FailureKind::BuildFailed,
+        _ => FailureKind::Other,
+    };
+    let mut failure = PackageFailure::new(PackageManager::Pnpm, kind);
+    failure.message = message.clone();
+
+    let mut parent = None;
+    for line in lines {
+        if let Some(caps) = p.pnpm_parent.captures(line) {
+            parent = Some(format!("{}@{}", &caps[1], &caps[2]));
+        } else if let Some(caps) = p.pnpm_unmet.captures(line) {
+            failure.selected = Some(format!("{}@{}", &caps[1], &caps[3]));
+            failure.add_requirement(Requirement {
+                package: caps[1].to_string(),
+                constraint: Some(caps[2].to_string()),
+                required_by: parent.clone(),
+                peer: true,
+            });
+        }
+    }
+
+    if kind == FailureKind::NotFound {
+        failure.package = message
+            .rsplit(' ')
+            .next()
+            .and_then(|spec| spec.rsplit_once('@').map(|(name, _)| name.to_string()));
+    }
+    if kind == FailureKind::BuildFailed {
+        failure.native = Some(NativeBuildError {
+            tool: "node-gyp".to_string(),
+            package: None,
+            errors: native_errors(lines.iter().copied()),
+        });
+    }
+    if failure.package.is_none() {
+        failure.package = failure.requirements.first().map(|r| r.package.clone());
+    }
+    Some(failure)
+}
+
+fn parse_yarn(lines: &[&str]) -> Option<PackageFailure> {
+    let p = patterns();
+    for line in lines {
+        if let Some(caps) = p.yarn_not_found.captures(line) {
+            let mut failure = PackageFailure::new(PackageManager::Yarn, FailureKind::NotFound);
+            failure.package = Some(caps[1].to_string());
+            failure.message = line.trim_start_matches("error ").to_string();
+            failure.add_requirement(Requirement {
+                package: caps[1].to_string(),
+                constraint: Some(caps[2].to_string()).filter(|c| !c.is_empty()),
+                required_by: caps.get(3).map(|m| m.as_str().to_string()),
+                peer: false,
+            });
+            return Some(failure);
+        }
+    }
+
+    let mut failure = PackageFailure::new(PackageManager::Yarn, FailureKind::Conflict);
+    for line in lines {
+        if let Some(caps) = p.yarn_unsatisfied.captures(line) {
+            failure.package.get_or_insert_with(|| caps[1].to_string());
+            failure.selected = Some(format!("{}@{}", &caps[1], &caps[2]));
+            failure.message = caps[0].to_string();
+            failure.add_requirement(Requirement {
+                package: caps[1].to_string(),
+                constraint: Some(caps[4].to_string()),
+                required_by: Some(caps[3].to_string()),
+                peer: true,
+            });
+        }
+    }
+    (!failure.requirements.is_empty()).then_some(failure)
+}
+
+fn parse_pip(lines: &[&str]) -> Option<PackageFailure> {
+    let p = patterns();
+
+    // Wheel build failures: the subprocess output sits between
+    // "╰─> [N lines of output]" and "[end of output]"
+    if let Some(package) = lines
+        .iter()
+        .find(|l| l.contains("Failed building wheel for") || l.contains("did not run successfully"))
+        .and_then(|_| lines.iter().find_map(|l| p.pip_wheel.captures(l)))
+        .map(|c| c[1].to_string())
+    {
+        let start = lines
+            .iter()
+            .position(|l| l.contains("lines of output]"))
+            .map(|i| i + 1)
+            .unwrap_or(0);
+        let end = lines[start..]
+            .iter()
+            .position(|l| l.contains("[end of output]"))
+            .map(|i| start + i)
+            .unwrap_or(lines.len());
+
+        let mut failure = PackageFailure::new(PackageManager::Pip, FailureKind::BuildFailed);
+        failure.package = Some(package.clone());
+        failure.message = lines
+            .iter()
+            .find(|l| l.contains("Failed building wheel for") || l.contains("× "))
+            .map(|l| l.trim().trim_start_matches("ERROR: ").to_string())
+            .unwrap_or_default();
+        failure.native = Some(NativeBuildError {
+            tool: "wheel build".to_string(),
+            package: Some(package),
+            errors: native_errors(lines[start..end].iter().copied()),
+        });
+        return Some(failure);
+    }
+
+    if lines
+        .iter()
+        .any(|l| l.contains("ResolutionImpossible") || l.contains("conflicting dependencies"))
+    {
+        let mut failure = PackageFailure::new(PackageManager::Pip, FailureKind::Conflict);
+        failure.message = lines
+            .iter()
+            .find(|l| l.contains("Cannot install") || l.contains("ResolutionImpossible"))
+            .map(|l| l.trim().trim_start_matches("ERROR: ").to_string())
+            .unwrap_or_default();
+        for line in lines {
+            if let Some(caps) = p.pip_user_requested.captures(line) {
+                failure.add_requirement(Requirement {
+                    package: caps[1].to_string(),
+                    constraint: caps.get(2).map(|m| m.as_str().to_string()),
+                    required_by: Some("the user".to_string()),
+                    peer: false,
+                });
+            } else if let Some(caps) = p.pip_depends_on.captures(line) {
+                failure.add_requirement(Requirement {
+                    package: caps[2].to_string(),
+                    constraint: caps.get(3).map(|m| m.as_str().trim().to_string()),
+                    required_by: Some(caps[1].to_string()),
+                    peer: false,
+                });
+            }
+        }
+        failure.package = most_required(&failure.requirements);
+        return Some(failure);
+    }
+
+    let caps = lines.iter().find_map(|l| p.pip_no_version.captures(l))?;
+    let mut failure = PackageFailure::new(PackageManager::Pip, FailureKind::NotFound);
+    failure.package = Some(caps[1].to_string());
+    failure.message = lines
+        .iter()
+        .find(|l| l.contains("Could not find a version"))
+        .map(|l| l.trim().trim_start_matches("ERROR: ").to_string())
+        .unwrap_or_default();
+    failure.add_requirement(Requirement {
+        package: caps[1].to_string(),
+        constraint: caps.get(2).map(|m| m.as_str().to_string()),
+        required_by: None,
+        peer: false,
+    });
+    Some(failure)
+}
+
+fn parse_cargo(lines: &[&str]) -> Option<PackageFailure> {
+    let p = patterns();
+
+    if let Some(caps) = lines.iter().find_map(|l| p.cargo_build_script.captures(l)) {
+        let crate_name = caps[1].to_string();
+        let stderr: Vec<&str> = lines
+            .iter()
+            .skip_while(|l| !l.trim().starts_with("--- stderr"))
+            .skip(1)
+            .copied()
+            .collect();
+        let mut failure = PackageFailure::new(PackageManager::Cargo, FailureKind::BuildFailed);
+        failure.package = Some(crate_name.clone());
+        failure.message = caps[0].to_string();
+        failure.native = Some(NativeBuildError {
+            tool: "build script".to_string(),
+            package: Some(crate_name),
+            errors: native_errors(stderr.into_iter()),
+        });
+        return Some(failure);
+    }
+
+    if let Some(caps) = lines.iter().find_map(|l| p.cargo_no_package.captures(l)) {
+        let mut failure = PackageFailure::new(PackageManager::Cargo, FailureKind::NotFound);
+        failure.package = Some(caps[1].to_string());
+        failure.message = caps[0].to_string();
+        failure.add_requirement(Requirement {
+            package: caps[1].to_string(),
+            constraint: None,
+            required_by: lines
+                .iter()
+                .find_map(|l| p.cargo_required_by.captures(l))
+                .map(|c| c[1].to_string()),
+            peer: false,
+        });
+        return Some(failure);
+    }
+
+    let caps = lines.iter().find_map(|l| p.cargo_select.captures(l))?;
+    let package = caps[1].to_string();
+    let mut failure = PackageFailure::new(PackageManager::Cargo, FailureKind::Conflict);
+    failure.message = lines
+        .iter()
+        .find(|l| l.contains("failed to select a version for"))
+        .map(|l| l.trim().trim_start_matches("error: ").to_string())
+        .unwrap_or_default();
+
+    let mut required_by = None;
+    for line in lines {
+        if let Some(caps) = p.cargo_required_by.captures(line) {
+            required_by = Some(caps[1].to_string());
+        } else if let Some(caps) = p.cargo_meets.captures(line) {
+            failure.add_requirement(Requirement {
+                package: package.clone(),
+                constraint: Some(caps[1].to_string()),
+                required_by: required_by.clone(),
+                peer: false,
+            });
+        } else if let Some(caps) = p.cargo_selected.captures(line) {
+            failure.selected = Some(caps[1].to_string());
+        } else if let Some(caps) = p.cargo_satisfies.captures(line) {
+            if caps[1] == package {
+                failure.add_requirement(Requirement {
+                    package: caps[1].to_string(),
+                    constraint: Some(caps[2].to_string()),
+                    required_by: Some(caps[3].to_string()),
+                    peer: false,
+                });
+            }
+        }
+    }
+    failure.package = Some(package);
+    Some(failure)
+}
+
+fn parse_go(lines: &[&str]) -> Option<PackageFailure> {
+    let p = patterns();
+    let mut failure = PackageFailure::new(PackageManager::Go, FailureKind::Other);
+    let mut requiring = None;
+
+    for line in lines {
+        let trimmed = line.trim();
+        if let Some(caps) = p.go_requires.captures(trimmed) {
+            requiring = Some(caps[1].to_string());
+            continue;
+        }
+        if let Some(caps) = p.go_missing_package.captures(trimmed) {
+            failure.kind = FailureKind::NotFound;
+            failure.package = Some(caps[1].to_string());
+            failure.message = trimmed.trim_start_matches("go: ").to_string();
+            continue;
+        }
+        if trimmed.contains("missing go.sum entry") || trimmed.contains("checksum mismatch") {
+            failure.message = trimmed.trim_start_matches("go: ").to_string();
+            continue;
+        }
+        if let Some(caps) = p.go_module_version.captures(trimmed) {
+            let message = caps[3].to_string();
+            let not_found = message.contains("unknown revision")
+                || message.contains("invalid version")
+                || message.contains("not found")
+                || message.contains("no matching versions");
+            if !not_found {
+                continue;
+            }
+            failure.kind = FailureKind::NotFound;
+            failure.package = Some(caps[1].to_string());
+            failure.message = trimmed.trim_start_matches("go: ").to_string();
+            failure.add_requirement(Requirement {
+                package: caps[1].to_string(),
+                constraint: Some(caps[2].to_string()),
+                required_by: requiring.take(),
+                peer: false,
+            });
+        }
+    }
+
+    (!failure.message.is_empty()).then_some(failure)
+}
+
+/// Compiler and tool errors from native build output
+fn native_errors<'a>(lines: impl Iterator<Item = &'a str>) -> Vec<String> {
+    let p = patterns();
+    let mut errors: Vec<String> = Vec::new();
+    for line in lines {
+        let line = line.trim();
+        if line.is_empty() || !p.native_error.is_match(line) {
+            continue;
+        }
+        // Skip the package managers' own summary lines
+        if line.starts_with("error: subprocess-exited-with-error")
+            || line.starts_with("ERROR: Failed building wheel")
+            || line.contains("not ok")
+        {
+            continue;
+        }
+        if !errors.iter().any(|e| e == line) {
+            errors.push(line.to_string());
+        }
+        if errors.len() == MAX_NATIVE_LINES {
+            break;
+        }
+    }
+    errors
+}
+
+/// The package named by the most requirements
+fn most_required(requirements: &[Requirement]) -> Option<String> {
+    let mut best: Option<(&str, usize)> = None;
+    for requirement in requirements {
+        let name = requirement.package.as_str();
+        let count = requirements
+            .iter()
+            .filter(|r| r.package.eq_ignore_ascii_case(name))
+            .count();
+        match best {
+            Some((_, most)) if most >= count => {}
+            _ => best = Some((name, count)),
+        }
+    }
+    best.map(|(name, _)| name.to_string())
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parses_npm_eresolve() {
+        let input = r#"npm ERR! code ERESOLVE
+npm ERR! ERESOLVE unable to resolve dependency tree
+npm ERR!
+npm ERR! While resolving: shop@1.0.0
+npm ERR! Found: react@18.2.0
+npm ERR! node_modules/react
+npm ERR!   react@"^18.2.0" from the root project
+npm ERR!
+npm ERR! Could not resolve dependency:
+npm ERR! peer react@"^16.8.5 || ^17.0.0" from react-beautiful-dnd@13.1.1
+npm ERR! node_modules/react-beautiful-dnd
+npm ERR!   react-beautiful-dnd@"^13.1.1" from the root project
+"#;
+
+        assert!(is_package_failure(input));
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.manager, PackageManager::Npm);
+        assert_eq!(failure.kind, FailureKind::Conflict);
+        assert_eq!(failure.package.as_deref(), Some("react"));
+        assert_eq!(failure.selected.as_deref(), Some("react@18.2.0"));
+        assert_eq!(failure.requirements.len(), 2);
+        assert_eq!(
+            failure.requirements[1].render(),
+            "react-beautiful-dnd@13.1.1 requires react ^16.8.5 || ^17.0.0 as a peer"
+        );
+
+        let summary = failure.prompt_summary();
+        assert!(summary.contains("- npm: cannot resolve react (2 conflicting requirement(s))"));
+        assert!(summary.contains("  - the root project requires react ^18.2.0"));
+    }
+
+    #[test]
+    fn test_parses_node_gyp_failure() {
+        let input = "npm error code 1
+npm error path /app/node_modules/bcrypt
+npm error command failed
+npm error command sh -c node-gyp rebuild
+npm error gyp info spawn make
+npm error ../src/bcrypt_node.cc:1:10: fatal error: node.h: No such file or directory
+npm error gyp ERR! build error
+npm error gyp ERR! stack Error: `make` failed with exit code: 2";
+
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.kind, FailureKind::BuildFailed);
+        assert_eq!(failure.package.as_deref(), Some("bcrypt"));
+        let native = failure.native.as_ref().unwrap();
+        assert_eq!(native.tool, "node-gyp");
+        assert_eq!(
+            native.errors[0],
+            "../src/bcrypt_node.cc:1:10: fatal error: node.h: No such file or directory"
+        );
+    }
+
+    #[test]
+    fn test_parses_pnpm_peer_issues() {
+        let input = r#" ERR_PNPM_PEER_DEP_ISSUES  Unmet peer dependencies
+
+.
+└─┬ react-beautiful-dnd 13.1.1
+  └── ✕ unmet peer react@"^16.8.5 || ^17.0.0": found 18.2.0
+"#;
+
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.manager, PackageManager::Pnpm);
+        assert_eq!(failure.kind, FailureKind::Conflict);
+        assert_eq!(failure.selected.as_deref(), Some("react@18.2.0"));
+        assert_eq!(
+            failure.requirements[0].required_by.as_deref(),
+            Some("react-beautiful-dnd@13.1.1")
+        );
+    }
+
+    #[test]
+    fn test_parses_yarn_missing_package() {
+        let input = r#"error Couldn't find package "left-padd@^1.3.0" required by "shop@1.0.0" on the "npm" registry."#;
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.manager, PackageManager::Yarn);
+        assert_eq!(failure.kind, FailureKind::NotFound);
+        assert_eq!(failure.package.as_deref(), Some("left-padd"));
+        assert_eq!(
+            failure.requirements[0].required_by.as_deref(),
+            Some("shop@1.0.0")
+        );
+    }
+
+    #[test]
+    fn test_parses_pip_resolution_impossible() {
+        let input = "\
+ERROR: Cannot install -r requirements.txt (line 2) and requests==2.20.0 because these package versions have conflicting dependencies.
+
+The conflict is caused by:
+    The user requested requests==2.20.0
+    httpx-helper 1.4.0 depends on requests>=2.25
+
+To fix this you could try to:
+1. loosen the range of package versions you've specified
+2. remove package versions to allow pip attempt to solve the dependency conflict
+
+ERROR: ResolutionImpossible: for help visit https://pip.pypa.io/en/latest/topics/dependency-resolution/";
+
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.manager, PackageManager::Pip);
+        assert_eq!(failure.kind, FailureKind::Conflict);
+        assert_eq!(failure.package.as_deref(), Some("requests"));
+        assert_eq!(failure.requirements.len(), 2);
+        assert_eq!(
+            failure.requirements[1].render(),
+            "httpx-helper 1.4.0 requires requests >=2.25"
+        );
+    }
+
+    #[test]
+    fn test_parses_pip_wheel_failure() {
+        let input = "\
+  Building wheel for psycopg2 (pyproject.toml) ... error
+  error: subprocess-exited-with-error
+
+  × Building wheel for psycopg2 (pyproject.toml) did not run successfully.
+  │ exit code: 1
+  ╰─> [4 lines of output]
+      running build_ext
+      Error: pg_config executable not found.
+      pg_config is required to build psycopg2 from source.
+      [end of output]
+
+  note: This error originates from a subprocess, and is likely not a problem with pip.
+  ERROR: Failed building wheel for psycopg2";
+
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.kind, FailureKind::BuildFailed);
+        assert_eq!(failure.package.as_deref(), Some("psycopg2"));
+        assert_eq!(
+            failure.native.as_ref().unwrap().errors,
+            vec!["Error: pg_config executable not found."]
+        );
+    }
+
+    #[test]
+    fn test_parses_cargo_version_conflict() {
+        let input = "\
+    Updating crates.io index
+error: failed to select a version for `serde`.
+    ... required by package `serde_json v1.0.108`
+    ... which satisfies dependency `serde_json = \"^1.0.108\"` of package `app v0.1.0 (/src/app)`
+versions that meet the requirements `^1.0.190` are: 1.0.193, 1.0.192, 1.0.190
+
+all possible versions conflict with previously selected packages.
+
+  previously selected package `serde v1.0.150`
+    ... which satisfies dependency `serde = \"=1.0.150\"` of package `app v0.1.0 (/src/app)`
+
+failed to select a version for `serde` which could resolve this conflict";
+
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.manager, PackageManager::Cargo);
+        assert_eq!(failure.kind, FailureKind::Conflict);
+        assert_eq!(failure.package.as_deref(), Some("serde"));
+        assert_eq!(failure.selected.as_deref(), Some("serde v1.0.150"));
+        assert_eq!(failure.requirements.len(), 2);
+        assert_eq!(
+            failure.requirements[0].render(),
+            "serde_json v1.0.108 requires serde ^1.0.190"
+        );
+        assert_eq!(
+            failure.requirements[1].render(),
+            "app v0.1.0 (/src/app) requires serde =1.0.150"
+        );
+    }
+
+    #[test]
+    fn test_parses_go_module_errors() {
+        let input = "\
+go: github.com/acme/api@v1.4.0 requires
+\tgithub.com/acme/proto@v0.9.9: reading github.com/acme/proto/go.mod at revision v0.9.9: unknown revision v0.9.9";
+
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.manager, PackageManager::Go);
+        assert_eq!(failure.kind, FailureKind::NotFound);
+        assert_eq!(failure.package.as_deref(), Some("github.com/acme/proto"));
+        assert_eq!(
+            failure.requirements[0].render(),
+            "github.com/acme/api@v1.4.0 requires github.com/acme/proto v0.9.9"
+        );
+
+        let input = "main.go:5:2: missing go.sum entry for module providing package github.com/google/uuid (imported by example.com/app); to add:\n\tgo get example.com/app";
+        assert!(is_package_failure(input));
+        let failure = parse_package_failure(input).unwrap();
+        assert_eq!(failure.kind, FailureKind::Other);
+        assert!(failure.prompt_summary().contains("go mod tidy"));
+    }
+}
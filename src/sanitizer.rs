@@ -0,0 +1,992 @@
+//! Sanitizer and Valgrind report parsing.
+//!
+//! AddressSanitizer, ThreadSanitizer, UndefinedBehaviorSanitizer,
+//! MemorySanitizer, LeakSanitizer and Valgrind memcheck print several stacks
+//! per report: the bad access, where the memory was allocated and freed, the
+//! racing access, where an uninitialized value came from. This module keeps
+//! each stack separate and labels it with its role, so the explanation can
+//! describe how they relate instead of seeing one long list of frames.
+
+use regex::Regex;
+use serde::Serialize;
+use std::fmt;
+use std::path::PathBuf;
+use std::sync::OnceLock;
+
+use crate::stack_trace::StackFrame;
+
+/// Maximum frames per stack included in the prompt summary
+const PROMPT_FRAMES_PER_STACK: usize = 4;
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// The tool that produced a report
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SanitizerTool {
+    Address,
+    Thread,
+    UndefinedBehavior,
+    Memory,
+    Leak,
+    Valgrind,
+}
+
+impl SanitizerTool {
+    fn from_name(name: &str) -> Option<Self> {
+        match name {
+            "AddressSanitizer" | "HWAddressSanitizer" => Some(SanitizerTool::Address),
+            "ThreadSanitizer" => Some(SanitizerTool::Thread),
+            "UndefinedBehaviorSanitizer" => Some(SanitizerTool::UndefinedBehavior),
+            "MemorySanitizer" => Some(SanitizerTool::Memory),
+            "LeakSanitizer" => Some(SanitizerTool::Leak),
+            _ => None,
+        }
+    }
+}
+
+impl fmt::Display for SanitizerTool {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            SanitizerTool::Address => write!(f, "AddressSanitizer"),
+            SanitizerTool::Thread => write!(f, "ThreadSanitizer"),
+            SanitizerTool::UndefinedBehavior => write!(f, "UndefinedBehaviorSanitizer"),
+            SanitizerTool::Memory => write!(f, "MemorySanitizer"),
+            SanitizerTool::Leak => write!(f, "LeakSanitizer"),
+            SanitizerTool::Valgrind => write!(f, "Valgrind"),
+        }
+    }
+}
+
+/// What a stack in a report represents
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum StackRole {
+    /// The access or operation that triggered the report
+    Access,
+    /// The earlier conflicting access in a data race
+    PreviousAccess,
+    /// Where the memory involved was allocated
+    Allocation,
+    /// Where the memory involved was freed
+    Free,
+    /// Where a thread involved in the report was started
+    ThreadCreation,
+    /// Where an uninitialized value was created or passed along
+    Origin,
+    /// Any other stack the tool printed (mutexes, stack locations, ...)
+    Other,
+}
+
+impl fmt::Display for StackRole {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            StackRole::Access => write!(f, "access"),
+            StackRole::PreviousAccess => write!(f, "previous access"),
+            StackRole::Allocation => write!(f, "allocation"),
+            StackRole::Free => write!(f, "free"),
+            StackRole::ThreadCreation => write!(f, "thread creation"),
+            StackRole::Origin => write!(f, "origin"),
+            StackRole::Other => write!(f, "other"),
+        }
+    }
+}
+
+/// One labelled stack from a report
+#[derive(Debug, Clone, Serialize)]
+pub struct SanitizerStack {
+    /// What this stack represents
+    pub role: StackRole,
+    /// The header line the tool printed above the stack
+    pub description: String,
+    /// Frames, innermost first
+    pub frames: Vec<StackFrame>,
+}
+
+/// A leaked allocation reported by LeakSanitizer or Valgrind
+#[derive(Debug, Clone, Serialize)]
+pub struct LeakRecord {
+    /// Leak category ("direct", "indirect", "definitely lost", ...)
+    pub kind: String,
+    /// Number of bytes leaked
+    pub bytes: u64,
+    /// Number of objects (blocks) leaked
+    pub objects: u64,
+    /// Allocation stack, innermost first
+    pub frames: Vec<StackFrame>,
+}
+
+impl LeakRecord {
+    /// Whether the memory is actually lost (not merely still reachable)
+    pub fn is_lost(&self) -> bool {
+        self.kind != "still reachable"
+    }
+}
+
+/// A structured sanitizer or Valgrind report
+#[derive(Debug, Clone, Serialize)]
+pub struct SanitizerReport {
+    /// The tool that produced the report
+    pub tool: SanitizerTool,
+    /// Short error kind (e.g., "heap-use-after-free", "data race")
+    pub error_kind: String,
+    /// The full error line from the report header
+    pub message: String,
+    /// Every stack in the first report, in the order printed
+    pub stacks: Vec<SanitizerStack>,
+    /// Leaked allocations
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub leaks: Vec<LeakRecord>,
+    /// Address descriptions and hints printed between stacks
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub notes: Vec<String>,
+    /// The tool's SUMMARY line, if printed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub summary: Option<String>,
+    /// Number of further reports in the same output that were not parsed
+    pub additional_reports: usize,
+}
+
+impl SanitizerReport {
+    fn new(tool: SanitizerTool, error_kind: impl Into<String>, message: impl Into<String>) -> Self {
+        Self {
+            tool,
+            error_kind: error_kind.into(),
+            message: message.into(),
+            stacks: Vec::new(),
+            leaks: Vec::new(),
+            notes: Vec::new(),
+            summary: None,
+            additional_reports: 0,
+        }
+    }
+
+    /// Get the first stack with the given role
+    pub fn stack(&self, role: StackRole) -> Option<&SanitizerStack> {
+        self.stacks.iter().find(|s| s.role == role)
+    }
+
+    /// Frames that best represent the report: the access stack, otherwise the
+    /// first leak, otherwise the first stack printed
+    pub fn primary_frames(&self) -> &[StackFrame] {
+        if let Some(stack) = self.stack(StackRole::Access) {
+            return &stack.frames;
+        }
+        if let Some(leak) = self.leaks.first() {
+            return &leak.frames;
+        }
+        self.stacks
+            .first()
+            .map(|s| s.frames.as_slice())
+            .unwrap_or(&[])
+    }
+
+    /// Total bytes definitely or indirectly lost
+    pub fn leaked_bytes(&self) -> u64 {
+        self.leaks
+            .iter()
+            .filter(|l| l.is_lost())
+            .map(|l| l.bytes)
+            .sum()
+    }
+
+    /// Render the report as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = format!("{} report: {}\n", self.tool, self.error_kind);
+
+        for stack in &self.stacks {
+            out.push_str(&format!(
+                "- {} stack ({}): {}\n",
+                stack.role,
+                stack.description,
+                summarize_frames(&stack.frames)
+            ));
+        }
+
+        if !self.leaks.is_empty() {
+            let lost: Vec<&LeakRecord> = self.leaks.iter().filter(|l| l.is_lost()).collect();
+            out.push_str(&format!(
+                "- leaks: {} byte(s) lost in {} record(s)\n",
+                self.leaked_bytes(),
+                lost.len()
+            ));
+            for leak in lost.iter().take(3) {
+                out.push_str(&format!(
+                    "  - {} leak of {} byte(s) in {} object(s) allocated at: {}\n",
+                    leak.kind,
+                    leak.bytes,
+                    leak.objects,
+                    summarize_frames(&leak.frames)
+                ));
+            }
+        }
+
+        for note in self.notes.iter().take(3) {
+            out.push_str(&format!("- note: {}\n", note));
+        }
+
+        if self.additional_reports > 0 {
+            out.push_str(&format!(
+                "- {} further report(s) omitted\n",
+                self.additional_reports
+            ));
+        }
+
+        out.push_str(&format!("How the stacks relate: {}", self.relationship()));
+        out
+    }
+
+    /// Explain how the stacks in this report relate to each other
+    fn relationship(&self) -> &'static str {
+        let kind = self.error_kind.to_lowercase();
+        let message = self.message.to_lowercase();
+        let has_free = self.stack(StackRole::Free).is_some();
+
+        if self.tool == SanitizerTool::Thread && kind.contains("data race") {
+            "the access stack and the previous access stack touched the same memory from \
+             different threads without synchronization; at least one of them is a write. \
+             The thread creation stacks show where those threads were started."
+        } else if kind.contains("lock-order-inversion") || kind.contains("deadlock") {
+            "two threads acquire the same mutexes in opposite orders; the mutex stacks show \
+             where each lock was taken, so one acquisition order must change."
+        } else if kind.contains("double-free") || kind.contains("invalid free") {
+            "the block was already released at the free stack and is released again at the \
+             access stack."
+        } else if kind.contains("use-after-free")
+            || (has_free && self.stack(StackRole::Access).is_some())
+        {
+            "the memory was allocated at the allocation stack, released at the free stack, \
+             and then used again at the access stack, so something still holds a dangling \
+             pointer after the free."
+        } else if kind.contains("overflow")
+            || kind.contains("out-of-bounds")
+            || message.contains("after a block")
+        {
+            "the access stack reads or writes outside the bounds of the buffer created at \
+             the allocation stack (or on the stack frame of the access)."
+        } else if kind.contains("uninitiali") || message.contains("uninitialised") {
+            "the value used at the access stack was never initialized; the origin stack \
+             shows where that value was created."
+        } else if !self.leaks.is_empty() && self.stack(StackRole::Access).is_none() {
+            "each leak stack shows where memory was allocated but never freed; direct leaks \
+             are unreachable blocks, indirect leaks are only reachable through other leaked \
+             blocks, so fixing the direct leaks usually fixes the rest."
+        } else if self.tool == SanitizerTool::UndefinedBehavior {
+            "the operation at the access stack has undefined behavior in C/C++; the program \
+             may appear to work but the compiler is free to do anything there."
+        } else if kind.contains("segv") || kind.contains("signal") {
+            "the access stack shows where the process touched memory it does not own, \
+             usually a null, freed, or garbage pointer."
+        } else {
+            "the access stack is where the tool detected the problem; the other stacks \
+             show where the memory or threads involved came from."
+        }
+    }
+}
+
+/// Compact "func (file:line) <- func (file:line)" rendering of a stack
+fn summarize_frames(frames: &[StackFrame]) -> String {
+    if frames.is_empty() {
+        return "no frames".to_string();
+    }
+
+    // Prefer user frames, falling back to the raw top of stack
+    let user: Vec<&StackFrame> = frames.iter().filter(|f| f.is_user_code).collect();
+    let chosen: Vec<&StackFrame> = if user.is_empty() {
+        frames.iter().take(PROMPT_FRAMES_PER_STACK).collect()
+    } else {
+        user.into_iter().take(PROMPT_FRAMES_PER_STACK).collect()
+    };
+
+    chosen
+        .iter()
+        .map(|f| {
+            let function = f.function.as_deref().unwrap_or("??");
+            match (&f.file, f.line) {
+                (Some(file), Some(line)) => format!("{} ({}:{})", function, file.display(), line),
+                (Some(file), None) => format!("{} ({})", function, file.display()),
+                _ => function.to_string(),
+            }
+        })
+        .collect::<Vec<_>>()
+        .join(" <- ")
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    pid_prefix: Regex,
+    header: Regex,
+    ubsan: Regex,
+    summary: Regex,
+    sanitizer_frame: Regex,
+    module_suffix: Regex,
+    location: Regex,
+    valgrind_frame: Regex,
+    valgrind_error: Regex,
+    access: Regex,
+    previous_access: Regex,
+    freed_by: Regex,
+    allocated_by: Regex,
+    thread_created: Regex,
+    origin: Regex,
+    leak: Regex,
+    valgrind_leak: Regex,
+    note: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        pid_prefix: Regex::new(r"^==\d+==").unwrap(),
+        header: Regex::new(r"^(?:ERROR|WARNING): (\w+Sanitizer): (.+)$").unwrap(),
+        ubsan: Regex::new(r"^(.+?):(\d+):(\d+): runtime error: (.+)$").unwrap(),
+        summary: Regex::new(r"^SUMMARY: (.+)$").unwrap(),
+        sanitizer_frame: Regex::new(r"^#\d+\s+(?:0x[0-9a-fA-F]+\s+)?(?:in\s+)?(.+)$").unwrap(),
+        module_suffix: Regex::new(r"\s*\(([^()\s]+)\+0x[0-9a-fA-F]+\)$").unwrap(),
+        location: Regex::new(r"^(.+?):(\d+)(?::(\d+))?$").unwrap(),
+        valgrind_frame: Regex::new(
+            r"^(?:at|by) 0x[0-9A-Fa-f]+: (.+?) \((?:in ([^)]+)|([^():]+):(\d+))\)$",
+        )
+        .unwrap(),
+        valgrind_error: Regex::new(
+            r"^(?:Invalid (?:read|write) of size \d+|Invalid free\(\).*|Mismatched free\(\).*|Conditional jump or move depends on uninitialised value\(s\)|Use of uninitialised value of size \d+|Syscall param .+ points to (?:unaddressable|uninitialised) byte\(s\)|Source and destination overlap in .+|Process terminating with default action of signal .+)$",
+        )
+        .unwrap(),
+        access: Regex::new(r"(?i)^(?:atomic )?(?:read|write) of size \d+ at ").unwrap(),
+        previous_access: Regex::new(r"(?i)^previous (?:atomic )?(?:read|write) of size").unwrap(),
+        freed_by: Regex::new(
+            r"^freed by thread .+ here:$|^Address 0x[0-9a-fA-F]+ is .+ block of size \d+ free'd$",
+        )
+        .unwrap(),
+        allocated_by: Regex::new(
+            r"^(?:previously )?allocated by thread .+ here:$|^Location is heap block .+:$|^Block was alloc'd at$|^Address 0x[0-9a-fA-F]+ is .+ block of size \d+ alloc'd$",
+        )
+        .unwrap(),
+        thread_created: Regex::new(r"^Thread T\d+ .*created by .+ (?:here|at):$").unwrap(),
+        origin: Regex::new(r"^Uninitiali[sz]ed value was (?:created|stored)").unwrap(),
+        leak: Regex::new(
+            r"^(Direct|Indirect) leak of (\d+) byte\(s\) in (\d+) object\(s\) allocated from:$",
+        )
+        .unwrap(),
+        valgrind_leak: Regex::new(
+            r"^([\d,]+)(?: \([\d,]+ direct, [\d,]+ indirect\))? bytes in ([\d,]+) blocks are (definitely lost|indirectly lost|possibly lost|still reachable) in loss record",
+        )
+        .unwrap(),
+        note: Regex::new(
+            r"is located |^Location is |^Address 0x[0-9a-fA-F]+ is |^The signal is caused by |^Hint: |^Access not within mapped region",
+        )
+        .unwrap(),
+    })
+}
+
+/// Check whether input contains a sanitizer or Valgrind report
+pub fn is_sanitizer_output(input: &str) -> bool {
+    input.contains("Sanitizer: ")
+        || input.contains(": runtime error: ")
+        || input.contains("Memcheck, a memory error detector")
+        || input.lines().any(|line| {
+            let p = patterns();
+            p.pid_prefix.is_match(line)
+                && p.valgrind_error
+                    .is_match(p.pid_prefix.replace(line, "").trim())
+        })
+}
+
+/// What incoming frame lines are currently attached to
+enum Target {
+    Stack(SanitizerStack),
+    Leak(LeakRecord),
+}
+
+/// Line-by-line state while building a report
+struct ReportBuilder {
+    report: Option<SanitizerReport>,
+    target: Option<Target>,
+    /// Set once a second report starts; its stacks are skipped
+    finished_primary: bool,
+    /// Frame used for the access stack when the tool printed none
+    fallback_frame: Option<StackFrame>,
+}
+
+impl ReportBuilder {
+    fn flush_target(&mut self) {
+        let Some(target) = self.target.take() else {
+            return;
+        };
+        let Some(report) = self.report.as_mut() else {
+            return;
+        };
+        match target {
+            Target::Stack(mut stack) => {
+                if stack.role == StackRole::Access && stack.frames.is_empty() {
+                    stack.frames.extend(self.fallback_frame.take());
+                }
+                if !stack.frames.is_empty() {
+                    report.stacks.push(stack);
+                }
+            }
+            Target::Leak(leak) => report.leaks.push(leak),
+        }
+    }
+
+    fn start_report(&mut self, report: SanitizerReport) {
+        self.flush_target();
+        if let Some(existing) = self.report.as_mut() {
+            existing.additional_reports += 1;
+            self.finished_primary = true;
+            return;
+        }
+        let description = report.message.clone();
+        self.report = Some(report);
+        self.start_stack(StackRole::Access, &description);
+    }
+
+    fn start_stack(&mut self, role: StackRole, description: &str) {
+        if self.report.is_none() || self.finished_primary {
+            self.flush_target();
+            return;
+        }
+        // A header describing a stack that has not received frames yet
+        // (e.g. "READ of size 4" right after the report header) refines it
+        if let Some(Target::Stack(stack)) = self.target.as_mut() {
+            if stack.role == role && stack.frames.is_empty() {
+                stack.description = description.to_string();
+                return;
+            }
+        }
+        self.flush_target();
+        self.target = Some(Target::Stack(SanitizerStack {
+            role,
+            description: description.to_string(),
+            frames: Vec::new(),
+        }));
+    }
+
+    fn start_leak(&mut self, leak: LeakRecord, tool: SanitizerTool) {
+        self.flush_target();
+        if self.report.is_none() {
+            self.report = Some(SanitizerReport::new(tool, "memory leak", "memory leak"));
+        }
+        self.target = Some(Target::Leak(leak));
+    }
+
+    fn add_frame(&mut self, frame: StackFrame) {
+        match self.target.as_mut() {
+            Some(Target::Stack(stack)) => stack.frames.push(frame),
+            Some(Target::Leak(leak)) => leak.frames.push(frame),
+            None => {}
+        }
+    }
+
+    fn add_note(&mut self, note: &str) {
+        if self.finished_primary {
+            return;
+        }
+        if let Some(report) = self.report.as_mut() {
+            report.notes.push(note.to_string());
+        }
+    }
+
+    fn finish(mut self) -> Option<SanitizerReport> {
+        self.flush_target();
+        self.report
+    }
+}
+
+/// Parse the first sanitizer or Valgrind report in the input
+pub fn parse_sanitizer_report(input: &str) -> Option<SanitizerReport> {
+    let p = patterns();
+    let mut builder = ReportBuilder {
+        report: None,
+        target: None,
+        finished_primary: false,
+        fallback_frame: None,
+    };
+
+    for raw_line in input.lines() {
+        let is_valgrind = p.pid_prefix.is_match(raw_line) && raw_line.contains("== ");
+        let stripped = p.pid_prefix.replace(raw_line, "");
+        let line = stripped.trim();
+
+        if line.is_empty() {
+            builder.flush_target();
+            continue;
+        }
+
+        if let Some(caps) = p.header.captures(line) {
+            let Some(tool) = SanitizerTool::from_name(&caps[1]) else {
+                continue;
+            };
+            let message = strip_pid(&caps[2]);
+            builder.start_report(SanitizerReport::new(tool, error_kind(&message), message));
+            continue;
+        }
+
+        if let Some(caps) = p.ubsan.captures(line) {
+            let message = caps[4].trim().to_string();
+            let kind = message
+                .split_once(": ")
+                .map(|(kind, _)| kind.to_string())
+                .unwrap_or_else(|| message.clone());
+            let primary = builder.report.is_none();
+            builder.start_report(SanitizerReport::new(
+                SanitizerTool::UndefinedBehavior,
+                kind,
+                message,
+            ));
+            if primary {
+                // Stack traces are optional for UBSan; fall back to the
+                // location on the runtime error line itself
+                let mut frame = StackFrame::new()
+                    .with_file(&caps[1])
+                    .with_is_user_code(true);
+                if let Ok(line) = caps[2].parse() {
+                    frame = frame.with_line(line);
+                }
+                if let Ok(column) = caps[3].parse() {
+                    frame = frame.with_column(column);
+                }
+                builder.fallback_frame = Some(frame);
+            }
+            continue;
+        }
+
+        if is_valgrind && p.valgrind_error.is_match(line) {
+            builder.start_report(SanitizerReport::new(SanitizerTool::Valgrind, line, line));
+            continue;
+        }
+
+        if let Some(caps) = p.summary.captures(line) {
+            builder.flush_target();
+            if let Some(report) = builder.report.as_mut() {
+                if report.summary.is_none() {
+                    report.summary = Some(caps[1].to_string());
+                }
+            }
+            continue;
+        }
+
+        if line.starts_with("HEAP SUMMARY:") || line.starts_with("LEAK SUMMARY:") {
+            builder.flush_target();
+            continue;
+        }
+
+        if let Some(frame) = parse_frame(line) {
+            builder.add_frame(frame);
+            continue;
+        }
+
+        if let Some(caps) = p.leak.captures(line) {
+            let leak = LeakRecord {
+                kind: caps[1].to_lowercase(),
+                bytes: parse_count(&caps[2]),
+                objects: parse_count(&caps[3]),
+                frames: Vec::new(),
+            };
+            builder.start_leak(leak, SanitizerTool::Leak);
+            continue;
+        }
+
+        if let Some(caps) = p.valgrind_leak.captures(line) {
+            let leak = LeakRecord {
+                kind: caps[3].to_string(),
+                bytes: parse_count(&caps[1]),
+                objects: parse_count(&caps[2]),
+                frames: Vec::new(),
+            };
+            builder.start_leak(leak, SanitizerTool::Valgrind);
+            continue;
+        }
+
+        if p.previous_access.is_match(line) {
+            builder.start_stack(StackRole::PreviousAccess, line);
+        } else if p.access.is_match(line) {
+            builder.start_stack(StackRole::Access, line);
+        } else if p.freed_by.is_match(line) {
+            builder.start_stack(StackRole::Free, line);
+        } else if p.allocated_by.is_match(line) {
+            builder.start_stack(StackRole::Allocation, line);
+        } else if p.thread_created.is_match(line) {
+            builder.start_stack(StackRole::ThreadCreation, line);
+        } else if p.origin.is_match(line) {
+            builder.start_stack(StackRole::Origin, line);
+        } else if p.note.is_match(line) {
+            builder.add_note(line);
+        } else if line.ends_with(':') && builder.report.is_some() {
+            builder.start_stack(StackRole::Other, line.trim_end_matches(':'));
+        }
+    }
+
+    builder.finish()
+}
+
+/// Parse a sanitizer ("#0 0x... in func file:line:col") or Valgrind
+/// ("at 0x...: func (file:line)") frame
+fn parse_frame(line: &str) -> Option<StackFrame> {
+    let p = patterns();
+
+    if let Some(caps) = p.valgrind_frame.captures(line) {
+        let function = caps[1].to_string();
+        let mut frame = StackFrame::new().with_function(function.clone());
+        if let (Some(file), Some(line)) = (caps.get(3), caps.get(4)) {
+            frame.file = Some(PathBuf::from(file.as_str()));
+            frame.line = line.as_str().parse().ok();
+        }
+        let module = caps.get(2).map(|m| m.as_str());
+        frame.is_user_code = is_user_frame(&function, frame.file.as_ref(), module);
+        return Some(frame);
+    }
+
+    let caps = p.sanitizer_frame.captures(line)?;
+    let mut rest = caps[1].trim().to_string();
+    let mut module = None;
+    if let Some(m) = p.module_suffix.captures(&rest) {
+        module = Some(m[1].to_string());
+        let end = m.get(0).map(|m| m.start()).unwrap_or(rest.len());
+        rest.truncate(end);
+    }
+
+    let mut frame = StackFrame::new();
+    let (function, location) = match rest.rsplit_once(' ') {
+        Some((func, loc)) if p.location.is_match(loc) || loc == "<null>" => {
+            (func.trim(), Some(loc))
+        }
+        _ if p.location.is_match(&rest) => ("", Some(rest.as_str())),
+        _ => (rest.trim(), None),
+    };
+
+    if !function.is_empty() && function != "<null>" {
+        frame.function = Some(function.to_string());
+    }
+    if let Some(loc) = location.and_then(|loc| p.location.captures(loc)) {
+        frame.file = Some(PathBuf::from(&loc[1]));
+        frame.line = loc[2].parse().ok();
+        frame.column = loc.get(3).and_then(|c| c.as_str().parse().ok());
+    }
+
+    if frame.function.is_none() && frame.file.is_none() && module.is_none() {
+        return None;
+    }
+
+    frame.is_user_code = is_user_frame(
+        frame.function.as_deref().unwrap_or(""),
+        frame.file.as_ref(),
+        module.as_deref(),
+    );
+    Some(frame)
+}
+
+/// Classify a frame as user code, excluding sanitizer runtimes, allocators
+/// and system libraries
+fn is_user_frame(function: &str, file: Option<&PathBuf>, module: Option<&str>) -> bool {
+    const RUNTIME_FUNCTIONS: &[&str] = &[
+        "__",
+        "_start",
+        "operator new",
+        "operator delete",
+        "malloc",
+        "calloc",
+        "realloc",
+        "free",
+        "pthread_",
+        "start_thread",
+        "clone",
+        "std::",
+    ];
+    const RUNTIME_PATHS: &[&str] = &[
+        "vg_replace_",
+        "compiler-rt",
+        "sanitizer_common",
+        "libsanitizer",
+        "/usr/include/",
+    ];
+    const RUNTIME_MODULES: &[&str] = &[
+        "libasan",
+        "libtsan",
+        "libmsan",
+        "liblsan",
+        "libubsan",
+        "libclang_rt",
+        "vgpreload",
+        "libc.so",
+        "libc-",
+        "libstdc++",
+        "libc++",
+        "ld-linux",
+        "libpthread",
+    ];
+
+    if function.is_empty() || RUNTIME_FUNCTIONS.iter().any(|f| function.starts_with(f)) {
+        return false;
+    }
+
+    match (file, module) {
+        (Some(file), _) => {
+            let path = file.to_string_lossy();
+            !RUNTIME_PATHS.iter().any(|p| path.contains(p))
+        }
+        (None, Some(module)) => {
+            !RUNTIME_MODULES.iter().any(|m| module.contains(m))
+                && !module.starts_with("/lib")
+                && !module.starts_with("/usr/lib")
+        }
+        (None, None) => false,
+    }
+}
+
+/// Drop the "(pid=1234)" suffix ThreadSanitizer appends to headers
+fn strip_pid(text: &str) -> String {
+    match text.rfind(" (pid=") {
+        Some(pos) if text.ends_with(')') => text[..pos].trim().to_string(),
+        _ => text.trim().to_string(),
+    }
+}
+
+/// Reduce a header message to its error kind ("heap-use-after-free on
+/// address ..." -> "heap-use-after-free")
+fn error_kind(message: &str) -> String {
+    message
+        .split(" on ")
+        .next()
+        .unwrap_or(message)
+        .trim_end_matches(':')
+        .trim()
+        .to_string()
+}
+
+fn parse_count(text: &str) -> u64 {
+    text.replace(',', "").parse().unwrap_or(0)
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const ASAN_UAF: &str = "\
+=================================================================
+==12345==ERROR: AddressSanitizer: heap-use-after-free on address 0x602000000010 at pc 0x0000004011f6 bp 0x7ffc sp 0x7ffc
+READ of size 4 at 0x602000000010 thread T0
+    #0 0x4011f5 in main /app/uaf.c:8:12
+    #1 0x7f2a3c in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x21b96)
+
+0x602000000010 is located 0 bytes inside of 4-byte region [0x602000000010,0x602000000014)
+freed by thread T0 here:
+    #0 0x4931ed in free (/app/a.out+0x4931ed)
+    #1 0x4011c9 in main /app/uaf.c:7:5
+
+previously allocated by thread T0 here:
+    #0 0x49346d in malloc (/app/a.out+0x49346d)
+    #1 0x4011b4 in main /app/uaf.c:6:14
+
+SUMMARY: AddressSanitizer: heap-use-after-free /app/uaf.c:8:12 in main
+==12345==ABORTING";
+
+    #[test]
+    fn test_asan_use_after_free_stacks() {
+        let report = parse_sanitizer_report(ASAN_UAF).unwrap();
+        assert_eq!(report.tool, SanitizerTool::Address);
+        assert_eq!(report.error_kind, "heap-use-after-free");
+        assert_eq!(report.stacks.len(), 3);
+
+        let access = report.stack(StackRole::Access).unwrap();
+        assert!(access.description.starts_with("READ of size 4"));
+        assert_eq!(access.frames[0].function.as_deref(), Some("main"));
+        assert_eq!(access.frames[0].file, Some(PathBuf::from("/app/uaf.c")));
+        assert_eq!(access.frames[0].line, Some(8));
+        assert_eq!(access.frames[0].column, Some(12));
+        assert!(access.frames[0].is_user_code);
+        assert!(!access.frames[1].is_user_code);
+
+        let free = report.stack(StackRole::Free).unwrap();
+        assert_eq!(free.frames[1].line, Some(7));
+        assert!(!free.frames[0].is_user_code);
+
+        let alloc = report.stack(StackRole::Allocation).unwrap();
+        assert_eq!(alloc.frames[1].line, Some(6));
+
+        assert_eq!(report.notes.len(), 1);
+        assert_eq!(
+            report.summary.as_deref(),
+            Some("AddressSanitizer: heap-use-after-free /app/uaf.c:8:12 in main")
+        );
+    }
+
+    #[test]
+    fn test_tsan_data_race() {
+        let input = "\
+==================
+WARNING: ThreadSanitizer: data race (pid=4242)
+  Write of size 4 at 0x7b0400000000 by thread T1:
+    #0 worker /app/race.c:6:11 (race+0x4b5a)
+    #1 <null> <null> (libtsan.so.0+0x2d1e)
+
+  Previous write of size 4 at 0x7b0400000000 by main thread:
+    #0 main /app/race.c:14:11 (race+0x4bd0)
+
+  Location is heap block of size 4 at 0x7b0400000000 allocated by main thread:
+    #0 malloc <null> (libtsan.so.0+0x3b5d1)
+    #1 main /app/race.c:12:14 (race+0x4b9c)
+
+  Thread T1 (tid=4244, running) created by main thread at:
+    #0 pthread_create <null> (libtsan.so.0+0x5e8b)
+    #1 main /app/race.c:13:3 (race+0x4bb6)
+
+SUMMARY: ThreadSanitizer: data race /app/race.c:6:11 in worker
+==================";
+
+        let report = parse_sanitizer_report(input).unwrap();
+        assert_eq!(report.tool, SanitizerTool::Thread);
+        assert_eq!(report.error_kind, "data race");
+
+        let roles: Vec<StackRole> = report.stacks.iter().map(|s| s.role).collect();
+        assert_eq!(
+            roles,
+            vec![
+                StackRole::Access,
+                StackRole::PreviousAccess,
+                StackRole::Allocation,
+                StackRole::ThreadCreation
+            ]
+        );
+
+        let access = report.stack(StackRole::Access).unwrap();
+        assert_eq!(access.frames[0].function.as_deref(), Some("worker"));
+        assert_eq!(access.frames[0].line, Some(6));
+        assert!(access.frames[1].function.is_none());
+        assert!(!access.frames[1].is_user_code);
+
+        let previous = report.stack(StackRole::PreviousAccess).unwrap();
+        assert_eq!(previous.frames[0].line, Some(14));
+
+        let summary = report.prompt_summary();
+        assert!(summary.contains("previous access stack"));
+        assert!(summary.contains("without synchronization"));
+    }
+
+    #[test]
+    fn test_ubsan_runtime_error_without_stack() {
+        let input = "/app/ub.c:5:12: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'\n\
+                     SUMMARY: UndefinedBehaviorSanitizer: undefined-behavior /app/ub.c:5:12 in";
+
+        let report = parse_sanitizer_report(input).unwrap();
+        assert_eq!(report.tool, SanitizerTool::UndefinedBehavior);
+        assert_eq!(report.error_kind, "signed integer overflow");
+        assert_eq!(report.primary_frames().len(), 1);
+        assert_eq!(report.primary_frames()[0].column, Some(12));
+    }
+
+    #[test]
+    fn test_msan_origin_stack() {
+        let input = "\
+==7==WARNING: MemorySanitizer: use-of-uninitialized-value
+    #0 0x49a8c3 in main /app/msan.c:6:7
+    #1 0x7f12 in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x21b96)
+
+  Uninitialized value was created by an allocation of 'x' in the stack frame of function 'main'
+    #0 0x49a7a0 in main /app/msan.c:3
+
+SUMMARY: MemorySanitizer: use-of-uninitialized-value /app/msan.c:6:7 in main";
+
+        let report = parse_sanitizer_report(input).unwrap();
+        assert_eq!(report.tool, SanitizerTool::Memory);
+        assert_eq!(report.error_kind, "use-of-uninitialized-value");
+        let origin = report.stack(StackRole::Origin).unwrap();
+        assert_eq!(origin.frames[0].line, Some(3));
+        assert!(report.prompt_summary().contains("origin stack"));
+    }
+
+    #[test]
+    fn test_lsan_leak_sizes() {
+        let input = "\
+=================================================================
+==99==ERROR: LeakSanitizer: detected memory leaks
+
+Direct leak of 7 byte(s) in 1 object(s) allocated from:
+    #0 0x4af01b in __interceptor_malloc (/app/a.out+0x4af01b)
+    #1 0x4da26a in main /app/leak.c:4:7
+
+Indirect leak of 16 byte(s) in 2 object(s) allocated from:
+    #0 0x4af01b in __interceptor_malloc (/app/a.out+0x4af01b)
+    #1 0x4da2aa in make_node /app/leak.c:10:20
+
+SUMMARY: AddressSanitizer: 23 byte(s) leaked in 3 allocation(s).";
+
+        let report = parse_sanitizer_report(input).unwrap();
+        assert_eq!(report.tool, SanitizerTool::Leak);
+        assert_eq!(report.leaks.len(), 2);
+        assert_eq!(report.leaks[0].kind, "direct");
+        assert_eq!(report.leaks[0].bytes, 7);
+        assert_eq!(report.leaks[1].objects, 2);
+        assert_eq!(report.leaked_bytes(), 23);
+        assert_eq!(report.primary_frames()[1].function.as_deref(), Some("main"));
+    }
+
+    #[test]
+    fn test_valgrind_invalid_read_and_leaks() {
+        let input = "\
+==3100== Memcheck, a memory error detector
+==3100== Invalid read of size 4
+==3100==    at 0x108668: main (uaf.c:8)
+==3100==  Address 0x522d040 is 0 bytes inside a block of size 4 free'd
+==3100==    at 0x4C30D3B: free (vg_replace_malloc.c:530)
+==3100==    by 0x10865F: main (uaf.c:7)
+==3100==  Block was alloc'd at
+==3100==    at 0x4C2FB0F: malloc (vg_replace_malloc.c:299)
+==3100==    by 0x10864F: main (uaf.c:6)
+==3100==
+==3100== HEAP SUMMARY:
+==3100==     in use at exit: 1,024 bytes in 1 blocks
+==3100==
+==3100== 1,024 bytes in 1 blocks are definitely lost in loss record 1 of 1
+==3100==    at 0x4C2FB0F: malloc (vg_replace_malloc.c:299)
+==3100==    by 0x108656: main (leak.c:4)
+==3100==
+==3100== ERROR SUMMARY: 2 errors from 2 contexts (suppressed: 0 from 0)";
+
+        let report = parse_sanitizer_report(input).unwrap();
+        assert_eq!(report.tool, SanitizerTool::Valgrind);
+        assert_eq!(report.error_kind, "Invalid read of size 4");
+
+        let access = report.stack(StackRole::Access).unwrap();
+        assert_eq!(access.frames[0].file, Some(PathBuf::from("uaf.c")));
+        assert_eq!(access.frames[0].line, Some(8));
+
+        let free = report.stack(StackRole::Free).unwrap();
+        assert!(!free.frames[0].is_user_code);
+        assert!(free.frames[1].is_user_code);
+        assert!(report.stack(StackRole::Allocation).is_some());
+
+        assert_eq!(report.leaks.len(), 1);
+        assert_eq!(report.leaks[0].kind, "definitely lost");
+        assert_eq!(report.leaked_bytes(), 1024);
+        assert!(report
+            .prompt_summary()
+            .contains("released at the free stack"));
+    }
+
+    #[test]
+    fn test_additional_reports_are_counted() {
+        let input = "/app/a.c:1:1: runtime error: load of null pointer of type 'int'\n\
+                     /app/a.c:2:1: runtime error: shift exponent 40 is too large";
+
+        let report = parse_sanitizer_report(input).unwrap();
+        assert_eq!(report.error_kind, "load of null pointer of type 'int'");
+        assert_eq!(report.additional_reports, 1);
+        assert_eq!(report.stacks.len(), 1);
+    }
+
+    #[test]
+    fn test_plain_output_is_not_a_report() {
+        assert!(parse_sanitizer_report("Segmentation fault (core dumped)").is_none());
+        assert!(!is_sanitizer_output("error: something failed"));
+    }
+}
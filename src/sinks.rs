@@ -0,0 +1,810 @@
+//! Notification sinks for watch mode.
+//!
+//! Each explained error becomes an `ErrorReport` that is handed to the sinks
+//! configured under `[[sinks]]`: an HTTP POST webhook (Slack/Matrix-compatible
+//! JSON, or a user template), a shell command that gets the report as JSON on
+//! stdin, or an append-only JSONL file. Delivery runs on a background thread
+//! so a slow webhook never stalls the watch loop, failed deliveries are
+//! retried with exponential backoff, and each sink can skip reports below a
+//! minimum severity.
+
+use anyhow::{bail, Context, Result};
+use colored::Colorize;
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+use std::fs::{self, OpenOptions};
+use std::io::{BufRead, BufReader, Read, Write};
+use std::net::{TcpStream, ToSocketAddrs};
+use std::path::{Path, PathBuf};
+use std::process::{Child, Command, ExitStatus, Stdio};
+use std::sync::mpsc;
+use std::sync::OnceLock;
+use std::thread::{self, JoinHandle};
+use std::time::{Duration, Instant, SystemTime, UNIX_EPOCH};
+
+use crate::config::{SinkConfig, SinkKind};
+use crate::output::ErrorExplanation;
+use crate::temp;
+use crate::watch::DetectedError;
+
+/// Timeout for connecting to and hearing back from a webhook
+const HTTP_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Time an exec sink command gets before it is killed
+const EXEC_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// How long exiting waits for queued reports before abandoning them
+const DRAIN_TIMEOUT: Duration = Duration::from_secs(15);
+
+/// Interval for polling a child process or the delivery thread
+const POLL_INTERVAL: Duration = Duration::from_millis(50);
+
+/// Cap on the backoff multiplier (2^6 = 64x the base delay)
+const MAX_BACKOFF_SHIFT: u32 = 6;
+
+// ============================================================================
+// Reports
+// ============================================================================
+
+/// How serious an error is, for per-sink filters
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum AlertLevel {
+    #[default]
+    Warning,
+    Error,
+    Critical,
+}
+
+impl AlertLevel {
+    /// Classify an error block: crashes and fatal errors are critical, blocks
+    /// that lead with a warning are warnings, everything else is an error
+    pub fn classify(content: &str) -> Self {
+        const CRITICAL: &[&str] = &[
+            "panic",
+            "fatal",
+            "segmentation fault",
+            "sigsegv",
+            "sigabrt",
+            "core dumped",
+            "out of memory",
+            "oom-kill",
+            "killed process",
+            "[crit]",
+            "[alert]",
+            "[emerg]",
+        ];
+
+        let lower = content.to_lowercase();
+        if CRITICAL.iter().any(|needle| lower.contains(needle)) {
+            return AlertLevel::Critical;
+        }
+
+        let first = lower.lines().next().unwrap_or_default().trim_start();
+        if first.starts_with("warn") || first.contains("[warn]") || first.contains("deprecat") {
+            AlertLevel::Warning
+        } else {
+            AlertLevel::Error
+        }
+    }
+}
+
+impl std::fmt::Display for AlertLevel {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        let name = match self {
+            AlertLevel::Warning => "warning",
+            AlertLevel::Error => "error",
+            AlertLevel::Critical => "critical",
+        };
+        write!(f, "{}", name)
+    }
+}
+
+/// An explained error as delivered to sinks
+#[derive(Debug, Clone, Serialize)]
+pub struct ErrorReport {
+    /// Unix time in seconds
+    pub timestamp: u64,
+    /// Watch target the error came from
+    pub source: String,
+    pub severity: AlertLevel,
+    pub error: String,
+    pub summary: String,
+    pub explanation: String,
+    pub suggestion: String,
+    /// The detected error block
+    pub input: String,
+    /// Output lines just before the error
+    pub pre_context: Vec<String>,
+}
+
+impl ErrorReport {
+    pub fn new(source: &str, error: &DetectedError, explanation: &ErrorExplanation) -> Self {
+        Self {
+            timestamp: SystemTime::now()
+                .duration_since(UNIX_EPOCH)
+                .unwrap_or_default()
+                .as_secs(),
+            source: source.to_string(),
+            severity: AlertLevel::classify(&error.content),
+            error: explanation.error.clone(),
+            summary: explanation.summary.clone(),
+            explanation: explanation.explanation.clone(),
+            suggestion: explanation.suggestion.clone(),
+            input: error.content.clone(),
+            pre_context: error.pre_context.clone(),
+        }
+    }
+
+    /// Short human-readable message for chat webhooks and notifications
+    pub fn message(&self) -> String {
+        let mut message = format!("[{}] {}: {}", self.severity, self.source, self.summary);
+        if !self.suggestion.is_empty() {
+            message.push_str(&format!("\nFix: {}", self.suggestion));
+        }
+        message
+    }
+}
+
+// ============================================================================
+// Delivery
+// ============================================================================
+
+/// Sends reports to the configured sinks on a background thread.
+///
+/// Dropping the notifier waits for queued reports to be delivered, for up to
+/// `DRAIN_TIMEOUT`; a sink that is still stuck after that is abandoned.
+pub struct Notifier {
+    tx: Option<mpsc::Sender<ErrorReport>>,
+    worker: Option<JoinHandle<()>>,
+}
+
+impl Notifier {
+    /// Start delivering to `sinks`; `None` when there is nothing to deliver to
+    pub fn new(sinks: Vec<SinkConfig>) -> Option<Self> {
+        if sinks.is_empty() {
+            return None;
+        }
+
+        let (tx, rx) = mpsc::channel::<ErrorReport>();
+        let worker = thread::spawn(move || {
+            for report in rx {
+                for sink in sinks.iter().filter(|s| report.severity >= s.min_severity) {
+                    if let Err(e) = deliver(sink, &report) {
+                        eprintln!(
+                            "{} {} sink failed: {:#}",
+                            "Warning:".yellow().bold(),
+                            sink.kind.name(),
+                            e
+                        );
+                    }
+                }
+            }
+        });
+
+        Some(Self {
+            tx: Some(tx),
+            worker: Some(worker),
+        })
+    }
+
+    /// Queue a report for delivery
+    pub fn send(&self, report: ErrorReport) {
+        if let Some(ref tx) = self.tx {
+            let _ = tx.send(report);
+        }
+    }
+}
+
+impl Drop for Notifier {
+    fn drop(&mut self) {
+        // Closing the channel ends the worker once the queue is drained
+        self.tx.take();
+        let Some(worker) = self.worker.take() else {
+            return;
+        };
+        let deadline = Instant::now() + DRAIN_TIMEOUT;
+        while !worker.is_finished() {
+            if Instant::now() >= deadline {
+                eprintln!(
+                    "{} Gave up waiting for notification sinks",
+                    "Warning:".yellow().bold()
+                );
+                return;
+            }
+            thread::sleep(POLL_INTERVAL);
+        }
+        let _ = worker.join();
+    }
+}
+
+/// Deliver a report to one sink, retrying with exponential backoff
+pub fn deliver(sink: &SinkConfig, report: &ErrorReport) -> Result<()> {
+    let mut attempt = 0;
+    loop {
+        match send_once(&sink.kind, report) {
+            Ok(()) => return Ok(()),
+            Err(e) if attempt >= sink.retries => {
+                return Err(e.context(format!("gave up after {} attempt(s)", attempt + 1)));
+            }
+            Err(_) => {
+                let shift = attempt.min(MAX_BACKOFF_SHIFT);
+                thread::sleep(Duration::from_millis(
+                    sink.backoff_ms.saturating_mul(1 << shift),
+                ));
+                attempt += 1;
+            }
+        }
+    }
+}
+
+fn send_once(kind: &SinkKind, report: &ErrorReport) -> Result<()> {
+    match kind {
+        SinkKind::Webhook { url, template } => {
+            let body = match template {
+                Some(template) => render_template(template, report)?,
+                None => default_payload(report)?,
+            };
+            post_json(url, &body)
+        }
+        SinkKind::Exec { command } => run_exec(command, report),
+        SinkKind::Jsonl { path } => append_jsonl(path, report),
+    }
+}
+
+// ============================================================================
+// Webhook
+// ============================================================================
+
+/// Payload understood by Slack incoming webhooks (`text`) and Matrix webhook
+/// bridges (`body`/`msgtype`), with the full report alongside
+fn default_payload(report: &ErrorReport) -> Result<String> {
+    let message = report.message();
+    let payload = serde_json::json!({
+        "text": message,
+        "body": message,
+        "msgtype": "m.text",
+        "report": report,
+    });
+    Ok(serde_json::to_string(&payload)?)
+}
+
+struct Patterns {
+    placeholder: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        placeholder: Regex::new(r"\{\{\s*(\w+)\s*\}\}").unwrap(),
+    })
+}
+
+/// Fill `{{field}}` placeholders in a JSON template.
+///
+/// Text fields are JSON-escaped without quotes, so they go inside a string
+/// literal (`"text": "why: {{summary}}"`); `{{report}}` is the whole report
+/// as a JSON object. Unknown placeholders are left as they are.
+pub fn render_template(template: &str, report: &ErrorReport) -> Result<String> {
+    let report_json = serde_json::to_string(report)?;
+    let escape = |value: &str| {
+        let quoted = serde_json::to_string(value).unwrap_or_default();
+        quoted[1..quoted.len() - 1].to_string()
+    };
+
+    let rendered =
+        patterns()
+            .placeholder
+            .replace_all(template, |caps: &regex::Captures| match &caps[1] {
+                "report" => report_json.clone(),
+                "text" => escape(&report.message()),
+                "severity" => report.severity.to_string(),
+                "source" => escape(&report.source),
+                "error" => escape(&report.error),
+                "summary" => escape(&report.summary),
+                "explanation" => escape(&report.explanation),
+                "suggestion" => escape(&report.suggestion),
+                "input" => escape(&report.input),
+                "timestamp" => report.timestamp.to_string(),
+                _ => caps[0].to_string(),
+            });
+    Ok(rendered.into_owned())
+}
+
+/// POST a JSON body; plain HTTP is spoken directly, HTTPS goes through curl
+fn post_json(url: &str, body: &str) -> Result<()> {
+    if url.starts_with("https://") {
+        post_with_curl(url, body)
+    } else {
+        post_http(url, body)
+    }
+}
+
+/// Split an `http://host[:port]/path` URL; IPv6 hosts are bracketed
+/// (`http://[::1]:8080/hook`) and returned without the brackets
+fn parse_http_url(url: &str) -> Result<(String, u16, String)> {
+    let Some(rest) = url.strip_prefix("http://") else {
+        bail!(
+            "Unsupported webhook URL (expected http:// or https://): {}",
+            url
+        );
+    };
+    let (authority, path) = match rest.find('/') {
+        Some(i) => (&rest[..i], &rest[i..]),
+        None => (rest, "/"),
+    };
+    // The port comes after the closing bracket of an IPv6 literal
+    let (host, port) = match authority.strip_prefix('[') {
+        Some(bracketed) => match bracketed.split_once(']') {
+            Some((host, "")) => (host, None),
+            Some((host, rest)) => match rest.strip_prefix(':') {
+                Some(port) => (host, Some(port)),
+                None => bail!("Invalid webhook URL: {}", url),
+            },
+            None => bail!("Unclosed IPv6 address in webhook URL: {}", url),
+        },
+        None => match authority.rsplit_once(':') {
+            Some((host, port)) => (host, Some(port)),
+            None => (authority, None),
+        },
+    };
+    let port = match port {
+        Some(port) => port
+            .parse::<u16>()
+            .with_context(|| format!("Invalid port in webhook URL: {}", url))?,
+        None => 80,
+    };
+    if host.is_empty() {
+        bail!("Missing host in webhook URL: {}", url);
+    }
+    Ok((host.to_string(), port, path.to_string()))
+}
+
+fn post_http(url: &str, body: &str) -> Result<()> {
+    let (host, port, path) = parse_http_url(url)?;
+    let addr = (host.as_str(), port)
+        .to_socket_addrs()
+        .with_context(|| format!("Failed to resolve {}", host))?
+        .next()
+        .with_context(|| format!("No address for {}", host))?;
+
+    let mut stream = TcpStream::connect_timeout(&addr, HTTP_TIMEOUT)
+        .with_context(|| format!("Failed to connect to {}:{}", host, port))?;
+    stream.set_read_timeout(Some(HTTP_TIMEOUT))?;
+    stream.set_write_timeout(Some(HTTP_TIMEOUT))?;
+
+    let host_header = if host.contains(':') {
+        format!("[{}]:{}", host, port)
+    } else {
+        format!("{}:{}", host, port)
+    };
+    write!(
+        stream,
+        "POST {} HTTP/1.1\r\nHost: {}\r\nUser-Agent: why/{}\r\n\
+         Content-Type: application/json\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+        path,
+        host_header,
+        env!("CARGO_PKG_VERSION"),
+        body.len(),
+        body
+    )?;
+    stream.flush()?;
+
+    let mut status_line = String::new();
+    BufReader::new(stream).read_line(&mut status_line)?;
+    let status: u16 = status_line
+        .split_whitespace()
+        .nth(1)
+        .and_then(|code| code.parse().ok())
+        .with_context(|| format!("Malformed HTTP response: {:?}", status_line.trim()))?;
+    if !(200..300).contains(&status) {
+        bail!("{} answered HTTP {}", url, status);
+    }
+    Ok(())
+}
+
+/// POST through curl. Webhook URLs are secrets, so the URL goes in a config
+/// on stdin rather than on the command line where `ps` shows it; the body is
+/// read from a private temp file.
+fn post_with_curl(url: &str, body: &str) -> Result<()> {
+    let (mut file, body_path) = temp::create_file("why-webhook-", ".json")?;
+    file.write_all(body.as_bytes())?;
+    drop(file);
+
+    let result = run_curl(url, &body_path);
+    let _ = fs::remove_file(&body_path);
+    result
+}
+
+fn run_curl(url: &str, body_path: &Path) -> Result<()> {
+    let mut child = Command::new("curl")
+        .args(["-sS", "--fail", "--max-time"])
+        .arg(HTTP_TIMEOUT.as_secs().to_string())
+        .args(["-X", "POST", "-H", "Content-Type: application/json"])
+        .arg("--data-binary")
+        .arg(format!("@{}", body_path.display()))
+        .args(["--config", "-"])
+        .stdin(Stdio::piped())
+        .stdout(Stdio::null())
+        .stderr(Stdio::piped())
+        .spawn()
+        .context("HTTPS webhooks need curl on PATH")?;
+
+    if let Some(mut stdin) = child.stdin.take() {
+        stdin.write_all(curl_config(url).as_bytes())?;
+    }
+    let output = child.wait_with_output()?;
+    if !output.status.success() {
+        bail!(
+            "curl failed: {}",
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+    Ok(())
+}
+
+/// curl config setting the URL, quoted with curl's backslash escapes
+fn curl_config(url: &str) -> String {
+    let mut quoted = String::new();
+    for c in url.chars() {
+        match c {
+            '\\' => quoted.push_str("\\\\"),
+            '"' => quoted.push_str("\\\""),
+            '\n' => quoted.push_str("\\n"),
+            '\r' => quoted.push_str("\\r"),
+            '\t' => quoted.push_str("\\t"),
+            c => quoted.push(c),
+        }
+    }
+    format!("url = \"{}\"\n", quoted)
+}
+
+// ============================================================================
+// Exec and JSONL
+// ============================================================================
+
+/// Run a shell command with the report as JSON on stdin; the severity,
+/// source and summary are also in `WHY_SEVERITY`, `WHY_SOURCE` and
+/// `WHY_SUMMARY` for commands like `notify-send` that don't read stdin
+fn run_exec(command: &str, report: &ErrorReport) -> Result<()> {
+    let mut child = Command::new("sh")
+        .arg("-c")
+        .arg(command)
+        .env("WHY_SEVERITY", report.severity.to_string())
+        .env("WHY_SOURCE", &report.source)
+        .env("WHY_SUMMARY", &report.summary)
+        .stdin(Stdio::piped())
+        .stdout(Stdio::null())
+        .stderr(Stdio::piped())
+        .spawn()
+        .with_context(|| format!("Failed to run: {}", command))?;
+
+    if let Some(mut stdin) = child.stdin.take() {
+        // The command may exit without reading stdin
+        let _ = writeln!(stdin, "{}", serde_json::to_string(report)?);
+    }
+    // Read stderr alongside so a chatty command can't block on a full pipe
+    let stderr = child.stderr.take().map(|mut pipe| {
+        thread::spawn(move || {
+            let mut text = String::new();
+            let _ = pipe.read_to_string(&mut text);
+            text
+        })
+    });
+    let status = wait_with_timeout(&mut child, EXEC_TIMEOUT)
+        .with_context(|| format!("`{}` did not finish", command))?;
+    if !status.success() {
+        let stderr = stderr
+            .and_then(|reader| reader.join().ok())
+            .unwrap_or_default();
+        bail!("`{}` exited with {}: {}", command, status, stderr.trim());
+    }
+    Ok(())
+}
+
+/// Wait for `child`, killing it once `timeout` has passed
+fn wait_with_timeout(child: &mut Child, timeout: Duration) -> Result<ExitStatus> {
+    let deadline = Instant::now() + timeout;
+    loop {
+        if let Some(status) = child.try_wait()? {
+            return Ok(status);
+        }
+        if Instant::now() >= deadline {
+            let _ = child.kill();
+            let _ = child.wait();
+            bail!("killed after {}s", timeout.as_secs());
+        }
+        thread::sleep(POLL_INTERVAL);
+    }
+}
+
+/// Append the report as one line of JSON
+fn append_jsonl(path: &Path, report: &ErrorReport) -> Result<()> {
+    let path = expand_home(path);
+    if let Some(parent) = path.parent().filter(|p| !p.as_os_str().is_empty()) {
+        fs::create_dir_all(parent)
+            .with_context(|| format!("Failed to create {}", parent.display()))?;
+    }
+    let mut file = OpenOptions::new()
+        .create(true)
+        .append(true)
+        .open(&path)
+        .with_context(|| format!("Failed to open {}", path.display()))?;
+    writeln!(file, "{}", serde_json::to_string(report)?)?;
+    Ok(())
+}
+
+/// Expand a leading `~/` to the home directory
+fn expand_home(path: &Path) -> PathBuf {
+    match (path.strip_prefix("~"), dirs::home_dir()) {
+        (Ok(rest), Some(home)) => home.join(rest),
+        _ => path.to_path_buf(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::Config;
+    use std::io::Read;
+    use std::net::TcpListener;
+
+    fn report(content: &str) -> ErrorReport {
+        let error = DetectedError::new(content.to_string())
+            .with_pre_context(vec!["GET /api/users".to_string()]);
+        let explanation = ErrorExplanation {
+            error: content.to_string(),
+            summary: "The \"users\" table is missing".to_string(),
+            explanation: "Migrations have not run.".to_string(),
+            suggestion: "Run the migrations.".to_string(),
+        };
+        ErrorReport::new("npm run dev", &error, &explanation)
+    }
+
+    fn sink(kind: SinkKind, retries: u32) -> SinkConfig {
+        SinkConfig {
+            kind,
+            min_severity: AlertLevel::Warning,
+            retries,
+            backoff_ms: 1,
+        }
+    }
+
+    #[test]
+    fn test_severity_and_config() {
+        assert_eq!(
+            AlertLevel::classify("thread 'main' panicked at src/main.rs:2:5"),
+            AlertLevel::Critical
+        );
+        assert_eq!(
+            AlertLevel::classify("warning: unused variable `x`"),
+            AlertLevel::Warning
+        );
+        assert_eq!(
+            AlertLevel::classify("Error: relation \"users\" does not exist"),
+            AlertLevel::Error
+        );
+        assert!(AlertLevel::Critical > AlertLevel::Error);
+
+        let config: Config = toml::from_str(
+            r#"
+[[sinks]]
+type = "webhook"
+url = "https://hooks.slack.com/services/T0/B0/x"
+min_severity = "error"
+retries = 5
+
+[[sinks]]
+type = "jsonl"
+path = "~/.local/state/why/errors.jsonl"
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.sinks.len(), 2);
+        assert_eq!(config.sinks[0].min_severity, AlertLevel::Error);
+        assert_eq!(config.sinks[0].retries, 5);
+        assert!(matches!(
+            config.sinks[0].kind,
+            SinkKind::Webhook { template: None, .. }
+        ));
+        assert_eq!(config.sinks[1].min_severity, AlertLevel::Warning);
+        assert_eq!(config.sinks[1].retries, 3);
+        assert_eq!(config.sinks[1].kind.name(), "jsonl");
+    }
+
+    #[test]
+    fn test_render_template_escapes_fields() {
+        let report = report("Error: relation \"users\" does not exist");
+        let rendered = render_template(
+            r#"{"text": "why ({{severity}}): {{summary}}", "data": {{report}}, "x": "{{unknown}}"}"#,
+            &report,
+        )
+        .unwrap();
+        let value: serde_json::Value = serde_json::from_str(&rendered).unwrap();
+        assert_eq!(value["text"], "why (error): The \"users\" table is missing");
+        assert_eq!(value["data"]["source"], "npm run dev");
+        assert_eq!(value["data"]["pre_context"][0], "GET /api/users");
+        assert_eq!(value["x"], "{{unknown}}");
+
+        let payload: serde_json::Value =
+            serde_json::from_str(&default_payload(&report).unwrap()).unwrap();
+        assert!(payload["text"]
+            .as_str()
+            .unwrap()
+            .starts_with("[error] npm run dev: The \"users\" table"));
+        assert_eq!(payload["msgtype"], "m.text");
+    }
+
+    #[test]
+    fn test_webhook_retries_against_local_stub() {
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let url = format!("http://{}/hooks/why", listener.local_addr().unwrap());
+
+        // Fail the first request, accept the second
+        let stub = thread::spawn(move || {
+            let mut bodies = Vec::new();
+            for status in ["500 Internal Server Error", "200 OK"] {
+                let (stream, _) = listener.accept().unwrap();
+                let mut reader = BufReader::new(stream);
+                let mut request_line = String::new();
+                reader.read_line(&mut request_line).unwrap();
+                let mut length = 0;
+                loop {
+                    let mut header = String::new();
+                    reader.read_line(&mut header).unwrap();
+                    if header.trim().is_empty() {
+                        break;
+                    }
+                    if let Some(value) = header.to_lowercase().strip_prefix("content-length:") {
+                        length = value.trim().parse().unwrap();
+                    }
+                }
+                let mut body = vec![0; length];
+                reader.read_exact(&mut body).unwrap();
+                bodies.push((request_line, String::from_utf8(body).unwrap()));
+                write!(
+                    reader.get_mut(),
+                    "HTTP/1.1 {}\r\nContent-Length: 0\r\n\r\n",
+                    status
+                )
+                .unwrap();
+            }
+            bodies
+        });
+
+        let webhook = sink(
+            SinkKind::Webhook {
+                url,
+                template: None,
+            },
+            2,
+        );
+        deliver(&webhook, &report("Error: connection refused")).unwrap();
+
+        let bodies = stub.join().unwrap();
+        assert_eq!(bodies.len(), 2);
+        assert!(bodies[1].0.starts_with("POST /hooks/why HTTP/1.1"));
+        let payload: serde_json::Value = serde_json::from_str(&bodies[1].1).unwrap();
+        assert_eq!(payload["report"]["input"], "Error: connection refused");
+
+        // Nothing listening: gives up after the configured retries
+        let closed = sink(
+            SinkKind::Webhook {
+                url: "http://127.0.0.1:1/".to_string(),
+                template: None,
+            },
+            1,
+        );
+        let err = deliver(&closed, &report("Error: x")).unwrap_err();
+        assert!(format!("{:#}", err).contains("gave up after 2 attempt(s)"));
+    }
+
+    #[test]
+    fn test_webhook_to_ipv6_literal() {
+        assert_eq!(
+            parse_http_url("http://[::1]/hook").unwrap(),
+            ("::1".to_string(), 80, "/hook".to_string())
+        );
+        assert!(parse_http_url("http://[::1/hook").is_err());
+
+        let Ok(listener) = TcpListener::bind("[::1]:0") else {
+            // No IPv6 loopback here
+            return;
+        };
+        let url = format!(
+            "http://[::1]:{}/hooks/why",
+            listener.local_addr().unwrap().port()
+        );
+        let stub = thread::spawn(move || {
+            let (stream, _) = listener.accept().unwrap();
+            let mut reader = BufReader::new(stream);
+            let mut head = Vec::new();
+            loop {
+                let mut line = String::new();
+                reader.read_line(&mut line).unwrap();
+                if line.trim().is_empty() {
+                    break;
+                }
+                head.push(line.trim().to_string());
+            }
+            write!(reader.get_mut(), "HTTP/1.1 204 No Content\r\n\r\n").unwrap();
+            head
+        });
+
+        let webhook = sink(
+            SinkKind::Webhook {
+                url,
+                template: None,
+            },
+            0,
+        );
+        deliver(&webhook, &report("Error: connection refused")).unwrap();
+        let head = stub.join().unwrap();
+        assert_eq!(head[0], "POST /hooks/why HTTP/1.1");
+        assert!(head[1].starts_with("Host: [::1]:"));
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_jsonl_and_exec_sinks() {
+        let dir = std::env::temp_dir().join(format!("why-sinks-{}", std::process::id()));
+        let log = dir.join("nested").join("errors.jsonl");
+        let jsonl = sink(SinkKind::Jsonl { path: log.clone() }, 0);
+        deliver(&jsonl, &report("Error: first")).unwrap();
+        deliver(&jsonl, &report("Error: second")).unwrap();
+        let lines: Vec<serde_json::Value> = fs::read_to_string(&log)
+            .unwrap()
+            .lines()
+            .map(|line| serde_json::from_str(line).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 2);
+        assert_eq!(lines[1]["input"], "Error: second");
+
+        let out = dir.join("exec.json");
+        let exec = sink(
+            SinkKind::Exec {
+                command: format!(
+                    "cat > '{}' && test \"$WHY_SEVERITY\" = error",
+                    out.display()
+                ),
+            },
+            0,
+        );
+        deliver(&exec, &report("Error: from exec")).unwrap();
+        let written: serde_json::Value =
+            serde_json::from_str(&fs::read_to_string(&out).unwrap()).unwrap();
+        assert_eq!(written["summary"], "The \"users\" table is missing");
+
+        let failing = sink(
+            SinkKind::Exec {
+                command: "exit 3".to_string(),
+            },
+            0,
+        );
+        assert!(deliver(&failing, &report("Error: x")).is_err());
+        fs::remove_dir_all(&dir).ok();
+    }
+
+    #[test]
+    fn test_curl_config_quotes_url() {
+        assert_eq!(
+            curl_config("https://hooks.slack.com/services/T0/B0/x"),
+            "url = \"https://hooks.slack.com/services/T0/B0/x\"\n"
+        );
+        assert_eq!(
+            curl_config("https://h/a\"b\\c"),
+            "url = \"https://h/a\\\"b\\\\c\"\n"
+        );
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_wait_with_timeout_kills_hung_command() {
+        let mut child = Command::new("sh").args(["-c", "sleep 30"]).spawn().unwrap();
+        let started = Instant::now();
+        let err = wait_with_timeout(&mut child, Duration::from_millis(100)).unwrap_err();
+        assert!(err.to_string().starts_with("killed after"));
+        assert!(started.elapsed() < Duration::from_secs(5));
+        assert!(child.try_wait().unwrap().is_some());
+    }
+}
@@ -1,15 +1,31 @@
 //! Stack trace parsing and intelligence for multiple programming languages.
 //!
 //! This module provides parsers for stack traces from Python, Rust, JavaScript,
-//! Go, Java, and C/C++, with features for:
+//! TypeScript, Go, Java, and C/C++, plus kernel crash logs, Kubernetes pod
+//! status, Docker builds, make/ninja/CMake/Bazel failures and package manager
+//! errors, with features for:
 //! - Auto-detection of language from stack trace patterns
 //! - Extraction of file, line, function information
 //! - User code vs framework code classification
 //! - Source context extraction
 
+use regex::Regex;
 use serde::Serialize;
 use std::fmt;
 use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+use crate::build_system::{self, BuildFailure};
+use crate::database::{self, DatabaseError};
+use crate::docker::{self, DockerBuildReport};
+use crate::iac::{self, IacReport};
+use crate::jvm::{self, JvmReport};
+use crate::kernel::{self, KernelReport};
+use crate::kubernetes::{self, KubernetesReport};
+use crate::lint::{self, LintReport};
+use crate::package_manager::{self, PackageFailure};
+use crate::sanitizer::{self, SanitizerReport};
+use crate::syntax::{self, SyntaxErrorReport};
 
 // ============================================================================
 // Core Types
@@ -22,9 +38,18 @@ pub enum Language {
     Python,
     Rust,
     JavaScript,
+    TypeScript,
     Go,
     Java,
     Cpp,
+    Kernel,
+    Kubernetes,
+    Docker,
+    Build,
+    Package,
+    Iac,
+    Lint,
+    Sql,
     Unknown,
 }
 
@@ -34,9 +59,18 @@ impl fmt::Display for Language {
             Language::Python => write!(f, "python"),
             Language::Rust => write!(f, "rust"),
             Language::JavaScript => write!(f, "javascript"),
+            Language::TypeScript => write!(f, "typescript"),
             Language::Go => write!(f, "go"),
             Language::Java => write!(f, "java"),
             Language::Cpp => write!(f, "c/c++"),
+            Language::Kernel => write!(f, "kernel"),
+            Language::Kubernetes => write!(f, "kubernetes"),
+            Language::Docker => write!(f, "docker"),
+            Language::Build => write!(f, "build"),
+            Language::Package => write!(f, "package"),
+            Language::Iac => write!(f, "iac"),
+            Language::Lint => write!(f, "lint"),
+            Language::Sql => write!(f, "sql"),
             Language::Unknown => write!(f, "unknown"),
         }
     }
@@ -55,6 +89,8 @@ pub struct StackFrame {
     pub column: Option<u32>,
     /// Whether this frame is user code (vs framework/stdlib)
     pub is_user_code: bool,
+    /// Whether the call was resumed across an async boundary (`at async fn`)
+    pub is_async: bool,
     /// Additional context for this frame (e.g., source snippet)
     pub context: Option<String>,
 }
@@ -68,6 +104,7 @@ impl StackFrame {
             line: None,
             column: None,
             is_user_code: true, // Assume user code by default
+            is_async: false,
             context: None,
         }
     }
@@ -115,6 +152,183 @@ impl Default for StackFrame {
     }
 }
 
+/// Severity of a compiler or tool diagnostic
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Severity {
+    Error,
+    Warning,
+    Info,
+}
+
+impl fmt::Display for Severity {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Severity::Error => write!(f, "error"),
+            Severity::Warning => write!(f, "warning"),
+            Severity::Info => write!(f, "info"),
+        }
+    }
+}
+
+/// A single diagnostic from a compiler, bundler or checker that can report
+/// several problems in one run
+#[derive(Debug, Clone, Serialize)]
+pub struct Diagnostic {
+    /// Severity of the diagnostic
+    pub severity: Severity,
+    /// Tool-specific code (e.g., "TS2339")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub code: Option<String>,
+    /// The diagnostic message (may span several lines)
+    pub message: String,
+    /// Source file path (if available)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<PathBuf>,
+    /// Line number in the source file (if available)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<u32>,
+    /// Column number in the source file (if available)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub column: Option<u32>,
+    /// Source excerpt printed by the tool underneath the message
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub code_frame: Option<String>,
+}
+
+impl Diagnostic {
+    /// Create a new diagnostic with the given severity and message
+    pub fn new(severity: Severity, message: impl Into<String>) -> Self {
+        Self {
+            severity,
+            code: None,
+            message: message.into(),
+            file: None,
+            line: None,
+            column: None,
+            code_frame: None,
+        }
+    }
+
+    /// Set the diagnostic code
+    pub fn with_code(mut self, code: impl Into<String>) -> Self {
+        self.code = Some(code.into());
+        self
+    }
+
+    /// Set the source location
+    pub fn with_location(
+        mut self,
+        file: impl Into<PathBuf>,
+        line: Option<u32>,
+        column: Option<u32>,
+    ) -> Self {
+        self.file = Some(file.into());
+        self.line = line;
+        self.column = column;
+        self
+    }
+
+    /// One-line summary: location, severity, code and first message line
+    pub fn summary_line(&self) -> String {
+        let mut out = String::new();
+        if let Some(file) = &self.file {
+            out.push_str(&file.display().to_string());
+            if let Some(line) = self.line {
+                out.push_str(&format!(":{}", line));
+                if let Some(column) = self.column {
+                    out.push_str(&format!(":{}", column));
+                }
+            }
+            out.push_str(": ");
+        }
+        out.push_str(&self.severity.to_string());
+        if let Some(code) = &self.code {
+            out.push_str(&format!(" {}", code));
+        }
+        out.push_str(&format!(
+            ": {}",
+            self.message.lines().next().unwrap_or_default()
+        ));
+        out
+    }
+
+    /// Convert the diagnostic location into a stack frame
+    pub fn to_frame(&self) -> StackFrame {
+        let mut frame = StackFrame::new();
+        frame.file = self.file.clone();
+        frame.line = self.line;
+        frame.column = self.column;
+        frame.context = self.code_frame.clone();
+        frame
+    }
+}
+
+/// Tool-specific report attached to a stack trace
+#[derive(Debug, Clone, Serialize)]
+#[serde(tag = "kind", rename_all = "snake_case")]
+pub enum TraceDetails {
+    /// Sanitizer or Valgrind report with every stack it printed
+    Sanitizer(SanitizerReport),
+    /// Python or Node.js syntax error located by its caret line
+    Syntax(SyntaxErrorReport),
+    /// Segfault, trap, OOM kill and hung task events from the kernel log
+    Kernel(KernelReport),
+    /// Pod, container and event status from kubectl output
+    Kubernetes(KubernetesReport),
+    /// Failing step of a Docker build, with its nested language-level parse
+    Docker(DockerBuildReport),
+    /// Failure chain from make, ninja, CMake or Bazel
+    Build(BuildFailure),
+    /// Dependency conflict, missing package or native build failure
+    Package(PackageFailure),
+    /// JVM thread dump (locks, deadlocks, hot stacks) or OutOfMemoryError
+    Jvm(JvmReport),
+    /// Terraform, Ansible or Helm errors with their file locations
+    Iac(IacReport),
+    /// Linter or type-checker findings grouped by rule
+    Lint(LintReport),
+    /// SQLSTATE, vendor code, statement and names from a database error
+    Database(DatabaseError),
+}
+
+impl TraceDetails {
+    /// Render the details as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        match self {
+            TraceDetails::Sanitizer(report) => report.prompt_summary(),
+            TraceDetails::Syntax(report) => report.prompt_summary(),
+            TraceDetails::Kernel(report) => report.prompt_summary(),
+            TraceDetails::Kubernetes(report) => report.prompt_summary(),
+            TraceDetails::Docker(report) => report.prompt_summary(),
+            TraceDetails::Build(failure) => failure.prompt_summary(),
+            TraceDetails::Package(failure) => failure.prompt_summary(),
+            TraceDetails::Jvm(report) => report.prompt_summary(),
+            TraceDetails::Iac(report) => report.prompt_summary(),
+            TraceDetails::Lint(report) => report.prompt_summary(),
+            TraceDetails::Database(error) => error.prompt_summary(),
+        }
+    }
+
+    /// A condensed replacement for the raw input, for tools whose output is
+    /// mostly noise around the part that matters
+    pub fn focused_input(&self) -> Option<String> {
+        match self {
+            TraceDetails::Sanitizer(_)
+            | TraceDetails::Syntax(_)
+            | TraceDetails::Kernel(_)
+            | TraceDetails::Package(_)
+            | TraceDetails::Jvm(_)
+            | TraceDetails::Database(_) => None,
+            TraceDetails::Kubernetes(report) => Some(report.focused_input()),
+            TraceDetails::Docker(report) => Some(report.focused_input()),
+            TraceDetails::Build(failure) => Some(failure.focused_input()),
+            TraceDetails::Iac(report) => Some(report.focused_input()),
+            TraceDetails::Lint(report) => Some(report.focused_input()),
+        }
+    }
+}
+
 /// A parsed stack trace from any supported language
 #[derive(Debug, Clone, Serialize)]
 pub struct StackTrace {
@@ -126,6 +340,10 @@ pub struct StackTrace {
     pub error_message: String,
     /// Stack frames, ordered from innermost (most recent) to outermost
     pub frames: Vec<StackFrame>,
+    /// Individual diagnostics, for tools that report several problems at once
+    pub diagnostics: Vec<Diagnostic>,
+    /// Structured report for tools that print more than a single stack
+    pub details: Option<TraceDetails>,
     /// The raw text of the stack trace
     pub raw_text: String,
 }
@@ -138,6 +356,8 @@ impl StackTrace {
             error_type: String::new(),
             error_message: String::new(),
             frames: Vec::new(),
+            diagnostics: Vec::new(),
+            details: None,
             raw_text: raw_text.into(),
         }
     }
@@ -159,6 +379,11 @@ impl StackTrace {
         self.frames.push(frame);
     }
 
+    /// Add a diagnostic to the stack trace
+    pub fn add_diagnostic(&mut self, diagnostic: Diagnostic) {
+        self.diagnostics.push(diagnostic);
+    }
+
     /// Get the most relevant frame (first user code frame, or first frame if none)
     pub fn root_cause_frame(&self) -> Option<&StackFrame> {
         self.frames
@@ -171,6 +396,29 @@ impl StackTrace {
     pub fn user_frames(&self) -> Vec<&StackFrame> {
         self.frames.iter().filter(|f| f.is_user_code).collect()
     }
+
+    /// Structured context to add to the model prompt, if any was parsed
+    ///
+    /// Tool reports take precedence; otherwise the diagnostics are listed,
+    /// which is all a compiler like tsc produces
+    pub fn prompt_context(&self) -> Option<String> {
+        if let Some(details) = &self.details {
+            return Some(details.prompt_summary());
+        }
+        if self.diagnostics.is_empty() {
+            return None;
+        }
+        let mut out = format!("Diagnostics ({}):", self.diagnostics.len());
+        for diagnostic in &self.diagnostics {
+            out.push_str(&format!("\n- {}", diagnostic.summary_line()));
+        }
+        Some(out)
+    }
+
+    /// Condensed input to show the model instead of `raw_text`, if any
+    pub fn focused_input(&self) -> Option<String> {
+        self.details.as_ref().and_then(TraceDetails::focused_input)
+    }
 }
 
 // ============================================================================
@@ -206,15 +454,40 @@ impl StackTraceParserRegistry {
     /// Create a registry with all built-in parsers
     pub fn with_builtins() -> Self {
         let mut registry = Self::new();
+        // kubectl output embeds container logs, so it has to win over the
+        // language parsers that would match those
+        registry.register(Box::new(KubernetesParser));
+        registry.register(Box::new(DockerBuildParser));
+        // node-gyp and build scripts print make failures of their own
+        registry.register(Box::new(PackageManagerParser));
+        registry.register(Box::new(BuildSystemParser));
+        // Ansible results carry module tracebacks and database errors
+        registry.register(Box::new(IacParser));
+        // Clippy, mypy and ESLint findings look like compiler diagnostics
+        registry.register(Box::new(LintParser));
+        // Driver exceptions arrive inside Python, Node and Java traces
+        registry.register(Box::new(DatabaseParser));
         registry.register(Box::new(PythonStackTraceParser));
         registry.register(Box::new(RustStackTraceParser));
+        registry.register(Box::new(TypeScriptStackTraceParser));
         registry.register(Box::new(JavaScriptStackTraceParser));
         registry.register(Box::new(GoStackTraceParser));
         registry.register(Box::new(JavaStackTraceParser));
+        registry.register(Box::new(KernelLogParser));
         registry.register(Box::new(CppStackTraceParser));
         registry
     }
 
+    /// All built-in parsers except those for the given languages, for
+    /// parsers that look inside their own input and must not match it again
+    pub fn with_builtins_except(languages: &[Language]) -> Self {
+        let mut registry = Self::with_builtins();
+        registry
+            .parsers
+            .retain(|parser| !languages.contains(&parser.language()));
+        registry
+    }
+
     /// Register a parser
     pub fn register(&mut self, parser: Box<dyn StackTraceParser>) {
         self.parsers.push(parser);
@@ -338,6 +611,30 @@ impl PythonStackTraceParser {
         Some(frame)
     }
 
+    /// Point the trace at a syntax error's caret: the error comes from the
+    /// compiler, so the source line may have been mistaken for the exception
+    fn apply_syntax_error(trace: &mut StackTrace, report: SyntaxErrorReport) {
+        trace.error_type = report.error_type.clone();
+        trace.error_message = report.message.clone();
+
+        // Inside a traceback the File line is already the last frame
+        let last = trace.frames.last_mut().filter(|f| {
+            f.file.as_deref() == Some(report.file.as_path()) && f.line == Some(report.line)
+        });
+        match last {
+            Some(frame) => {
+                frame.column = report.column;
+                frame.is_user_code = !Self::is_framework_path(&report.file.to_string_lossy());
+            }
+            None => {
+                let mut frame = report.to_frame();
+                frame.is_user_code = !Self::is_framework_path(&report.file.to_string_lossy());
+                trace.add_frame(frame);
+            }
+        }
+        trace.details = Some(TraceDetails::Syntax(report));
+    }
+
     /// Check if a path is a framework/stdlib path
     fn is_framework_path(path: &str) -> bool {
         let path_lower = path.to_lowercase();
@@ -425,6 +722,10 @@ impl StackTraceParser for PythonStackTraceParser {
             }
         }
 
+        if let Some(report) = syntax::parse_python_syntax_error(input) {
+            Self::apply_syntax_error(&mut trace, report);
+        }
+
         Some(trace)
     }
 }
@@ -611,51 +912,81 @@ impl StackTraceParser for RustStackTraceParser {
 // JavaScript Parser
 // ============================================================================
 
-/// JavaScript/Node.js stack trace parser
+/// JavaScript stack trace parser for Node.js, Deno and Bun
 pub struct JavaScriptStackTraceParser;
 
 impl JavaScriptStackTraceParser {
     fn parse_error_line(line: &str) -> Option<(String, String)> {
-        let error_types = [
-            "Error",
-            "TypeError",
-            "ReferenceError",
-            "SyntaxError",
-            "RangeError",
-            "URIError",
-            "EvalError",
-            "AggregateError",
-            "InternalError",
-            "AssertionError",
-        ];
-
-        for error_type in error_types {
-            if let Some(rest) = line.strip_prefix(error_type) {
-                if let Some(after_colon) = rest.strip_prefix(':') {
-                    return Some((error_type.to_string(), after_colon.trim().to_string()));
-                } else if rest.is_empty()
-                    || rest
-                        .chars()
-                        .next()
-                        .map(|c| c.is_whitespace())
-                        .unwrap_or(false)
-                {
-                    return Some((error_type.to_string(), rest.trim().to_string()));
-                }
+        // Deno: "error: Uncaught (in promise) TypeError: ..."
+        let line = line
+            .strip_prefix("error: Uncaught (in promise) ")
+            .or_else(|| line.strip_prefix("error: Uncaught "))
+            .unwrap_or(line);
+        // Node < 15: "(node:1234) UnhandledPromiseRejectionWarning: Error: ..."
+        let line = match line.find(") UnhandledPromiseRejectionWarning: ") {
+            Some(pos) if line.starts_with("(node:") => {
+                &line[pos + ") UnhandledPromiseRejectionWarning: ".len()..]
+            }
+            _ => line,
+        };
+
+        // Node >= 15 wraps rejections with non-Error reasons
+        if let Some(rest) = line.strip_prefix("[UnhandledPromiseRejection: ") {
+            let rest = rest.trim_end_matches(" {").trim_end_matches(']');
+            let message = rest
+                .find("The promise rejected with the reason ")
+                .map_or(rest, |pos| &rest[pos..]);
+            return Some(("UnhandledPromiseRejection".to_string(), message.to_string()));
+        }
+
+        // "TypeError: ...", "ValidationError: ...", "Error [ERR_X]: ..."
+        let name_end = line
+            .find(|c: char| !c.is_ascii_alphanumeric() && c != '_')
+            .unwrap_or(line.len());
+        let name = &line[..name_end];
+        let is_error_name = name.starts_with(|c: char| c.is_ascii_uppercase())
+            && (name.ends_with("Error") || name.ends_with("Exception"));
+        if is_error_name {
+            let rest = &line[name_end..];
+            let (error_type, rest) = match rest.strip_prefix(" [").and_then(|r| r.split_once(']')) {
+                Some((code, rest)) => (format!("{} [{}]", name, code), rest),
+                None => (name.to_string(), rest),
+            };
+            if let Some(message) = rest.strip_prefix(':') {
+                return Some((error_type, message.trim().to_string()));
+            }
+            if rest.is_empty() || rest.starts_with(char::is_whitespace) {
+                return Some((error_type, rest.trim().to_string()));
             }
         }
+
         None
     }
 
+    /// Bun prints plain errors as "error: message" right above the stack;
+    /// rustc, go, clang and npm use the same prefix, so the frame has to follow
+    fn parse_bun_error_line(line: &str, next: Option<&str>) -> Option<(String, String)> {
+        let message = line.strip_prefix("error: ")?;
+        next.filter(|next| next.trim_start().starts_with("at "))?;
+        Some(("Error".to_string(), message.trim().to_string()))
+    }
+
     fn parse_frame_line(line: &str) -> Option<StackFrame> {
         let trimmed = line.trim();
         if !trimmed.starts_with("at ") {
             return None;
         }
 
-        let content = &trimmed[3..].trim();
+        let mut content = trimmed[3..].trim();
         let mut frame = StackFrame::new();
 
+        if let Some(rest) = content.strip_prefix("async ") {
+            frame.is_async = true;
+            content = rest;
+        }
+        // The cause's frames end with " {" before its properties
+        content = content.trim_end_matches(" {");
+
         if let Some(paren_start) = content.find(" (") {
             if let Some(paren_end) = content.rfind(')') {
                 let func_name = &content[..paren_start];
@@ -684,25 +1015,52 @@ impl JavaScriptStackTraceParser {
                 if let Ok(line) = parts[1].trim().parse::<u32>() {
                     frame.line = Some(line);
                     if parts.len() > 2 {
-                        frame.file = Some(PathBuf::from(parts[2]));
+                        frame.file = Some(PathBuf::from(Self::normalize_path(parts[2])));
                     }
                 }
             } else if let Ok(line) = parts[0].trim().parse::<u32>() {
                 frame.line = Some(line);
                 if parts.len() > 1 {
                     let file_parts: Vec<&str> = parts[1..].iter().rev().copied().collect();
-                    frame.file = Some(PathBuf::from(file_parts.join(":")));
+                    frame.file = Some(PathBuf::from(Self::normalize_path(&file_parts.join(":"))));
                 }
             }
         }
     }
 
+    /// Turn ESM `file://` URLs and webpack module ids into filesystem paths
+    fn normalize_path(location: &str) -> String {
+        if let Some(rest) = location.strip_prefix("file://") {
+            let path = percent_decode(rest);
+            // file:///C:/app/index.js
+            let bytes = path.as_bytes();
+            if bytes.len() > 3 && bytes[0] == b'/' && bytes[2] == b':' {
+                return path[1..].to_string();
+            }
+            return path;
+        }
+
+        // webpack-internal:///(app-pages-browser)/./src/app/page.tsx
+        if let Some(rest) = location
+            .strip_prefix("webpack-internal:///")
+            .or_else(|| location.strip_prefix("webpack:///"))
+        {
+            let rest = match rest.strip_prefix('(').and_then(|r| r.split_once(")/")) {
+                Some((_, path)) => path,
+                None => rest,
+            };
+            return rest.strip_prefix("./").unwrap_or(rest).to_string();
+        }
+
+        location.to_string()
+    }
+
     fn is_framework_path(frame: &StackFrame) -> bool {
-        let path = frame
-            .file
-            .as_ref()
-            .map(|p| p.to_string_lossy().to_lowercase())
-            .unwrap_or_default();
+        let Some(file) = frame.file.as_ref() else {
+            // "at new Promise (<anonymous>)", "at async Promise.all (index 0)"
+            return true;
+        };
+        let path = file.to_string_lossy().to_lowercase();
 
         let func = frame
             .function
@@ -710,17 +1068,52 @@ impl JavaScriptStackTraceParser {
             .map(|f| f.to_lowercase())
             .unwrap_or_default();
 
+        // Covers pnpm's node_modules/.pnpm/<pkg>@<ver>/node_modules/<pkg> too
         path.contains("node_modules")
-            || path.contains("internal/")
+            || path.starts_with("internal/")
             || path.starts_with("node:")
             || path.starts_with("internal:")
+            // Deno runtime and remote modules, Deno's npm cache
+            || path.starts_with("ext:")
+            || path.starts_with("deno:")
+            || path.starts_with("https://deno.land/")
+            || path.starts_with("https://jsr.io/")
+            || path.starts_with("https://esm.sh/")
+            || path.contains("/deno/npm/")
+            // Bun runtime ("native:7:39") and install cache
+            || path == "native"
+            || path.starts_with("native:")
+            || path.starts_with("bun:")
+            || path.contains("/.bun/install/")
+            || path.starts_with("webpack/")
             || func.starts_with("native ")
-            || func.contains("<anonymous>")
             || func == "module.load"
             || func == "module._compile"
     }
 }
 
+/// Decode %XX escapes in a file URL path
+fn percent_decode(path: &str) -> String {
+    let bytes = path.as_bytes();
+    let mut out = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let escaped = bytes
+            .get(i + 1..i + 3)
+            .filter(|_| bytes[i] == b'%')
+            .and_then(|hex| std::str::from_utf8(hex).ok())
+            .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+        if let Some(byte) = escaped {
+            out.push(byte);
+            i += 3;
+            continue;
+        }
+        out.push(bytes[i]);
+        i += 1;
+    }
+    String::from_utf8_lossy(&out).into_owned()
+}
+
 impl StackTraceParser for JavaScriptStackTraceParser {
     fn language(&self) -> Language {
         Language::JavaScript
@@ -729,8 +1122,13 @@ impl StackTraceParser for JavaScriptStackTraceParser {
     fn can_parse(&self, input: &str) -> bool {
         (input.contains("Error:")
             || input.contains("TypeError:")
-            || input.contains("ReferenceError:"))
+            || input.contains("ReferenceError:")
+            || input.contains("Error ["))
             && input.contains("    at ")
+            || input.contains("SyntaxError:") && syntax::parse_node_syntax_error(input).is_some()
+            || input.contains("[UnhandledPromiseRejection:")
+            || input.contains("error: Uncaught ")
+            || input.contains("    at ") && input.lines().any(|l| l.starts_with("Bun v"))
     }
 
     fn parse(&self, input: &str) -> Option<StackTrace> {
@@ -739,12 +1137,28 @@ impl StackTraceParser for JavaScriptStackTraceParser {
         }
 
         let mut trace = StackTrace::new(Language::JavaScript, input);
+        // Frames of the error and of each nested [cause], outermost first
+        let mut stacks: Vec<Vec<StackFrame>> = vec![Vec::new()];
 
-        for line in input.lines() {
+        let mut lines = input.lines().peekable();
+        while let Some(line) = lines.next() {
             let trimmed = line.trim();
 
+            // Node prints `error.cause` nested under the error it caused; the
+            // innermost cause is where things actually went wrong
+            if let Some(cause) = trimmed.strip_prefix("[cause]: ") {
+                if let Some((error_type, message)) = Self::parse_error_line(cause) {
+                    trace.error_type = error_type;
+                    trace.error_message = message;
+                    stacks.push(Vec::new());
+                }
+                continue;
+            }
+
             if trace.error_type.is_empty() {
-                if let Some((error_type, message)) = Self::parse_error_line(trimmed) {
+                let parsed = Self::parse_error_line(trimmed)
+                    .or_else(|| Self::parse_bun_error_line(trimmed, lines.peek().copied()));
+                if let Some((error_type, message)) = parsed {
                     trace.error_type = error_type;
                     trace.error_message = message;
                     continue;
@@ -752,8 +1166,395 @@ impl StackTraceParser for JavaScriptStackTraceParser {
             }
 
             if let Some(frame) = Self::parse_frame_line(line) {
+                if let Some(stack) = stacks.last_mut() {
+                    stack.push(frame);
+                }
+            }
+        }
+
+        for stack in stacks.into_iter().rev() {
+            for frame in stack {
+                trace.add_frame(frame);
+            }
+        }
+
+        // The frames of a syntax error are the loader compiling the file; the
+        // file itself only appears in the header above the caret
+        if let Some(report) = syntax::parse_node_syntax_error(input) {
+            trace.error_type = report.error_type.clone();
+            trace.error_message = report.message.clone();
+            let mut frame = report.to_frame();
+            frame.is_user_code = !Self::is_framework_path(&frame);
+            trace.frames.insert(0, frame);
+            trace.details = Some(TraceDetails::Syntax(report));
+        }
+
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// TypeScript / Bundler Parser
+// ============================================================================
+
+/// TypeScript compiler (tsc) and bundler (esbuild, Vite, webpack, Babel)
+/// diagnostic parser
+pub struct TypeScriptStackTraceParser;
+
+/// Tool that produced a diagnostic header
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum BuildTool {
+    Tsc,
+    Esbuild,
+    Vite,
+    Webpack,
+    Babel,
+}
+
+/// A diagnostic being assembled from its header and the lines that follow it
+struct PendingDiagnostic {
+    tool: BuildTool,
+    error_type: String,
+    diagnostic: Diagnostic,
+    frame_lines: Vec<String>,
+    seen_blank: bool,
+}
+
+impl PendingDiagnostic {
+    fn new(tool: BuildTool, error_type: impl Into<String>, diagnostic: Diagnostic) -> Self {
+        Self {
+            tool,
+            error_type: error_type.into(),
+            diagnostic,
+            frame_lines: Vec::new(),
+            seen_blank: false,
+        }
+    }
+
+    fn finish(mut self) -> (Diagnostic, String) {
+        if !self.frame_lines.is_empty() {
+            self.diagnostic.code_frame = Some(self.frame_lines.join("\n"));
+        }
+        self.diagnostic.message = self.diagnostic.message.trim().to_string();
+        (self.diagnostic, self.error_type)
+    }
+}
+
+/// Compiled header patterns for the supported tools
+struct BuildPatterns {
+    tsc: Regex,
+    esbuild: Regex,
+    inline_location: Regex,
+    location: Regex,
+    vite: Regex,
+    vite_import: Regex,
+    webpack: Regex,
+    webpack_location: Regex,
+    babel: Regex,
+}
+
+fn build_patterns() -> &'static BuildPatterns {
+    static PATTERNS: OnceLock<BuildPatterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| BuildPatterns {
+        // src/app.ts(12,5): error TS2339: ... / src/app.ts:12:5 - error TS2339: ...
+        tsc: Regex::new(
+            r"^(?:(?P<file>.+?)\((?P<line>\d+),(?P<col>\d+)\): |(?P<pfile>.+?):(?P<pline>\d+):(?P<pcol>\d+) - )?(?P<sev>error|warning|message) (?P<code>TS\d+): (?P<msg>.*)$",
+        )
+        .unwrap(),
+        // ✘ [ERROR] Could not resolve "./missing" [plugin-name]
+        esbuild: Regex::new(
+            r"^[✘X×▲!] \[(?P<sev>ERROR|WARNING)\] (?P<msg>.*?)(?: \[(?P<code>[\w:-]+)\])?$",
+        )
+        .unwrap(),
+        // > src/index.ts:1:20: error: ... (legacy esbuild, Vite transform errors)
+        inline_location: Regex::new(
+            r"^(?:> )?(?P<file>.+?\.(?:[cm]?[jt]sx?|vue|svelte|astro)):(?P<line>\d+):(?P<col>\d+): (?P<sev>error|warning|ERROR|WARNING): (?P<msg>.*)$",
+        )
+        .unwrap(),
+        // "    src/index.ts:1:20:" below an esbuild header, "  File: /app/src/main.ts:3:10" in Vite
+        location: Regex::new(r"^\s*(?:File: )?(?P<file>\S.*?):(?P<line>\d+):(?P<col>\d+):?\s*$")
+            .unwrap(),
+        vite: Regex::new(r"^\[(?:plugin:)?vite(?::[\w:-]+)?\]:? (?P<msg>.+)$").unwrap(),
+        vite_import: Regex::new(r#"from "(?P<file>[^"]+)""#).unwrap(),
+        // ERROR in ./src/index.ts 3:10 / [tsl] ERROR in /app/src/index.ts(12,5)
+        webpack: Regex::new(r"^(?:\[tsl\] )?(?P<sev>ERROR|WARNING) in (?P<loc>.+)$").unwrap(),
+        webpack_location: Regex::new(
+            r"^(?P<file>.+?)(?:\((?P<l1>\d+),(?P<c1>\d+)\)| (?P<l2>\d+):(?P<c2>\d+)(?:-\d+)?|:(?P<l3>\d+):(?P<c3>\d+))?$",
+        )
+        .unwrap(),
+        // SyntaxError: /app/src/index.js: Unexpected token, expected "," (3:10)
+        babel: Regex::new(
+            r"^(?P<kind>\w*Error): (?P<file>.+?\.\w+): (?P<msg>.+?) \((?P<line>\d+):(?P<col>\d+)\)$",
+        )
+        .unwrap(),
+    })
+}
+
+impl TypeScriptStackTraceParser {
+    /// Try to start a new diagnostic from a header line
+    fn parse_header(line: &str) -> Option<PendingDiagnostic> {
+        let patterns = build_patterns();
+
+        if let Some(caps) = patterns.tsc.captures(line) {
+            let code = caps["code"].to_string();
+            let mut diagnostic =
+                Diagnostic::new(Self::parse_severity(&caps["sev"]), &caps["msg"]).with_code(&code);
+            let file = caps.name("file").or_else(|| caps.name("pfile"));
+            let line_num = caps.name("line").or_else(|| caps.name("pline"));
+            let col = caps.name("col").or_else(|| caps.name("pcol"));
+            if let Some(file) = file {
+                diagnostic = diagnostic.with_location(
+                    file.as_str(),
+                    line_num.and_then(|m| m.as_str().parse().ok()),
+                    col.and_then(|m| m.as_str().parse().ok()),
+                );
+            }
+            return Some(PendingDiagnostic::new(BuildTool::Tsc, code, diagnostic));
+        }
+
+        if let Some(caps) = patterns.esbuild.captures(line) {
+            let mut diagnostic = Diagnostic::new(Self::parse_severity(&caps["sev"]), &caps["msg"]);
+            if let Some(code) = caps.name("code") {
+                diagnostic = diagnostic.with_code(code.as_str());
+            }
+            return Some(PendingDiagnostic::new(
+                BuildTool::Esbuild,
+                "BuildError",
+                diagnostic,
+            ));
+        }
+
+        if let Some(caps) = patterns.inline_location.captures(line) {
+            let diagnostic = Diagnostic::new(Self::parse_severity(&caps["sev"]), &caps["msg"])
+                .with_location(
+                    &caps["file"],
+                    caps["line"].parse().ok(),
+                    caps["col"].parse().ok(),
+                );
+            return Some(PendingDiagnostic::new(
+                BuildTool::Esbuild,
+                "BuildError",
+                diagnostic,
+            ));
+        }
+
+        if let Some(caps) = patterns.babel.captures(line) {
+            let diagnostic = Diagnostic::new(Severity::Error, &caps["msg"]).with_location(
+                &caps["file"],
+                caps["line"].parse().ok(),
+                caps["col"].parse().ok(),
+            );
+            return Some(PendingDiagnostic::new(
+                BuildTool::Babel,
+                &caps["kind"],
+                diagnostic,
+            ));
+        }
+
+        if let Some(caps) = patterns.webpack.captures(line) {
+            let mut diagnostic = Diagnostic::new(Self::parse_severity(&caps["sev"]), "");
+            if let Some(loc) = patterns.webpack_location.captures(caps["loc"].trim()) {
+                let line_num = loc.name("l1").or(loc.name("l2")).or(loc.name("l3"));
+                let col = loc.name("c1").or(loc.name("c2")).or(loc.name("c3"));
+                diagnostic = diagnostic.with_location(
+                    &loc["file"],
+                    line_num.and_then(|m| m.as_str().parse().ok()),
+                    col.and_then(|m| m.as_str().parse().ok()),
+                );
+            }
+            return Some(PendingDiagnostic::new(
+                BuildTool::Webpack,
+                "BuildError",
+                diagnostic,
+            ));
+        }
+
+        if let Some(caps) = patterns.vite.captures(line) {
+            let message = caps["msg"]
+                .trim_start_matches("Internal server error:")
+                .trim();
+            // "Transform failed with 1 error:" is followed by the real diagnostics
+            if message.ends_with("error:") || message.ends_with("errors:") {
+                return None;
+            }
+            let mut diagnostic = Diagnostic::new(Severity::Error, message);
+            if let Some(import) = patterns.vite_import.captures(message) {
+                diagnostic = diagnostic.with_location(&import["file"], None, None);
+            }
+            return Some(PendingDiagnostic::new(
+                BuildTool::Vite,
+                "BuildError",
+                diagnostic,
+            ));
+        }
+
+        None
+    }
+
+    /// Feed a non-header line to the diagnostic being collected
+    fn parse_continuation(pending: &mut PendingDiagnostic, line: &str) {
+        let trimmed = line.trim();
+
+        if trimmed.is_empty() {
+            pending.seen_blank = true;
+            return;
+        }
+
+        let is_tsc_source_line = pending.tool == BuildTool::Tsc
+            && pending.seen_blank
+            && trimmed.starts_with(|c: char| c.is_ascii_digit());
+        if is_tsc_source_line || Self::is_code_frame_line(line) {
+            pending.frame_lines.push(line.trim_end().to_string());
+            return;
+        }
+
+        if pending.diagnostic.file.is_none() {
+            if let Some(caps) = build_patterns().location.captures(line) {
+                pending.diagnostic.file = Some(PathBuf::from(&caps["file"]));
+                pending.diagnostic.line = caps["line"].parse().ok();
+                pending.diagnostic.column = caps["col"].parse().ok();
+                return;
+            }
+        }
+
+        if pending.diagnostic.message.is_empty() {
+            // webpack prints the loader chain before the actual error
+            if trimmed.starts_with("Module build failed") || trimmed.starts_with("Module Error") {
+                return;
+            }
+            if let Some((code, message)) = trimmed.split_once(": ") {
+                if code.starts_with("TS") && code[2..].chars().all(|c| c.is_ascii_digit()) {
+                    pending.diagnostic.code = Some(code.to_string());
+                    pending.diagnostic.message = message.to_string();
+                    pending.error_type = code.to_string();
+                    return;
+                }
+            }
+            pending.diagnostic.message = trimmed.to_string();
+            return;
+        }
+
+        // tsc elaborates a message with indented lines before the code frame
+        if pending.tool == BuildTool::Tsc
+            && !pending.seen_blank
+            && pending.frame_lines.is_empty()
+            && line.starts_with(char::is_whitespace)
+        {
+            pending.diagnostic.message.push('\n');
+            pending.diagnostic.message.push_str(trimmed);
+        }
+    }
+
+    /// Check if a line is part of a source excerpt (code frame)
+    fn is_code_frame_line(line: &str) -> bool {
+        let trimmed = line.trim();
+        if trimmed.is_empty() {
+            return false;
+        }
+
+        // esbuild uses box-drawing gutters
+        if trimmed.contains('│') || trimmed.starts_with('╵') {
+            return true;
+        }
+
+        // Babel and Vite: "> 3 |   c: 2" and "    |   ^"
+        let gutter = trimmed.trim_start_matches('>').trim_start();
+        let digits = gutter.chars().take_while(|c| c.is_ascii_digit()).count();
+        if gutter[digits..].trim_start().starts_with('|') {
+            return true;
+        }
+
+        // Underline rows ("~~~~~" or "^")
+        trimmed
+            .chars()
+            .all(|c| c == '~' || c == '^' || c.is_whitespace())
+    }
+
+    fn parse_severity(text: &str) -> Severity {
+        match text.to_lowercase().as_str() {
+            "warning" => Severity::Warning,
+            "message" => Severity::Info,
+            _ => Severity::Error,
+        }
+    }
+
+    fn is_framework_path(path: &Path) -> bool {
+        let path = path.to_string_lossy();
+        path.contains("node_modules") || path.starts_with("node:")
+    }
+}
+
+impl StackTraceParser for TypeScriptStackTraceParser {
+    fn language(&self) -> Language {
+        Language::TypeScript
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        strip_ansi(input)
+            .lines()
+            .any(|line| Self::parse_header(line.trim_end()).is_some())
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let cleaned = strip_ansi(input);
+        let mut collected: Vec<(Diagnostic, String)> = Vec::new();
+        let mut pending: Option<PendingDiagnostic> = None;
+
+        for line in cleaned.lines() {
+            let line = line.trim_end();
+
+            if let Some(header) = Self::parse_header(line) {
+                // webpack reports the Babel error on the lines after its own header
+                if let Some(current) = pending.as_mut() {
+                    if current.tool == BuildTool::Webpack
+                        && current.diagnostic.message.is_empty()
+                        && header.tool == BuildTool::Babel
+                    {
+                        current.error_type = header.error_type;
+                        current.diagnostic.message = header.diagnostic.message;
+                        current.diagnostic.file = header.diagnostic.file;
+                        current.diagnostic.line = header.diagnostic.line;
+                        current.diagnostic.column = header.diagnostic.column;
+                        continue;
+                    }
+                }
+                if let Some(done) = pending.take() {
+                    collected.push(done.finish());
+                }
+                pending = Some(header);
+                continue;
+            }
+
+            if let Some(current) = pending.as_mut() {
+                Self::parse_continuation(current, line);
+            }
+        }
+
+        if let Some(done) = pending.take() {
+            collected.push(done.finish());
+        }
+
+        if collected.is_empty() {
+            return None;
+        }
+
+        let mut trace = StackTrace::new(Language::TypeScript, input);
+
+        let primary = collected
+            .iter()
+            .find(|(d, _)| d.severity == Severity::Error)
+            .unwrap_or(&collected[0]);
+        trace.error_type = primary.1.clone();
+        trace.error_message = primary.0.message.lines().next().unwrap_or("").to_string();
+
+        for (diagnostic, _) in collected {
+            if let Some(ref file) = diagnostic.file {
+                let frame = diagnostic
+                    .to_frame()
+                    .with_is_user_code(!Self::is_framework_path(file));
                 trace.add_frame(frame);
             }
+            trace.add_diagnostic(diagnostic);
         }
 
         Some(trace)
@@ -961,6 +1762,7 @@ impl StackTraceParser for JavaStackTraceParser {
         input.contains("Exception in thread")
             || input.contains("at ") && input.contains(".java:")
             || input.contains("Caused by:")
+            || jvm::is_jvm_report(input)
     }
 
     fn parse(&self, input: &str) -> Option<StackTrace> {
@@ -968,6 +1770,32 @@ impl StackTraceParser for JavaStackTraceParser {
             return None;
         }
 
+        let report = jvm::parse_jvm_report(input);
+        if let Some(dump) = report.as_ref().and_then(|r| r.dump.as_ref()) {
+            // A dump holds every thread's stack; keep only the one that matters
+            let oom = report.as_ref().and_then(|r| r.oom.as_ref());
+            let (error_type, message) = match (oom, dump.deadlock_summary()) {
+                (Some(oom), _) => ("java.lang.OutOfMemoryError", oom.kind.clone()),
+                (None, Some(summary)) => ("Deadlock", summary),
+                (None, None) => ("ThreadDump", dump.summary()),
+            };
+            let mut trace = StackTrace::new(Language::Java, input)
+                .with_error_type(error_type)
+                .with_error_message(message);
+            let oom_thread = oom
+                .and_then(|oom| oom.thread.as_deref())
+                .and_then(|name| dump.thread(name));
+            if let Some(thread) = oom_thread.or_else(|| dump.focus_thread()) {
+                for frame in &thread.frames {
+                    if let Some(frame) = Self::parse_frame_line(&format!("at {}", frame)) {
+                        trace.add_frame(frame);
+                    }
+                }
+            }
+            trace.details = report.map(TraceDetails::Jvm);
+            return Some(trace);
+        }
+
         let mut trace = StackTrace::new(Language::Java, input);
 
         for line in input.lines() {
@@ -992,6 +1820,16 @@ impl StackTraceParser for JavaStackTraceParser {
             }
         }
 
+        if let Some(report) = report {
+            if let Some(oom) = &report.oom {
+                if !trace.error_type.ends_with("OutOfMemoryError") {
+                    trace.error_type = "java.lang.OutOfMemoryError".to_string();
+                    trace.error_message = oom.kind.clone();
+                }
+            }
+            trace.details = Some(TraceDetails::Jvm(report));
+        }
+
         Some(trace)
     }
 }
@@ -1083,6 +1921,74 @@ impl CppStackTraceParser {
         Some(frame)
     }
 
+    /// Parse an lldb frame like
+    /// `* frame #0: 0x0000000100003f6c server`handle(req=0x0) at server.c:42:9`
+    fn parse_lldb_frame(line: &str) -> Option<StackFrame> {
+        let trimmed = line.trim().trim_start_matches("* ");
+        let rest = trimmed.strip_prefix("frame #")?;
+        let (_, rest) = rest.split_once(": ")?;
+        let rest = match rest.split_once(' ') {
+            Some((address, rest)) if address.starts_with("0x") => rest,
+            _ => rest,
+        };
+        let (module, rest) = rest.split_once('`').unwrap_or(("", rest));
+
+        let (func_part, location) = match rest.split_once(" at ") {
+            Some((func, location)) => (func, Some(location)),
+            None => (rest, None),
+        };
+        let func = func_part
+            .split(" + ")
+            .next()
+            .unwrap_or(func_part)
+            .split('(')
+            .next()
+            .unwrap_or(func_part)
+            .trim();
+
+        let mut frame = StackFrame::new();
+        if !func.is_empty() {
+            frame.function = Some(func.to_string());
+        }
+        frame.is_user_code = !Self::is_framework_function(func)
+            && !(module.starts_with("lib")
+                && (module.contains(".so") || module.contains(".dylib")));
+
+        if let Some(location) = location {
+            let mut parts = location.trim().split(':');
+            frame.file = parts.next().map(PathBuf::from);
+            frame.line = parts.next().and_then(|l| l.parse().ok());
+            frame.column = parts.next().and_then(|c| c.parse().ok());
+        }
+
+        Some(frame)
+    }
+
+    /// Join gdb frames whose argument list wrapped onto following lines
+    fn join_wrapped_frames(input: &str) -> Vec<String> {
+        let mut lines: Vec<String> = Vec::new();
+        let mut open = 0i32;
+        for line in input.lines() {
+            let depth = line.matches('(').count() as i32 - line.matches(')').count() as i32;
+            match lines.last_mut() {
+                Some(last) if open > 0 => {
+                    last.push(' ');
+                    last.push_str(line.trim());
+                    open += depth;
+                }
+                _ => {
+                    lines.push(line.to_string());
+                    open = if line.trim_start().starts_with('#') {
+                        depth
+                    } else {
+                        0
+                    };
+                }
+            }
+        }
+        lines
+    }
+
     fn parse_asan_frame(line: &str) -> Option<StackFrame> {
         let trimmed = line.trim();
 
@@ -1153,8 +2059,10 @@ impl StackTraceParser for CppStackTraceParser {
 
     fn can_parse(&self, input: &str) -> bool {
         input.contains("#0 ") && (input.contains(" in ") || input.contains(" at "))
+            || input.contains("frame #0: ")
             || input.contains("AddressSanitizer:")
             || input.contains("Segmentation fault")
+            || sanitizer::is_sanitizer_output(input)
     }
 
     fn parse(&self, input: &str) -> Option<StackTrace> {
@@ -1162,9 +2070,21 @@ impl StackTraceParser for CppStackTraceParser {
             return None;
         }
 
+        if let Some(report) = sanitizer::parse_sanitizer_report(input) {
+            let mut trace = StackTrace::new(Language::Cpp, input)
+                .with_error_type(report.tool.to_string())
+                .with_error_message(report.message.clone());
+            for frame in report.primary_frames() {
+                trace.add_frame(frame.clone());
+            }
+            trace.details = Some(TraceDetails::Sanitizer(report));
+            return Some(trace);
+        }
+
         let mut trace = StackTrace::new(Language::Cpp, input);
 
-        for line in input.lines() {
+        for line in Self::join_wrapped_frames(input) {
+            let line = line.as_str();
             if trace.error_type.is_empty() {
                 if let Some((error_type, message)) = Self::parse_asan_line(line) {
                     trace.error_type = error_type;
@@ -1182,15 +2102,20 @@ impl StackTraceParser for CppStackTraceParser {
             }
 
             if line.trim().starts_with('#') {
-                if let Some(frame) = Self::parse_asan_frame(line) {
-                    if frame.function.is_some() || frame.file.is_some() {
-                        trace.add_frame(frame);
-                        continue;
+                // gdb puts the location after " at "; ASan after the function
+                if !line.contains(" at ") {
+                    if let Some(frame) = Self::parse_asan_frame(line) {
+                        if frame.function.is_some() || frame.file.is_some() {
+                            trace.add_frame(frame);
+                            continue;
+                        }
                     }
                 }
                 if let Some(frame) = Self::parse_gdb_frame(line) {
                     trace.add_frame(frame);
                 }
+            } else if let Some(frame) = Self::parse_lldb_frame(line) {
+                trace.add_frame(frame);
             }
         }
 
@@ -1198,6 +2123,340 @@ impl StackTraceParser for CppStackTraceParser {
     }
 }
 
+// ============================================================================
+// Kernel Log Parser
+// ============================================================================
+
+/// Kernel log (dmesg) parser for segfaults, traps, OOM kills and hung tasks
+pub struct KernelLogParser;
+
+impl StackTraceParser for KernelLogParser {
+    fn language(&self) -> Language {
+        Language::Kernel
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        kernel::is_kernel_log(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let report = kernel::parse_kernel_log(input)?;
+        let event = report.primary()?;
+
+        let error_type = match event.kind {
+            kernel::KernelEventKind::Segfault => "Segfault".to_string(),
+            kernel::KernelEventKind::Trap => "Trap".to_string(),
+            kernel::KernelEventKind::OomKill => "OutOfMemory".to_string(),
+            kernel::KernelEventKind::HungTask => "HungTask".to_string(),
+        };
+        let mut trace = StackTrace::new(Language::Kernel, input)
+            .with_error_type(error_type)
+            .with_error_message(format!("{}: {}", event.process, event.description));
+
+        if let Some(frame) = event.fault_frame() {
+            trace.add_frame(frame);
+        }
+        for frame in &event.call_trace {
+            trace.add_frame(frame.clone());
+        }
+
+        trace.details = Some(TraceDetails::Kernel(report));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Kubernetes Parser
+// ============================================================================
+
+/// kubectl describe/get events/get pod -o yaml|json parser for pod failures
+pub struct KubernetesParser;
+
+impl StackTraceParser for KubernetesParser {
+    fn language(&self) -> Language {
+        Language::Kubernetes
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        kubernetes::is_kubectl_output(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let report = kubernetes::parse_kubectl_output(input)?;
+        let failure = report.primary_failure()?;
+
+        let mut trace = StackTrace::new(Language::Kubernetes, input)
+            .with_error_type(failure.reason)
+            .with_error_message(failure.summary);
+        trace.details = Some(TraceDetails::Kubernetes(report));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Docker Build Parser
+// ============================================================================
+
+/// BuildKit and legacy `docker build` output parser
+pub struct DockerBuildParser;
+
+impl StackTraceParser for DockerBuildParser {
+    fn language(&self) -> Language {
+        Language::Docker
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        docker::is_docker_build_output(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let mut report = docker::parse_docker_build(input)?;
+        if report.failed_step.is_none() && report.error.is_none() {
+            return None;
+        }
+
+        // The failing step's output is usually a compiler or runtime error
+        // in its own right; parse it with the language parsers
+        let nested = report
+            .failed_step
+            .as_ref()
+            .filter(|step| !step.output.is_empty())
+            .and_then(|step| {
+                // A `RUN make` step holds a build failure, which nests again;
+                // leave out the parsers that would recurse
+                StackTraceParserRegistry::with_builtins_except(&[Language::Docker, Language::Build])
+                    .parse(&step.output.join("\n"))
+            });
+
+        let mut trace = StackTrace::new(Language::Docker, input)
+            .with_error_type("DockerBuildError")
+            .with_error_message(report.summary());
+
+        if let Some(location) = &report.location {
+            let mut frame = StackFrame::new()
+                .with_file(&location.file)
+                .with_line(location.line);
+            frame.function = report.failed_step.as_ref().map(|s| s.instruction.clone());
+            trace.add_frame(frame);
+        }
+        if let Some(nested) = &nested {
+            for frame in &nested.frames {
+                trace.add_frame(frame.clone());
+            }
+            for diagnostic in &nested.diagnostics {
+                trace.add_diagnostic(diagnostic.clone());
+            }
+        }
+
+        report.nested = nested.map(Box::new);
+        trace.details = Some(TraceDetails::Docker(report));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Build System Parser
+// ============================================================================
+
+/// make, ninja, CMake and Bazel failure parser
+pub struct BuildSystemParser;
+
+impl StackTraceParser for BuildSystemParser {
+    fn language(&self) -> Language {
+        Language::Build
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        build_system::is_build_failure(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let mut failure = build_system::parse_build_failure(input)?;
+
+        // The isolated block usually holds a compiler or script error
+        // Without this parser and Docker's, a block that still looks like a
+        // build failure can't send the parse around again
+        let nested =
+            StackTraceParserRegistry::with_builtins_except(&[Language::Build, Language::Docker])
+                .parse(&failure.block.join("\n"));
+
+        let mut trace = StackTrace::new(Language::Build, input)
+            .with_error_type("BuildError")
+            .with_error_message(failure.summary());
+
+        // Innermost first: the nested error's own frames, then each failing
+        // target's Makefile/BUILD location
+        if let Some(nested) = &nested {
+            for frame in &nested.frames {
+                trace.add_frame(frame.clone());
+            }
+            for diagnostic in &nested.diagnostics {
+                trace.add_diagnostic(diagnostic.clone());
+            }
+        }
+        for frame in failure.frames() {
+            trace.add_frame(frame);
+        }
+
+        failure.nested = nested.map(Box::new);
+        trace.details = Some(TraceDetails::Build(failure));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Package Manager Parser
+// ============================================================================
+
+/// npm/pnpm/yarn, pip, cargo and go module failure parser
+pub struct PackageManagerParser;
+
+impl StackTraceParser for PackageManagerParser {
+    fn language(&self) -> Language {
+        Language::Package
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        package_manager::is_package_failure(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let failure = package_manager::parse_package_failure(input)?;
+
+        let mut trace = StackTrace::new(Language::Package, input)
+            .with_error_type(failure.kind.error_type())
+            .with_error_message(failure.summary());
+        trace.details = Some(TraceDetails::Package(failure));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Infrastructure-as-Code Parser
+// ============================================================================
+
+/// Terraform, Ansible and Helm error parser
+pub struct IacParser;
+
+impl StackTraceParser for IacParser {
+    fn language(&self) -> Language {
+        Language::Iac
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        iac::is_iac_error(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let report = iac::parse_iac_error(input)?;
+        let primary = report.primary()?;
+
+        let mut trace = StackTrace::new(Language::Iac, input)
+            .with_error_type(report.error_type())
+            .with_error_message(&primary.summary);
+        for frame in report.frames() {
+            trace.add_frame(frame);
+        }
+        for error in &report.errors {
+            trace.add_diagnostic(error.to_diagnostic());
+        }
+
+        trace.details = Some(TraceDetails::Iac(report));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Lint Parser
+// ============================================================================
+
+/// ESLint, Ruff/flake8/Pylint, mypy/Pyright, golangci-lint/go vet and Clippy
+/// output parser
+pub struct LintParser;
+
+impl StackTraceParser for LintParser {
+    fn language(&self) -> Language {
+        Language::Lint
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        lint::is_lint_output(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let report = lint::parse_lint_output(input)?;
+        let first = report.rules.first()?;
+
+        let mut trace = StackTrace::new(Language::Lint, input)
+            .with_error_type(&first.rule)
+            .with_error_message(report.summary());
+        for finding in report.findings() {
+            if finding.file.is_some() {
+                trace.add_frame(finding.to_frame());
+            }
+            trace.add_diagnostic(finding.clone());
+        }
+
+        trace.details = Some(TraceDetails::Lint(report));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Database Parser
+// ============================================================================
+
+/// PostgreSQL, MySQL and SQLite error parser, including ORM-wrapped errors
+pub struct DatabaseParser;
+
+impl StackTraceParser for DatabaseParser {
+    fn language(&self) -> Language {
+        Language::Sql
+    }
+
+    fn can_parse(&self, input: &str) -> bool {
+        database::is_database_error(input)
+    }
+
+    fn parse(&self, input: &str) -> Option<StackTrace> {
+        let error = database::parse_database_error(input)?;
+
+        let mut trace = StackTrace::new(Language::Sql, input)
+            .with_error_type(error.error_type())
+            .with_error_message(&error.message);
+
+        // Keep the frames of the application code that ran the query. Only
+        // the language parsers are tried, since this one would match again.
+        let mut languages = StackTraceParserRegistry::new();
+        languages.register(Box::new(PythonStackTraceParser));
+        languages.register(Box::new(RustStackTraceParser));
+        languages.register(Box::new(TypeScriptStackTraceParser));
+        languages.register(Box::new(JavaScriptStackTraceParser));
+        languages.register(Box::new(GoStackTraceParser));
+        languages.register(Box::new(JavaStackTraceParser));
+        if let Some(nested) = languages.parse(input) {
+            for frame in nested.frames {
+                trace.add_frame(frame);
+            }
+        }
+
+        trace.details = Some(TraceDetails::Database(error));
+        Some(trace)
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/// Remove ANSI color escape sequences from tool output
+pub fn strip_ansi(text: &str) -> String {
+    static ANSI: OnceLock<Regex> = OnceLock::new();
+    ANSI.get_or_init(|| Regex::new(r"\x1b\[[0-9;?]*[A-Za-z]").unwrap())
+        .replace_all(text, "")
+        .into_owned()
+}
+
 // ============================================================================
 // Source Context
 // ============================================================================
@@ -1341,6 +2600,8 @@ pub struct StackFrameJson {
     #[serde(skip_serializing_if = "Option::is_none")]
     pub column: Option<u32>,
     pub is_user_code: bool,
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub is_async: bool,
 }
 
 impl From<&StackFrame> for StackFrameJson {
@@ -1351,6 +2612,7 @@ impl From<&StackFrame> for StackFrameJson {
             line: frame.line,
             column: frame.column,
             is_user_code: frame.is_user_code,
+            is_async: frame.is_async,
         }
     }
 }
@@ -1364,6 +2626,10 @@ pub struct StackTraceJson {
     pub frames: Vec<StackFrameJson>,
     #[serde(skip_serializing_if = "Option::is_none")]
     pub root_cause_frame: Option<StackFrameJson>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub diagnostics: Vec<Diagnostic>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub details: Option<TraceDetails>,
 }
 
 impl From<&StackTrace> for StackTraceJson {
@@ -1374,6 +2640,8 @@ impl From<&StackTrace> for StackTraceJson {
             error_message: trace.error_message.clone(),
             frames: trace.frames.iter().map(StackFrameJson::from).collect(),
             root_cause_frame: trace.root_cause_frame().map(StackFrameJson::from),
+            diagnostics: trace.diagnostics.clone(),
+            details: trace.details.clone(),
         }
     }
 }
@@ -1606,6 +2874,592 @@ mod tests {
         assert_eq!(registry.detect_language(input), Language::Cpp);
     }
 
+    #[test]
+    fn test_cpp_gdb_core_backtrace() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "Core was generated by `./server --port 8080'.
+Program terminated with signal SIGSEGV, Segmentation fault.
+#0  0x000055555555513d in parse_header (buf=0x0, len=12) at src/http.c:41
+        i = 0
+#1  0x00005555555551a2 in handle_request (conn=0x5555555592a0, opts=0x7fffffffe3c0,
+    flags=3) at src/server.c:88
+        req = {method = 0x0}
+#2  0x00007ffff7c29d90 in __libc_start_call_main () from /lib/x86_64-linux-gnu/libc.so.6";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Cpp);
+        assert_eq!(trace.error_message, "Segmentation fault (SIGSEGV)");
+        assert_eq!(trace.frames.len(), 3);
+        assert_eq!(trace.frames[0].function.as_deref(), Some("parse_header"));
+        assert_eq!(trace.frames[0].file, Some(PathBuf::from("src/http.c")));
+        assert_eq!(trace.frames[1].function.as_deref(), Some("handle_request"));
+        assert_eq!(trace.frames[1].line, Some(88));
+        assert!(!trace.frames[2].is_user_code);
+    }
+
+    #[test]
+    fn test_cpp_lldb_core_backtrace() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "* thread #1, name = 'server', stop reason = signal SIGSEGV: invalid address (fault address: 0x0)
+  * frame #0: 0x0000555555555139 server`parse_header(buf=0x0000000000000000, len=12) at http.c:41:14
+    frame #1: 0x00005555555551a2 server`main at server.c:88:5
+    frame #2: 0x00007ffff7c29d90 libc.so.6`__libc_start_call_main + 128";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Cpp);
+        assert_eq!(trace.error_message, "Segmentation fault (SIGSEGV)");
+        assert_eq!(trace.frames.len(), 3);
+        assert_eq!(trace.frames[0].function.as_deref(), Some("parse_header"));
+        assert_eq!(trace.frames[0].line, Some(41));
+        assert_eq!(trace.frames[0].column, Some(14));
+        assert_eq!(trace.frames[1].function.as_deref(), Some("main"));
+        assert!(!trace.frames[2].is_user_code);
+    }
+
+    #[test]
+    fn test_cpp_sanitizer_report_attaches_details() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "WARNING: ThreadSanitizer: data race (pid=1)\n\
+                     \x20 Write of size 4 at 0x7b04 by thread T1:\n\
+                     \x20   #0 worker /app/race.c:6:11 (race+0x4b5a)\n\
+                     \n\
+                     \x20 Previous read of size 4 at 0x7b04 by main thread:\n\
+                     \x20   #0 main /app/race.c:14:3 (race+0x4bd0)";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Cpp);
+        assert_eq!(trace.error_type, "ThreadSanitizer");
+        assert_eq!(trace.error_message, "data race");
+        assert_eq!(trace.frames.len(), 1);
+        assert_eq!(trace.frames[0].column, Some(11));
+        assert!(matches!(trace.details, Some(TraceDetails::Sanitizer(_))));
+
+        let context = trace.prompt_context().unwrap();
+        assert!(context.contains("previous access stack"));
+        assert!(context.contains("main (/app/race.c:14)"));
+    }
+
+    #[test]
+    fn test_kernel_oom_kill_detected_before_cpp() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "[ 51.2] Out of memory: Killed process 4242 (node) total-vm:2048000kB, anon-rss:1900000kB, file-rss:0kB, shmem-rss:0kB";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Kernel);
+        assert_eq!(trace.error_type, "OutOfMemory");
+        assert!(trace
+            .error_message
+            .starts_with("node: killed by the OOM killer"));
+        assert!(trace.frames.is_empty());
+        assert!(trace.prompt_context().unwrap().contains("anon-rss 1.8 GiB"));
+    }
+
+    #[test]
+    fn test_kubernetes_describe_detected_before_python() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "Name:         api-0
+Namespace:    default
+Status:       Running
+Containers:
+  api:
+    State:          Waiting
+      Reason:       CrashLoopBackOff
+    Last State:     Terminated
+      Reason:       Error
+      Message:      Traceback (most recent call last):
+      Exit Code:    1
+    Restart Count:  4
+";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Kubernetes);
+        assert_eq!(trace.error_type, "CrashLoopBackOff");
+        assert!(trace.error_message.contains("exit code 1"));
+        assert!(trace
+            .focused_input()
+            .unwrap()
+            .starts_with("Pod default/api-0 (phase Running)"));
+    }
+
+    #[test]
+    fn test_docker_build_parses_nested_step_output() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "#6 [2/2] RUN python -c 'import app'
+#6 0.301 Traceback (most recent call last):
+#6 0.301   File \"/src/app.py\", line 3, in <module>
+#6 0.301     import requests
+#6 0.302 ModuleNotFoundError: No module named 'requests'
+#6 ERROR: process \"/bin/sh -c python -c 'import app'\" did not complete successfully: exit code: 1
+Dockerfile:4
+--------------------
+   4 | >>> RUN python -c 'import app'
+--------------------
+ERROR: failed to solve: process \"/bin/sh -c python -c 'import app'\" did not complete successfully: exit code: 1";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Docker);
+        assert_eq!(trace.error_type, "DockerBuildError");
+        assert_eq!(trace.frames[0].file, Some(PathBuf::from("Dockerfile")));
+        assert_eq!(trace.frames[0].line, Some(4));
+        assert_eq!(trace.frames[1].file, Some(PathBuf::from("/src/app.py")));
+
+        let context = trace.prompt_context().unwrap();
+        assert!(context.contains("- exit code 1: General error"));
+        assert!(context.contains("contains a python error: ModuleNotFoundError"));
+        assert!(trace
+            .focused_input()
+            .unwrap()
+            .contains("Failing step: [2/2] RUN python -c 'import app'"));
+    }
+
+    #[test]
+    fn test_docker_build_with_failing_make_step() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "#7 [3/3] RUN make
+#7 0.412 cc -c main.c -o main.o
+#7 0.530 make: *** [Makefile:4: main.o] Error 1
+#7 ERROR: process \"/bin/sh -c make\" did not complete successfully: exit code: 2
+Dockerfile:6
+--------------------
+   6 | >>> RUN make
+--------------------
+ERROR: failed to solve: process \"/bin/sh -c make\" did not complete successfully: exit code: 2";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Docker);
+        assert_eq!(trace.frames[0].file, Some(PathBuf::from("Dockerfile")));
+        assert!(trace
+            .focused_input()
+            .unwrap()
+            .contains("Failing step: [3/3] RUN make"));
+    }
+
+    #[test]
+    fn test_build_failure_parses_nested_block() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "python3 scripts/gen.py
+Traceback (most recent call last):
+  File \"/repo/scripts/gen.py\", line 8, in <module>
+    main()
+  File \"/repo/scripts/gen.py\", line 5, in main
+    open(\"schema.json\")
+FileNotFoundError: [Errno 2] No such file or directory: 'schema.json'
+make: *** [Makefile:12: generated.h] Error 1";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Build);
+        assert_eq!(trace.error_type, "BuildError");
+        assert_eq!(
+            trace.error_message,
+            "make: generated.h (Makefile:12) failed with exit code 1"
+        );
+        assert_eq!(
+            trace.frames.last().unwrap().file,
+            Some(PathBuf::from("Makefile"))
+        );
+        assert_eq!(trace.frames.last().unwrap().line, Some(12));
+        assert_eq!(trace.frames.len(), 3);
+
+        let context = trace.prompt_context().unwrap();
+        assert!(context.contains("- failing command: python3 scripts/gen.py"));
+        assert!(context.contains("contains a python error: FileNotFoundError"));
+    }
+
+    #[test]
+    fn test_build_failure_cmake_configure_does_not_recurse() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "CMake Error at CMakeLists.txt:12 (find_package):
+  Could not find a package configuration file provided by \"Foo\".
+
+-- Configuring incomplete, errors occurred!";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Build);
+        assert_eq!(trace.frames[0].file, Some(PathBuf::from("CMakeLists.txt")));
+        assert!(!trace.prompt_context().unwrap().contains("contains a"));
+    }
+
+    #[test]
+    fn test_build_failure_single_make_line_does_not_recurse() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let trace = registry.parse("make: *** [all] Error 2").unwrap();
+        assert_eq!(trace.language, Language::Build);
+        assert_eq!(trace.error_type, "BuildError");
+        assert!(trace.error_message.contains("all"));
+    }
+
+    #[test]
+    fn test_package_failure_detected_before_build() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "error: failed to run custom build command for `openssl-sys v0.9.93`
+
+Caused by:
+  process didn't exit successfully: `/app/target/debug/build/openssl-sys-1/build-script-main` (exit status: 101)
+  --- stderr
+  Could not find directory of OpenSSL installation
+  make: *** [Makefile:3: build] Error 101";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Package);
+        assert_eq!(trace.error_type, "NativeBuildFailed");
+        assert_eq!(
+            trace.error_message,
+            "cargo: build script failed for openssl-sys v0.9.93"
+        );
+        assert!(trace.focused_input().is_none());
+        assert!(trace.prompt_context().unwrap().contains("pkg-config"));
+    }
+
+    #[test]
+    fn test_terraform_error_has_location_frame() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = r#"╷
+│ Error: Reference to undeclared input variable
+│
+│   on main.tf line 8, in resource "aws_instance" "web":
+│    8:   instance_type = var.instance_typ
+│
+│ An input variable with the name "instance_typ" has not been declared.
+╵"#;
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Iac);
+        assert_eq!(trace.error_type, "TerraformError");
+        assert_eq!(
+            trace.error_message,
+            "Reference to undeclared input variable"
+        );
+        let frame = trace.root_cause_frame().unwrap();
+        assert_eq!(frame.file.as_deref(), Some(Path::new("main.tf")));
+        assert_eq!(frame.line, Some(8));
+        assert_eq!(trace.diagnostics.len(), 1);
+        assert!(trace
+            .focused_input()
+            .unwrap()
+            .starts_with("Error: Reference"));
+    }
+
+    #[test]
+    fn test_lint_output_detected_before_rust() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "warning: unneeded `return` statement
+ --> src/main.rs:3:5
+  |
+3 |     return x;
+  |     ^^^^^^^^^
+  = note: `#[warn(clippy::needless_return)]` on by default
+
+error[E0425]: cannot find value `y` in this scope
+ --> src/lib.rs:8:9
+  |
+8 |         y
+  |         ^ not found in this scope";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Lint);
+        assert_eq!(trace.error_type, "E0425");
+        assert_eq!(trace.diagnostics.len(), 2);
+        let frame = trace.root_cause_frame().unwrap();
+        assert_eq!(frame.file.as_deref(), Some(Path::new("src/lib.rs")));
+        assert!(trace
+            .focused_input()
+            .unwrap()
+            .contains("Clippy reported clippy::needless_return (warning, 1 location)"));
+    }
+
+    #[test]
+    fn test_database_error_keeps_python_frames() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = r#"Traceback (most recent call last):
+  File "/app/main.py", line 12, in <module>
+    create_user(session, "ann@example.com")
+  File "/app/users.py", line 8, in create_user
+    session.commit()
+psycopg2.errors.UniqueViolation: duplicate key value violates unique constraint "users_email_key"
+DETAIL:  Key (email)=(ann@example.com) already exists."#;
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Sql);
+        assert_eq!(trace.error_type, "unique_violation");
+        assert_eq!(trace.frames.len(), 2);
+        let context = trace.prompt_context().unwrap();
+        assert!(context.contains("Database error (PostgreSQL)"));
+        assert!(context.contains("- involves constraint users_email_key, column email"));
+    }
+
+    #[test]
+    fn test_python_syntax_error_source_is_not_the_exception() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = r#"  File "/app/report.py", line 4
+    print("Error: total is" total)
+          ^^^^^^^^^^^^^^^^^^^^^^^
+SyntaxError: invalid syntax. Perhaps you forgot a comma?"#;
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Python);
+        assert_eq!(trace.error_type, "SyntaxError");
+        assert_eq!(trace.frames.len(), 1);
+        let frame = trace.root_cause_frame().unwrap();
+        assert_eq!(frame.line, Some(4));
+        assert_eq!(frame.column, Some(7));
+        assert!(trace
+            .prompt_context()
+            .unwrap()
+            .starts_with("SyntaxError at /app/report.py:4:7\n"));
+    }
+
+    #[test]
+    fn test_node_syntax_error_frame_leads_loader_frames() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "/app/server.js:12
+    app.get('/', (req, res) => {;
+                                ^
+
+SyntaxError: Unexpected token ';'
+    at wrapSafe (node:internal/modules/cjs/loader:1464:18)
+    at Module._compile (node:internal/modules/cjs/loader:1495:20)";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::JavaScript);
+        assert_eq!(trace.error_message, "Unexpected token ';'");
+        assert_eq!(trace.frames.len(), 3);
+        let frame = trace.root_cause_frame().unwrap();
+        assert_eq!(frame.file, Some(PathBuf::from("/app/server.js")));
+        assert_eq!(frame.column, Some(33));
+    }
+
+    #[test]
+    fn test_node_async_frames_and_esm_urls() {
+        let input = "TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (file:///srv/my%20app/src/users.mjs:14:22)
+    at async handler (file:///srv/my%20app/src/internal/routes.mjs:30:5)
+    at async Promise.all (index 0)
+    at process.processTicksAndRejections (node:internal/process/task_queues:95:5)";
+
+        let trace = JavaScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "TypeError");
+        assert_eq!(trace.frames.len(), 4);
+        assert_eq!(
+            trace.frames[0].file,
+            Some(PathBuf::from("/srv/my app/src/users.mjs"))
+        );
+        assert!(!trace.frames[0].is_async);
+        assert!(trace.frames[1].is_async);
+        assert_eq!(trace.frames[1].function.as_deref(), Some("handler"));
+        assert!(trace.frames[1].is_user_code);
+        assert!(trace.frames[2].is_async);
+        assert!(!trace.frames[2].is_user_code);
+        assert!(!trace.frames[3].is_user_code);
+    }
+
+    #[test]
+    fn test_node_cause_frames_come_first() {
+        let input = "Error: failed to load config
+    at loadConfig (/app/src/config.js:20:11)
+    at Object.<anonymous> (/app/src/index.js:3:1)
+    at Module._compile (node:internal/modules/cjs/loader:1256:14) {
+  [cause]: Error: ENOENT: no such file or directory, open '/app/config.json'
+      at Object.openSync (node:fs:601:3)
+      at readConfigFile (/app/src/config.js:8:6)
+      at loadConfig (/app/src/config.js:18:5) {
+    errno: -2,
+    code: 'ENOENT'
+  }
+}";
+
+        let trace = JavaScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "Error");
+        assert!(trace.error_message.starts_with("ENOENT: no such file"));
+        assert_eq!(trace.frames.len(), 6);
+        let root = trace.root_cause_frame().unwrap();
+        assert_eq!(root.function.as_deref(), Some("readConfigFile"));
+        assert_eq!(root.line, Some(8));
+        // Module top level is user code
+        assert!(trace.frames[4].is_user_code);
+    }
+
+    #[test]
+    fn test_node_unhandled_rejection() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "node:internal/process/promises:288
+            triggerUncaughtException(err, true /* fromPromise */);
+            ^
+
+[UnhandledPromiseRejection: This error originated either by throwing inside of an async function without a catch block, or by rejecting a promise which was not handled with .catch(). The promise rejected with the reason \"timeout\".] {
+  code: 'ERR_UNHANDLED_REJECTION'
+}
+
+Node.js v18.17.0";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::JavaScript);
+        assert_eq!(trace.error_type, "UnhandledPromiseRejection");
+        assert_eq!(
+            trace.error_message,
+            "The promise rejected with the reason \"timeout\"."
+        );
+
+        let legacy = "(node:4242) UnhandledPromiseRejectionWarning: Error [ERR_MODULE_NOT_FOUND]: Cannot find package 'zod'
+    at main (/app/index.js:4:3)";
+        let trace = registry.parse(legacy).unwrap();
+        assert_eq!(trace.error_type, "Error [ERR_MODULE_NOT_FOUND]");
+        assert_eq!(trace.error_message, "Cannot find package 'zod'");
+    }
+
+    #[test]
+    fn test_deno_and_bun_errors() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let deno = "error: Uncaught (in promise) TypeError: Cannot read properties of undefined (reading 'x')
+    at file:///home/ann/app/main.ts:3:15
+    at https://deno.land/std@0.200.0/http/server.ts:120:9
+    at eventLoopTick (ext:core/01_core.js:168:7)";
+
+        let trace = registry.parse(deno).unwrap();
+        assert_eq!(trace.language, Language::JavaScript);
+        assert_eq!(trace.error_type, "TypeError");
+        assert_eq!(
+            trace.frames[0].file,
+            Some(PathBuf::from("/home/ann/app/main.ts"))
+        );
+        assert_eq!(trace.user_frames().len(), 1);
+
+        let bun = "1 | export function f() {
+2 |   throw new Error(\"boom\");
+              ^
+error: boom
+      at f (/app/src/index.ts:2:9)
+      at /app/src/index.ts:5:1
+      at processTicksAndRejections (native:7:39)
+
+Bun v1.1.8 (Linux x64)";
+
+        let trace = registry.parse(bun).unwrap();
+        assert_eq!(trace.language, Language::JavaScript);
+        assert_eq!(trace.error_type, "Error");
+        assert_eq!(trace.error_message, "boom");
+        assert_eq!(trace.frames.len(), 3);
+        assert_eq!(trace.user_frames().len(), 2);
+    }
+
+    #[test]
+    fn test_javascript_ignores_other_tools_error_prefix() {
+        let input = "error: could not compile `wasm-pkg` (lib) due to 2 previous errors
+TypeError: Cannot read properties of undefined (reading 'init')
+    at load (/app/src/wasm.js:4:11)";
+
+        let trace = JavaScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "TypeError");
+        assert_eq!(
+            trace.error_message,
+            "Cannot read properties of undefined (reading 'init')"
+        );
+    }
+
+    #[test]
+    fn test_javascript_user_file_named_native() {
+        let input = "TypeError: bridge.send is not a function
+    at post (/app/src/nativeBridge.js:14:12)
+    at native/handlers.js:3:5
+    at processTicksAndRejections (native:7:39)";
+
+        let trace = JavaScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.user_frames().len(), 2);
+        assert_eq!(
+            trace.root_cause_frame().unwrap().file,
+            Some(PathBuf::from("/app/src/nativeBridge.js"))
+        );
+        assert!(!trace.frames[2].is_user_code);
+    }
+
+    #[test]
+    fn test_javascript_webpack_and_pnpm_paths() {
+        let input = "ReferenceError: user is not defined
+    at Page (webpack-internal:///(app-pages-browser)/./src/app/page.tsx:12:9)
+    at renderWithHooks (webpack-internal:///(app-pages-browser)/./node_modules/next/dist/compiled/react-dom/cjs/react-dom.development.js:11121:18)
+    at Layer.handle (/repo/node_modules/.pnpm/express@4.18.2/node_modules/express/lib/router/layer.js:95:5)";
+
+        let trace = JavaScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(
+            trace.frames[0].file,
+            Some(PathBuf::from("src/app/page.tsx"))
+        );
+        assert!(trace.frames[0].is_user_code);
+        assert!(!trace.frames[1].is_user_code);
+        assert!(!trace.frames[2].is_user_code);
+    }
+
+    #[test]
+    fn test_java_thread_dump_focuses_deadlocked_thread() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "Full thread dump OpenJDK 64-Bit Server VM (17.0.2+8-86 mixed mode):
+
+\"worker-1\" #12 prio=5 tid=0x00007f3c5c1a2000 nid=0x2b waiting for monitor entry
+   java.lang.Thread.State: BLOCKED (on object monitor)
+\tat com.example.Bank.transfer(Bank.java:15)
+\t- waiting to lock <0x0000000000000002> (a java.lang.Object)
+\t- locked <0x0000000000000001> (a java.lang.Object)
+\tat com.example.Bank.lambda$main$0(Bank.java:24)
+
+\"worker-2\" #13 prio=5 tid=0x00007f3c5c1a4000 nid=0x2c waiting for monitor entry
+   java.lang.Thread.State: BLOCKED (on object monitor)
+\tat com.example.Bank.transfer(Bank.java:15)
+\t- waiting to lock <0x0000000000000001> (a java.lang.Object)
+\t- locked <0x0000000000000002> (a java.lang.Object)
+\tat com.example.Bank.lambda$main$1(Bank.java:25)
+
+Found one Java-level deadlock:
+=============================
+\"worker-1\":
+  waiting to lock monitor 0x00007f3c38003f08 (object 0x0000000000000002, a java.lang.Object),
+  which is held by \"worker-2\"
+\"worker-2\":
+  waiting to lock monitor 0x00007f3c38006a08 (object 0x0000000000000001, a java.lang.Object),
+  which is held by \"worker-1\"
+
+Found 1 deadlock.";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.language, Language::Java);
+        assert_eq!(trace.error_type, "Deadlock");
+        assert!(trace
+            .error_message
+            .starts_with("threads \"worker-1\" and \"worker-2\""));
+        assert_eq!(trace.frames.len(), 2);
+        assert_eq!(trace.frames[0].line, Some(15));
+        assert_eq!(
+            trace.frames[1].function.as_deref(),
+            Some("com.example.Bank.lambda$main$0")
+        );
+        let context = trace.prompt_context().unwrap();
+        assert!(context.contains(
+            "\"worker-1\" waits for <0x0000000000000002> (java.lang.Object) held by \"worker-2\" \
+             while holding <0x0000000000000001> (java.lang.Object)"
+        ));
+    }
+
+    #[test]
+    fn test_java_out_of_memory_attaches_details() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "Exception in thread \"main\" java.lang.OutOfMemoryError: Metaspace
+\tat java.base/java.lang.ClassLoader.defineClass1(Native Method)
+\tat com.example.Plugins.load(Plugins.java:31)
+Heap
+ Metaspace       used 262100K, committed 262144K, reserved 1310720K";
+
+        let trace = registry.parse(input).unwrap();
+        assert_eq!(trace.error_type, "java.lang.OutOfMemoryError");
+        assert_eq!(trace.error_message, "Metaspace");
+        assert_eq!(trace.frames.len(), 2);
+        let context = trace.prompt_context().unwrap();
+        assert!(context.contains("- Metaspace: 262100K used of 262144K, 1310720K reserved"));
+    }
+
+    #[test]
+    fn test_prompt_context_absent_without_details() {
+        let trace = StackTrace::new(Language::Python, "Traceback...");
+        assert!(trace.prompt_context().is_none());
+    }
+
     #[test]
     fn test_auto_detection_unknown() {
         let registry = StackTraceParserRegistry::with_builtins();
@@ -1614,6 +3468,178 @@ mod tests {
         assert_eq!(registry.detect_language(input), Language::Unknown);
     }
 
+    #[test]
+    fn test_auto_detection_typescript() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input = "src/app.ts(12,5): error TS2339: Property 'x' does not exist on type 'Y'.";
+
+        assert_eq!(registry.detect_language(input), Language::TypeScript);
+    }
+
+    #[test]
+    fn test_typescript_parses_tsc_plain_format() {
+        let input = "src/app.ts(12,5): error TS2339: Property 'x' does not exist on type 'Y'.\n\
+                     src/util.ts(3,10): error TS2322: Type 'string' is not assignable to type 'number'.";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.language, Language::TypeScript);
+        assert_eq!(trace.error_type, "TS2339");
+        assert_eq!(
+            trace.error_message,
+            "Property 'x' does not exist on type 'Y'."
+        );
+        assert_eq!(trace.diagnostics.len(), 2);
+        assert_eq!(trace.diagnostics[1].code.as_deref(), Some("TS2322"));
+        assert_eq!(trace.frames.len(), 2);
+        assert_eq!(trace.frames[0].file, Some(PathBuf::from("src/app.ts")));
+        assert_eq!(trace.frames[0].line, Some(12));
+        assert_eq!(trace.frames[0].column, Some(5));
+    }
+
+    #[test]
+    fn test_typescript_diagnostics_in_prompt_context() {
+        let input = "src/app.ts(12,5): error TS2339: Property 'x' does not exist on type 'Y'.\n\
+                     src/util.ts(3,10): error TS2322: Type 'string' is not assignable to type 'number'.";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        let context = trace.prompt_context().unwrap();
+        assert!(context.starts_with("Diagnostics (2):"));
+        assert!(context
+            .contains("- src/app.ts:12:5: error TS2339: Property 'x' does not exist on type 'Y'."));
+        assert!(context.contains("- src/util.ts:3:10: error TS2322: Type 'string'"));
+    }
+
+    #[test]
+    fn test_typescript_parses_tsc_pretty_format() {
+        let input = "examples/typescript_type_error.ts:34:22 - error TS2345: Argument of type 'User | null' is not assignable to parameter of type 'User'.\n\
+                     \x20 Type 'null' is not assignable to type 'User'.\n\
+                     \n\
+                     34     sendWelcomeEmail(response.data);\n\
+                     \x20                       ~~~~~~~~~~~~~\n\
+                     \n\
+                     \n\
+                     Found 1 error in examples/typescript_type_error.ts:34";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "TS2345");
+        assert_eq!(trace.diagnostics.len(), 1);
+
+        let diagnostic = &trace.diagnostics[0];
+        assert!(diagnostic.message.contains("Type 'null' is not assignable"));
+        assert_eq!(diagnostic.line, Some(34));
+        assert_eq!(diagnostic.column, Some(22));
+        let frame = diagnostic.code_frame.as_deref().unwrap();
+        assert!(frame.contains("sendWelcomeEmail(response.data)"));
+        assert!(frame.contains("~~~~"));
+        assert!(!frame.contains("Found 1 error"));
+    }
+
+    #[test]
+    fn test_typescript_parses_tsc_without_location() {
+        let input = "error TS2322: Type 'string' is not assignable to type 'number'.";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "TS2322");
+        assert!(trace.frames.is_empty());
+        assert_eq!(trace.diagnostics.len(), 1);
+    }
+
+    #[test]
+    fn test_typescript_strips_ansi_colors() {
+        let input = "\x1b[96msrc/app.ts\x1b[0m:\x1b[93m12\x1b[0m:\x1b[93m5\x1b[0m - \x1b[91merror\x1b[0m\x1b[90m TS2339: \x1b[0mProperty 'x' does not exist on type 'Y'.";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "TS2339");
+        assert_eq!(trace.frames[0].line, Some(12));
+        assert_eq!(trace.frames[0].column, Some(5));
+    }
+
+    #[test]
+    fn test_typescript_parses_esbuild_block() {
+        let input = "✘ [ERROR] Could not resolve \"./missing\"\n\
+                     \n\
+                     \x20   src/index.ts:1:20:\n\
+                     \x20     1 │ import { x } from \"./missing\";\n\
+                     \x20       ╵                   ~~~~~~~~~~~\n\
+                     \n\
+                     1 error";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "BuildError");
+        assert_eq!(trace.error_message, "Could not resolve \"./missing\"");
+        let diagnostic = &trace.diagnostics[0];
+        assert_eq!(diagnostic.file, Some(PathBuf::from("src/index.ts")));
+        assert_eq!(diagnostic.line, Some(1));
+        assert_eq!(diagnostic.column, Some(20));
+        assert!(diagnostic
+            .code_frame
+            .as_deref()
+            .unwrap()
+            .contains("import { x }"));
+    }
+
+    #[test]
+    fn test_typescript_parses_vite_transform_error() {
+        let input = "[vite:esbuild] Transform failed with 1 error:\n\
+                     /app/src/main.ts:3:10: ERROR: Expected \";\" but found \"x\"";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.diagnostics.len(), 1);
+        assert_eq!(trace.error_message, "Expected \";\" but found \"x\"");
+        assert_eq!(
+            trace.frames[0].file,
+            Some(PathBuf::from("/app/src/main.ts"))
+        );
+        assert_eq!(trace.frames[0].line, Some(3));
+    }
+
+    #[test]
+    fn test_typescript_parses_webpack_babel_error() {
+        let input = "ERROR in ./src/index.js\n\
+                     Module build failed (from ./node_modules/babel-loader/lib/index.js):\n\
+                     SyntaxError: /app/src/index.js: Unexpected token, expected \",\" (3:4)\n\
+                     \n\
+                     \x20 1 | const a = {\n\
+                     \x20 2 |   b: 1\n\
+                     > 3 |   c: 2\n\
+                     \x20   |   ^";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.diagnostics.len(), 1);
+        assert_eq!(trace.error_type, "SyntaxError");
+        assert_eq!(trace.error_message, "Unexpected token, expected \",\"");
+        let diagnostic = &trace.diagnostics[0];
+        assert_eq!(diagnostic.file, Some(PathBuf::from("/app/src/index.js")));
+        assert_eq!(diagnostic.line, Some(3));
+        assert_eq!(diagnostic.column, Some(4));
+        assert!(diagnostic
+            .code_frame
+            .as_deref()
+            .unwrap()
+            .contains("> 3 |   c: 2"));
+    }
+
+    #[test]
+    fn test_typescript_parses_webpack_ts_loader_error() {
+        let input = "[tsl] ERROR in /app/src/index.ts(12,5)\n\
+                     \x20     TS2339: Property 'x' does not exist on type 'Y'.";
+
+        let trace = TypeScriptStackTraceParser.parse(input).unwrap();
+        assert_eq!(trace.error_type, "TS2339");
+        assert_eq!(trace.frames[0].line, Some(12));
+        assert_eq!(trace.frames[0].column, Some(5));
+    }
+
+    #[test]
+    fn test_typescript_does_not_claim_runtime_errors() {
+        let registry = StackTraceParserRegistry::with_builtins();
+        let input =
+            "TypeError: Cannot read property 'x' of undefined\n    at func (/app/index.js:10:5)";
+
+        assert!(!TypeScriptStackTraceParser.can_parse(input));
+        assert_eq!(registry.detect_language(input), Language::JavaScript);
+    }
+
     #[test]
     fn test_registry_parse_returns_stack_trace() {
         let registry = StackTraceParserRegistry::with_builtins();
@@ -1640,9 +3666,11 @@ mod tests {
         assert_eq!(format!("{}", Language::Python), "python");
         assert_eq!(format!("{}", Language::Rust), "rust");
         assert_eq!(format!("{}", Language::JavaScript), "javascript");
+        assert_eq!(format!("{}", Language::TypeScript), "typescript");
         assert_eq!(format!("{}", Language::Go), "go");
         assert_eq!(format!("{}", Language::Java), "java");
         assert_eq!(format!("{}", Language::Cpp), "c/c++");
+        assert_eq!(format!("{}", Language::Kernel), "kernel");
         assert_eq!(format!("{}", Language::Unknown), "unknown");
     }
 
@@ -0,0 +1,339 @@
+//! Syntax error parsing for Python and Node.js.
+//!
+//! Both interpreters print the offending source line with a caret line
+//! underneath instead of a column number. This module turns the caret back
+//! into a column, and keeps the source line and the token it points at so
+//! the explanation doesn't have to guess where the parser gave up.
+
+use regex::Regex;
+use serde::Serialize;
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+use crate::stack_trace::{strip_ansi, StackFrame};
+
+/// A syntax error located by the interpreter's caret line
+#[derive(Debug, Clone, Serialize)]
+pub struct SyntaxErrorReport {
+    /// Exception type (e.g. "SyntaxError", "IndentationError")
+    pub error_type: String,
+    /// Message after the exception type
+    pub message: String,
+    /// File the interpreter was compiling
+    pub file: PathBuf,
+    /// 1-based line number
+    pub line: u32,
+    /// 1-based column of the first caret
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub column: Option<u32>,
+    /// 1-based column of the last caret, when it underlines a range
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub end_column: Option<u32>,
+    /// Source line as printed
+    pub source: String,
+    /// Text under the carets, or the token named in the message
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub token: Option<String>,
+}
+
+impl SyntaxErrorReport {
+    /// "app.py:3:15"
+    pub fn location(&self) -> String {
+        match self.column {
+            Some(column) => format!("{}:{}:{}", self.file.display(), self.line, column),
+            None => format!("{}:{}", self.file.display(), self.line),
+        }
+    }
+
+    /// Convert the error location into a stack frame
+    pub fn to_frame(&self) -> StackFrame {
+        let mut frame = StackFrame::new()
+            .with_file(self.file.clone())
+            .with_line(self.line)
+            .with_context(self.source.trim());
+        frame.column = self.column;
+        frame
+    }
+
+    /// Render the report as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = format!("{} at {}\n", self.error_type, self.location());
+        out.push_str(&format!("- source: {}\n", self.source.trim()));
+        if let Some(column) = self.column {
+            let span = match self.end_column {
+                Some(end) if end > column => format!("columns {}-{}", column, end),
+                _ => format!("column {}", column),
+            };
+            out.push_str(&format!("- the parser stopped at {}\n", span));
+        }
+        if let Some(token) = &self.token {
+            out.push_str(&format!("- offending token: {}\n", token));
+        }
+        out.trim_end().to_string()
+    }
+}
+
+struct Patterns {
+    python_file: Regex,
+    python_error: Regex,
+    node_file: Regex,
+    node_error: Regex,
+    caret: Regex,
+    quoted_token: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        python_file: Regex::new(r#"^\s*File "(.+)", line (\d+)$"#).unwrap(),
+        python_error: Regex::new(r"^(SyntaxError|IndentationError|TabError): (.+)$").unwrap(),
+        node_file: Regex::new(r"^(?:file://)?(\S+):(\d+)$").unwrap(),
+        node_error: Regex::new(r"^SyntaxError: (.+)$").unwrap(),
+        caret: Regex::new(r"^(\s*)(\^+)\s*$").unwrap(),
+        quoted_token: Regex::new(r"(?:token|identifier) '([^']+)'").unwrap(),
+    })
+}
+
+/// Parse a Python `SyntaxError`, `IndentationError` or `TabError`
+///
+/// Python dedents the source line before printing it, so the caret offset is
+/// relative to the dedented text. When the file is readable the indentation
+/// is added back to get the real column.
+pub fn parse_python_syntax_error(input: &str) -> Option<SyntaxErrorReport> {
+    let p = patterns();
+    let input = strip_ansi(input);
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+
+    // The error is reported against the last File line
+    let start = lines.iter().rposition(|l| p.python_file.is_match(l))?;
+    let caps = p.python_file.captures(lines[start])?;
+    let file = PathBuf::from(&caps[1]);
+    let line: u32 = caps[2].parse().ok()?;
+
+    let rest = &lines[start + 1..];
+    let error_at = rest
+        .iter()
+        .take(3)
+        .position(|l| p.python_error.is_match(l))?;
+    let error = p.python_error.captures(rest[error_at])?;
+    let source = rest[..error_at]
+        .iter()
+        .find(|l| !p.caret.is_match(l))
+        .copied()
+        .unwrap_or("");
+
+    let mut report = SyntaxErrorReport {
+        error_type: error[1].to_string(),
+        message: error[2].trim().to_string(),
+        file,
+        line,
+        column: None,
+        end_column: None,
+        source: source.trim().to_string(),
+        token: None,
+    };
+
+    if let Some(caret) = rest[..error_at].iter().find_map(|l| p.caret.captures(l)) {
+        let shown_indent = source.chars().take_while(|c| c.is_whitespace()).count();
+        let offset = caret[1].chars().count().saturating_sub(shown_indent);
+        let width = caret[2].len();
+        let shown = report.source.clone();
+        let indent = source_indent(&report.file, line, &shown);
+        apply_caret(&mut report, &shown, offset, width, indent);
+    }
+
+    Some(report)
+}
+
+/// Parse a Node.js `SyntaxError` reported with its source line and caret
+pub fn parse_node_syntax_error(input: &str) -> Option<SyntaxErrorReport> {
+    let p = patterns();
+    let input = strip_ansi(input);
+    let lines: Vec<&str> = input.lines().map(str::trim_end).collect();
+
+    for (i, header) in lines.iter().enumerate() {
+        let Some(caps) = p.node_file.captures(header) else {
+            continue;
+        };
+        let Some(caret) = lines.get(i + 2).and_then(|l| p.caret.captures(l)) else {
+            continue;
+        };
+        let Some(error) = lines
+            .iter()
+            .skip(i + 3)
+            .take(2)
+            .find_map(|l| p.node_error.captures(l))
+        else {
+            continue;
+        };
+
+        let source = lines[i + 1];
+        let mut report = SyntaxErrorReport {
+            error_type: "SyntaxError".to_string(),
+            message: error[1].trim().to_string(),
+            file: PathBuf::from(&caps[1]),
+            line: caps[2].parse().ok()?,
+            column: None,
+            end_column: None,
+            source: source.to_string(),
+            token: None,
+        };
+        // Node prints the line as it is in the file
+        apply_caret(
+            &mut report,
+            source,
+            caret[1].chars().count(),
+            caret[2].len(),
+            0,
+        );
+        if let Some(token) = p.quoted_token.captures(&report.message) {
+            report.token = Some(token[1].to_string());
+        }
+        report.source = report.source.trim().to_string();
+        return Some(report);
+    }
+    None
+}
+
+/// Set column, end column and token from a caret at `offset` chars into
+/// `source`, `width` carets wide, with `indent` chars of indentation the
+/// interpreter didn't print
+fn apply_caret(
+    report: &mut SyntaxErrorReport,
+    source: &str,
+    offset: usize,
+    width: usize,
+    indent: usize,
+) {
+    let column = (indent + offset + 1) as u32;
+    report.column = Some(column);
+    if width > 1 {
+        report.end_column = Some(column + width as u32 - 1);
+    }
+
+    let token: String = if width > 1 {
+        source.chars().skip(offset).take(width).collect()
+    } else {
+        source
+            .chars()
+            .skip(offset)
+            .take_while(|c| !c.is_whitespace())
+            .take(1)
+            .collect()
+    };
+    if !token.trim().is_empty() {
+        report.token = Some(token.trim().to_string());
+    }
+}
+
+/// Leading whitespace of `line` in `file`, if the file is readable and the
+/// line still matches what the interpreter printed
+fn source_indent(file: &Path, line: u32, shown: &str) -> usize {
+    let Ok(contents) = std::fs::read_to_string(file) else {
+        return 0;
+    };
+    contents
+        .lines()
+        .nth(line.saturating_sub(1) as usize)
+        .filter(|l| l.trim() == shown)
+        .map_or(0, |l| l.chars().take_while(|c| c.is_whitespace()).count())
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_python_caret_range() {
+        let input = r#"Traceback (most recent call last):
+  File "/app/main.py", line 1, in <module>
+    import settings
+  File "/app/settings.py", line 3
+    print("a" "b" 1)
+          ^^^^^^^^^
+SyntaxError: invalid syntax. Perhaps you forgot a comma?"#;
+
+        let report = parse_python_syntax_error(input).unwrap();
+        assert_eq!(report.error_type, "SyntaxError");
+        assert_eq!(report.file, PathBuf::from("/app/settings.py"));
+        assert_eq!(report.line, 3);
+        assert_eq!(report.column, Some(7));
+        assert_eq!(report.end_column, Some(15));
+        assert_eq!(report.source, "print(\"a\" \"b\" 1)");
+        assert_eq!(report.token.as_deref(), Some("\"a\" \"b\" 1"));
+        assert_eq!(report.location(), "/app/settings.py:3:7");
+    }
+
+    #[test]
+    fn test_python_column_restores_indentation() {
+        let dir = std::env::temp_dir().join(format!("why-syntax-{}", std::process::id()));
+        std::fs::create_dir_all(&dir).unwrap();
+        let file = dir.join("indented.py");
+        std::fs::write(&file, "def f():\n    if True:\n        x = (1,\n").unwrap();
+
+        let input = format!(
+            "  File \"{}\", line 3\n    x = (1,\n        ^\nSyntaxError: '(' was never closed",
+            file.display()
+        );
+        let report = parse_python_syntax_error(&input).unwrap();
+        std::fs::remove_dir_all(&dir).ok();
+
+        assert_eq!(report.column, Some(13));
+        assert_eq!(report.end_column, None);
+        assert_eq!(report.token.as_deref(), Some("("));
+    }
+
+    #[test]
+    fn test_python_indentation_error_without_caret() {
+        let input = "  File \"/app/x.py\", line 2\n    y = 2\nIndentationError: unexpected indent";
+
+        let report = parse_python_syntax_error(input).unwrap();
+        assert_eq!(report.error_type, "IndentationError");
+        assert_eq!(report.column, None);
+        assert_eq!(report.source, "y = 2");
+        assert_eq!(report.location(), "/app/x.py:2");
+    }
+
+    #[test]
+    fn test_node_caret_column_and_token() {
+        let input = "/app/index.js:2
+    const x = {;
+               ^
+
+SyntaxError: Unexpected token ';'
+    at wrapSafe (node:internal/modules/cjs/loader:1464:18)
+    at Module._compile (node:internal/modules/cjs/loader:1495:20)
+
+Node.js v20.19.5";
+
+        let report = parse_node_syntax_error(input).unwrap();
+        assert_eq!(report.file, PathBuf::from("/app/index.js"));
+        assert_eq!(report.line, 2);
+        assert_eq!(report.column, Some(16));
+        assert_eq!(report.token.as_deref(), Some(";"));
+        assert_eq!(report.source, "const x = {;");
+
+        let summary = report.prompt_summary();
+        assert!(summary.starts_with("SyntaxError at /app/index.js:2:16\n"));
+        assert!(summary.contains("- offending token: ;"));
+    }
+
+    #[test]
+    fn test_node_esm_file_url() {
+        let input = "file:///app/main.mjs:2
+const b = a +* 2;
+             ^
+
+SyntaxError: Unexpected token '*'
+    at compileSourceTextModule (node:internal/modules/esm/utils:346:16)";
+
+        let report = parse_node_syntax_error(input).unwrap();
+        assert_eq!(report.file, PathBuf::from("/app/main.mjs"));
+        assert_eq!(report.column, Some(14));
+        assert_eq!(report.token.as_deref(), Some("*"));
+    }
+}
@@ -0,0 +1,536 @@
+//! strace output summarization for commands that fail without saying why.
+//!
+//! `why --capture --trace` runs the command under `strace -f`. A program that
+//! exits 1 without printing anything has usually just failed to open a file,
+//! connect to a socket or exec a helper; those failures are right there in
+//! the syscall log. This module picks them out of the noise (library search
+//! probing, tty checks, non-blocking retries) and keeps the last calls before
+//! the process exited.
+
+use anyhow::{bail, Context, Result};
+use regex::Regex;
+use serde::Serialize;
+use std::collections::HashSet;
+use std::path::Path;
+use std::process::{Command, Stdio};
+use std::sync::OnceLock;
+
+/// Failed calls listed in the summary
+const MAX_FAILURES: usize = 8;
+
+/// Calls kept from the end of the trace
+const LAST_CALLS: usize = 8;
+
+/// Longest argument string strace prints (`-s`)
+const STRING_LIMIT: usize = 256;
+
+/// Errors that programs hit routinely and handle themselves
+const ROUTINE_ERRNOS: &[&str] = &[
+    "ENOTTY",
+    "EAGAIN",
+    "EWOULDBLOCK",
+    "EINPROGRESS",
+    "EINTR",
+    "ERESTARTSYS",
+    "ECHILD",
+    "ENOSYS",
+    "ENODATA",
+];
+
+// ============================================================================
+// Core Types
+// ============================================================================
+
+/// One completed syscall from the trace
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Syscall {
+    /// Process that made the call
+    pub pid: Option<u32>,
+    /// Syscall name (e.g. "openat")
+    pub name: String,
+    /// Arguments as strace printed them
+    pub args: String,
+    /// Return value as printed ("-1", "3", "?")
+    pub result: String,
+    /// errno name for failed calls (e.g. "ENOENT")
+    pub errno: Option<String>,
+    /// errno description (e.g. "No such file or directory")
+    pub errno_message: Option<String>,
+}
+
+impl Syscall {
+    /// The file, socket address or program the call was about, if any
+    pub fn target(&self) -> Option<String> {
+        let p = patterns();
+        if let Some(caps) = p.inet.captures(&self.args) {
+            return Some(format!("{}:{}", &caps[2], &caps[1]));
+        }
+        if let Some(caps) = p.inet6.captures(&self.args) {
+            return Some(format!("[{}]:{}", &caps[2], &caps[1]));
+        }
+        if let Some(caps) = p.unix.captures(&self.args) {
+            return Some(caps[1].to_string());
+        }
+        p.quoted
+            .captures(&self.args)
+            .map(|caps| caps[1].to_string())
+            .filter(|s| !s.is_empty())
+    }
+
+    /// "openat(AT_FDCWD, "/etc/app.yaml", O_RDONLY) = -1 ENOENT"
+    pub fn describe(&self) -> String {
+        let mut args = self.args.clone();
+        if args.len() > 120 {
+            let cut = (0..=117)
+                .rev()
+                .find(|&i| args.is_char_boundary(i))
+                .unwrap_or(0);
+            args.truncate(cut);
+            args.push_str("...");
+        }
+        match &self.errno {
+            Some(errno) => format!("{}({}) = {} {}", self.name, args, self.result, errno),
+            None => format!("{}({}) = {}", self.name, args, self.result),
+        }
+    }
+}
+
+/// A failed call, merged with identical failures
+#[derive(Debug, Clone, Serialize)]
+pub struct SyscallFailure {
+    /// Syscall name
+    pub syscall: String,
+    /// errno name
+    pub errno: String,
+    /// errno description
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+    /// File, address or program the call was about
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub target: Option<String>,
+    /// How many times it failed this way
+    pub count: usize,
+}
+
+impl SyscallFailure {
+    /// "openat /etc/app/config.yaml: ENOENT (No such file or directory) x2"
+    pub fn describe(&self) -> String {
+        let mut out = self.syscall.clone();
+        if let Some(target) = &self.target {
+            out.push_str(&format!(" {}", target));
+        }
+        out.push_str(&format!(": {}", self.errno));
+        if let Some(message) = &self.message {
+            out.push_str(&format!(" ({})", message));
+        }
+        if self.count > 1 {
+            out.push_str(&format!(" x{}", self.count));
+        }
+        out
+    }
+}
+
+/// What the trace says about a failed run
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct TraceSummary {
+    /// PID of the traced command
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub pid: Option<u32>,
+    /// Failed calls worth looking at, in the order they first happened
+    pub failures: Vec<SyscallFailure>,
+    /// Routine failures left out of `failures`
+    pub ignored_failures: usize,
+    /// Last calls of the traced command, oldest first
+    pub last_calls: Vec<String>,
+    /// Fatal signals delivered (e.g. "SIGSEGV {si_code=SEGV_MAPERR, si_addr=NULL}")
+    pub signals: Vec<String>,
+    /// How the traced command ended ("exited with 1", "killed by SIGKILL")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exit: Option<String>,
+}
+
+impl TraceSummary {
+    /// Whether the trace found anything that could explain the failure
+    pub fn has_findings(&self) -> bool {
+        !self.failures.is_empty() || !self.signals.is_empty()
+    }
+
+    /// Render the summary as a compact block for the model prompt
+    pub fn prompt_summary(&self) -> String {
+        let mut out = String::new();
+        if self.failures.is_empty() {
+            out.push_str("No failed system calls stood out.\n");
+        } else {
+            out.push_str("Failed system calls:\n");
+            for failure in &self.failures {
+                out.push_str(&format!("- {}\n", failure.describe()));
+            }
+        }
+        if self.ignored_failures > 0 {
+            out.push_str(&format!(
+                "({} routine failures such as library lookups left out)\n",
+                self.ignored_failures
+            ));
+        }
+        for signal in &self.signals {
+            out.push_str(&format!("Signal: {}\n", signal));
+        }
+        if !self.last_calls.is_empty() {
+            out.push_str("Last system calls before exit:\n");
+            for call in &self.last_calls {
+                out.push_str(&format!("  {}\n", call));
+            }
+        }
+        if let Some(exit) = &self.exit {
+            out.push_str(&format!("Process {}\n", exit));
+        }
+        out.trim_end().to_string()
+    }
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+struct Patterns {
+    call: Regex,
+    unfinished: Regex,
+    resumed: Regex,
+    signal: Regex,
+    exit: Regex,
+    quoted: Regex,
+    inet: Regex,
+    inet6: Regex,
+    unix: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        call: Regex::new(
+            r"^(?:(?:\[pid\s+)?(\d+)\]?\s+)?(\w+)\((.*)\)\s+=\s+(\S+)(?:\s+(E[A-Z0-9]+)(?:\s+\((.+)\))?)?",
+        )
+        .unwrap(),
+        unfinished: Regex::new(r"^(?:(?:\[pid\s+)?(\d+)\]?\s+)?(\w+)\((.*?)\s*<unfinished \.\.\.>$")
+            .unwrap(),
+        resumed: Regex::new(
+            r"^(?:(?:\[pid\s+)?(\d+)\]?\s+)?<\.\.\. (\w+) resumed>\s?(.*)\)\s+=\s+(\S+)(?:\s+(E[A-Z0-9]+)(?:\s+\((.+)\))?)?",
+        )
+        .unwrap(),
+        signal: Regex::new(r"^(?:(?:\[pid\s+)?(\d+)\]?\s+)?--- (SIG\w+) \{(.*)\} ---$").unwrap(),
+        exit: Regex::new(r"^(?:(?:\[pid\s+)?(\d+)\]?\s+)?\+\+\+ (.+?) \+\+\+$").unwrap(),
+        quoted: Regex::new(r#""((?:[^"\\]|\\.)*)""#).unwrap(),
+        inet: Regex::new(r#"sin_port=htons\((\d+)\), sin_addr=inet_addr\("([^"]+)"\)"#).unwrap(),
+        inet6: Regex::new(r#"sin6_port=htons\((\d+)\).*?inet_pton\(AF_INET6, "([^"]+)""#)
+            .unwrap(),
+        unix: Regex::new(r#"sun_path=@?"([^"]+)""#).unwrap(),
+    })
+}
+
+fn parse_pid(m: Option<regex::Match>) -> Option<u32> {
+    m.and_then(|m| m.as_str().parse().ok())
+}
+
+/// Parse `strace -f -o` output into completed calls, signals and exits
+pub fn parse_strace(output: &str) -> TraceSummary {
+    let p = patterns();
+    let mut calls: Vec<Syscall> = Vec::new();
+    // Calls interrupted by another process's output: (pid, name) -> args
+    let mut pending: Vec<(Option<u32>, String, String)> = Vec::new();
+    let mut summary = TraceSummary::default();
+    let mut exits: Vec<(Option<u32>, String)> = Vec::new();
+
+    for line in output.lines().map(str::trim_end) {
+        if summary.pid.is_none() {
+            summary.pid = line
+                .split_whitespace()
+                .next()
+                .and_then(|first| first.parse().ok());
+        }
+
+        if let Some(caps) = p.exit.captures(line) {
+            exits.push((parse_pid(caps.get(1)), caps[2].to_string()));
+        } else if let Some(caps) = p.signal.captures(line) {
+            let detail = caps[3].to_string();
+            // Only signals that come from a fault, not SIGCHLD and friends
+            if detail.contains("SEGV_")
+                || detail.contains("BUS_")
+                || detail.contains("FPE_")
+                || detail.contains("ILL_")
+                || &caps[2] == "SIGABRT"
+            {
+                let detail = detail
+                    .split(", ")
+                    .filter(|part| !part.starts_with("si_signo="))
+                    .collect::<Vec<_>>()
+                    .join(", ");
+                summary.signals.push(format!("{} {{{}}}", &caps[2], detail));
+            }
+        } else if let Some(caps) = p.unfinished.captures(line) {
+            pending.push((
+                parse_pid(caps.get(1)),
+                caps[2].to_string(),
+                caps[3].to_string(),
+            ));
+        } else if let Some(caps) = p.resumed.captures(line) {
+            let pid = parse_pid(caps.get(1));
+            let name = caps[2].to_string();
+            let args = match pending.iter().position(|(p, n, _)| *p == pid && *n == name) {
+                Some(i) => {
+                    let (_, _, args) = pending.remove(i);
+                    format!("{}{}", args, &caps[3])
+                }
+                None => caps[3].to_string(),
+            };
+            calls.push(Syscall {
+                pid,
+                name,
+                args,
+                result: caps[4].to_string(),
+                errno: caps.get(5).map(|m| m.as_str().to_string()),
+                errno_message: caps.get(6).map(|m| m.as_str().to_string()),
+            });
+        } else if let Some(caps) = p.call.captures(line) {
+            calls.push(Syscall {
+                pid: parse_pid(caps.get(1)),
+                name: caps[2].to_string(),
+                args: caps[3].to_string(),
+                result: caps[4].to_string(),
+                errno: caps.get(5).map(|m| m.as_str().to_string()),
+                errno_message: caps.get(6).map(|m| m.as_str().to_string()),
+            });
+        }
+    }
+
+    summary.exit = exits
+        .iter()
+        .find(|(pid, _)| pid.is_none() || *pid == summary.pid)
+        .or(exits.last())
+        .map(|(_, exit)| exit.clone());
+
+    summarize_failures(&calls, &mut summary);
+
+    let main_calls: Vec<&Syscall> = calls
+        .iter()
+        .filter(|c| c.pid.is_none() || c.pid == summary.pid)
+        .collect();
+    let main_calls = if main_calls.is_empty() {
+        calls.iter().collect()
+    } else {
+        main_calls
+    };
+    summary.last_calls = main_calls
+        .iter()
+        .rev()
+        .take(LAST_CALLS)
+        .rev()
+        .map(|c| c.describe())
+        .collect();
+
+    summary
+}
+
+/// Group failed calls, leaving out routine ones
+fn summarize_failures(calls: &[Syscall], summary: &mut TraceSummary) {
+    // A path that failed and was later found elsewhere was a search, not a
+    // failure (ld.so, PATH lookup, Python's sys.path)
+    let found: HashSet<String> = calls
+        .iter()
+        .filter(|c| c.errno.is_none())
+        .filter_map(|c| c.target())
+        .filter_map(|t| file_name(&t).map(str::to_string))
+        .collect();
+
+    for call in calls {
+        let Some(errno) = &call.errno else {
+            continue;
+        };
+        let target = call.target();
+        if is_routine(call, errno, target.as_deref(), &found) {
+            summary.ignored_failures += 1;
+            continue;
+        }
+
+        match summary
+            .failures
+            .iter_mut()
+            .find(|f| f.syscall == call.name && &f.errno == errno && f.target == target)
+        {
+            Some(existing) => existing.count += 1,
+            None => summary.failures.push(SyscallFailure {
+                syscall: call.name.clone(),
+                errno: errno.clone(),
+                message: call.errno_message.clone(),
+                target,
+                count: 1,
+            }),
+        }
+    }
+
+    if summary.failures.len() > MAX_FAILURES {
+        summary.ignored_failures += summary.failures[MAX_FAILURES..]
+            .iter()
+            .map(|f| f.count)
+            .sum::<usize>();
+        summary.failures.truncate(MAX_FAILURES);
+    }
+}
+
+fn is_routine(call: &Syscall, errno: &str, target: Option<&str>, found: &HashSet<String>) -> bool {
+    if ROUTINE_ERRNOS.contains(&errno) {
+        return true;
+    }
+    // readlink on a non-link, lseek on a pipe
+    if matches!(
+        (call.name.as_str(), errno),
+        ("readlink" | "readlinkat", "EINVAL")
+    ) || matches!((call.name.as_str(), errno), ("lseek", "ESPIPE"))
+    {
+        return true;
+    }
+    if errno != "ENOENT" {
+        return false;
+    }
+    let Some(target) = target else {
+        return false;
+    };
+    target.contains(".so")
+        || target.contains("/locale")
+        || target.contains("/gconv/")
+        || target.contains("__pycache__")
+        || target.ends_with(".pyc")
+        || file_name(target).is_some_and(|name| found.contains(name))
+}
+
+fn file_name(path: &str) -> Option<&str> {
+    path.rsplit('/').next().filter(|name| !name.is_empty())
+}
+
+// ============================================================================
+// Running
+// ============================================================================
+
+/// Whether strace is installed
+pub fn is_available() -> bool {
+    Command::new("strace")
+        .arg("-V")
+        .stdin(Stdio::null())
+        .stdout(Stdio::null())
+        .stderr(Stdio::null())
+        .status()
+        .is_ok_and(|status| status.success())
+}
+
+/// Command that runs `command` under `strace -f`, writing the trace to `log`
+pub fn traced_command(command: &[String], log: &Path) -> Result<Command> {
+    if !is_available() {
+        bail!("--trace needs strace, which isn't installed");
+    }
+    let mut traced = Command::new("strace");
+    traced
+        .args(["-f", "-q", "-s", &STRING_LIMIT.to_string(), "-o"])
+        .arg(log)
+        .arg("--")
+        .args(command);
+    Ok(traced)
+}
+
+/// Read and summarize a trace written by `traced_command`
+pub fn read_trace(log: &Path) -> Result<TraceSummary> {
+    let output = std::fs::read_to_string(log)
+        .with_context(|| format!("Failed to read strace output from {}", log.display()))?;
+    Ok(parse_strace(&output))
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const TRACE: &str = r#"48213 execve("./server", ["./server"], 0x7ffd5d2a1f28 /* 32 vars */) = 0
+48213 openat(AT_FDCWD, "/etc/ld.so.cache", O_RDONLY|O_CLOEXEC) = 3
+48213 openat(AT_FDCWD, "/lib/x86_64-linux-gnu/glibc-hwcaps/x86-64-v3/libssl.so.3", O_RDONLY|O_CLOEXEC) = -1 ENOENT (No such file or directory)
+48213 openat(AT_FDCWD, "/lib/x86_64-linux-gnu/libssl.so.3", O_RDONLY|O_CLOEXEC) = 3
+48213 ioctl(1, TCGETS, 0x7ffc8d0) = -1 ENOTTY (Inappropriate ioctl for device)
+48213 openat(AT_FDCWD, "/home/ann/.config/server/config.yaml", O_RDONLY) = -1 ENOENT (No such file or directory)
+48213 openat(AT_FDCWD, "/etc/server/config.yaml", O_RDONLY) = -1 ENOENT (No such file or directory)
+48213 clone3({flags=CLONE_VM|CLONE_FS, exit_signal=0, stack=0x7f, stack_size=0x7fff00}, 88) = 48214
+48214 socket(AF_INET, SOCK_STREAM|SOCK_CLOEXEC, IPPROTO_IP) = 5
+48214 connect(5, {sa_family=AF_INET, sin_port=htons(5432), sin_addr=inet_addr("127.0.0.1")}, 16 <unfinished ...>
+48213 futex(0x7f1c, FUTEX_WAIT_BITSET_PRIVATE, 0, NULL, FUTEX_BITSET_MATCH_ANY <unfinished ...>
+48214 <... connect resumed>) = -1 ECONNREFUSED (Connection refused)
+48214 +++ exited with 0 +++
+48213 <... futex resumed>) = 0
+48213 write(2, "", 0) = 0
+48213 exit_group(1) = ?
+48213 +++ exited with 1 +++"#;
+
+    #[test]
+    fn test_summarizes_failed_calls() {
+        let summary = parse_strace(TRACE);
+        assert_eq!(summary.pid, Some(48213));
+        assert_eq!(summary.exit.as_deref(), Some("exited with 1"));
+        assert_eq!(summary.ignored_failures, 2);
+        assert_eq!(summary.failures.len(), 3);
+
+        assert_eq!(
+            summary.failures[0].describe(),
+            "openat /home/ann/.config/server/config.yaml: ENOENT (No such file or directory)"
+        );
+        assert_eq!(
+            summary.failures[2].describe(),
+            "connect 127.0.0.1:5432: ECONNREFUSED (Connection refused)"
+        );
+        assert!(summary.has_findings());
+    }
+
+    #[test]
+    fn test_last_calls_follow_the_traced_process() {
+        let summary = parse_strace(TRACE);
+        assert_eq!(summary.last_calls.len(), LAST_CALLS);
+        assert_eq!(summary.last_calls.last().unwrap(), "exit_group(1) = ?");
+        assert!(summary
+            .last_calls
+            .iter()
+            .all(|call| !call.starts_with("socket")));
+        assert!(summary.last_calls.contains(
+            &"futex(0x7f1c, FUTEX_WAIT_BITSET_PRIVATE, 0, NULL, FUTEX_BITSET_MATCH_ANY) = 0"
+                .to_string()
+        ));
+    }
+
+    #[test]
+    fn test_path_search_is_not_a_failure() {
+        let trace = r#"execve("/usr/local/bin/node", ["node"], 0x7ffd /* 3 vars */) = -1 ENOENT (No such file or directory)
+execve("/usr/bin/node", ["node"], 0x7ffd /* 3 vars */) = 0
+access("/etc/app.conf", R_OK) = -1 EACCES (Permission denied)
++++ exited with 1 +++"#;
+
+        let summary = parse_strace(trace);
+        assert_eq!(summary.failures.len(), 1);
+        assert_eq!(summary.failures[0].errno, "EACCES");
+        assert_eq!(summary.ignored_failures, 1);
+    }
+
+    #[test]
+    fn test_fault_signal() {
+        let trace = "9 --- SIGCHLD {si_signo=SIGCHLD, si_code=CLD_EXITED, si_pid=10} ---
+9 --- SIGSEGV {si_signo=SIGSEGV, si_code=SEGV_MAPERR, si_addr=NULL} ---
+9 +++ killed by SIGSEGV (core dumped) +++";
+
+        let summary = parse_strace(trace);
+        assert_eq!(
+            summary.signals,
+            vec!["SIGSEGV {si_code=SEGV_MAPERR, si_addr=NULL}"]
+        );
+        assert_eq!(
+            summary.exit.as_deref(),
+            Some("killed by SIGSEGV (core dumped)")
+        );
+        let prompt = summary.prompt_summary();
+        assert!(prompt.starts_with("No failed system calls stood out.\nSignal: SIGSEGV"));
+    }
+}
@@ -0,0 +1,93 @@
+//! Private temporary files for external tools that write their output to a path.
+//!
+//! strace and coredumpctl take an output path and happily follow a symlink
+//! someone else left there. A path built from the PID alone is easy to guess,
+//! so these helpers create the file exclusively (O_EXCL, mode 0600) under a
+//! name that also carries the time and a counter, and retry on collisions.
+
+use anyhow::{Context, Result};
+use std::fs::{File, OpenOptions};
+use std::io;
+use std::path::PathBuf;
+use std::sync::atomic::{AtomicU32, Ordering};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+#[cfg(unix)]
+use std::os::unix::fs::OpenOptionsExt;
+
+/// Names tried before giving up on a crowded temp directory
+const MAX_ATTEMPTS: u32 = 16;
+
+static COUNTER: AtomicU32 = AtomicU32::new(0);
+
+/// Candidate path `<temp_dir>/<prefix><pid>-<nanos>-<n><suffix>`
+fn candidate(prefix: &str, suffix: &str) -> PathBuf {
+    let nanos = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.subsec_nanos())
+        .unwrap_or_default();
+    let n = COUNTER.fetch_add(1, Ordering::Relaxed);
+    std::env::temp_dir().join(format!(
+        "{}{}-{:x}-{}{}",
+        prefix,
+        std::process::id(),
+        nanos,
+        n,
+        suffix
+    ))
+}
+
+/// Create a new, empty file only the current user can read, failing rather
+/// than reusing anything already at the path
+pub fn create_file(prefix: &str, suffix: &str) -> Result<(File, PathBuf)> {
+    let mut last_error = None;
+    for _ in 0..MAX_ATTEMPTS {
+        let path = candidate(prefix, suffix);
+        let mut options = OpenOptions::new();
+        options.write(true).create_new(true);
+        #[cfg(unix)]
+        options.mode(0o600);
+        match options.open(&path) {
+            Ok(file) => return Ok((file, path)),
+            Err(e) if e.kind() == io::ErrorKind::AlreadyExists => last_error = Some(e),
+            Err(e) => {
+                return Err(e).with_context(|| format!("Failed to create {}", path.display()))
+            }
+        }
+    }
+    Err(last_error.unwrap_or_else(|| io::Error::from(io::ErrorKind::AlreadyExists)))
+        .context("Failed to create a temporary file")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::fs;
+
+    #[test]
+    fn test_create_file_is_new_and_unique() {
+        let (_, first) = create_file("why-test-", ".log").unwrap();
+        let (_, second) = create_file("why-test-", ".log").unwrap();
+        assert_ne!(first, second);
+        assert!(first
+            .file_name()
+            .unwrap()
+            .to_str()
+            .unwrap()
+            .ends_with(".log"));
+        assert_eq!(fs::metadata(&first).unwrap().len(), 0);
+        fs::remove_file(first).ok();
+        fs::remove_file(second).ok();
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_create_file_is_private() {
+        use std::os::unix::fs::PermissionsExt;
+
+        let (_, path) = create_file("why-test-", "").unwrap();
+        let mode = fs::metadata(&path).unwrap().permissions().mode();
+        fs::remove_file(&path).ok();
+        assert_eq!(mode & 0o777, 0o600);
+    }
+}
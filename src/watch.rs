@@ -7,12 +7,21 @@
 //! tight coupling with the inference and CLI systems. This module exports
 //! the core types used by watch mode.
 
+use anyhow::Result;
 use regex::Regex;
 use std::collections::hash_map::DefaultHasher;
-use std::collections::HashMap;
+use std::collections::{HashMap, VecDeque};
+use std::fs;
 use std::hash::{Hash, Hasher};
+use std::io::{BufRead, Write};
+use std::path::{Path, PathBuf};
+use std::sync::{mpsc, OnceLock};
+use std::thread;
 use std::time::{Duration, Instant};
 
+use crate::config::SinkConfig;
+use crate::detect::DetectionRules;
+
 /// Configuration for watch mode
 #[derive(Debug, Clone)]
 pub struct WatchConfig {
@@ -22,14 +31,22 @@ pub struct WatchConfig {
     pub dedup: bool,
     /// TTL for duplicate suppression (5 minutes)
     pub dedup_ttl: Duration,
-    /// Custom error detection pattern
-    pub pattern: Option<Regex>,
+    /// Rules deciding which lines start an error
+    pub rules: DetectionRules,
     /// Whether to clear screen between errors
     pub clear: bool,
     /// Quiet mode - no status messages
     pub quiet: bool,
     /// Maximum lines to aggregate for an error
     pub max_aggregation_lines: usize,
+    /// Lines of output before an error to keep as context (0 disables)
+    pub pre_context_lines: usize,
+    /// Only keep pre-error lines seen within this window
+    pub pre_context_window: Option<Duration>,
+    /// Watch target, as reported to notification sinks
+    pub source: String,
+    /// Where explained errors are sent besides the terminal
+    pub sinks: Vec<SinkConfig>,
 }
 
 impl Default for WatchConfig {
@@ -38,14 +55,34 @@ impl Default for WatchConfig {
             debounce_ms: 500,
             dedup: true,
             dedup_ttl: Duration::from_secs(300), // 5 minutes
-            pattern: None,
+            rules: DetectionRules::default(),
             clear: false,
             quiet: false,
             max_aggregation_lines: 50,
+            pre_context_lines: 5,
+            pre_context_window: None,
+            source: String::new(),
+            sinks: Vec::new(),
         }
     }
 }
 
+struct Patterns {
+    timestamp: Regex,
+    line_number: Regex,
+}
+
+fn patterns() -> &'static Patterns {
+    static PATTERNS: OnceLock<Patterns> = OnceLock::new();
+    PATTERNS.get_or_init(|| Patterns {
+        timestamp: Regex::new(
+            r"(?:\d{4}-\d{2}-\d{2}|\d{2}:\d{2}:\d{2}|\[\d{10,}\]|\d{2}/\d{2}/\d{4})",
+        )
+        .unwrap(),
+        line_number: Regex::new(r"(?::\d+:|line \d+|at line \d+)").unwrap(),
+    })
+}
+
 /// Represents a detected error in watch mode
 #[derive(Debug, Clone)]
 pub struct DetectedError {
@@ -55,6 +92,8 @@ pub struct DetectedError {
     pub timestamp: Instant,
     /// Hash of the error content (for deduplication)
     pub content_hash: u64,
+    /// Output lines seen just before the error started
+    pub pre_context: Vec<String>,
 }
 
 impl DetectedError {
@@ -65,9 +104,29 @@ impl DetectedError {
             content,
             timestamp: Instant::now(),
             content_hash,
+            pre_context: Vec::new(),
         }
     }
 
+    /// Attach the lines that preceded the error
+    pub fn with_pre_context(mut self, pre_context: Vec<String>) -> Self {
+        self.pre_context = pre_context;
+        self
+    }
+
+    /// Text sent to the model: the error itself, then the preceding output
+    /// in its own block so it isn't mistaken for part of the error
+    pub fn prompt_input(&self) -> String {
+        if self.pre_context.is_empty() {
+            return self.content.clone();
+        }
+        format!(
+            "{}\n\nOutput just before the error (context only):\n{}",
+            self.content,
+            self.pre_context.join("\n")
+        )
+    }
+
     /// Compute a hash of error content, ignoring timestamps and line numbers
     pub fn compute_hash(content: &str) -> u64 {
         let normalized = Self::normalize_for_hash(content);
@@ -78,13 +137,9 @@ impl DetectedError {
 
     /// Normalize content for hashing by stripping timestamps and line numbers
     pub fn normalize_for_hash(content: &str) -> String {
-        let timestamp_re =
-            Regex::new(r"(?:\d{4}-\d{2}-\d{2}|\d{2}:\d{2}:\d{2}|\[\d{10,}\]|\d{2}/\d{2}/\d{4})")
-                .unwrap();
-        let line_re = Regex::new(r"(?::\d+:|line \d+|at line \d+)").unwrap();
-
-        let mut normalized = timestamp_re.replace_all(content, "").to_string();
-        normalized = line_re.replace_all(&normalized, "").to_string();
+        let p = patterns();
+        let mut normalized = p.timestamp.replace_all(content, "").to_string();
+        normalized = p.line_number.replace_all(&normalized, "").to_string();
         normalized.split_whitespace().collect::<Vec<_>>().join(" ")
     }
 }
@@ -128,8 +183,8 @@ impl ErrorDeduplicator {
 
 /// Error detector for watch mode
 pub struct ErrorDetector {
-    /// Custom pattern (if any)
-    pattern: Option<Regex>,
+    /// Rules deciding which lines start an error
+    rules: DetectionRules,
     /// Lines being aggregated for current error
     aggregation_buffer: Vec<String>,
     /// Maximum lines to aggregate
@@ -138,55 +193,48 @@ pub struct ErrorDetector {
     in_error: bool,
     /// Blank line count (for boundary detection)
     blank_count: usize,
+    /// Recent non-error lines, with when they were seen
+    recent: VecDeque<(Instant, String)>,
+    /// Maximum number of recent lines to keep
+    pre_context_lines: usize,
+    /// Maximum age of recent lines
+    pre_context_window: Option<Duration>,
+    /// Pre-context captured when the current error started
+    pending_pre_context: Vec<String>,
 }
 
 impl ErrorDetector {
     pub fn new(pattern: Option<Regex>, max_lines: usize) -> Self {
         Self {
-            pattern,
+            rules: DetectionRules::from_pattern(pattern),
             aggregation_buffer: Vec::new(),
             max_lines,
             in_error: false,
             blank_count: 0,
+            recent: VecDeque::new(),
+            pre_context_lines: 0,
+            pre_context_window: None,
+            pending_pre_context: Vec::new(),
         }
     }
 
+    /// Use layered detection rules instead of a single pattern
+    pub fn with_rules(mut self, rules: DetectionRules) -> Self {
+        self.rules = rules;
+        self
+    }
+
+    /// Keep up to `lines` lines (seen within `window`, if set) from before
+    /// each error and attach them as its `pre_context`
+    pub fn with_pre_context(mut self, lines: usize, window: Option<Duration>) -> Self {
+        self.pre_context_lines = lines;
+        self.pre_context_window = window;
+        self
+    }
+
     /// Check if a line looks like an error
     pub fn is_error_line(&self, line: &str) -> bool {
-        if let Some(ref pattern) = self.pattern {
-            return pattern.is_match(line);
-        }
-
-        let lower = line.to_lowercase();
-
-        let starts_with_error = lower.starts_with("error")
-            || lower.starts_with("e:")
-            || lower.starts_with("err:")
-            || lower.starts_with("fatal")
-            || lower.starts_with("panic")
-            || lower.starts_with("exception")
-            || lower.starts_with("traceback");
-
-        let contains_error = lower.contains("error:")
-            || lower.contains("error[e")
-            || lower.contains("exception:")
-            || lower.contains("failed:")
-            || lower.contains(": error:")
-            || lower.contains("panic:")
-            || lower.contains("segmentation fault")
-            || lower.contains("sigsegv")
-            || lower.contains("sigabrt")
-            || lower.contains("undefined reference")
-            || lower.contains("cannot find")
-            || lower.contains("not found")
-            || lower.contains("no such file");
-
-        let is_stack_trace = line.trim().starts_with("at ")
-            || line.contains("File \"")
-            || line.contains("at /")
-            || lower.contains("traceback (most recent call last)");
-
-        starts_with_error || contains_error || is_stack_trace
+        self.rules.is_error_line(line)
     }
 
     /// Process a line and return detected errors
@@ -202,6 +250,9 @@ impl ErrorDetector {
         }
 
         if is_error {
+            if !self.in_error {
+                self.pending_pre_context = self.take_recent();
+            }
             self.in_error = true;
             self.aggregation_buffer.push(line.to_string());
             None
@@ -216,10 +267,37 @@ impl ErrorDetector {
             }
             None
         } else {
+            if !is_blank {
+                self.remember(line);
+            }
             None
         }
     }
 
+    /// Add a line to the pre-context ring buffer
+    fn remember(&mut self, line: &str) {
+        if self.pre_context_lines == 0 {
+            return;
+        }
+        if self.recent.len() == self.pre_context_lines {
+            self.recent.pop_front();
+        }
+        self.recent.push_back((Instant::now(), line.to_string()));
+    }
+
+    /// Drain the ring buffer, dropping lines older than the window
+    fn take_recent(&mut self) -> Vec<String> {
+        let window = self.pre_context_window;
+        self.recent
+            .drain(..)
+            .filter(|(seen, _)| match window {
+                Some(window) => seen.elapsed() <= window,
+                None => true,
+            })
+            .map(|(_, line)| line)
+            .collect()
+    }
+
     /// Flush current aggregation buffer and return detected error
     pub fn flush_error(&mut self) -> Option<DetectedError> {
         if self.aggregation_buffer.is_empty() {
@@ -231,6 +309,298 @@ impl ErrorDetector {
         self.in_error = false;
         self.blank_count = 0;
 
-        Some(DetectedError::new(content))
+        let pre_context = std::mem::take(&mut self.pending_pre_context);
+        Some(DetectedError::new(content).with_pre_context(pre_context))
+    }
+}
+
+/// Where watch mode reads from
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum WatchSource {
+    /// A regular file, tailed from its current end
+    File(PathBuf),
+    /// Standard input (`--watch -`)
+    Stdin,
+    /// A named pipe, reopened whenever its writer goes away
+    Fifo(PathBuf),
+    /// A character device such as a serial console, read until EOF
+    Device(PathBuf),
+    /// A command whose stdout and stderr are watched
+    Command(String),
+}
+
+impl WatchSource {
+    /// Classify a `--watch` target
+    pub fn from_target(target: &str) -> Self {
+        if target == "-" {
+            return WatchSource::Stdin;
+        }
+
+        let path = Path::new(target);
+        if let Ok(metadata) = fs::metadata(path) {
+            let file_type = metadata.file_type();
+            if is_fifo(&file_type) {
+                return WatchSource::Fifo(path.to_path_buf());
+            }
+            if is_char_device(&file_type) {
+                return WatchSource::Device(path.to_path_buf());
+            }
+            return WatchSource::File(path.to_path_buf());
+        }
+
+        // A path that doesn't exist yet still reads as a file, so the
+        // watcher can report it missing rather than trying to run it
+        if target.contains('/') || target.contains('\\') {
+            WatchSource::File(path.to_path_buf())
+        } else {
+            WatchSource::Command(target.to_string())
+        }
+    }
+
+    /// Whether the source is consumed as a stream of lines rather than
+    /// tailed or spawned
+    pub fn is_stream(&self) -> bool {
+        matches!(
+            self,
+            WatchSource::Stdin | WatchSource::Fifo(_) | WatchSource::Device(_)
+        )
+    }
+
+    /// Name shown in the watch banner
+    pub fn label(&self) -> String {
+        match self {
+            WatchSource::File(path) => path.display().to_string(),
+            WatchSource::Stdin => "stdin".to_string(),
+            WatchSource::Fifo(path) => format!("{} (named pipe)", path.display()),
+            WatchSource::Device(path) => format!("{} (device)", path.display()),
+            WatchSource::Command(command) => command.clone(),
+        }
+    }
+}
+
+#[cfg(unix)]
+fn is_fifo(file_type: &fs::FileType) -> bool {
+    use std::os::unix::fs::FileTypeExt;
+    file_type.is_fifo()
+}
+
+#[cfg(not(unix))]
+fn is_fifo(_file_type: &fs::FileType) -> bool {
+    false
+}
+
+#[cfg(unix)]
+fn is_char_device(file_type: &fs::FileType) -> bool {
+    use std::os::unix::fs::FileTypeExt;
+    file_type.is_char_device()
+}
+
+#[cfg(not(unix))]
+fn is_char_device(_file_type: &fs::FileType) -> bool {
+    false
+}
+
+// ============================================================================
+// Tee Mode
+// ============================================================================
+
+/// Copy `input` to `output` byte for byte on a separate thread, so the
+/// upstream command is never blocked on a full pipe while the model is busy.
+///
+/// Each line is also sent, without its line ending, to the returned
+/// receiver. The thread reads to EOF even after `output` closes and returns
+/// the number of lines it saw.
+pub fn spawn_passthrough<R, W>(
+    mut input: R,
+    mut output: W,
+) -> (mpsc::Receiver<String>, thread::JoinHandle<usize>)
+where
+    R: BufRead + Send + 'static,
+    W: Write + Send + 'static,
+{
+    let (tx, rx) = mpsc::channel();
+    let handle = thread::spawn(move || {
+        let mut buf = Vec::new();
+        let mut lines = 0;
+        let mut output_open = true;
+        loop {
+            buf.clear();
+            match input.read_until(b'\n', &mut buf) {
+                Ok(0) | Err(_) => break,
+                Ok(_) => {}
+            }
+            lines += 1;
+            if output_open {
+                output_open = output.write_all(&buf).and_then(|_| output.flush()).is_ok();
+            }
+            let line = String::from_utf8_lossy(&buf);
+            let _ = tx.send(line.trim_end_matches(['\n', '\r']).to_string());
+        }
+        lines
+    });
+    (rx, handle)
+}
+
+/// Hand passthrough lines to `step` until the input ends.
+///
+/// `step` gets `Some(line)` for each line and `None` whenever the input has
+/// been quiet for `idle` or has ended, so a block that stops mid-stream is
+/// still completed.
+pub fn drain_lines<F>(lines: &mpsc::Receiver<String>, idle: Duration, mut step: F) -> Result<()>
+where
+    F: FnMut(Option<&str>) -> Result<()>,
+{
+    loop {
+        match lines.recv_timeout(idle) {
+            Ok(line) => step(Some(&line))?,
+            Err(mpsc::RecvTimeoutError::Timeout) => step(None)?,
+            Err(mpsc::RecvTimeoutError::Disconnected) => return step(None),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_detector_attaches_pre_context() {
+        let mut detector = ErrorDetector::new(None, 50).with_pre_context(2, None);
+        let lines = [
+            "Compiling foo v0.1.0",
+            "Compiling bar v0.2.0",
+            "",
+            "Running migration 0042",
+            "Error: relation \"users\" does not exist",
+            "  at migrate (db.js:12)",
+        ];
+        for line in lines {
+            assert!(detector.process_line(line).is_none());
+        }
+        let error = detector.flush_error().unwrap();
+        assert_eq!(
+            error.pre_context,
+            vec!["Compiling bar v0.2.0", "Running migration 0042"]
+        );
+        assert!(error.content.starts_with("Error: relation"));
+        assert!(!error.content.contains("migration 0042"));
+
+        let prompt = error.prompt_input();
+        assert!(prompt.starts_with("Error: relation"));
+        assert!(prompt.contains("(context only):\nCompiling bar v0.2.0\nRunning migration 0042"));
+
+        // The buffer starts over after each error
+        detector.process_line("GET /api/users 500");
+        detector.process_line("panic: nil map");
+        let next = detector.flush_error().unwrap();
+        assert_eq!(next.pre_context, vec!["GET /api/users 500"]);
+    }
+
+    #[test]
+    fn test_detector_pre_context_window_and_default() {
+        let mut detector = ErrorDetector::new(None, 50).with_pre_context(5, Some(Duration::ZERO));
+        detector.process_line("Starting server");
+        std::thread::sleep(Duration::from_millis(5));
+        detector.process_line("Error: address in use");
+        assert!(detector.flush_error().unwrap().pre_context.is_empty());
+
+        // Without with_pre_context nothing is kept, and the prompt is just the error
+        let mut detector = ErrorDetector::new(None, 50);
+        detector.process_line("Starting server");
+        detector.process_line("Error: address in use");
+        let error = detector.flush_error().unwrap();
+        assert!(error.pre_context.is_empty());
+        assert_eq!(error.prompt_input(), "Error: address in use");
+    }
+
+    /// Writer the passthrough thread can own while the test reads it back
+    #[derive(Clone, Default)]
+    struct SharedBuf(std::sync::Arc<std::sync::Mutex<Vec<u8>>>);
+
+    impl Write for SharedBuf {
+        fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+            self.0.lock().unwrap().extend_from_slice(buf);
+            Ok(buf.len())
+        }
+
+        fn flush(&mut self) -> std::io::Result<()> {
+            Ok(())
+        }
+    }
+
+    #[test]
+    fn test_tee_passes_input_through_and_explains_errors() {
+        let input = "Compiling app v0.1.0\r\n\
+                     warning: unused variable `x`\n\
+                     Error: connection refused (os error 111)\n\
+                     \x20   at connect (net.js:12)\n\
+                     \n\
+                     Finished in 2.3s\n\
+                     no trailing newline";
+        let stdout = SharedBuf::default();
+        let (lines, reader) = spawn_passthrough(
+            std::io::Cursor::new(input.as_bytes().to_vec()),
+            stdout.clone(),
+        );
+
+        let mut detector = ErrorDetector::new(None, 50);
+        let mut explained = Vec::new();
+        drain_lines(&lines, Duration::from_millis(50), |line| {
+            let error = match line {
+                Some(line) => detector.process_line(line),
+                None => detector.flush_error(),
+            };
+            explained.extend(error.map(|e| e.content));
+            Ok(())
+        })
+        .unwrap();
+
+        assert_eq!(reader.join().unwrap(), 7);
+        assert_eq!(stdout.0.lock().unwrap().as_slice(), input.as_bytes());
+        assert_eq!(explained.len(), 1);
+        assert!(explained[0].starts_with("Error: connection refused"));
+        assert!(explained[0].contains("at connect (net.js:12)"));
+    }
+
+    #[test]
+    fn test_watch_source_stdin_and_commands() {
+        assert_eq!(WatchSource::from_target("-"), WatchSource::Stdin);
+        assert_eq!(
+            WatchSource::from_target("npm run dev"),
+            WatchSource::Command("npm run dev".to_string())
+        );
+        assert_eq!(
+            WatchSource::from_target("logs/missing.log"),
+            WatchSource::File(PathBuf::from("logs/missing.log"))
+        );
+        assert!(WatchSource::Stdin.is_stream());
+        assert!(!WatchSource::Command("make".to_string()).is_stream());
+    }
+
+    #[test]
+    fn test_watch_source_regular_file() {
+        let path = std::env::temp_dir().join(format!("why-watch-{}.log", std::process::id()));
+        fs::write(&path, "started\n").unwrap();
+        let source = WatchSource::from_target(path.to_str().unwrap());
+        fs::remove_file(&path).ok();
+        assert_eq!(source, WatchSource::File(path));
+        assert!(!source.is_stream());
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_watch_source_special_files() {
+        assert_eq!(
+            WatchSource::from_target("/dev/null"),
+            WatchSource::Device(PathBuf::from("/dev/null"))
+        );
+
+        let path = std::env::temp_dir().join(format!("why-watch-{}.fifo", std::process::id()));
+        let c_path = std::ffi::CString::new(path.to_str().unwrap()).unwrap();
+        assert_eq!(unsafe { libc::mkfifo(c_path.as_ptr(), 0o600) }, 0);
+        let source = WatchSource::from_target(path.to_str().unwrap());
+        fs::remove_file(&path).ok();
+        assert_eq!(source, WatchSource::Fifo(path));
+        assert!(source.is_stream());
     }
 }